@@ -0,0 +1,120 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/server/router"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/engine-api/types"
+
+	vicbackends "github.com/vmware/vic/lib/apiservers/engine/backends"
+	"github.com/vmware/vic/lib/metadata"
+)
+
+// createRouter creates containers in place of the docker container router, as the
+// container config of the docker version we vendor drops the health check given to
+// docker run. It has to be registered before the container router for its route to
+// take precedence.
+type createRouter struct {
+	backend *vicbackends.Container
+	routes  []router.Route
+}
+
+func newCreateRouter(backend *vicbackends.Container) router.Router {
+	r := &createRouter{backend: backend}
+	r.routes = []router.Route{
+		router.NewPostRoute("/containers/create", r.postContainersCreate),
+	}
+	return r
+}
+
+// Routes returns the available routes to the create controller
+func (r *createRouter) Routes() []router.Route {
+	return r.routes
+}
+
+func (r *createRouter) postContainersCreate(ctx context.Context, w http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(req); err != nil {
+		return err
+	}
+	if err := httputils.CheckForJSON(req); err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+
+	config, hostConfig, networkingConfig, err := runconfig.DecodeContainerConfig(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	healthcheck, err := decodeHealthcheck(body)
+	if err != nil {
+		return err
+	}
+
+	version := httputils.VersionFromContext(ctx)
+	adjustCPUShares := version.LessThan("1.19")
+
+	ccr, err := r.backend.ContainerCreateWithHealthcheck(types.ContainerCreateConfig{
+		Name:             req.Form.Get("name"),
+		Config:           config,
+		HostConfig:       hostConfig,
+		NetworkingConfig: networkingConfig,
+		AdjustCPUShares:  adjustCPUShares,
+	}, healthcheck)
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusCreated, ccr)
+}
+
+// decodeHealthcheck returns the health check of a create request, if any
+func decodeHealthcheck(body []byte) (*metadata.HealthConfig, error) {
+	config := struct {
+		Healthcheck *metadata.HealthConfig
+	}{}
+
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, err
+	}
+
+	hc := config.Healthcheck
+	if hc == nil {
+		return nil, nil
+	}
+
+	if hc.Interval < 0 || hc.Timeout < 0 {
+		return nil, fmt.Errorf("Interval and Timeout in Healthcheck cannot be negative")
+	}
+	if hc.Retries < 0 {
+		return nil, fmt.Errorf("Retries in Healthcheck cannot be negative")
+	}
+
+	return hc, nil
+}
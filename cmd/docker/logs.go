@@ -0,0 +1,95 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/server/router"
+	"github.com/docker/docker/api/types/backend"
+	"github.com/docker/engine-api/types"
+
+	vicbackends "github.com/vmware/vic/lib/apiservers/engine/backends"
+)
+
+// logsRouter serves container logs in place of the docker container router, which
+// does not pass the until given to docker logs to the backend. It has to be
+// registered before the container router for its route to take precedence.
+type logsRouter struct {
+	backend *vicbackends.Container
+	routes  []router.Route
+}
+
+func newLogsRouter(backend *vicbackends.Container) router.Router {
+	r := &logsRouter{backend: backend}
+	r.routes = []router.Route{
+		router.NewGetRoute("/containers/{name:.*}/logs", r.getContainersLogs),
+	}
+	return r
+}
+
+// Routes returns the available routes to the logs controller
+func (r *logsRouter) Routes() []router.Route {
+	return r.routes
+}
+
+func (r *logsRouter) getContainersLogs(ctx context.Context, w http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(req); err != nil {
+		return err
+	}
+
+	// Args are validated before the stream starts as once it has, the status code
+	// has been sent and errors can only be reported in the stream itself
+	stdout, stderr := httputils.BoolValue(req, "stdout"), httputils.BoolValue(req, "stderr")
+	if !(stdout || stderr) {
+		return fmt.Errorf("Bad parameters: you must choose at least one stream")
+	}
+
+	var closeNotifier <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closeNotifier = notifier.CloseNotify()
+	}
+
+	logsConfig := &backend.ContainerLogsConfig{
+		ContainerLogsOptions: types.ContainerLogsOptions{
+			Follow:     httputils.BoolValue(req, "follow"),
+			Timestamps: httputils.BoolValue(req, "timestamps"),
+			Since:      req.Form.Get("since"),
+			Tail:       req.Form.Get("tail"),
+			ShowStdout: stdout,
+			ShowStderr: stderr,
+		},
+		OutStream: w,
+		Stop:      closeNotifier,
+	}
+
+	chStarted := make(chan struct{})
+	if err := r.backend.ContainerLogsUntil(vars["name"], logsConfig, req.Form.Get("until"), chStarted); err != nil {
+		select {
+		case <-chStarted:
+			// the client may be expecting multiplexed data, which OutStream will
+			// have been set up to send by now
+			fmt.Fprintf(logsConfig.OutStream, "Error running logs job: %v\n", err)
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
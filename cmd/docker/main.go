@@ -23,6 +23,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	apiserver "github.com/docker/docker/api/server"
+	"github.com/docker/docker/api/server/router/build"
 	"github.com/docker/docker/api/server/router/container"
 	"github.com/docker/docker/api/server/router/image"
 	"github.com/docker/docker/api/server/router/network"
@@ -240,9 +241,14 @@ func setAPIRoutes(api *apiserver.Server) {
 	volumeHandler := &vicbackends.Volume{}
 	networkHandler := &vicbackends.Network{}
 	systemHandler := vicbackends.NewSystemBackend()
+	builderHandler := vicbackends.NewBuilderBackend(containerHandler, imageHandler)
 
 	api.InitRouter(false,
+		newSearchRouter(imageHandler),
 		image.NewRouter(imageHandler),
+		build.NewRouter(builderHandler),
+		newLogsRouter(containerHandler),
+		newCreateRouter(containerHandler),
 		container.NewRouter(containerHandler),
 		volume.NewRouter(volumeHandler),
 		network.NewRouter(networkHandler),
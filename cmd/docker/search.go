@@ -0,0 +1,93 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/server/router"
+	"github.com/docker/engine-api/types"
+
+	vicbackends "github.com/vmware/vic/lib/apiservers/engine/backends"
+	"github.com/vmware/vic/lib/imagec"
+)
+
+// searchRouter serves image searches in place of the docker image router, which
+// does not pass the filters and limit given to docker search to the backend. It
+// has to be registered before the image router for its route to take precedence.
+type searchRouter struct {
+	backend *vicbackends.Image
+	routes  []router.Route
+}
+
+func newSearchRouter(backend *vicbackends.Image) router.Router {
+	r := &searchRouter{backend: backend}
+	r.routes = []router.Route{
+		router.NewGetRoute("/images/search", r.getImagesSearch),
+	}
+	return r
+}
+
+// Routes returns the available routes to the search controller
+func (r *searchRouter) Routes() []router.Route {
+	return r.routes
+}
+
+func (r *searchRouter) getImagesSearch(ctx context.Context, w http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(req); err != nil {
+		return err
+	}
+
+	var (
+		config      *types.AuthConfig
+		authEncoded = req.Header.Get("X-Registry-Auth")
+		headers     = map[string][]string{}
+	)
+
+	if authEncoded != "" {
+		authJSON := base64.NewDecoder(base64.URLEncoding, strings.NewReader(authEncoded))
+		if err := json.NewDecoder(authJSON).Decode(&config); err != nil {
+			// for a search it is not an error if no auth was given
+			config = &types.AuthConfig{}
+		}
+	}
+	for k, v := range req.Header {
+		if strings.HasPrefix(k, "X-Meta-") {
+			headers[k] = v
+		}
+	}
+
+	limit := imagec.DefaultSearchLimit
+	if value := req.Form.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		limit = n
+	}
+
+	query, err := r.backend.SearchImages(ctx, req.Form.Get("filters"), req.Form.Get("term"), limit, config, headers)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, query.Results)
+}
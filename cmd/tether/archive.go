@@ -0,0 +1,119 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/vmware/vic/cmd/tether/msgs"
+	"github.com/vmware/vic/pkg/archive"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// root of the container filesystem as seen by the tether
+const archiveRoot = "/"
+
+// archive services an archive channel. The tar stream is carried on the channel
+// and any failure is reported on the channel's stderr before it is closed.
+func (t *attachServerSSH) archive(newchan ssh.NewChannel) {
+	defer trace.End(trace.Begin("archive request"))
+
+	msg := msgs.ArchiveMsg{}
+	if err := msg.Unmarshal(newchan.ExtraData()); err != nil {
+		detail := fmt.Sprintf("archive channel requires request in ExtraData: %s", err)
+		log.Error(detail)
+		newchan.Reject(ssh.Prohibited, detail)
+		return
+	}
+
+	if msg.Op != msgs.ArchiveExport && msg.Op != msgs.ArchiveImport && msg.Op != msgs.ArchiveExportFilesystem {
+		detail := fmt.Sprintf("unknown archive operation %q", msg.Op)
+		log.Error(detail)
+		newchan.Reject(ssh.Prohibited, detail)
+		return
+	}
+
+	channel, requests, err := newchan.Accept()
+	if err != nil {
+		log.Errorf("could not accept archive channel: %s", err)
+		return
+	}
+	defer channel.Close()
+
+	// we don't support any requests on this channel
+	go ssh.DiscardRequests(requests)
+
+	log.Infof("%s archive of %s", msg.Op, msg.Path)
+
+	switch msg.Op {
+	case msgs.ArchiveExport:
+		var rc io.ReadCloser
+		if rc, err = archive.Export(archiveRoot, msg.Path); err == nil {
+			_, err = io.Copy(channel, rc)
+			rc.Close()
+		}
+	case msgs.ArchiveExportFilesystem:
+		var rc io.ReadCloser
+		if rc, err = archive.ExportFilesystem(archiveRoot); err == nil {
+			_, err = io.Copy(channel, rc)
+			rc.Close()
+		}
+	case msgs.ArchiveImport:
+		if err = archive.Import(archiveRoot, msg.Path, msg.NoOverwriteDirNonDir, channel); err != nil {
+			// drain so the client isn't left blocked on the write
+			io.Copy(ioutil.Discard, channel)
+		}
+	}
+
+	if err != nil {
+		log.Errorf("%s archive of %s failed: %s", msg.Op, msg.Path, err)
+		io.WriteString(channel.Stderr(), err.Error())
+	}
+
+	channel.CloseWrite()
+}
+
+// statPath returns the details of the path in the container filesystem
+func statPath(payload []byte) ([]byte, error) {
+	msg := msgs.StatPathMsg{}
+	if err := msg.Unmarshal(payload); err != nil {
+		return nil, err
+	}
+
+	reply := msgs.PathStatMsg{}
+
+	stat, err := archive.Stat(archiveRoot, msg.Path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		return reply.Marshal(), nil
+	}
+
+	reply.Found = true
+	reply.Name = stat.Name
+	reply.Size = uint64(stat.Size)
+	reply.Mode = uint32(stat.Mode)
+	reply.Mtime = uint64(stat.Mtime.UnixNano())
+	reply.LinkTarget = stat.LinkTarget
+
+	return reply.Marshal(), nil
+}
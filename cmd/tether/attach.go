@@ -45,10 +45,17 @@ var once sync.Once
 type AttachServer interface {
 	tether.Extension
 
+	// Handle registers the handler for global requests of the given type
+	Handle(reqType string, handler GlobalRequestHandler)
+
 	start() error
 	stop() error
 }
 
+// GlobalRequestHandler returns the reply payload for a global request, allowing
+// other extensions to answer requests over the attach connection
+type GlobalRequestHandler func(payload []byte) ([]byte, error)
+
 type attachServerSSH struct {
 	// serializes data access for exported functions
 	m sync.Mutex
@@ -63,6 +70,9 @@ type attachServerSSH struct {
 	config    *tether.ExecutorConfig
 	sshConfig *ssh.ServerConfig
 
+	// handlers for global requests registered by other extensions
+	handlers map[string]GlobalRequestHandler
+
 	enabled int32
 
 	// Cancelable context and its cancel func. Used for resolving the deadlock
@@ -99,6 +109,13 @@ func (t *attachServerSSH) Reload(config *tether.ExecutorConfig) error {
 
 	t.config = config
 
+	// the server only needs to be started once, subsequent reloads (e.g. to launch
+	// an exec session) pick up the updated config
+	if t.IsEnabled() {
+		log.Debugf("attach server is already enabled, updated config")
+		return nil
+	}
+
 	err := server.start()
 	if err != nil {
 		detail := fmt.Sprintf("unable to start attach server: %s", err)
@@ -108,6 +125,25 @@ func (t *attachServerSSH) Reload(config *tether.ExecutorConfig) error {
 	return nil
 }
 
+// Handle registers the handler for global requests of the given type
+func (t *attachServerSSH) Handle(reqType string, handler GlobalRequestHandler) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.handlers == nil {
+		t.handlers = make(map[string]GlobalRequestHandler)
+	}
+	t.handlers[reqType] = handler
+}
+
+// handler returns the registered handler for the request type, if any
+func (t *attachServerSSH) handler(reqType string) GlobalRequestHandler {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.handlers[reqType]
+}
+
 // Enabled sets the enabled to true
 func (t *attachServerSSH) Enabled() {
 	atomic.StoreInt32(&t.enabled, 1)
@@ -350,7 +386,13 @@ func (t *attachServerSSH) run() error {
 		log.Println("ready to service attach requests")
 		// Service the incoming channels
 		for attachchan := range chans {
-			// The only channel type we'll support is attach
+			// archive channels are independent of the sessions
+			if attachchan.ChannelType() == msgs.ArchiveChannelType {
+				go t.archive(attachchan)
+				continue
+			}
+
+			// The only other channel type we'll support is attach
 			if attachchan.ChannelType() != attachChannelType {
 				detail := fmt.Sprintf("unknown channel type %s", attachchan.ChannelType())
 				log.Error(detail)
@@ -373,6 +415,8 @@ func (t *attachServerSSH) run() error {
 			reason := ""
 			if !ok {
 				reason = "is unknown"
+			} else if session.Held() {
+				// the process is waiting for this attach to launch
 			} else if session.Cmd.Process == nil {
 				reason = "process has not been launched"
 			} else if session.Cmd.Process.Signal(syscall.Signal(0)) != nil {
@@ -415,8 +459,8 @@ func (t *attachServerSSH) run() error {
 			}
 
 			// tty's merge stdout and stderr so we don't bind an additional reader in that case
-			// but we need to do so for non-tty
-			if session.Pty == nil {
+			// but we need to do so for non-tty. The pty is not yet allocated if the process is held.
+			if !session.Tty {
 				session.Errwriter.Add(channel.Stderr())
 
 				// no good way to function chain, so reimplement appropriately
@@ -425,7 +469,7 @@ func (t *attachServerSSH) run() error {
 
 					session.Outwriter.Remove(channel)
 					session.Reader.Remove(channel)
-					session.Errwriter.Remove(channel)
+					session.Errwriter.Remove(channel.Stderr())
 
 					channel.Close()
 
@@ -456,17 +500,43 @@ func (t *attachServerSSH) globalMux(reqchan <-chan *ssh.Request) {
 
 		switch req.Type {
 		case msgs.ContainersReq:
-			keys := make([]string, len(t.config.Sessions))
-			i := 0
-			for k := range t.config.Sessions {
-				keys[i] = k
-				i++
+			var keys []string
+			for k, session := range t.config.Sessions {
+				// only report sessions that can be attached to, including those held waiting for an attach
+				if session.Held() {
+					keys = append(keys, k)
+					continue
+				}
+
+				if session.Cmd.Process == nil || session.Cmd.Process.Signal(syscall.Signal(0)) != nil {
+					log.Debugf("not reporting session %s as it is not running", k)
+					continue
+				}
+				keys = append(keys, k)
 			}
 			msg := msgs.ContainersMsg{IDs: keys}
 			payload = msg.Marshal()
+		case msgs.StatPathReq:
+			var err error
+			if payload, err = statPath(req.Payload); err != nil {
+				log.Errorf("stat path request failed: %s", err)
+				ok = false
+				payload = []byte(err.Error())
+			}
 		default:
-			ok = false
-			payload = []byte("unknown global request type: " + req.Type)
+			handler := t.handler(req.Type)
+			if handler == nil {
+				ok = false
+				payload = []byte("unknown global request type: " + req.Type)
+				break
+			}
+
+			var err error
+			if payload, err = handler(req.Payload); err != nil {
+				log.Errorf("%s request failed: %s", req.Type, err)
+				ok = false
+				payload = []byte(err.Error())
+			}
 		}
 
 		log.Debugf("Returning payload: %s", string(payload))
@@ -491,9 +561,6 @@ func (t *attachServerSSH) channelMux(in <-chan *ssh.Request, session *tether.Ses
 	// detach
 	defer detach()
 
-	process := session.Cmd.Process
-	pty := session.Pty
-
 	var err error
 	for req := range in {
 		var pendingFn func()
@@ -502,6 +569,7 @@ func (t *attachServerSSH) channelMux(in <-chan *ssh.Request, session *tether.Ses
 		switch req.Type {
 		case msgs.WindowChangeReq:
 			msg := msgs.WindowChangeMsg{}
+			pty := session.Pty
 			if pty == nil {
 				ok = false
 				log.Errorf("illegal window-change request for non-tty")
@@ -514,9 +582,14 @@ func (t *attachServerSSH) channelMux(in <-chan *ssh.Request, session *tether.Ses
 			}
 		case msgs.SignalReq:
 			msg := msgs.SignalMsg{}
+			// the process is looked up per request as it is not launched while held
+			process := session.Cmd.Process
 			if err = msg.Unmarshal(req.Payload); err != nil {
 				ok = false
 				log.Errorf(err.Error())
+			} else if process == nil {
+				ok = false
+				log.Errorf("illegal signal request for process that has not been launched")
 			} else {
 				log.Infof("Sending signal %s to container process, pid=%d\n", string(msg.Signal), process.Pid)
 				err = signalProcess(process, msg.Signal)
@@ -530,6 +603,9 @@ func (t *attachServerSSH) channelMux(in <-chan *ssh.Request, session *tether.Ses
 				log.Debugf("Closing stdin for %s", session.ID)
 				session.Reader.Close()
 			}
+		case msgs.RunUnblockReq:
+			log.Infof("Releasing process for %s after attach", session.ID)
+			session.Unblock()
 		default:
 			ok = false
 			err = fmt.Errorf("ssh request type %s is not supported", req.Type)
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
 	"sync"
@@ -35,6 +36,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/ssh"
 
+	"github.com/vmware/vic/cmd/tether/msgs"
 	"github.com/vmware/vic/lib/config/executor"
 	"github.com/vmware/vic/lib/portlayer/attach"
 	"github.com/vmware/vic/lib/tether"
@@ -306,6 +308,179 @@ func TestAttach(t *testing.T) {
 	assert.Equal(t, buf.Bytes(), testBytes)
 }
 
+func TestArchive(t *testing.T) {
+	_, mocker := testSetup(t)
+	defer testTeardown(t, mocker)
+
+	testServer, _ := server.(*testAttachServer)
+
+	cfg := executor.ExecutorConfig{
+		Common: executor.Common{
+			ID:   "archive",
+			Name: "tether_test_executor",
+		},
+
+		Sessions: map[string]*executor.SessionConfig{
+			"archive": &executor.SessionConfig{
+				Common: executor.Common{
+					ID:   "archive",
+					Name: "tether_test_session",
+				},
+				Tty:    false,
+				Attach: true,
+				Cmd: executor.Cmd{
+					Path: "/usr/bin/tee",
+					Args: []string{"/usr/bin/tee", pathPrefix + "/tee.out"},
+					Env:  []string{},
+					Dir:  "/",
+				},
+			},
+		},
+		Key: genKey(),
+	}
+
+	src := pathPrefix + "/archive-src"
+	dst := pathPrefix + "/archive-dst"
+	assert.NoError(t, os.MkdirAll(src, 0755))
+	assert.NoError(t, os.MkdirAll(dst, 0755))
+	assert.NoError(t, ioutil.WriteFile(src+"/data", []byte("archive data"), 0644))
+
+	_, _, conn := StartAttachTether(t, &cfg, mocker)
+	defer conn.Close()
+
+	// wait for updates to occur
+	<-testServer.updated
+
+	containerConfig := &ssh.ClientConfig{
+		User: "daemon",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		},
+	}
+
+	// create the SSH client from the mocked connection
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, "notappliable", containerConfig)
+	assert.NoError(t, err)
+	defer sshConn.Close()
+
+	attachClient := ssh.NewClient(sshConn, chans, reqs)
+
+	sshSession, err := attach.SSHAttach(attachClient, cfg.ID)
+	assert.NoError(t, err)
+
+	stat, err := sshSession.StatPath(src + "/data")
+	assert.NoError(t, err)
+	assert.Equal(t, "data", stat.Name)
+	assert.Equal(t, int64(len("archive data")), stat.Size)
+
+	_, err = sshSession.StatPath(src + "/missing")
+	assert.True(t, os.IsNotExist(err), "Expected not exist error, got %s", err)
+
+	// copy the directory out of the container and back in to a new location
+	tar, err := sshSession.ArchiveExport(src)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sshSession.ArchiveImport(dst, false, tar))
+	tar.Close()
+
+	data, err := ioutil.ReadFile(dst + "/archive-src/data")
+	assert.NoError(t, err)
+	assert.Equal(t, "archive data", string(data))
+
+	// errors in the tether are reported to the caller
+	tar, err = sshSession.ArchiveExport(src + "/missing")
+	assert.NoError(t, err)
+	_, err = ioutil.ReadAll(tar)
+	assert.Error(t, err)
+	tar.Close()
+
+	err = sshSession.ArchiveImport(src+"/data", false, bytes.NewReader(nil))
+	assert.Error(t, err)
+
+	sshSession.CloseStdin()
+}
+
+func TestProcessList(t *testing.T) {
+	_, mocker := testSetup(t)
+	defer testTeardown(t, mocker)
+
+	testServer, _ := server.(*testAttachServer)
+	testServer.Handle(msgs.ProcessListReq, tether.NewProcessList().Handle)
+
+	cfg := executor.ExecutorConfig{
+		Common: executor.Common{
+			ID:   "top",
+			Name: "tether_test_executor",
+		},
+
+		Sessions: map[string]*executor.SessionConfig{
+			"top": &executor.SessionConfig{
+				Common: executor.Common{
+					ID:   "top",
+					Name: "tether_test_session",
+				},
+				Tty:    false,
+				Attach: true,
+				Cmd: executor.Cmd{
+					Path: "/usr/bin/tee",
+					Args: []string{"/usr/bin/tee", pathPrefix + "/tee.out"},
+					Env:  []string{},
+					Dir:  "/",
+				},
+			},
+		},
+		Key: genKey(),
+	}
+
+	_, _, conn := StartAttachTether(t, &cfg, mocker)
+	defer conn.Close()
+
+	// wait for updates to occur
+	<-testServer.updated
+
+	containerConfig := &ssh.ClientConfig{
+		User: "daemon",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		},
+	}
+
+	// create the SSH client from the mocked connection
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, "notappliable", containerConfig)
+	assert.NoError(t, err)
+	defer sshConn.Close()
+
+	attachClient := ssh.NewClient(sshConn, chans, reqs)
+
+	sshSession, err := attach.SSHAttach(attachClient, cfg.ID)
+	assert.NoError(t, err)
+
+	// the session process may not have completed exec yet so allow a few attempts
+	found := false
+	for i := 0; i < 10 && !found; i++ {
+		titles, processes, err := sshSession.ProcessList("-o pid,args")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"PID", "COMMAND"}, titles)
+
+		for _, process := range processes {
+			if process[1] == "/usr/bin/tee "+pathPrefix+"/tee.out" {
+				found = true
+			}
+		}
+
+		if !found {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	assert.True(t, found, "Expected to find the session process")
+
+	// errors in the tether are reported to the caller
+	_, _, err = sshSession.ProcessList("-z")
+	assert.Error(t, err)
+
+	sshSession.CloseStdin()
+}
+
 //
 /////////////////////////////////////////////////////////////////////////////////////
 
@@ -794,3 +969,94 @@ func TestReattach(t *testing.T) {
 		assert.Equal(t, buf.Bytes(), testBytes)
 	}
 }
+
+func TestAttachBlock(t *testing.T) {
+	_, mocker := testSetup(t)
+	defer testTeardown(t, mocker)
+
+	testServer, _ := server.(*testAttachServer)
+
+	cfg := executor.ExecutorConfig{
+		Common: executor.Common{
+			ID:   "attach",
+			Name: "tether_test_executor",
+		},
+
+		Sessions: map[string]*executor.SessionConfig{
+			"attach": &executor.SessionConfig{
+				Common: executor.Common{
+					ID:   "attach",
+					Name: "tether_test_session",
+				},
+				Tty:           false,
+				Attach:        true,
+				AttachTimeout: time.Minute,
+				Cmd: executor.Cmd{
+					Path: "/bin/echo",
+					// short lived, so the output is lost unless the launch waits for the attach
+					Args: []string{"/bin/echo", "hello world"},
+					Env:  []string{},
+					Dir:  "/",
+				},
+			},
+		},
+		Key: genKey(),
+	}
+
+	_, src, conn := StartAttachTether(t, &cfg, mocker)
+	defer conn.Close()
+
+	// wait for updates to occur
+	<-testServer.updated
+
+	if !testServer.enabled {
+		t.Errorf("attach server was not enabled")
+	}
+
+	containerConfig := &ssh.ClientConfig{
+		User: "daemon",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		},
+	}
+
+	// create the SSH client from the mocked connection
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, "notappliable", containerConfig)
+	assert.NoError(t, err)
+	defer sshConn.Close()
+
+	attachClient := ssh.NewClient(sshConn, chans, reqs)
+
+	// the held session is listed and can be attached to before the process is launched
+	ids, err := attach.SSHls(attachClient)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{cfg.ID}, ids)
+
+	started, _ := src("guestinfo.vice..sessions|attach.started")
+	assert.Equal(t, "", started, "process was launched before the attach completed")
+
+	sshSession, err := attach.SSHAttach(attachClient, cfg.ID)
+	assert.NoError(t, err)
+
+	testBytes := []byte("hello world\n")
+	buf := &bytes.Buffer{}
+	done := make(chan bool)
+	go func() { io.CopyN(buf, sshSession.Stdout(), int64(len(testBytes))); done <- true }()
+
+	assert.NoError(t, sshSession.Unblock())
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the output of the released process")
+	}
+
+	assert.Equal(t, testBytes, buf.Bytes())
+
+	// the launch is reported once the process is released
+	for i := 0; i < 100 && started == ""; i++ {
+		time.Sleep(100 * time.Millisecond)
+		started, _ = src("guestinfo.vice..sessions|attach.started")
+	}
+	assert.Equal(t, "true", started)
+}
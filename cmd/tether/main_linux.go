@@ -24,6 +24,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 
+	"github.com/vmware/vic/cmd/tether/msgs"
 	"github.com/vmware/vic/lib/tether"
 	"github.com/vmware/vic/pkg/trace"
 	"github.com/vmware/vic/pkg/vsphere/extraconfig"
@@ -95,7 +96,14 @@ func main() {
 	tthr.Register("Attach", sshserver)
 
 	// register the toolbox extension
-	tthr.Register("Toolbox", tether.NewToolbox().InContainer())
+	toolbox := tether.NewToolbox().InContainer()
+	toolbox.ReloadHandler = tthr.Reload
+	tthr.Register("Toolbox", toolbox)
+
+	// register the process listing extension, which answers over the attach connection
+	ps := tether.NewProcessList()
+	sshserver.Handle(msgs.ProcessListReq, ps.Handle)
+	tthr.Register("ProcessList", ps)
 
 	err = tthr.Start()
 	if err != nil {
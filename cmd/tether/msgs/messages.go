@@ -15,6 +15,7 @@
 package msgs
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -124,6 +125,10 @@ func (s *SignalMsg) FromString(name string) error {
 // CloseStdinMsg
 const CloseStdinReq = "close-stdin"
 
+// RunUnblockReq releases the process of the session if its launch is held waiting
+// for the attach to complete
+const RunUnblockReq = "run-unblock"
+
 // ContainersMsg
 const ContainersReq = "container-ids"
 
@@ -142,3 +147,116 @@ func (s *ContainersMsg) Marshal() []byte {
 func (s *ContainersMsg) Unmarshal(payload []byte) error {
 	return ssh.Unmarshal(payload, s)
 }
+
+// ArchiveMsg is sent as the extra data when opening an archive channel
+const ArchiveChannelType = "archive"
+
+const (
+	// ArchiveExport streams a tar of the path from the container
+	ArchiveExport = "export"
+	// ArchiveImport extracts the tar streamed to the container into the path
+	ArchiveImport = "import"
+	// ArchiveExportFilesystem streams a tar of the container filesystem without
+	// the filesystems mounted on it
+	ArchiveExportFilesystem = "export-filesystem"
+)
+
+type ArchiveMsg struct {
+	Op                   string
+	Path                 string
+	NoOverwriteDirNonDir bool
+}
+
+func (s *ArchiveMsg) RequestType() string {
+	return ArchiveChannelType
+}
+
+func (s *ArchiveMsg) Marshal() []byte {
+	return ssh.Marshal(*s)
+}
+
+func (s *ArchiveMsg) Unmarshal(payload []byte) error {
+	return ssh.Unmarshal(payload, s)
+}
+
+// StatPathMsg
+const StatPathReq = "stat-path"
+
+type StatPathMsg struct {
+	Path string
+}
+
+func (s *StatPathMsg) RequestType() string {
+	return StatPathReq
+}
+
+func (s *StatPathMsg) Marshal() []byte {
+	return ssh.Marshal(*s)
+}
+
+func (s *StatPathMsg) Unmarshal(payload []byte) error {
+	return ssh.Unmarshal(payload, s)
+}
+
+// PathStatMsg is the reply to a StatPathReq. Found is false if the path does not exist.
+type PathStatMsg struct {
+	Found bool
+	Name  string
+	Size  uint64
+	Mode  uint32
+	// Mtime is in nanoseconds since the epoch
+	Mtime      uint64
+	LinkTarget string
+}
+
+func (s *PathStatMsg) RequestType() string {
+	return StatPathReq
+}
+
+func (s *PathStatMsg) Marshal() []byte {
+	return ssh.Marshal(*s)
+}
+
+func (s *PathStatMsg) Unmarshal(payload []byte) error {
+	return ssh.Unmarshal(payload, s)
+}
+
+// ProcessListMsg requests the process table of the container formatted as for
+// the given ps arguments
+const ProcessListReq = "process-list"
+
+type ProcessListMsg struct {
+	Args string
+}
+
+func (s *ProcessListMsg) RequestType() string {
+	return ProcessListReq
+}
+
+func (s *ProcessListMsg) Marshal() []byte {
+	return ssh.Marshal(*s)
+}
+
+func (s *ProcessListMsg) Unmarshal(payload []byte) error {
+	return ssh.Unmarshal(payload, s)
+}
+
+// ProcessTableMsg is the reply to a ProcessListReq. It is encoded as JSON as the
+// values may contain commas, which ssh name-lists cannot represent.
+type ProcessTableMsg struct {
+	Titles    []string
+	Processes [][]string
+}
+
+func (s *ProcessTableMsg) RequestType() string {
+	return ProcessListReq
+}
+
+func (s *ProcessTableMsg) Marshal() []byte {
+	payload, _ := json.Marshal(s)
+	return payload
+}
+
+func (s *ProcessTableMsg) Unmarshal(payload []byte) error {
+	return json.Unmarshal(payload, s)
+}
@@ -69,3 +69,42 @@ func TestContainers(t *testing.T) {
 
 	assert.Equal(t, s, out)
 }
+
+func TestArchive(t *testing.T) {
+	s := &ArchiveMsg{Op: ArchiveImport, Path: "/etc", NoOverwriteDirNonDir: true}
+
+	assert.Equal(t, s.RequestType(), ArchiveChannelType)
+
+	tmp := s.Marshal()
+	out := &ArchiveMsg{}
+	out.Unmarshal(tmp)
+
+	assert.Equal(t, s, out)
+}
+
+func TestPathStat(t *testing.T) {
+	s := &PathStatMsg{Found: true, Name: "hosts", Size: 42, Mode: 0644, Mtime: 1473117600, LinkTarget: ""}
+
+	assert.Equal(t, s.RequestType(), StatPathReq)
+
+	tmp := s.Marshal()
+	out := &PathStatMsg{}
+	out.Unmarshal(tmp)
+
+	assert.Equal(t, s, out)
+}
+
+func TestProcessTable(t *testing.T) {
+	s := &ProcessTableMsg{
+		Titles:    []string{"PID", "CMD"},
+		Processes: [][]string{{"1", "/bin/sh"}, {"7", "ps -o pid,args"}},
+	}
+
+	assert.Equal(t, s.RequestType(), ProcessListReq)
+
+	tmp := s.Marshal()
+	out := &ProcessTableMsg{}
+	out.Unmarshal(tmp)
+
+	assert.Equal(t, s, out)
+}
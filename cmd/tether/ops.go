@@ -28,6 +28,10 @@ func (t *operations) Apply(endpoint *tether.NetworkEndpoint) error {
 	return t.BaseOperations.Apply(endpoint)
 }
 
+func (t *operations) Remove(endpoint *tether.NetworkEndpoint) error {
+	return t.BaseOperations.Remove(endpoint)
+}
+
 // HandleSessionExit controls the behaviour on session exit - for the tether if the session exiting
 // is the primary session (i.e. SessionID matches ExecutorID) then we exit everything.
 func (t *operations) HandleSessionExit(config *tether.ExecutorConfig, session *tether.SessionConfig) func() {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/vmware/vic/lib/tether"
 	"github.com/vmware/vic/pkg/dio"
+	"github.com/vmware/vic/pkg/iolog"
 	"github.com/vmware/vic/pkg/trace"
 )
 
@@ -115,7 +116,7 @@ func (t *operations) Log() (io.Writer, error) {
 }
 
 // sessionLogWriter returns a writer that will persist the session output
-func (t *operations) SessionLog(session *tether.SessionConfig) (dio.DynamicMultiWriter, error) {
+func (t *operations) SessionLog(session *tether.SessionConfig) (dio.DynamicMultiWriter, dio.DynamicMultiWriter, error) {
 	com := "COM3"
 
 	defer trace.End(trace.Begin("configure session log writer"))
@@ -123,7 +124,7 @@ func (t *operations) SessionLog(session *tether.SessionConfig) (dio.DynamicMulti
 	if t.logging {
 		detail := "unable to log more than one session concurrently"
 		log.Error(detail)
-		return nil, errors.New(detail)
+		return nil, nil, errors.New(detail)
 	}
 
 	t.logging = true
@@ -134,11 +135,14 @@ func (t *operations) SessionLog(session *tether.SessionConfig) (dio.DynamicMulti
 	if err != nil {
 		detail := fmt.Sprintf("failed to open serial port for session log: %s", err)
 		log.Error(detail)
-		return nil, errors.New(detail)
+		return nil, nil, errors.New(detail)
 	}
 
+	// frame the output so the log records the stream and time of each line
+	stdout, stderr := iolog.NewWriters(f)
+
 	// use multi-writer so it goes to both screen and session log
-	return dio.MultiWriter(f, os.Stdout), nil
+	return dio.MultiWriter(stdout, os.Stdout), dio.MultiWriter(stderr, os.Stdout), nil
 }
 
 func (t *operations) Setup(sink tether.Config) error {
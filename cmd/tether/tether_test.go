@@ -99,8 +99,8 @@ func (t *Mocker) Log() (io.Writer, error) {
 	return &t.LogBuffer, nil
 }
 
-func (t *Mocker) SessionLog(session *tether.SessionConfig) (dio.DynamicMultiWriter, error) {
-	return dio.MultiWriter(&t.SessionLogBuffer), nil
+func (t *Mocker) SessionLog(session *tether.SessionConfig) (dio.DynamicMultiWriter, dio.DynamicMultiWriter, error) {
+	return dio.MultiWriter(&t.SessionLogBuffer), dio.MultiWriter(&t.SessionLogBuffer), nil
 }
 
 func (t *Mocker) HandleSessionExit(config *tether.ExecutorConfig, session *tether.SessionConfig) func() {
@@ -134,6 +134,14 @@ func (t *Mocker) Apply(endpoint *tether.NetworkEndpoint) error {
 	return nil
 }
 
+// Remove tears down the network endpoint configuration
+func (t *Mocker) Remove(endpoint *tether.NetworkEndpoint) error {
+	defer trace.End(trace.Begin("mocking endpoint removal for " + endpoint.Network.Name))
+	delete(t.IPs, endpoint.Network.Name)
+
+	return nil
+}
+
 // MountLabel performs a mount with the source treated as a disk label
 // This assumes that /dev/disk/by-label is being populated, probably by udev
 func (t *Mocker) MountLabel(ctx context.Context, label, target string) error {
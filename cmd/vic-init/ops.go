@@ -83,6 +83,10 @@ func (t *operations) Apply(endpoint *tether.NetworkEndpoint) error {
 	return t.BaseOperations.Apply(endpoint)
 }
 
+func (t *operations) Remove(endpoint *tether.NetworkEndpoint) error {
+	return t.BaseOperations.Remove(endpoint)
+}
+
 func (t *operations) Log() (io.Writer, error) {
 	defer trace.End(trace.Begin("operations.Log"))
 
@@ -104,7 +108,7 @@ func (t *operations) Log() (io.Writer, error) {
 }
 
 // sessionLogWriter returns a writer that will persist the session output
-func (t *operations) SessionLog(session *tether.SessionConfig) (dio.DynamicMultiWriter, error) {
+func (t *operations) SessionLog(session *tether.SessionConfig) (dio.DynamicMultiWriter, dio.DynamicMultiWriter, error) {
 	defer trace.End(trace.Begin("configure session log writer"))
 
 	name := session.ID
@@ -120,9 +124,11 @@ func (t *operations) SessionLog(session *tether.SessionConfig) (dio.DynamicMulti
 	if err != nil {
 		detail := fmt.Sprintf("failed to open file for session log: %s", err)
 		log.Error(detail)
-		return nil, errors.New(detail)
+		return nil, nil, errors.New(detail)
 	}
 
-	// use multi-writer so it goes to both screen and session log
-	return dio.MultiWriter(f, os.Stdout), nil
+	// use multi-writer so it goes to both screen and session log - both streams share the
+	// plain log file as the infrastructure services are not read back via docker logs
+	w := dio.MultiWriter(f, os.Stdout)
+	return w, w, nil
 }
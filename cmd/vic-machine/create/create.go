@@ -32,6 +32,7 @@ import (
 
 	"github.com/urfave/cli"
 
+	"github.com/vmware/vic/lib/config"
 	"github.com/vmware/vic/lib/install/data"
 	"github.com/vmware/vic/lib/install/management"
 	"github.com/vmware/vic/lib/install/validate"
@@ -148,6 +149,13 @@ func (c *Create) Flags() []cli.Flag {
 			Destination: &c.ContainerDatastoreName,
 			Hidden:      true,
 		},
+		cli.StringFlag{
+			Name:        "container-pause-mode",
+			Value:       config.PauseSignal,
+			Usage:       "How paused containers are frozen, either \"signal\" to stop their processes or \"suspend\" to suspend their VMs",
+			Destination: &c.ContainerPauseMode,
+			Hidden:      true,
+		},
 
 		// volume
 		cli.StringSliceFlag{
@@ -497,6 +505,10 @@ func (c *Create) processParams() error {
 		return err
 	}
 
+	if c.ContainerPauseMode != config.PauseSignal && c.ContainerPauseMode != config.PauseSuspend {
+		return cli.NewExitError(fmt.Sprintf("%s is an invalid container pause mode, use %q or %q", c.ContainerPauseMode, config.PauseSignal, config.PauseSuspend), 1)
+	}
+
 	return nil
 }
 
@@ -943,6 +955,7 @@ func (c *Create) Run(cliContext *cli.Context) (err error) {
 	vConfig.BootstrapISO = path.Base(c.BootstrapISO)
 
 	vchConfig.InsecureRegistries = c.Data.InsecureRegistries
+	vchConfig.PauseMode = c.Data.ContainerPauseMode
 
 	if validator.Session.IsVC() { // create certificates for VCH extension
 		var certbuffer, keybuffer bytes.Buffer
@@ -208,6 +208,40 @@ func InsecureRegistries() []string {
 	return registries
 }
 
+// registryHost returns the host of a registry in the VCH config, which may have
+// been given without a scheme
+func registryHost(u url.URL) string {
+	if u.Host != "" {
+		return u.Host
+	}
+	return u.Path
+}
+
+// RegistryAllowed returns whether the registry whitelist and blacklist of the VCH
+// config permit talking to the registry known by any of the given names
+func RegistryAllowed(names ...string) bool {
+	if vchConfig == nil {
+		return true
+	}
+
+	listed := func(registries []url.URL) bool {
+		for _, u := range registries {
+			for _, name := range names {
+				if registryHost(u) == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if listed(vchConfig.RegistryBlacklist) {
+		return false
+	}
+
+	return len(vchConfig.RegistryWhitelist) == 0 || listed(vchConfig.RegistryWhitelist)
+}
+
 // syncContainerCache runs once at startup to populate the container cache
 func syncContainerCache() error {
 	log.Debugf("Sync up container cache from portlyaer")
@@ -242,7 +276,9 @@ func setPortMapping(info *models.ContainerInfo, backend *Container, container *c
 		log.Infof("container state is nil")
 		return nil
 	}
-	if *info.ContainerConfig.State != "Running" || len(container.HostConfig.PortBindings) == 0 {
+	// paused containers keep their ports
+	state := *info.ContainerConfig.State
+	if (state != "Running" && state != "Paused") || len(container.HostConfig.PortBindings) == 0 {
 		log.Infof("Container info state: %s", *info.ContainerConfig.State)
 		log.Infof("container portbinding: %+v", container.HostConfig.PortBindings)
 		return nil
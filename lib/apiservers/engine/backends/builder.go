@@ -0,0 +1,1347 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backends
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/api/types/backend"
+	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/dockerfile"
+	"github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+	docker "github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/signal"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/pkg/urlutil"
+	"github.com/docker/docker/reference"
+	dockerRegistry "github.com/docker/docker/registry"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/strslice"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
+	"github.com/vmware/vic/lib/imagec"
+	"github.com/vmware/vic/lib/metadata"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/vsphere/sys"
+)
+
+// envInstructions are the instructions whose arguments have the environment of the
+// image substituted into them, as docker does
+var envInstructions = map[string]bool{
+	command.Env:        true,
+	command.Label:      true,
+	command.Add:        true,
+	command.Copy:       true,
+	command.Workdir:    true,
+	command.Expose:     true,
+	command.Volume:     true,
+	command.User:       true,
+	command.StopSignal: true,
+	command.Arg:        true,
+}
+
+// Builder is the backend of docker build. Each RUN instruction of the Dockerfile is run
+// in a container VM whose changes are committed as a new image layer, and the files of
+// ADD and COPY are extracted into the disk of a container VM that is committed without
+// being started. Instructions that only change the image config are written as empty
+// layers without a container VM.
+type Builder struct {
+	containers *Container
+	images     *Image
+}
+
+func NewBuilderBackend(containers *Container, images *Image) *Builder {
+	return &Builder{
+		containers: containers,
+		images:     images,
+	}
+}
+
+// imageBuild is the state of a single build
+type imageBuild struct {
+	*Builder
+
+	ctx     context.Context
+	options *types.ImageBuildOptions
+	context builder.Context
+	stdout  io.Writer
+	stderr  io.Writer
+	out     io.Writer
+
+	// cancelled is closed when the client goes away
+	cancelled chan struct{}
+
+	// image is the image the next step builds on, nil until FROM
+	image      *metadata.ImageConfig
+	maintainer string
+	// cmdSet is whether the Dockerfile has set CMD, which ENTRYPOINT otherwise resets
+	cmdSet bool
+	// cacheBusted is set once a step missed the cache, as no later step can then hit it
+	cacheBusted bool
+
+	// buildArgs are the values of the build arguments, given by the options or by the
+	// defaults of ARG, which only apply once ARG has declared them
+	buildArgs    map[string]string
+	declaredArgs map[string]bool
+}
+
+// Build builds the image described by the Dockerfile in the build context, tags it with
+// the tags of the options and returns its ID.
+func (b *Builder) Build(clientCtx context.Context, options *types.ImageBuildOptions, buildContext builder.Context, stdout, stderr, out io.Writer, clientGone <-chan bool) (string, error) {
+	defer trace.End(trace.Begin(""))
+
+	tags, err := buildTags(options.Tags)
+	if err != nil {
+		return "", BadRequestError(err.Error())
+	}
+
+	steps, err := readDockerfile(buildContext, options.Dockerfile)
+	if err != nil {
+		return "", err
+	}
+
+	// the labels of the options are applied by a last step, so that the cache tells
+	// the image with them from the image without
+	if len(options.Labels) > 0 {
+		label, err := labelStep(options.Labels)
+		if err != nil {
+			return "", err
+		}
+		steps = append(steps, label)
+	}
+
+	ib := &imageBuild{
+		Builder:      b,
+		ctx:          clientCtx,
+		options:      options,
+		context:      buildContext,
+		stdout:       stdout,
+		stderr:       stderr,
+		out:          out,
+		cancelled:    make(chan struct{}),
+		buildArgs:    make(map[string]string),
+		declaredArgs: make(map[string]bool),
+	}
+	for k, v := range options.BuildArgs {
+		ib.buildArgs[k] = v
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-finished:
+		case <-clientGone:
+			close(ib.cancelled)
+		}
+	}()
+
+	for i, step := range steps {
+		select {
+		case <-ib.cancelled:
+			log.Debugf("Build cancelled")
+			fmt.Fprintf(stdout, "Build cancelled\n")
+			return "", fmt.Errorf("Build cancelled")
+		default:
+		}
+
+		fmt.Fprintf(stdout, "Step %d : %s\n", i+1, stepMessage(step))
+
+		if err := ib.dispatch(step); err != nil {
+			return "", err
+		}
+
+		if ib.image.ImageID != "" {
+			fmt.Fprintf(stdout, " ---> %s\n", stringid.TruncateID(ib.image.ImageID))
+		}
+	}
+
+	if ib.image == nil || ib.image.ImageID == "" {
+		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?")
+	}
+
+	// a build argument that no ARG declared is most likely a mistake
+	var unused []string
+	for k := range options.BuildArgs {
+		if !ib.declaredArgs[k] {
+			unused = append(unused, k)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("One or more build-args %v were not consumed, failing build.", unused)
+	}
+
+	for _, tag := range tags {
+		if err := b.images.TagImage(tag, ib.image.ImageID); err != nil {
+			return "", err
+		}
+	}
+
+	fmt.Fprintf(stdout, "Successfully built %s\n", stringid.TruncateID(ib.image.ImageID))
+
+	return "sha256:" + ib.image.ImageID, nil
+}
+
+// dispatch runs a step of the Dockerfile
+func (ib *imageBuild) dispatch(step *parser.Node) error {
+	cmd := step.Value
+
+	if len(step.Flags) > 0 {
+		return fmt.Errorf("Flags are not supported: %s", strings.Join(step.Flags, " "))
+	}
+
+	args, err := stepArgs(step, ib.stepEnv())
+	if err != nil {
+		return err
+	}
+
+	if cmd == command.From {
+		return ib.from(args)
+	}
+
+	if ib.image == nil {
+		return fmt.Errorf("Please provide a source image with `from` prior to %s", cmd)
+	}
+
+	switch cmd {
+	case command.Run:
+		return ib.run(args, step.Attributes)
+	case command.Maintainer:
+		if len(args) != 1 {
+			return fmt.Errorf("MAINTAINER requires exactly one argument")
+		}
+		ib.maintainer = args[0]
+		return ib.commitConfig(ib.image.Config, "MAINTAINER "+ib.maintainer)
+	case command.Env, command.Label, command.Workdir, command.Cmd, command.Entrypoint,
+		command.Expose, command.Volume, command.User, command.StopSignal:
+		config, comment, err := ib.applyConfig(ib.image.Config, cmd, args, step.Attributes)
+		if err != nil {
+			return err
+		}
+		return ib.commitConfig(config, comment)
+	case command.Add, command.Copy:
+		return ib.copyFiles(cmd, args)
+	case command.Arg:
+		return ib.arg(args)
+	case command.Onbuild:
+		return fmt.Errorf("%s is not supported", strings.ToUpper(cmd))
+	default:
+		return fmt.Errorf("Unknown instruction: %s", strings.ToUpper(cmd))
+	}
+}
+
+// from starts the build from the named image, pulling it if it is not in the cache or the
+// options ask for the latest version of it
+func (ib *imageBuild) from(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("FROM requires exactly one argument")
+	}
+	name := args[0]
+
+	// scratch has no filesystem so it does not have a layer in the image store
+	if name == api.NoBaseImageSpecifier {
+		ib.image = &metadata.ImageConfig{
+			V1Image: docker.V1Image{
+				Architecture: runtime.GOARCH,
+				OS:           "linux",
+				Config:       &container.Config{},
+			},
+		}
+		return nil
+	}
+
+	image, err := cache.ImageCache().GetImage(name)
+	if err != nil || ib.options.PullParent {
+		ref, err := reference.ParseNamed(name)
+		if err != nil {
+			return err
+		}
+		ref = reference.WithDefaultTag(ref)
+
+		repoInfo, err := dockerRegistry.ParseRepositoryInfo(ref)
+		if err != nil {
+			return err
+		}
+		authConfig := dockerRegistry.ResolveAuthConfig(ib.options.AuthConfigs, repoInfo.Index)
+
+		if err = ib.images.PullImage(ib.ctx, ref, nil, &authConfig, ib.out); err != nil {
+			return err
+		}
+
+		if image, err = cache.ImageCache().GetImage(ref.String()); err != nil {
+			return err
+		}
+	}
+
+	if len(image.Config.OnBuild) > 0 {
+		return fmt.Errorf("The ONBUILD triggers of %s are not supported", name)
+	}
+
+	ib.image = image
+	return nil
+}
+
+// arg declares a build argument, which takes its default value unless the options set it
+func (ib *imageBuild) arg(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("ARG requires exactly one argument definition")
+	}
+
+	name, value, hasDefault := args[0], "", false
+	if i := strings.Index(args[0], "="); i >= 0 {
+		name, value, hasDefault = args[0][:i], args[0][i+1:], true
+	}
+	if name == "" {
+		return fmt.Errorf("ARG names can not be blank")
+	}
+
+	if _, ok := ib.buildArgs[name]; !ok && hasDefault {
+		ib.buildArgs[name] = value
+	}
+	ib.declaredArgs[name] = true
+
+	return ib.commitConfig(ib.image.Config, "ARG "+args[0])
+}
+
+// runEnv returns the declared build arguments that have a value and are not set by the
+// environment of the image, sorted by name
+func (ib *imageBuild) runEnv() []string {
+	set := make(map[string]bool)
+	if ib.image != nil {
+		for _, e := range ib.image.Config.Env {
+			set[strings.SplitN(e, "=", 2)[0]] = true
+		}
+	}
+
+	var env []string
+	for k, v := range ib.buildArgs {
+		if ib.declaredArgs[k] && !set[k] {
+			env = append(env, k+"="+v)
+		}
+	}
+	sort.Strings(env)
+
+	return env
+}
+
+// stepEnv returns the environment substituted into the arguments of a step, in which the
+// environment of the image takes precedence over the build arguments
+func (ib *imageBuild) stepEnv() []string {
+	var env []string
+	if ib.image != nil {
+		env = append(env, ib.image.Config.Env...)
+	}
+	return append(env, ib.runEnv()...)
+}
+
+// run runs the command of a RUN step in a container VM created from the image and commits
+// the changes the command made to its filesystem as a new layer
+func (ib *imageBuild) run(args []string, attributes map[string]bool) error {
+	// scratch has no filesystem, so nothing to run the command from
+	if ib.image.ID == "" {
+		return fmt.Errorf("RUN requires a base image with a filesystem, not %s", api.NoBaseImageSpecifier)
+	}
+
+	args = jsonArgs(args, attributes)
+	if !attributes["json"] {
+		args = append([]string{"/bin/sh", "-c"}, args...)
+	}
+
+	// the build arguments are in the environment of the command but not in the config of
+	// the image, so the history records them to tell the commands run with them apart
+	env := ib.runEnv()
+
+	runConfig := copyBuildConfig(ib.image.Config)
+	runConfig.Cmd = strslice.StrSlice(args)
+	if len(env) > 0 {
+		runConfig.Cmd = append(strslice.StrSlice{fmt.Sprintf("|%d", len(env))}, env...)
+		runConfig.Cmd = append(runConfig.Cmd, args...)
+	}
+
+	createdBy := strings.Join(runConfig.Cmd, " ")
+	if ib.probeCache(createdBy) {
+		return nil
+	}
+
+	id, err := ib.create(args, env)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ib.stdout, " ---> Running in %s\n", stringid.TruncateID(id))
+
+	if err = ib.runContainer(id, createdBy); err != nil {
+		if ib.options.ForceRemove {
+			ib.removeContainer(id)
+		}
+		return err
+	}
+
+	// the command leaves the config of the image as it was
+	config := &types.ContainerCommitConfig{
+		Author: ib.maintainer,
+		Config: copyBuildConfig(ib.image.Config),
+	}
+
+	image, err := commitContainer(id, id, ib.image, runConfig, config, nil)
+	if err != nil {
+		return err
+	}
+	ib.image = image
+
+	if ib.options.Remove || ib.options.ForceRemove {
+		ib.removeContainer(id)
+	}
+
+	return nil
+}
+
+// create creates the container VM of a step, which runs the command with the environment
+// of the image and env
+func (ib *imageBuild) create(args, env []string) (string, error) {
+	config := copyBuildConfig(ib.image.Config)
+	config.Image = ib.image.ImageID
+	config.Env = append(config.Env, env...)
+
+	// the command is run as the entrypoint as the entrypoint of the image would otherwise
+	// be run in its place; the command of the image is still appended when the command
+	// has no arguments
+	config.Entrypoint = strslice.StrSlice(args[:1])
+	config.Cmd = strslice.StrSlice(args[1:])
+
+	config.AttachStdin = false
+	config.AttachStdout = true
+	config.AttachStderr = true
+	config.OpenStdin = false
+	config.StdinOnce = false
+	config.Tty = false
+
+	hostConfig := &container.HostConfig{
+		Isolation: ib.options.Isolation,
+		ShmSize:   ib.options.ShmSize,
+		Resources: container.Resources{
+			CgroupParent: ib.options.CgroupParent,
+			CPUShares:    ib.options.CPUShares,
+			CPUPeriod:    ib.options.CPUPeriod,
+			CPUQuota:     ib.options.CPUQuota,
+			CpusetCpus:   ib.options.CPUSetCPUs,
+			CpusetMems:   ib.options.CPUSetMems,
+			Memory:       ib.options.Memory,
+			MemorySwap:   ib.options.MemorySwap,
+			Ulimits:      ib.options.Ulimits,
+		},
+	}
+
+	c, err := ib.containers.ContainerCreate(types.ContainerCreateConfig{
+		Config:     config,
+		HostConfig: hostConfig,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, warning := range c.Warnings {
+		fmt.Fprintf(ib.stdout, " ---> [Warning] %s\n", warning)
+	}
+
+	return c.ID, nil
+}
+
+// runContainer runs the container to completion, streaming its output to the client. The
+// tether holds the process until the attach is in place, so none of the output is lost.
+func (ib *imageBuild) runContainer(id, createdBy string) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ib.containers.ContainerAttach(id, &backend.ContainerAttachConfig{
+			GetStreams: func() (io.ReadCloser, io.Writer, io.Writer, error) {
+				return nil, ib.stdout, ib.stderr, nil
+			},
+			UseStdout: true,
+			UseStderr: true,
+			Stream:    true,
+		})
+	}()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ib.cancelled:
+			log.Debugf("Build cancelled, killing container %s", id)
+			if err := ib.containers.ContainerKill(id, uint64(syscall.SIGKILL)); err != nil {
+				log.Errorf("Failed to kill container %s: %s", id, err)
+			}
+		case <-finished:
+		}
+	}()
+
+	if err := ib.containers.ContainerStart(id, nil); err != nil {
+		return err
+	}
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+
+	ret, err := ib.containers.ContainerWait(id, -1*time.Second)
+	if err != nil {
+		return err
+	}
+
+	if ret != 0 {
+		return &jsonmessage.JSONError{
+			Message: fmt.Sprintf("The command '%s' returned a non-zero code: %d", createdBy, ret),
+			Code:    ret,
+		}
+	}
+
+	return nil
+}
+
+func (ib *imageBuild) removeContainer(id string) {
+	config := &types.ContainerRmConfig{
+		ForceRemove:  true,
+		RemoveVolume: true,
+	}
+
+	if err := ib.containers.ContainerRm(id, config); err != nil {
+		fmt.Fprintf(ib.stdout, "Error removing intermediate container %s: %s\n", stringid.TruncateID(id), err)
+		return
+	}
+	fmt.Fprintf(ib.stdout, "Removing intermediate container %s\n", stringid.TruncateID(id))
+}
+
+// contextSource is a file or directory of the build context that ADD or COPY copies
+type contextSource struct {
+	path string
+	info builder.FileInfo
+	// archive is set for a tar archive that ADD extracts rather than copies
+	archive bool
+}
+
+// copyFiles copies files and directories of the build context into the image by extracting
+// them into the disk of a container VM, which is committed as a new layer without being
+// started
+func (ib *imageBuild) copyFiles(cmd string, args []string) error {
+	name := strings.ToUpper(cmd)
+
+	if len(args) < 2 {
+		return fmt.Errorf("Invalid %s format - at least two arguments required", name)
+	}
+
+	// scratch has no filesystem, so no container VM to copy the files into
+	if ib.image.ID == "" {
+		return fmt.Errorf("%s requires a base image with a filesystem, not %s", name, api.NoBaseImageSpecifier)
+	}
+
+	dest := args[len(args)-1]
+
+	var sources []*contextSource
+	for _, src := range args[:len(args)-1] {
+		if urlutil.IsURL(src) {
+			return fmt.Errorf("%s from a URL is not supported", name)
+		}
+
+		found, err := ib.contextSources(cmd == command.Add, src)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, found...)
+	}
+
+	if len(sources) > 1 && !strings.HasSuffix(dest, "/") {
+		return fmt.Errorf("When using %s with more than one source file, the destination must be a directory and end with a /", name)
+	}
+
+	// a relative destination is in the working directory
+	if !path.IsAbs(dest) {
+		dir := strings.HasSuffix(dest, "/")
+		dest = path.Join("/", ib.image.Config.WorkingDir, dest)
+		if dir && dest != "/" {
+			dest += "/"
+		}
+	}
+
+	hash, err := sourcesHash(ib.context, sources)
+	if err != nil {
+		return err
+	}
+
+	// without the hashes of the files the cache cannot tell whether they changed
+	if hash == "" {
+		for _, src := range sources {
+			hash += " " + src.path
+		}
+		hash = strings.TrimSpace(hash)
+		ib.cacheBusted = true
+	}
+
+	runConfig := copyBuildConfig(ib.image.Config)
+	runConfig.Cmd = strslice.StrSlice{"/bin/sh", "-c", fmt.Sprintf("#(nop) %s %s in %s", name, hash, dest)}
+
+	if ib.probeCache(strings.Join(runConfig.Cmd, " ")) {
+		return nil
+	}
+
+	id, err := ib.create(runConfig.Cmd, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = ib.extract(id, sources, dest); err != nil {
+		if ib.options.ForceRemove {
+			ib.removeContainer(id)
+		}
+		return err
+	}
+
+	config := &types.ContainerCommitConfig{
+		Author: ib.maintainer,
+		Config: copyBuildConfig(ib.image.Config),
+	}
+
+	image, err := commitContainer(id, id, ib.image, runConfig, config, nil)
+	if err != nil {
+		return err
+	}
+	ib.image = image
+
+	if ib.options.Remove || ib.options.ForceRemove {
+		ib.removeContainer(id)
+	}
+
+	return nil
+}
+
+// contextSources returns the files and directories of the build context that src names,
+// which may be a pattern
+func (ib *imageBuild) contextSources(add bool, src string) ([]*contextSource, error) {
+	var sources []*contextSource
+
+	if strings.ContainsAny(src, "*?[") {
+		pattern := path.Clean("/" + src)[1:]
+
+		err := ib.context.Walk("", func(rel string, fi builder.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if match, _ := filepath.Match(pattern, rel); match {
+				sources = append(sources, &contextSource{path: rel, info: fi})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(sources) == 0 {
+			return nil, fmt.Errorf("No source files were specified")
+		}
+	} else {
+		rel, fi, err := ib.context.Stat(src)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, &contextSource{path: rel, info: fi})
+	}
+
+	if add {
+		for _, source := range sources {
+			if !source.info.Mode().IsRegular() {
+				continue
+			}
+
+			var err error
+			if source.archive, err = isArchive(ib.context, source.path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// extract extracts the sources into the filesystem of the container at dest. The
+// destination is a directory if it ends with a slash, already is one, or a directory or
+// archive is copied into it, and it is created if it does not exist.
+func (ib *imageBuild) extract(id string, sources []*contextSource, dest string) error {
+	stat, err := ib.containers.ContainerStatPath(id, dest)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	dir := strings.HasSuffix(dest, "/") || (exists && stat.Mode.IsDir())
+	for _, src := range sources {
+		dir = dir || src.info.IsDir() || src.archive
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	go func() {
+		tw := tar.NewWriter(pw)
+
+		err := writeSources(tw, ib.context, sources, dest, dir, dir && !exists)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return ib.containers.ContainerExtractToDir(id, "/", false, pr)
+}
+
+// commitConfig writes an empty layer on top of the image that records the config of a step
+// that does not change the filesystem, so no container VM is needed for it
+func (ib *imageBuild) commitConfig(config *container.Config, comment string) error {
+	runConfig := copyBuildConfig(config)
+	runConfig.Cmd = strslice.StrSlice{"/bin/sh", "-c", "#(nop) " + comment}
+
+	if ib.probeCache(strings.Join(runConfig.Cmd, " ")) {
+		return nil
+	}
+
+	commitConfig := &types.ContainerCommitConfig{
+		Author: ib.maintainer,
+		Config: config,
+	}
+
+	layerID := stringid.GenerateRandomID()
+	image, err := commitImageConfig(ib.image, "", runConfig, commitConfig, layerID)
+	if err != nil {
+		return err
+	}
+	image.DiffIDs[layerID] = imagec.DigestSHA256EmptyTar
+	image.LayerSizes[layerID] = 0
+
+	blob, err := json.Marshal(image)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal image metadata: %s", err)
+	}
+
+	// needed for image store
+	host, err := sys.UUID()
+	if err != nil {
+		return err
+	}
+
+	if err = imagec.WriteEmptyLayer(PortLayerServer(), host, ib.layer(), layerID, string(blob)); err != nil {
+		return fmt.Errorf("Failed to write image layer: %s", err)
+	}
+
+	cache.ImageCache().AddImage(image)
+	cache.LayerCache().AddExisting(layerID)
+
+	ib.image = image
+	return nil
+}
+
+// layer returns the ID of the image store layer the next step builds on
+func (ib *imageBuild) layer() string {
+	if ib.image.ID == "" {
+		return "scratch"
+	}
+	return ib.image.ID
+}
+
+// probeCache moves the build on to the cached image of the step, if there is one
+func (ib *imageBuild) probeCache(createdBy string) bool {
+	if ib.options.NoCache || ib.cacheBusted {
+		return false
+	}
+
+	image := cachedImage(cache.ImageCache().GetImages(), ib.image, createdBy)
+	if image == nil {
+		log.Debugf("Build cache miss: %s", createdBy)
+		ib.cacheBusted = true
+		return false
+	}
+
+	fmt.Fprintf(ib.stdout, " ---> Using cache\n")
+	log.Debugf("Build cache hit: %s", createdBy)
+
+	ib.image = image
+	return true
+}
+
+// applyConfig applies an instruction that only changes the image config to a copy of the
+// config and returns the copy, along with the comment the image history records for it
+func (ib *imageBuild) applyConfig(config *container.Config, cmd string, args []string, attributes map[string]bool) (*container.Config, string, error) {
+	c := copyBuildConfig(config)
+
+	switch cmd {
+	case command.Env, command.Label:
+		name := strings.ToUpper(cmd)
+		if len(args) == 0 {
+			return nil, "", fmt.Errorf("%s requires at least one argument", name)
+		}
+		if len(args)%2 != 0 {
+			return nil, "", fmt.Errorf("Bad input to %s, too many arguments", name)
+		}
+
+		comment := name
+		for i := 0; i < len(args); i += 2 {
+			key, value := args[i], args[i+1]
+			comment += " " + key + "=" + value
+
+			if cmd == command.Label {
+				if c.Labels == nil {
+					c.Labels = make(map[string]string)
+				}
+				c.Labels[key] = value
+				continue
+			}
+
+			set := false
+			for j, env := range c.Env {
+				if strings.SplitN(env, "=", 2)[0] == key {
+					c.Env[j] = key + "=" + value
+					set = true
+					break
+				}
+			}
+			if !set {
+				c.Env = append(c.Env, key+"="+value)
+			}
+		}
+		return c, comment, nil
+
+	case command.Workdir:
+		if len(args) != 1 {
+			return nil, "", fmt.Errorf("WORKDIR requires exactly one argument")
+		}
+
+		workdir := args[0]
+		if !path.IsAbs(workdir) {
+			workdir = path.Join("/", c.WorkingDir, workdir)
+		}
+		c.WorkingDir = workdir
+		return c, "WORKDIR " + workdir, nil
+
+	case command.Cmd:
+		cmdSlice := jsonArgs(args, attributes)
+		if !attributes["json"] {
+			cmdSlice = append([]string{"/bin/sh", "-c"}, cmdSlice...)
+		}
+
+		c.Cmd = strslice.StrSlice(cmdSlice)
+		if len(args) != 0 {
+			ib.cmdSet = true
+		}
+		return c, fmt.Sprintf("CMD %q", cmdSlice), nil
+
+	case command.Entrypoint:
+		parsed := jsonArgs(args, attributes)
+
+		switch {
+		case attributes["json"]:
+			c.Entrypoint = strslice.StrSlice(parsed)
+		case len(parsed) == 0:
+			c.Entrypoint = nil
+		default:
+			c.Entrypoint = strslice.StrSlice{"/bin/sh", "-c", parsed[0]}
+		}
+
+		// the command of the base image does not apply to a new entrypoint
+		if !ib.cmdSet {
+			c.Cmd = nil
+		}
+		return c, fmt.Sprintf("ENTRYPOINT %q", c.Entrypoint), nil
+
+	case command.Expose:
+		if len(args) == 0 {
+			return nil, "", fmt.Errorf("EXPOSE requires at least one argument")
+		}
+
+		ports, _, err := nat.ParsePortSpecs(args)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if c.ExposedPorts == nil {
+			c.ExposedPorts = make(nat.PortSet)
+		}
+		portList := make([]string, 0, len(ports))
+		for port := range ports {
+			c.ExposedPorts[port] = struct{}{}
+			portList = append(portList, string(port))
+		}
+		sort.Strings(portList)
+		return c, "EXPOSE " + strings.Join(portList, " "), nil
+
+	case command.Volume:
+		if len(args) == 0 {
+			return nil, "", fmt.Errorf("VOLUME requires at least one argument")
+		}
+
+		if c.Volumes == nil {
+			c.Volumes = make(map[string]struct{})
+		}
+		for _, v := range args {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				return nil, "", fmt.Errorf("Volume specified can not be an empty string")
+			}
+			c.Volumes[v] = struct{}{}
+		}
+		return c, fmt.Sprintf("VOLUME %v", args), nil
+
+	case command.User:
+		if len(args) != 1 {
+			return nil, "", fmt.Errorf("USER requires exactly one argument")
+		}
+
+		c.User = args[0]
+		return c, fmt.Sprintf("USER %v", args), nil
+
+	case command.StopSignal:
+		if len(args) != 1 {
+			return nil, "", fmt.Errorf("STOPSIGNAL requires exactly one argument")
+		}
+
+		if _, err := signal.ParseSignal(args[0]); err != nil {
+			return nil, "", err
+		}
+		c.StopSignal = args[0]
+		return c, fmt.Sprintf("STOPSIGNAL %v", args), nil
+	}
+
+	return nil, "", fmt.Errorf("%s does not apply to the image config", strings.ToUpper(cmd))
+}
+
+// writeSources writes the sources to the tar archive as they are laid out in the image,
+// creating the destination directory first if mkdir is set
+func writeSources(tw *tar.Writer, ctx builder.Context, sources []*contextSource, dest string, dir, mkdir bool) error {
+	destName := strings.TrimPrefix(path.Clean(dest), "/")
+
+	if mkdir {
+		hdr := &tar.Header{
+			Name:     destName + "/",
+			Mode:     0755,
+			Typeflag: tar.TypeDir,
+			ModTime:  time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+	}
+
+	for _, src := range sources {
+		var err error
+
+		switch {
+		case src.archive:
+			err = writeArchive(tw, ctx, src.path, destName)
+
+		case src.info.IsDir():
+			// the contents of a directory are copied, not the directory itself
+			err = ctx.Walk(src.path, func(rel string, fi builder.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				sub, err := filepath.Rel(src.path, rel)
+				if err != nil || sub == "." {
+					return err
+				}
+				return writeEntry(tw, ctx, rel, fi, path.Join(destName, filepath.ToSlash(sub)))
+			})
+
+		default:
+			name := destName
+			if dir {
+				name = path.Join(destName, src.info.Name())
+			}
+			err = writeEntry(tw, ctx, src.path, src.info, name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeEntry writes the file of the build context to the tar archive under name, owned by
+// root as docker has the files it copies into an image
+func writeEntry(tw *tar.Writer, ctx builder.Context, rel string, fi builder.FileInfo, name string) error {
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(fi.Path()); err != nil {
+			return err
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	hdr.Uid, hdr.Gid = 0, 0
+	hdr.Uname, hdr.Gname = "", ""
+
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := ctx.Open(rel)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// writeArchive writes the entries of the tar archive of the build context, which may be
+// compressed, to the tar archive under the directory dir
+func writeArchive(tw *tar.Writer, ctx builder.Context, rel string, dir string) error {
+	f, err := ctx.Open(rel)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := archive.DecompressStream(f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = path.Join(dir, hdr.Name)
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = path.Join(dir, hdr.Linkname)
+		}
+
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// isArchive returns whether the file of the build context is a tar archive, which may be
+// compressed
+func isArchive(ctx builder.Context, rel string) (bool, error) {
+	f, err := ctx.Open(rel)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r, err := archive.DecompressStream(f)
+	if err != nil {
+		return false, nil
+	}
+	defer r.Close()
+
+	_, err = tar.NewReader(r).Next()
+	return err == nil, nil
+}
+
+// sourcesHash returns the hash of the sources that the cache tells them apart by, or ""
+// if the build context does not have the hashes of its files
+func sourcesHash(ctx builder.Context, sources []*contextSource) (string, error) {
+	sum := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+
+	var hashes []string
+	for _, src := range sources {
+		h, ok := src.info.(builder.Hashed)
+		if !ok {
+			return "", nil
+		}
+
+		if !src.info.IsDir() {
+			hashes = append(hashes, "file:"+h.Hash())
+			continue
+		}
+
+		var sums []string
+		err := ctx.Walk(src.path, func(rel string, fi builder.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if h, ok := fi.(builder.Hashed); ok {
+				sums = append(sums, h.Hash())
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+		hashes = append(hashes, "dir:"+sum(strings.Join(sums, ",")))
+	}
+
+	if len(hashes) == 1 {
+		return hashes[0], nil
+	}
+	return "multi:" + sum(strings.Join(hashes, ",")), nil
+}
+
+// isNotFound returns whether the error is that of a request for something that is not found
+func isNotFound(err error) bool {
+	e, ok := err.(interface {
+		HTTPErrorStatusCode() int
+	})
+	return ok && e.HTTPErrorStatusCode() == http.StatusNotFound
+}
+
+// cachedImage returns the newest of the images that was built from the parent by the step
+// the image history records as createdBy, or nil if there is none
+func cachedImage(images []*metadata.ImageConfig, parent *metadata.ImageConfig, createdBy string) *metadata.ImageConfig {
+	var cached *metadata.ImageConfig
+
+	for _, image := range images {
+		// an image built on scratch has no parent, as have the base layers of pulled
+		// images, so the length of the history tells the two apart
+		if image.Parent != parent.ID || len(image.History) != len(parent.History)+1 {
+			continue
+		}
+
+		if image.History[len(image.History)-1].CreatedBy != createdBy {
+			continue
+		}
+
+		if cached == nil || image.Created.After(cached.Created) {
+			cached = image
+		}
+	}
+
+	return cached
+}
+
+// readDockerfile parses the named Dockerfile of the build context, or the default
+// Dockerfile if none is named, and returns its steps
+func readDockerfile(buildContext builder.Context, name string) ([]*parser.Node, error) {
+	names := []string{name}
+	if name == "" {
+		names = []string{builder.DefaultDockerfileName, strings.ToLower(builder.DefaultDockerfileName)}
+	}
+
+	var f io.ReadCloser
+	var err error
+	for _, name = range names {
+		if f, err = buildContext.Open(name); err == nil || !os.IsNotExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Cannot locate specified Dockerfile: %s", names[0])
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	root, err := parser.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(root.Children) == 0 {
+		return nil, fmt.Errorf("The Dockerfile (%s) cannot be empty", name)
+	}
+
+	return root.Children, nil
+}
+
+// labelStep returns a LABEL step that applies the labels
+func labelStep(labels map[string]string) (*parser.Node, error) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	line := "LABEL"
+	for _, k := range keys {
+		line += fmt.Sprintf(" %q=%q", k, labels[k])
+	}
+
+	root, err := parser.Parse(strings.NewReader(line))
+	if err != nil {
+		return nil, err
+	}
+	return root.Children[0], nil
+}
+
+// stepMessage returns the step as docker reports it
+func stepMessage(step *parser.Node) string {
+	msg := strings.ToUpper(step.Value)
+	if len(step.Flags) > 0 {
+		msg += " " + strings.Join(step.Flags, " ")
+	}
+	for n := step.Next; n != nil; n = n.Next {
+		msg += " " + n.Value
+	}
+	return msg
+}
+
+// stepArgs returns the arguments of the step, with the environment substituted into them
+// for the instructions docker substitutes it in
+func stepArgs(step *parser.Node, env []string) ([]string, error) {
+	var args []string
+
+	for n := step.Next; n != nil; n = n.Next {
+		switch {
+		case !envInstructions[step.Value]:
+			args = append(args, n.Value)
+		case step.Value == command.Expose:
+			// EXPOSE $ports exposes each of the ports in the variable
+			words, err := dockerfile.ProcessWords(n.Value, env)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, words...)
+		default:
+			word, err := dockerfile.ProcessWord(n.Value, env)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, word)
+		}
+	}
+
+	return args, nil
+}
+
+// jsonArgs returns the arguments of a step that takes either a command or a JSON array,
+// joining the words of a command back together
+func jsonArgs(args []string, attributes map[string]bool) []string {
+	if len(args) == 0 {
+		return []string{}
+	}
+
+	if attributes["json"] {
+		return args
+	}
+
+	return []string{strings.Join(args, " ")}
+}
+
+// buildTags validates the tags given for the image and returns them, each once
+func buildTags(tags []string) ([]reference.Named, error) {
+	var refs []reference.Named
+	seen := make(map[string]bool)
+
+	for _, tag := range tags {
+		ref, err := reference.ParseNamed(tag)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := ref.(reference.Canonical); ok {
+			return nil, fmt.Errorf("build tag cannot contain a digest")
+		}
+
+		ref = reference.WithDefaultTag(ref)
+		if seen[ref.String()] {
+			continue
+		}
+		seen[ref.String()] = true
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// copyBuildConfig returns a copy of the config that a step can change without changing
+// the config of the image it was taken from
+func copyBuildConfig(config *container.Config) *container.Config {
+	c := *config
+
+	c.Cmd = append(strslice.StrSlice(nil), config.Cmd...)
+	c.Entrypoint = append(strslice.StrSlice(nil), config.Entrypoint...)
+	c.Env = append([]string(nil), config.Env...)
+	c.OnBuild = append([]string(nil), config.OnBuild...)
+
+	if config.Labels != nil {
+		c.Labels = make(map[string]string, len(config.Labels))
+		for k, v := range config.Labels {
+			c.Labels[k] = v
+		}
+	}
+
+	if config.ExposedPorts != nil {
+		c.ExposedPorts = make(nat.PortSet, len(config.ExposedPorts))
+		for k, v := range config.ExposedPorts {
+			c.ExposedPorts[k] = v
+		}
+	}
+
+	if config.Volumes != nil {
+		c.Volumes = make(map[string]struct{}, len(config.Volumes))
+		for k, v := range config.Volumes {
+			c.Volumes[k] = v
+		}
+	}
+
+	return &c
+}
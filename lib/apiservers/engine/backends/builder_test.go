@@ -0,0 +1,393 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backends
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/dockerfile/parser"
+	v1 "github.com/docker/docker/image"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/strslice"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/metadata"
+)
+
+// parseSteps returns the steps of the Dockerfile
+func parseSteps(t *testing.T, dockerfile string) []*parser.Node {
+	root, err := parser.Parse(strings.NewReader(dockerfile))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return root.Children
+}
+
+func TestApplyConfig(t *testing.T) {
+	base := &container.Config{
+		Env:        []string{"PATH=/bin", "HOME=/root"},
+		Cmd:        strslice.StrSlice{"/bin/sh"},
+		WorkingDir: "/root",
+		Labels:     map[string]string{"a": "1"},
+	}
+
+	steps := parseSteps(t, `ENV HOME=/home/user
+ENV LANG C
+LABEL b=2 "c d"="$HOME"
+WORKDIR src
+EXPOSE 80 53/udp
+USER nobody
+VOLUME ["/data"]
+STOPSIGNAL SIGTERM
+CMD echo hello
+ENTRYPOINT ["/init"]
+`)
+
+	ib := &imageBuild{}
+	config := base
+	var comments []string
+	for _, step := range steps {
+		args, err := stepArgs(step, config.Env)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var comment string
+		config, comment, err = ib.applyConfig(config, step.Value, args, step.Attributes)
+		if !assert.NoError(t, err) {
+			return
+		}
+		comments = append(comments, comment)
+	}
+
+	assert.Equal(t, []string{"PATH=/bin", "HOME=/home/user", "LANG=C"}, config.Env)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2", "c d": "/home/user"}, config.Labels)
+	assert.Equal(t, "/root/src", config.WorkingDir)
+	assert.Equal(t, map[nat.Port]struct{}{"80/tcp": {}, "53/udp": {}}, config.ExposedPorts)
+	assert.Equal(t, "nobody", config.User)
+	assert.Equal(t, map[string]struct{}{"/data": {}}, config.Volumes)
+	assert.Equal(t, "SIGTERM", config.StopSignal)
+	assert.Equal(t, strslice.StrSlice{"/init"}, config.Entrypoint)
+	// the entrypoint keeps the command set by the Dockerfile
+	assert.Equal(t, strslice.StrSlice{"/bin/sh", "-c", "echo hello"}, config.Cmd)
+
+	assert.Equal(t, []string{
+		"ENV HOME=/home/user",
+		"ENV LANG=C",
+		"LABEL b=2 c d=/home/user",
+		"WORKDIR /root/src",
+		"EXPOSE 53/udp 80/tcp",
+		"USER [nobody]",
+		"VOLUME [/data]",
+		"STOPSIGNAL [SIGTERM]",
+		`CMD ["/bin/sh" "-c" "echo hello"]`,
+		`ENTRYPOINT ["/init"]`,
+	}, comments)
+
+	// the config of the image the steps started from is left as it was
+	assert.Equal(t, []string{"PATH=/bin", "HOME=/root"}, base.Env)
+	assert.Equal(t, map[string]string{"a": "1"}, base.Labels)
+	assert.Equal(t, strslice.StrSlice{"/bin/sh"}, base.Cmd)
+
+	// an entrypoint resets the command of the base image
+	ib = &imageBuild{}
+	step := parseSteps(t, "ENTRYPOINT top -b")[0]
+	args, err := stepArgs(step, nil)
+	assert.NoError(t, err)
+	config, _, err = ib.applyConfig(base, step.Value, args, step.Attributes)
+	assert.NoError(t, err)
+	assert.Equal(t, strslice.StrSlice{"/bin/sh", "-c", "top -b"}, config.Entrypoint)
+	assert.Nil(t, config.Cmd)
+
+	for _, dockerfile := range []string{"EXPOSE 80-abc", "STOPSIGNAL NOPE", `VOLUME [" "]`} {
+		step := parseSteps(t, dockerfile)[0]
+		args, _ := stepArgs(step, nil)
+		_, _, err := ib.applyConfig(base, step.Value, args, step.Attributes)
+		assert.Error(t, err, dockerfile)
+	}
+}
+
+func TestCachedImage(t *testing.T) {
+	now := time.Now()
+
+	history := func(createdBy ...string) []v1.History {
+		var h []v1.History
+		for _, c := range createdBy {
+			h = append(h, v1.History{CreatedBy: c})
+		}
+		return h
+	}
+
+	image := func(id, parent string, created time.Time, h []v1.History) *metadata.ImageConfig {
+		return &metadata.ImageConfig{
+			V1Image: v1.V1Image{ID: id, Parent: parent, Created: created},
+			ImageID: id + "-image",
+			History: h,
+		}
+	}
+
+	scratch := &metadata.ImageConfig{}
+	base := image("base", "", now, history("ADD file", "CMD sh"))
+
+	images := []*metadata.ImageConfig{
+		base,
+		image("old", "base", now.Add(-time.Hour), history("ADD file", "CMD sh", "/bin/sh -c make")),
+		image("new", "base", now, history("ADD file", "CMD sh", "/bin/sh -c make")),
+		image("other", "base", now, history("ADD file", "CMD sh", "/bin/sh -c make install")),
+		image("scratch-env", "", now, history("/bin/sh -c #(nop) ENV A=1")),
+	}
+
+	// the newest image built by the step is used
+	cached := cachedImage(images, base, "/bin/sh -c make")
+	if assert.NotNil(t, cached) {
+		assert.Equal(t, "new", cached.ID)
+	}
+
+	assert.Nil(t, cachedImage(images, base, "/bin/sh -c make check"))
+
+	cached = cachedImage(images, scratch, "/bin/sh -c #(nop) ENV A=1")
+	if assert.NotNil(t, cached) {
+		assert.Equal(t, "scratch-env", cached.ID)
+	}
+
+	// the base image has no parent either, but it was not built on scratch
+	assert.Nil(t, cachedImage(images, scratch, "CMD sh"))
+}
+
+func TestStepArgs(t *testing.T) {
+	env := []string{"PORTS=80 443", "DIR=/srv"}
+
+	tests := []struct {
+		dockerfile string
+		args       []string
+		message    string
+	}{
+		{"EXPOSE $PORTS", []string{"80", "443"}, "EXPOSE $PORTS"},
+		{`EXPOSE "$PORTS"`, []string{"80 443"}, `EXPOSE "$PORTS"`},
+		{"WORKDIR ${DIR}/app", []string{"/srv/app"}, "WORKDIR ${DIR}/app"},
+		// the shell substitutes the environment of a command
+		{"RUN echo $DIR", []string{"echo $DIR"}, "RUN echo $DIR"},
+		{`CMD ["ls", "$DIR"]`, []string{"ls", "$DIR"}, "CMD ls $DIR"},
+	}
+
+	for _, test := range tests {
+		step := parseSteps(t, test.dockerfile)[0]
+
+		args, err := stepArgs(step, env)
+		assert.NoError(t, err, test.dockerfile)
+		assert.Equal(t, test.args, args, test.dockerfile)
+		assert.Equal(t, test.message, stepMessage(step), test.dockerfile)
+	}
+}
+
+func TestLabelStep(t *testing.T) {
+	step, err := labelStep(map[string]string{"version": "1.0", "maintainer": "a b"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	args, err := stepArgs(step, nil)
+	assert.NoError(t, err)
+
+	config, comment, err := (&imageBuild{}).applyConfig(&container.Config{}, step.Value, args, step.Attributes)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"version": "1.0", "maintainer": "a b"}, config.Labels)
+	assert.Equal(t, "LABEL maintainer=a b version=1.0", comment)
+}
+
+func TestBuildTags(t *testing.T) {
+	tags, err := buildTags([]string{"busybox", "busybox:latest", "registry:5000/app:v1"})
+	if assert.NoError(t, err) && assert.Len(t, tags, 2) {
+		assert.Equal(t, "busybox:latest", tags[0].String())
+		assert.Equal(t, "registry:5000/app:v1", tags[1].String())
+	}
+
+	_, err = buildTags([]string{"busybox@sha256:7cc4b5aefd1d0cadf8d97d4350462ba51c694ebca145b08d7d41b41acc8db5aa"})
+	assert.Error(t, err)
+
+	_, err = buildTags([]string{"Invalid"})
+	assert.Error(t, err)
+}
+
+func TestBuildArgs(t *testing.T) {
+	ib := &imageBuild{
+		image: &metadata.ImageConfig{
+			V1Image: v1.V1Image{Config: &container.Config{Env: []string{"HOME=/root"}}},
+		},
+		buildArgs:    map[string]string{"VERSION": "1.0", "HOME": "/home", "UNUSED": "x"},
+		declaredArgs: map[string]bool{"VERSION": true, "HOME": true},
+	}
+
+	// only declared arguments are set, and the environment of the image takes precedence
+	assert.Equal(t, []string{"VERSION=1.0"}, ib.runEnv())
+	assert.Equal(t, []string{"HOME=/root", "VERSION=1.0"}, ib.stepEnv())
+
+	args, err := stepArgs(parseSteps(t, "COPY app-$VERSION.tgz $HOME/")[0], ib.stepEnv())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app-1.0.tgz", "/root/"}, args)
+}
+
+// buildContext returns a build context holding the files, or directories for names that end
+// with a slash
+func buildContext(t *testing.T, files map[string]string) builder.Context {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			hdr.Mode, hdr.Size, hdr.Typeflag = 0755, 0, tar.TypeDir
+		}
+		assert.NoError(t, tw.WriteHeader(hdr))
+		tw.Write([]byte(content))
+	}
+	assert.NoError(t, tw.Close())
+
+	ctx, err := builder.MakeTarSumContext(buf)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return ctx
+}
+
+// tarArchive returns a tar archive of the files
+func tarArchive(t *testing.T, files ...string) string {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for _, name := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg}))
+		tw.Write([]byte("x"))
+	}
+	assert.NoError(t, tw.Close())
+
+	return buf.String()
+}
+
+// archiveFiles returns the names and contents of the regular files in the tar archive
+func archiveFiles(t *testing.T, r io.Reader) map[string]string {
+	files := make(map[string]string)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if !assert.NoError(t, err) {
+			return files
+		}
+
+		content, _ := ioutil.ReadAll(tr)
+		files[hdr.Name] = string(content)
+		if hdr.Typeflag == tar.TypeReg {
+			assert.Equal(t, 0, hdr.Uid, hdr.Name)
+		}
+	}
+}
+
+func TestWriteSources(t *testing.T) {
+	ctx := buildContext(t, map[string]string{
+		"a.txt":        "a",
+		"b.txt":        "b",
+		"src/":         "",
+		"src/main.go":  "main",
+		"app.tar":      tarArchive(t, "bin/app"),
+		"notes.tar.md": "not an archive",
+	})
+	defer ctx.Close()
+
+	tests := []struct {
+		add     bool
+		sources []string
+		dest    string
+		dir     bool
+		mkdir   bool
+		files   map[string]string
+	}{
+		// a file is copied as the destination unless that is a directory
+		{false, []string{"a.txt"}, "/etc/a.conf", false, false, map[string]string{"etc/a.conf": "a"}},
+		{false, []string{"a.txt"}, "/etc/", true, false, map[string]string{"etc/a.txt": "a"}},
+		// the contents of a directory are copied into a new destination directory
+		{false, []string{"src"}, "/go/src/app", true, true, map[string]string{"go/src/app/": "", "go/src/app/main.go": "main"}},
+		{false, []string{"*.txt"}, "/data/", true, false, map[string]string{"data/a.txt": "a", "data/b.txt": "b"}},
+		// ADD extracts an archive, COPY copies it
+		{true, []string{"app.tar"}, "/opt", true, false, map[string]string{"opt/bin/app": "x"}},
+		{false, []string{"app.tar"}, "/opt/", true, false, map[string]string{"opt/app.tar": tarArchive(t, "bin/app")}},
+		{true, []string{"notes.tar.md"}, "/opt/", true, false, map[string]string{"opt/notes.tar.md": "not an archive"}},
+	}
+
+	ib := &imageBuild{context: ctx}
+	for _, test := range tests {
+		var sources []*contextSource
+		for _, src := range test.sources {
+			found, err := ib.contextSources(test.add, src)
+			if !assert.NoError(t, err, src) {
+				return
+			}
+			sources = append(sources, found...)
+		}
+
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		assert.NoError(t, writeSources(tw, ctx, sources, test.dest, test.dir, test.mkdir))
+		assert.NoError(t, tw.Close())
+
+		assert.Equal(t, test.files, archiveFiles(t, buf), "%v to %s", test.sources, test.dest)
+	}
+
+	_, err := ib.contextSources(false, "missing.txt")
+	assert.Error(t, err)
+	_, err = ib.contextSources(false, "*.missing")
+	assert.Error(t, err)
+}
+
+func TestSourcesHash(t *testing.T) {
+	hash := func(files map[string]string, src string) string {
+		ctx := buildContext(t, files)
+		defer ctx.Close()
+
+		sources, err := (&imageBuild{context: ctx}).contextSources(false, src)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+
+		h, err := sourcesHash(ctx, sources)
+		assert.NoError(t, err)
+		return h
+	}
+
+	file := hash(map[string]string{"a.txt": "a"}, "a.txt")
+	assert.True(t, strings.HasPrefix(file, "file:"), file)
+	assert.Equal(t, file, hash(map[string]string{"a.txt": "a", "b.txt": "b"}, "a.txt"))
+	assert.NotEqual(t, file, hash(map[string]string{"a.txt": "changed"}, "a.txt"))
+
+	// a directory changes with any of its files
+	dir := hash(map[string]string{"src/": "", "src/a": "a"}, "src")
+	assert.True(t, strings.HasPrefix(dir, "dir:"), dir)
+	assert.NotEqual(t, dir, hash(map[string]string{"src/": "", "src/a": "changed"}, "src"))
+
+	multi := hash(map[string]string{"a.txt": "a", "b.txt": "b"}, "*.txt")
+	assert.True(t, strings.HasPrefix(multi, "multi:"), multi)
+}
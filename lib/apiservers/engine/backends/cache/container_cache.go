@@ -15,6 +15,7 @@
 package cache
 
 import (
+	"fmt"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
@@ -83,6 +84,28 @@ func (cc *CCache) AddContainer(container *container.VicContainer) {
 	cc.containersByName[container.Name] = container
 }
 
+// UpdateContainerName changes the name of the container in the cache, failing if
+// the new name is already in use
+func (cc *CCache) UpdateContainerName(nameOrID, newName string) error {
+	cc.m.Lock()
+	defer cc.m.Unlock()
+
+	container := cc.getContainer(nameOrID)
+	if container == nil {
+		return fmt.Errorf("No such container: %s", nameOrID)
+	}
+
+	if exists, ok := cc.containersByName[newName]; ok {
+		return fmt.Errorf("The name %q is already in use by container %s", newName, exists.ContainerID)
+	}
+
+	delete(cc.containersByName, container.Name)
+	container.Name = newName
+	cc.containersByName[newName] = container
+
+	return nil
+}
+
 func (cc *CCache) DeleteContainer(nameOrID string) {
 	cc.m.Lock()
 	defer cc.m.Unlock()
@@ -0,0 +1,111 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+
+	"github.com/vmware/vic/lib/apiservers/engine/backends/container"
+)
+
+// ECache tracks the exec instances created via the docker API
+type ECache struct {
+	m sync.RWMutex
+
+	execsByID map[string]*container.VicExec
+}
+
+var execCache *ECache
+
+func init() {
+	execCache = &ECache{
+		execsByID: make(map[string]*container.VicExec),
+	}
+}
+
+// ExecCache returns a reference to the exec cache
+func ExecCache() *ECache {
+	return execCache
+}
+
+// GetExec returns the exec with the given ID or nil if it's not present
+func (ec *ECache) GetExec(id string) *container.VicExec {
+	ec.m.RLock()
+	defer ec.m.RUnlock()
+
+	return ec.execsByID[id]
+}
+
+// AddExec adds the exec to the cache
+func (ec *ECache) AddExec(exec *container.VicExec) {
+	ec.m.Lock()
+	defer ec.m.Unlock()
+
+	ec.execsByID[exec.ID] = exec
+}
+
+// StartExec marks the exec with the given ID as started. It returns false if the exec is not
+// present or has already been started, so that only one caller can start it.
+func (ec *ECache) StartExec(id string) bool {
+	ec.m.Lock()
+	defer ec.m.Unlock()
+
+	exec, ok := ec.execsByID[id]
+	if !ok || exec.Started {
+		return false
+	}
+
+	exec.Started = true
+	return true
+}
+
+// ResetExec marks the exec with the given ID as not started, as when it failed to start
+func (ec *ECache) ResetExec(id string) {
+	ec.m.Lock()
+	defer ec.m.Unlock()
+
+	if exec, ok := ec.execsByID[id]; ok {
+		exec.Started = false
+	}
+}
+
+// ExecStarted returns whether the exec with the given ID has been started
+func (ec *ECache) ExecStarted(id string) bool {
+	ec.m.RLock()
+	defer ec.m.RUnlock()
+
+	exec, ok := ec.execsByID[id]
+	return ok && exec.Started
+}
+
+// DeleteExec removes the exec from the cache
+func (ec *ECache) DeleteExec(id string) {
+	ec.m.Lock()
+	defer ec.m.Unlock()
+
+	delete(ec.execsByID, id)
+}
+
+// DeleteContainerExecs removes all of the execs for the given container
+func (ec *ECache) DeleteContainerExecs(containerID string) {
+	ec.m.Lock()
+	defer ec.m.Unlock()
+
+	for id, exec := range ec.execsByID {
+		if exec.ContainerID == containerID {
+			delete(ec.execsByID, id)
+		}
+	}
+}
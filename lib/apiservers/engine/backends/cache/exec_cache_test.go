@@ -0,0 +1,62 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/docker/engine-api/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/apiservers/engine/backends/container"
+)
+
+func TestStartExec(t *testing.T) {
+	ec := &ECache{
+		execsByID: make(map[string]*container.VicExec),
+	}
+	ec.AddExec(container.NewVicExec("exec", "container", &types.ExecConfig{}))
+
+	assert.False(t, ec.StartExec("missing"))
+	assert.False(t, ec.ExecStarted("exec"))
+
+	// only one of the concurrent starts claims the exec
+	var wg sync.WaitGroup
+	started := make(chan bool, 10)
+	for i := 0; i < cap(started); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- ec.StartExec("exec")
+		}()
+	}
+	wg.Wait()
+	close(started)
+
+	claims := 0
+	for s := range started {
+		if s {
+			claims++
+		}
+	}
+	assert.Equal(t, 1, claims)
+	assert.True(t, ec.ExecStarted("exec"))
+
+	// a reset exec can be started again
+	ec.ResetExec("exec")
+	assert.False(t, ec.ExecStarted("exec"))
+	assert.True(t, ec.StartExec("exec"))
+}
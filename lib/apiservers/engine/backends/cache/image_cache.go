@@ -135,8 +135,9 @@ func (ic *ICache) GetImage(idOrRef string) (*metadata.ImageConfig, error) {
 	ic.m.RLock()
 	defer ic.m.RUnlock()
 
-	// cover the case of creating by a full reference
-	if config, ok := ic.cacheByName[idOrRef]; ok {
+	// cover the case of creating by a full reference, unless the reference
+	// has since been tagged on another image
+	if config, ok := ic.cacheByName[idOrRef]; ok && !retagged(idOrRef, config) {
 		return config, nil
 	}
 
@@ -186,6 +187,18 @@ func (ic *ICache) getImageByNamed(named reference.Named) *metadata.ImageConfig {
 	return copyImageConfig(ic.cacheByID[prefixImageID(id)])
 }
 
+// retagged returns true if the reference the image was cached by now refers
+// to a different image in the repository cache
+func retagged(ref string, config *metadata.ImageConfig) bool {
+	named, err := reference.ParseNamed(ref)
+	if err != nil {
+		return false
+	}
+
+	id, err := RepositoryCache().Get(named)
+	return err == nil && id != config.ImageID
+}
+
 // Add the "sha256:" prefix to the image ID if missing.
 // Don't assume the image id in image has "sha256:<id> as format.  We store it in
 // this format to make it easier to lookup by digest
@@ -202,6 +215,13 @@ func (ic *ICache) AddImage(imageConfig *metadata.ImageConfig) {
 	ic.m.Lock()
 	defer ic.m.Unlock()
 
+	// images committed without a repository are only known by ID
+	if imageConfig.Name == "" {
+		ic.idIndex.Add(imageConfig.ImageID)
+		ic.cacheByID[prefixImageID(imageConfig.ImageID)] = imageConfig
+		return
+	}
+
 	// Normalize the name stored in imageConfig using Docker's reference code
 	ref, err := reference.WithName(imageConfig.Name)
 	if err != nil {
@@ -15,9 +15,12 @@
 package backends
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -32,16 +35,22 @@ import (
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/namesgenerator"
+	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/pkg/term"
 	"github.com/docker/docker/pkg/version"
 	"github.com/docker/docker/reference"
+	"github.com/docker/docker/utils"
 	"github.com/docker/engine-api/types"
 	containertypes "github.com/docker/engine-api/types/container"
 	dnetwork "github.com/docker/engine-api/types/network"
 	timetypes "github.com/docker/engine-api/types/time"
+	"github.com/docker/engine-api/types/versions/v1p20"
 	"github.com/docker/go-connections/nat"
 	"github.com/docker/go-units"
 	"github.com/docker/libnetwork/portallocator"
+	"github.com/go-swagger/go-swagger/swag"
 	"github.com/vishvananda/netlink"
 
 	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
@@ -68,8 +77,18 @@ var (
 		scope string
 	}
 
+	// pendingAttaches counts the attaches in progress for each container, a container
+	// started while one is pending holds its process until the attach is complete
+	pendingAttaches struct {
+		sync.Mutex
+		ids map[string]int
+	}
+
 	portMapper portmap.PortMapper
 
+	// container names are restricted as docker restricts them
+	validContainerNamePattern = utils.RestrictedNamePattern
+
 	ctx = context.TODO()
 )
 
@@ -103,6 +122,15 @@ type Container struct {
 
 const (
 	defaultEnvPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	// statsFrameInterval is how often a frame is written to a stats stream
+	statsFrameInterval = time.Second
+	// statsNetwork is the interface the network counters of a container are reported against
+	statsNetwork = "eth0"
+
+	// minMemoryLimit is the smallest memory limit or reservation, and the granularity
+	// of the memory of a container VM
+	minMemoryLimit = 4 * units.MiB
 )
 
 func (c *Container) Handle(id, name string) (string, error) {
@@ -132,32 +160,213 @@ func NewContainerBackend() *Container {
 
 // ContainerExecCreate sets up an exec in a running container.
 func (c *Container) ContainerExecCreate(config *types.ExecConfig) (string, error) {
-	return "", fmt.Errorf("%s does not implement container.ContainerExecCreate", ProductName())
+	defer trace.End(trace.Begin(config.Container))
+
+	// Look up the container name in the metadata cache to get long ID
+	vc := cache.ContainerCache().GetContainer(config.Container)
+	if vc == nil {
+		return "", NotFoundError(config.Container)
+	}
+
+	running, err := c.containerProxy.IsRunning(vc)
+	if err != nil {
+		return "", err
+	}
+	if !running {
+		return "", derr.NewRequestConflictError(fmt.Errorf("Container %s is not running", config.Container))
+	}
+
+	if config.DetachKeys != "" {
+		if _, err := term.ToBytes(config.DetachKeys); err != nil {
+			return "", derr.NewBadRequestError(fmt.Errorf("Invalid escape keys (%s) provided", config.DetachKeys))
+		}
+	}
+
+	// exec inherits the user of the container unless one is specified
+	if config.User == "" {
+		config.User = vc.Config.User
+	}
+
+	id := stringid.GenerateRandomID()
+	cache.ExecCache().AddExec(viccontainer.NewVicExec(id, vc.ContainerID, config))
+
+	return id, nil
 }
 
 // ContainerExecInspect returns low-level information about the exec
 // command. An error is returned if the exec cannot be found.
 func (c *Container) ContainerExecInspect(id string) (*backend.ExecInspect, error) {
-	return nil, fmt.Errorf("%s does not implement container.ContainerExecInspect", ProductName())
+	defer trace.End(trace.Begin(id))
+
+	ec := cache.ExecCache().GetExec(id)
+	if ec == nil {
+		return nil, ExecNotFoundError(id)
+	}
+
+	privileged := ec.Config.Privileged
+	inspect := &backend.ExecInspect{
+		ID:          ec.ID,
+		ContainerID: ec.ContainerID,
+		OpenStdin:   ec.Config.AttachStdin,
+		OpenStdout:  ec.Config.AttachStdout,
+		OpenStderr:  ec.Config.AttachStderr,
+		DetachKeys:  []byte(ec.Config.DetachKeys),
+		ProcessConfig: &backend.ExecProcessConfig{
+			Tty:        ec.Config.Tty,
+			Entrypoint: ec.Config.Cmd[0],
+			Arguments:  ec.Config.Cmd[1:],
+			Privileged: &privileged,
+			User:       ec.Config.User,
+		},
+	}
+
+	// nothing more to report until the exec has been handed to the portlayer
+	if !cache.ExecCache().ExecStarted(ec.ID) {
+		return inspect, nil
+	}
+
+	vc := cache.ContainerCache().GetContainer(ec.ContainerID)
+	if vc == nil {
+		return nil, NotFoundError(ec.ContainerID)
+	}
+
+	handle, err := c.Handle(vc.ContainerID, vc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := c.containerProxy.InspectExecTask(handle, ec.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	inspect.Running = task.Running != nil && *task.Running
+	if !inspect.Running && task.ExitCode != nil {
+		exitCode := int(*task.ExitCode)
+		inspect.ExitCode = &exitCode
+	}
+
+	return inspect, nil
 }
 
 // ContainerExecResize changes the size of the TTY of the process
 // running in the exec with the given name to the given height and
 // width.
 func (c *Container) ContainerExecResize(name string, height, width int) error {
-	return fmt.Errorf("%s does not implement container.ContainerExecResize", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	ec := cache.ExecCache().GetExec(name)
+	if ec == nil {
+		return ExecNotFoundError(name)
+	}
+
+	// Call the port layer to resize
+	plHeight := int32(height)
+	plWidth := int32(width)
+
+	return c.containerProxy.Resize(ec.ID, plHeight, plWidth)
 }
 
 // ContainerExecStart starts a previously set up exec instance. The
 // std streams are set up.
 func (c *Container) ContainerExecStart(name string, stdin io.ReadCloser, stdout io.Writer, stderr io.Writer) error {
-	return fmt.Errorf("%s does not implement container.ContainerExecStart", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	ec := cache.ExecCache().GetExec(name)
+	if ec == nil {
+		return ExecNotFoundError(name)
+	}
+
+	vc := cache.ContainerCache().GetContainer(ec.ContainerID)
+	if vc == nil {
+		return NotFoundError(ec.ContainerID)
+	}
+
+	keys, err := term.ToBytes(ec.Config.DetachKeys)
+	if err != nil {
+		return derr.NewBadRequestError(fmt.Errorf("Invalid escape keys (%s) provided", ec.Config.DetachKeys))
+	}
+
+	// claim the exec so that it is only started once
+	if !cache.ExecCache().StartExec(ec.ID) {
+		return derr.NewRequestConflictError(fmt.Errorf("Error: Exec command %s has already run", name))
+	}
+
+	// release the claim if the exec session is not launched
+	defer func() {
+		if err != nil {
+			cache.ExecCache().ResetExec(ec.ID)
+		}
+	}()
+
+	handle, err := c.Handle(vc.ContainerID, vc.Name)
+	if err != nil {
+		return err
+	}
+
+	ac := &AttachConfig{
+		ContainerAttachConfig: &backend.ContainerAttachConfig{
+			UseStdin:   ec.Config.AttachStdin && stdin != nil,
+			UseStdout:  ec.Config.AttachStdout && stdout != nil,
+			UseStderr:  ec.Config.AttachStderr && stderr != nil,
+			DetachKeys: keys,
+		},
+		ID:     ec.ID,
+		UseTty: ec.Config.Tty,
+	}
+
+	attach := stdin != nil || stdout != nil || stderr != nil
+	if attach {
+		// make sure the interaction connection is enabled so we can reach the exec session
+		handle, err = c.containerProxy.BindInteraction(handle, vc.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the attach releases the held process once the output streams are in place
+	hold := ac.UseStdout || ac.UseStderr
+	handle, err = c.containerProxy.CreateExecTask(handle, vc, ec, hold)
+	if err != nil {
+		return err
+	}
+
+	if !attach {
+		// commit the handle; this will reconfigure the vm and launch the exec session
+		err = c.commitHandle(handle, vc.Name)
+		return err
+	}
+
+	// the commit waits for the launch of the exec session, which is held until the attach
+	// releases it, so the attach has to be set up while the commit is in progress
+	actx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	attached := make(chan error, 1)
+	go func() {
+		attached <- c.containerProxy.AttachStreams(actx, ac, stdin, stdout, stderr)
+	}()
+
+	// commit the handle; this will reconfigure the vm and launch the exec session
+	if err = c.commitHandle(handle, vc.Name); err != nil {
+		cancel()
+		<-attached
+		return err
+	}
+
+	return <-attached
 }
 
 // ExecExists looks up the exec instance and returns a bool if it exists or not.
 // It will also return the error produced by `getConfig`
 func (c *Container) ExecExists(name string) (bool, error) {
-	return false, fmt.Errorf("%s does not implement container.ExecExists", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	if ec := cache.ExecCache().GetExec(name); ec == nil {
+		return false, ExecNotFoundError(name)
+	}
+
+	return true, nil
 }
 
 // docker's container.copyBackend
@@ -166,19 +375,54 @@ func (c *Container) ExecExists(name string) (bool, error) {
 // specified path in the container identified by the given name. Returns a
 // tar archive of the resource and whether it was a directory or a single file.
 func (c *Container) ContainerArchivePath(name string, path string) (content io.ReadCloser, stat *types.ContainerPathStat, err error) {
-	return nil, nil, fmt.Errorf("%s does not implement container.ContainerArchivePath", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return nil, nil, NotFoundError(name)
+	}
+
+	if err = c.bindFilesystem(vc); err != nil {
+		return nil, nil, err
+	}
+
+	stat, err = c.containerProxy.StatPath(vc.ContainerID, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(c.containerProxy.ArchiveExport(vc.ContainerID, path, writer))
+	}()
+
+	return reader, stat, nil
 }
 
 // ContainerCopy performs a deprecated operation of archiving the resource at
 // the specified path in the container identified by the given name.
 func (c *Container) ContainerCopy(name string, res string) (io.ReadCloser, error) {
-	return nil, fmt.Errorf("%s does not implement container.ContainerCopy", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	content, _, err := c.ContainerArchivePath(name, res)
+	return content, err
 }
 
 // ContainerExport writes the contents of the container to the given
 // writer. An error is returned if the container cannot be found.
 func (c *Container) ContainerExport(name string, out io.Writer) error {
-	return fmt.Errorf("%s does not implement container.ContainerExport", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return NotFoundError(name)
+	}
+
+	if err := c.bindFilesystem(vc); err != nil {
+		return err
+	}
+
+	return c.containerProxy.Export(vc.ContainerID, out)
 }
 
 // ContainerExtractToDir extracts the given archive to the specified location
@@ -188,19 +432,106 @@ func (c *Container) ContainerExport(name string, out io.Writer) error {
 // be an error if unpacking the given content would cause an existing directory
 // to be replaced with a non-directory and vice versa.
 func (c *Container) ContainerExtractToDir(name, path string, noOverwriteDirNonDir bool, content io.Reader) error {
-	return fmt.Errorf("%s does not implement container.ContainerExtractToDir", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return NotFoundError(name)
+	}
+
+	if err := c.bindFilesystem(vc); err != nil {
+		return err
+	}
+
+	stat, err := c.containerProxy.StatPath(vc.ContainerID, path)
+	if err != nil {
+		return err
+	}
+
+	// symlinks are resolved within the container so leave those to the portlayer
+	if !stat.Mode.IsDir() && stat.Mode&os.ModeSymlink == 0 {
+		return derr.NewBadRequestError(ErrExtractPointNotDirectory)
+	}
+
+	return c.containerProxy.ArchiveImport(vc.ContainerID, path, noOverwriteDirNonDir, content)
 }
 
 // ContainerStatPath stats the filesystem resource at the specified path in the
 // container identified by the given name.
 func (c *Container) ContainerStatPath(name string, path string) (stat *types.ContainerPathStat, err error) {
-	return nil, fmt.Errorf("%s does not implement container.ContainerStatPath", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return nil, NotFoundError(name)
+	}
+
+	if err = c.bindFilesystem(vc); err != nil {
+		return nil, err
+	}
+
+	return c.containerProxy.StatPath(vc.ContainerID, path)
+}
+
+// bindFilesystem makes sure the portlayer can reach the filesystem of a running
+// container. The filesystem of a stopped container is accessed by mounting its
+// disk so nothing needs to be done in that case.
+func (c *Container) bindFilesystem(vc *viccontainer.VicContainer) error {
+	running, err := c.containerProxy.IsRunning(vc)
+	if err != nil || !running {
+		return err
+	}
+
+	return c.bindInteraction(vc)
+}
+
+// bindInteraction makes sure the portlayer has a connection to the tether of a
+// running container
+func (c *Container) bindInteraction(vc *viccontainer.VicContainer) error {
+	handle, err := c.Handle(vc.ContainerID, vc.Name)
+	if err != nil {
+		return err
+	}
+
+	handle, err = c.containerProxy.BindInteraction(handle, vc.Name)
+	if err != nil {
+		return err
+	}
+
+	return c.commitHandle(handle, vc.Name)
+}
+
+// commitHandle commits the handle, reconfiguring the container as needed
+func (c *Container) commitHandle(handle, name string) error {
+	client := c.containerProxy.Client()
+	_, err := client.Containers.Commit(containers.NewCommitParamsWithContext(ctx).WithHandle(handle))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.CommitNotFound:
+			return NotFoundError(name)
+		case *containers.CommitConflict:
+			return ConflictError(err.Error())
+		case *containers.CommitDefault:
+			return InternalServerError(err.Payload.Message)
+		default:
+			return InternalServerError(err.Error())
+		}
+	}
+
+	return nil
 }
 
 // docker's container.stateBackend
 
 // ContainerCreate creates a container.
 func (c *Container) ContainerCreate(config types.ContainerCreateConfig) (types.ContainerCreateResponse, error) {
+	return c.ContainerCreateWithHealthcheck(config, nil)
+}
+
+// ContainerCreateWithHealthcheck creates a container with the given health check, which
+// the docker create config cannot carry in the docker version we vendor. The health check
+// is merged with the one of the image.
+func (c *Container) ContainerCreateWithHealthcheck(config types.ContainerCreateConfig, healthcheck *metadata.HealthConfig) (types.ContainerCreateResponse, error) {
 	defer trace.End(trace.Begin(""))
 
 	var err error
@@ -234,6 +565,7 @@ func (c *Container) ContainerCreate(config types.ContainerCreateConfig) (types.C
 	if err != nil {
 		return types.ContainerCreateResponse{}, err
 	}
+	container.Healthcheck = mergeHealthcheck(healthcheck, image.Healthcheck)
 
 	// Create an actualized container in the VIC port layer
 	id, err := c.containerCreate(container, config)
@@ -267,7 +599,7 @@ func (c *Container) containerCreate(vc *viccontainer.VicContainer, config types.
 
 	imageID := vc.ImageID
 
-	id, h, err := c.containerProxy.CreateContainerHandle(imageID, config)
+	id, h, err := c.containerProxy.CreateContainerHandle(imageID, config, vc.Healthcheck)
 	if err != nil {
 		return "", err
 	}
@@ -320,14 +652,128 @@ func (c *Container) ContainerKill(name string, sig uint64) error {
 
 // ContainerPause pauses a container
 func (c *Container) ContainerPause(name string) error {
-	return fmt.Errorf("%s does not implement container.ContainerPause", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	return c.containerPauseStateChange(name, "Running", "PAUSED")
+}
+
+// containerPauseStateChange moves the container from the expected state, as reported by
+// the portlayer, to the target state given to the portlayer
+func (c *Container) containerPauseStateChange(name, expected, target string) error {
+	// Look up the container name in the metadata cache to get long ID
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return NotFoundError(name)
+	}
+	id := vc.ContainerID
+
+	client := c.containerProxy.Client()
+
+	infoResponse, err := client.Containers.GetContainerInfo(containers.NewGetContainerInfoParamsWithContext(ctx).WithID(id))
+	if err != nil {
+		cache.ContainerCache().DeleteContainer(id)
+		return NotFoundError(name)
+	}
+
+	if state := *infoResponse.Payload.ContainerConfig.State; state != expected {
+		switch {
+		case state == "Paused":
+			return derr.NewRequestConflictError(fmt.Errorf("Container %s is already paused", name))
+		case expected == "Paused":
+			return derr.NewRequestConflictError(fmt.Errorf("Container %s is not paused", name))
+		default:
+			return derr.NewRequestConflictError(fmt.Errorf("Container %s is not running", name))
+		}
+	}
+
+	handle, err := c.Handle(id, name)
+	if err != nil {
+		return err
+	}
+
+	stateChangeResponse, err := client.Containers.StateChange(containers.NewStateChangeParamsWithContext(ctx).WithHandle(handle).WithState(target))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.StateChangeNotFound:
+			cache.ContainerCache().DeleteContainer(id)
+			return NotFoundError(name)
+		case *containers.StateChangeDefault:
+			return InternalServerError(err.Payload.Message)
+		default:
+			return InternalServerError(err.Error())
+		}
+	}
+
+	_, err = client.Containers.Commit(containers.NewCommitParamsWithContext(ctx).WithHandle(stateChangeResponse.Payload))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.CommitNotFound:
+			cache.ContainerCache().DeleteContainer(id)
+			return NotFoundError(name)
+		case *containers.CommitConflict:
+			return ConflictError(err.Error())
+		case *containers.CommitDefault:
+			return InternalServerError(err.Payload.Message)
+		default:
+			return InternalServerError(err.Error())
+		}
+	}
+
+	return nil
 }
 
 // ContainerRename changes the name of a container, using the oldName
 // to find the container. An error is returned if newName is already
 // reserved.
 func (c *Container) ContainerRename(oldName, newName string) error {
-	return fmt.Errorf("%s does not implement container.ContainerRename", ProductName())
+	defer trace.End(trace.Begin(fmt.Sprintf("%s -> %s", oldName, newName)))
+
+	if oldName == "" || newName == "" {
+		return derr.NewBadRequestError(fmt.Errorf("Neither old nor new names may be empty"))
+	}
+
+	newName = strings.TrimPrefix(newName, "/")
+	if !validContainerNamePattern.MatchString(newName) {
+		return derr.NewBadRequestError(fmt.Errorf("Invalid container name (%s), only %s are allowed", newName, utils.RestrictedNameChars))
+	}
+
+	// Look up the container name in the metadata cache to get long ID
+	vc := cache.ContainerCache().GetContainer(oldName)
+	if vc == nil {
+		return NotFoundError(oldName)
+	}
+	id := vc.ContainerID
+
+	if vc.Name == newName {
+		return derr.NewRequestConflictError(fmt.Errorf("Renaming a container with the same name as its current name"))
+	}
+
+	if exists := cache.ContainerCache().GetContainer(newName); exists != nil {
+		return derr.NewRequestConflictError(fmt.Errorf("Conflict. The name %q is already in use by container %s. You have to remove (or rename) that container to be able to re use that name.", newName, exists.ContainerID))
+	}
+
+	// the portlayer renames the VM and moves the network names of the container in one commit
+	client := c.containerProxy.Client()
+	_, err := client.Containers.ContainerRename(containers.NewContainerRenameParamsWithContext(ctx).WithID(id).WithName(newName))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.ContainerRenameNotFound:
+			cache.ContainerCache().DeleteContainer(id)
+			return NotFoundError(oldName)
+		case *containers.ContainerRenameConflict:
+			return derr.NewRequestConflictError(fmt.Errorf("Failed to rename container %s: %s", oldName, err.Payload.Message))
+		case *containers.ContainerRenameInternalServerError:
+			return InternalServerError(err.Payload.Message)
+		default:
+			return InternalServerError(err.Error())
+		}
+	}
+
+	if err = cache.ContainerCache().UpdateContainerName(id, newName); err != nil {
+		return derr.NewRequestConflictError(err)
+	}
+
+	return nil
 }
 
 // ContainerResize changes the size of the TTY of the process running
@@ -345,7 +791,7 @@ func (c *Container) ContainerResize(name string, height, width int) error {
 	plHeight := int32(height)
 	plWidth := int32(width)
 
-	return c.containerProxy.Resize(vc, plHeight, plWidth)
+	return c.containerProxy.Resize(vc.ContainerID, plHeight, plWidth)
 }
 
 // ContainerRestart stops and starts a container. It attempts to
@@ -410,8 +856,9 @@ func (c *Container) ContainerRm(name string, config *types.ContainerRmConfig) er
 			return InternalServerError(err.Error())
 		}
 	}
-	// delete container from the cache
+	// delete container and any of its execs from the cache
 	cache.ContainerCache().DeleteContainer(id)
+	cache.ExecCache().DeleteContainerExecs(id)
 	return nil
 }
 
@@ -481,6 +928,26 @@ func (c *Container) containerStart(name string, hostConfig *containertypes.HostC
 
 	}
 
+	// hold the process at launch if the container is started for an attach that is
+	// still being set up, so no output is lost
+	if attachPending(id) {
+		var bindRes *interaction.InteractionBindOK
+		bindRes, err = client.Interaction.InteractionBind(interaction.NewInteractionBindParamsWithContext(ctx).
+			WithConfig(&models.InteractionBindConfig{
+				Handle:        handle,
+				AttachTimeout: swag.Int64(int64(attachHoldTimeout)),
+			}))
+		if err != nil {
+			return InternalServerError(err.Error())
+		}
+
+		var ok bool
+		if handle, ok = bindRes.Payload.Handle.(string); !ok {
+			err = fmt.Errorf("Type assertion failed for %#+v", bindRes.Payload.Handle)
+			return InternalServerError(err.Error())
+		}
+	}
+
 	// change the state of the container
 	// TODO: We need a resolved ID from the name
 	var stateChangeRes *containers.StateChangeOK
@@ -531,6 +998,37 @@ func (c *Container) containerStart(name string, hostConfig *containertypes.HostC
 	return nil
 }
 
+// addPendingAttach records an attach in progress for the container
+func addPendingAttach(id string) {
+	pendingAttaches.Lock()
+	defer pendingAttaches.Unlock()
+
+	if pendingAttaches.ids == nil {
+		pendingAttaches.ids = make(map[string]int)
+	}
+	pendingAttaches.ids[id]++
+}
+
+// removePendingAttach records the end of an attach to the container
+func removePendingAttach(id string) {
+	pendingAttaches.Lock()
+	defer pendingAttaches.Unlock()
+
+	if pendingAttaches.ids[id] <= 1 {
+		delete(pendingAttaches.ids, id)
+		return
+	}
+	pendingAttaches.ids[id]--
+}
+
+// attachPending returns whether an attach to the container is in progress
+func attachPending(id string) bool {
+	pendingAttaches.Lock()
+	defer pendingAttaches.Unlock()
+
+	return pendingAttaches.ids[id] > 0
+}
+
 // requestHostPort finds a free port on the host
 func requestHostPort(proto string) (int, error) {
 	pa := portallocator.Get()
@@ -552,6 +1050,12 @@ func (c *Container) mapPorts(op portmap.Operation, hostconfig *containertypes.Ho
 	if err != nil {
 		return err
 	}
+
+	// the port bindings of the host config that each mapping is for, so the host
+	// ports that are chosen when the ports are mapped can be recorded in them
+	var mappings []portmap.Binding
+	var pbRefs []*nat.PortBinding
+
 	for p := range bindings {
 		proto, port := nat.SplitProtoPort(string(p))
 		var nport nat.Port
@@ -566,27 +1070,65 @@ func (c *Container) mapPorts(op portmap.Operation, hostconfig *containertypes.Ho
 		}
 
 		for i := range pbs {
-			var hostPort int
+			var start, end int
 			if pbs[i].HostPort == "" {
+				if op == portmap.Unmap {
+					// the port was never mapped
+					continue
+				}
+
 				// use a random port since no host port is specified
-				hostPort, err = requestHostPort(proto)
+				start, err = requestHostPort(proto)
 				if err != nil {
 					log.Errorf("could not find available port on host")
 					return err
 				}
-				// update the hostconfig
-				pbs[i].HostPort = strconv.Itoa(hostPort)
-
+				end = start
 			} else {
-				hostPort, err = strconv.Atoi(pbs[i].HostPort)
+				start, end, err = nat.ParsePortRangeToInt(pbs[i].HostPort)
 				if err != nil {
 					return err
 				}
+
+				if op == portmap.Unmap && start != end {
+					// a port was never chosen from the range
+					continue
+				}
 			}
 
-			if err = portMapper.MapPort(op, nil, hostPort, nport.Proto(), containerIP.String(), nport.Int(), clientIfaceName, bridgeIfaceName); err != nil {
-				return err
+			hostIP := net.ParseIP(pbs[i].HostIP)
+			if op == portmap.Map && hostIP != nil && !hostIP.IsUnspecified() {
+				if err = checkClientAddr(hostIP); err != nil {
+					return err
+				}
 			}
+
+			mappings = append(mappings, portmap.Binding{
+				IP:       hostIP,
+				Port:     start,
+				EndPort:  end,
+				Proto:    nport.Proto(),
+				DestIP:   containerIP.String(),
+				DestPort: nport.Int(),
+			})
+			pbRefs = append(pbRefs, &pbs[i])
+		}
+	}
+
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	// the ports are all mapped or none of them are
+	mapped, err := portMapper.MapPorts(op, mappings, clientIfaceName, bridgeIfaceName)
+	if err != nil {
+		return err
+	}
+
+	if op == portmap.Map {
+		// update the hostconfig with the ports that were chosen
+		for i := range mapped {
+			pbRefs[i].HostPort = strconv.Itoa(mapped[i].Port)
 		}
 	}
 
@@ -712,45 +1254,100 @@ func (c *Container) containerStop(name string, seconds int, unbound bool) error
 	stateChangeResponse, err := client.Containers.StateChange(containers.NewStateChangeParamsWithContext(ctx).WithHandle(handle).WithState("STOPPED"))
 	if err != nil {
 		switch err := err.(type) {
-		case *containers.StateChangeNotFound:
+		case *containers.StateChangeNotFound:
+			cache.ContainerCache().DeleteContainer(id)
+			return NotFoundError(name)
+		case *containers.StateChangeDefault:
+			return InternalServerError(err.Payload.Message)
+		default:
+			return InternalServerError(err.Error())
+		}
+	}
+
+	handle = stateChangeResponse.Payload
+	wait := int32(seconds)
+
+	_, err = client.Containers.Commit(containers.NewCommitParamsWithContext(ctx).WithHandle(handle).WithWaitTime(&wait))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.CommitNotFound:
+			cache.ContainerCache().DeleteContainer(id)
+			return NotFoundError(name)
+		case *containers.CommitConflict:
+			return ConflictError(err.Error())
+		case *containers.CommitDefault:
+			return InternalServerError(err.Payload.Message)
+		default:
+			return InternalServerError(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// ContainerUnpause unpauses a container
+func (c *Container) ContainerUnpause(name string) error {
+	defer trace.End(trace.Begin(name))
+
+	return c.containerPauseStateChange(name, "Paused", "RUNNING")
+}
+
+// ContainerUpdate updates configuration of the container
+func (c *Container) ContainerUpdate(name string, hostConfig *containertypes.HostConfig) ([]string, error) {
+	defer trace.End(trace.Begin(name))
+
+	// Look up the container name in the metadata cache to get long ID
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return nil, NotFoundError(name)
+	}
+	id := vc.ContainerID
+
+	config, warnings, err := updateConfigFromHostConfig(hostConfig)
+	if err != nil {
+		return nil, derr.NewBadRequestError(err)
+	}
+
+	handle, err := c.Handle(id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.containerProxy.Client()
+
+	updateResponse, err := client.Containers.ContainerUpdate(containers.NewContainerUpdateParamsWithContext(ctx).WithHandle(handle).WithConfig(config))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.ContainerUpdateNotFound:
 			cache.ContainerCache().DeleteContainer(id)
-			return NotFoundError(name)
-		case *containers.StateChangeDefault:
-			return InternalServerError(err.Payload.Message)
+			return nil, NotFoundError(name)
+		case *containers.ContainerUpdateDefault:
+			return nil, InternalServerError(err.Payload.Message)
 		default:
-			return InternalServerError(err.Error())
+			return nil, InternalServerError(err.Error())
 		}
 	}
 
-	handle = stateChangeResponse.Payload
-	wait := int32(seconds)
-
-	_, err = client.Containers.Commit(containers.NewCommitParamsWithContext(ctx).WithHandle(handle).WithWaitTime(&wait))
+	_, err = client.Containers.Commit(containers.NewCommitParamsWithContext(ctx).WithHandle(swag.StringValue(updateResponse.Payload.Handle)))
 	if err != nil {
 		switch err := err.(type) {
 		case *containers.CommitNotFound:
 			cache.ContainerCache().DeleteContainer(id)
-			return NotFoundError(name)
+			return nil, NotFoundError(name)
 		case *containers.CommitConflict:
-			return ConflictError(err.Error())
+			return nil, ConflictError(err.Error())
 		case *containers.CommitDefault:
-			return InternalServerError(err.Payload.Message)
+			return nil, InternalServerError(err.Payload.Message)
 		default:
-			return InternalServerError(err.Error())
+			return nil, InternalServerError(err.Error())
 		}
 	}
 
-	return nil
-}
-
-// ContainerUnpause unpauses a container
-func (c *Container) ContainerUnpause(name string) error {
-	return fmt.Errorf("%s does not implement container.ContainerUnpause", ProductName())
-}
+	if config.RestartPolicy != nil && vc.HostConfig != nil {
+		vc.HostConfig.RestartPolicy = hostConfig.RestartPolicy
+	}
 
-// ContainerUpdate updates configuration of the container
-func (c *Container) ContainerUpdate(name string, hostConfig *containertypes.HostConfig) ([]string, error) {
-	return make([]string, 0, 0), fmt.Errorf("%s does not implement container.ContainerUpdate", ProductName())
+	return append(warnings, updateResponse.Payload.Warnings...), nil
 }
 
 // ContainerWait stops processing until the given container is
@@ -796,6 +1393,10 @@ func dockerStatus(exitCode int, status string, state string, started time.Time,
 		if !started.IsZero() {
 			dockStatus = fmt.Sprintf("Up %s", units.HumanDuration(time.Now().UTC().Sub(started)))
 		}
+	case "Paused":
+		if !started.IsZero() {
+			dockStatus = fmt.Sprintf("Up %s (Paused)", units.HumanDuration(time.Now().UTC().Sub(started)))
+		}
 	case "Stopped":
 		// if we don't have a finished date then don't process exitCode and return "Stopped" for the status
 		if !finished.IsZero() {
@@ -827,7 +1428,14 @@ func dockerStatus(exitCode int, status string, state string, started time.Time,
 
 // ContainerChanges returns a list of container fs changes
 func (c *Container) ContainerChanges(name string) ([]archive.Change, error) {
-	return make([]archive.Change, 0, 0), fmt.Errorf("%s does not implement container.ContainerChanges", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return nil, NotFoundError(name)
+	}
+
+	return c.containerProxy.Changes(vc.ContainerID)
 }
 
 // ContainerInspect returns low-level information about a
@@ -891,12 +1499,28 @@ func (c *Container) ContainerInspect(name string, size bool, version version.Ver
 		log.Debugf("Docker inspect - network settings = null")
 	}
 
+	if health := dockerHealth(results.Payload.ProcessConfig.Health); health != nil {
+		return &ContainerJSON{
+			ContainerJSON: inspectJSON,
+			State: &ContainerState{
+				ContainerState: inspectJSON.State,
+				Health:         health,
+			},
+		}, nil
+	}
+
 	return inspectJSON, nil
 }
 
 // ContainerLogs hooks up a container's stdout and stderr streams
 // configured with the given struct.
 func (c *Container) ContainerLogs(name string, config *backend.ContainerLogsConfig, started chan struct{}) error {
+	return c.ContainerLogsUntil(name, config, "", started)
+}
+
+// ContainerLogsUntil is ContainerLogs with the end of the log bounded by until, which
+// the docker backend interface does not yet carry.
+func (c *Container) ContainerLogsUntil(name string, config *backend.ContainerLogsConfig, until string, started chan struct{}) error {
 	defer trace.End(trace.Begin(""))
 
 	// Look up the container name in the metadata cache to get long ID
@@ -906,7 +1530,7 @@ func (c *Container) ContainerLogs(name string, config *backend.ContainerLogsConf
 	}
 	name = vc.ContainerID
 
-	tailLines, since, err := c.validateContainerLogsConfig(vc, config)
+	tailLines, since, untilTime, err := c.validateContainerLogsConfig(vc, config, until)
 	if err != nil {
 		return err
 	}
@@ -918,13 +1542,20 @@ func (c *Container) ContainerLogs(name string, config *backend.ContainerLogsConf
 
 	wf.Flush()
 
-	outStream := io.Writer(wf)
+	// a tty merges the streams, otherwise each is multiplexed if it was asked for
+	stdout, stderr := io.Writer(wf), ioutil.Discard
 	if !vc.Config.Tty {
-		outStream = stdcopy.NewStdWriter(outStream, stdcopy.Stdout)
+		stdout = ioutil.Discard
+		if config.ShowStdout {
+			stdout = stdcopy.NewStdWriter(wf, stdcopy.Stdout)
+		}
+		if config.ShowStderr {
+			stderr = stdcopy.NewStdWriter(wf, stdcopy.Stderr)
+		}
 	}
 
 	// Make a call to our proxy to handle the remoting
-	err = c.containerProxy.StreamContainerLogs(name, outStream, started, config.Timestamps, config.Follow, since, tailLines)
+	err = c.containerProxy.StreamContainerLogs(name, stdout, stderr, started, config.Timestamps, config.Follow, since, untilTime, tailLines)
 
 	return err
 }
@@ -932,7 +1563,194 @@ func (c *Container) ContainerLogs(name string, config *backend.ContainerLogsConf
 // ContainerStats writes information about the container to the stream
 // given in the config object.
 func (c *Container) ContainerStats(name string, config *backend.ContainerStatsConfig) error {
-	return fmt.Errorf("%s does not implement container.ContainerStats", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	// Look up the container name in the metadata cache to get long ID
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return NotFoundError(name)
+	}
+
+	sample, err := c.containerProxy.Stats(vc)
+	if err != nil {
+		return err
+	}
+
+	// As with docker, a container that is not running has empty stats
+	if sample == nil {
+		if !config.Stream {
+			return json.NewEncoder(config.OutStream).Encode(&types.Stats{})
+		}
+		return ConflictError(fmt.Sprintf("Container %s is not running", name))
+	}
+
+	outStream := config.OutStream
+	if config.Stream {
+		wf := ioutils.NewWriteFlusher(outStream)
+		defer wf.Close()
+		wf.Flush()
+		outStream = wf
+	}
+
+	apiVersion := version.Version(config.Version)
+	enc := json.NewEncoder(outStream)
+
+	ticker := time.NewTicker(statsFrameInterval)
+	defer ticker.Stop()
+
+	stats := &containerStats{}
+	stats.update(sample)
+	fetched := time.Now()
+
+	for {
+		now := time.Now()
+
+		// the port layer sample only changes once per sample interval
+		if now.Sub(fetched) >= stats.interval() {
+			if sample, err = c.containerProxy.Stats(vc); err != nil {
+				return err
+			}
+
+			// the stream ends when the container stops
+			if sample == nil {
+				return nil
+			}
+
+			stats.update(sample)
+			fetched = now
+		}
+
+		var frame interface{} = stats.advance(now)
+		if apiVersion.LessThan("1.21") {
+			frame = stats.v1p20()
+		}
+
+		if err := enc.Encode(frame); err != nil {
+			return err
+		}
+
+		if !config.Stream {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-config.Stop:
+			return nil
+		}
+	}
+}
+
+// containerStats converts the rates sampled by the port layer into the
+// cumulative counters reported by the docker stats API
+type containerStats struct {
+	sample *models.ContainerStats
+	stats  types.StatsJSON
+}
+
+// update sets the sample used to advance the counters
+func (s *containerStats) update(sample *models.ContainerStats) {
+	s.sample = sample
+
+	// the first frame covers the interval of the first sample so that it has
+	// meaningful deltas when there is no stream
+	if s.stats.Read.IsZero() {
+		s.stats.Read = time.Unix(0, sample.Read).Add(-s.interval())
+	}
+}
+
+// interval returns the length of the current sample
+func (s *containerStats) interval() time.Duration {
+	if interval := swag.Int32Value(s.sample.Interval); interval > 0 {
+		return time.Duration(interval) * time.Second
+	}
+	return statsFrameInterval
+}
+
+// advance moves the counters forward to now at the rates of the current sample
+// and returns the resulting frame
+func (s *containerStats) advance(now time.Time) *types.StatsJSON {
+	if now.Before(s.stats.Read) {
+		now = s.stats.Read
+	}
+	elapsed := now.Sub(s.stats.Read)
+	seconds := elapsed.Seconds()
+
+	cpus := uint64(swag.Int32Value(s.sample.CPUCount))
+	if cpus == 0 {
+		cpus = 1
+	}
+
+	// usage is in hundredths of a percent of the capacity of all the vCPUs
+	usage := float64(swag.Int64Value(s.sample.CPUUsage)) / 10000
+	total := uint64(usage * float64(cpus) * float64(elapsed.Nanoseconds()))
+
+	pre := s.stats.CPUStats
+	cpu := types.CPUStats{
+		CPUUsage: types.CPUUsage{
+			TotalUsage:  pre.CPUUsage.TotalUsage + total,
+			PercpuUsage: make([]uint64, cpus),
+		},
+		SystemUsage: pre.SystemUsage + cpus*uint64(elapsed.Nanoseconds()),
+	}
+	for i := range cpu.CPUUsage.PercpuUsage {
+		if i < len(pre.CPUUsage.PercpuUsage) {
+			cpu.CPUUsage.PercpuUsage[i] = pre.CPUUsage.PercpuUsage[i]
+		}
+		cpu.CPUUsage.PercpuUsage[i] += total / cpus
+	}
+
+	// rates are in KBps
+	bytes := func(rate *int64) uint64 {
+		return uint64(float64(swag.Int64Value(rate)) * 1024 * seconds)
+	}
+
+	network := s.stats.Networks[statsNetwork]
+	network.RxBytes += bytes(s.sample.NetworkRx)
+	network.TxBytes += bytes(s.sample.NetworkTx)
+
+	var read, write uint64
+	if blkio := s.stats.BlkioStats.IoServiceBytesRecursive; len(blkio) == 2 {
+		read, write = blkio[0].Value, blkio[1].Value
+	}
+
+	memory := uint64(swag.Int64Value(s.sample.MemoryActive)) * 1024
+	maxMemory := s.stats.MemoryStats.MaxUsage
+	if memory > maxMemory {
+		maxMemory = memory
+	}
+
+	s.stats = types.StatsJSON{
+		Stats: types.Stats{
+			Read:        now,
+			PreCPUStats: pre,
+			CPUStats:    cpu,
+			MemoryStats: types.MemoryStats{
+				Usage:    memory,
+				MaxUsage: maxMemory,
+				Limit:    uint64(swag.Int64Value(s.sample.MemoryLimit)) * 1024,
+			},
+			BlkioStats: types.BlkioStats{
+				IoServiceBytesRecursive: []types.BlkioStatEntry{
+					{Op: "Read", Value: read + bytes(s.sample.DiskRead)},
+					{Op: "Write", Value: write + bytes(s.sample.DiskWrite)},
+				},
+			},
+		},
+		Networks: map[string]types.NetworkStats{
+			statsNetwork: network,
+		},
+	}
+
+	return &s.stats
+}
+
+// v1p20 returns the current frame in the format of API versions before 1.21
+func (s *containerStats) v1p20() *v1p20.StatsJSON {
+	return &v1p20.StatsJSON{
+		Stats:   s.stats.Stats,
+		Network: s.stats.Networks[statsNetwork],
+	}
 }
 
 // ContainerTop lists the processes running inside of the given
@@ -941,19 +1759,62 @@ func (c *Container) ContainerStats(name string, config *backend.ContainerStatsCo
 // is not found, or is not running, or if there are any problems
 // running ps, or parsing the output.
 func (c *Container) ContainerTop(name string, psArgs string) (*types.ContainerProcessList, error) {
-	return nil, fmt.Errorf("%s does not implement container.ContainerTop", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	// Look up the container name in the metadata cache to get long ID
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return nil, NotFoundError(name)
+	}
+
+	running, err := c.containerProxy.IsRunning(vc)
+	if err != nil {
+		return nil, err
+	}
+
+	if !running {
+		return nil, derr.NewRequestConflictError(fmt.Errorf("Container %s is not running", name))
+	}
+
+	if err = c.bindInteraction(vc); err != nil {
+		return nil, err
+	}
+
+	return c.containerProxy.ProcessList(vc.ContainerID, psArgs)
 }
 
 // Containers returns the list of containers to show given the user's filtering.
 func (c *Container) Containers(config *types.ContainerListOptions) ([]*types.Container, error) {
 
+	params, err := containerListParams(config)
+	if err != nil {
+		return nil, err
+	}
+	if params == nil {
+		// the filters cannot match any container
+		return []*types.Container{}, nil
+	}
+
+	// labels are kept in an annotation that only the docker personality decodes, so they
+	// are filtered here, which means the limit has to be applied here as well
+	limit := swag.Int64Value(params.Limit)
+	if config.Filter.Include("label") {
+		params.Limit = nil
+	}
+
 	// Get an API client to the portlayer
 	client := c.containerProxy.Client()
 
-	containme, err := client.Containers.GetContainerList(containers.NewGetContainerListParamsWithContext(ctx).WithAll(&config.All))
+	containme, err := client.Containers.GetContainerList(params)
 	if err != nil {
 		switch err := err.(type) {
 
+		case *containers.GetContainerListBadRequest:
+			return nil, BadRequestError(err.Payload.Message)
+
+		case *containers.GetContainerListNotFound:
+			return nil, derr.NewRequestNotFoundError(fmt.Errorf("%s", err.Payload.Message))
+
 		case *containers.GetContainerListInternalServerError:
 			return nil, fmt.Errorf("Error invoking GetContainerList: %s", err.Payload.Message)
 
@@ -964,6 +1825,14 @@ func (c *Container) Containers(config *types.ContainerListOptions) ([]*types.Con
 	// TODO: move to conversion function
 	containers := make([]*types.Container, 0, len(containme.Payload))
 	for _, t := range containme.Payload {
+		labels := &containertypes.Config{}
+		if err := labelsFromAnnotations(labels, t.ContainerConfig.Annotations); err != nil {
+			log.Errorf("Unable to decode the labels of container %s: %s", *t.ContainerConfig.ContainerID, err)
+		}
+		if !config.Filter.MatchKVList("label", labels.Labels) {
+			continue
+		}
+
 		cmd := strings.Join(t.ProcessConfig.ExecArgs, " ")
 		// the docker client expects the friendly name to be prefixed
 		// with a forward slash -- create a new slice and add here
@@ -981,6 +1850,9 @@ func (c *Container) Containers(config *types.ContainerListOptions) ([]*types.Con
 		}
 		// get the docker friendly status
 		_, status := dockerStatus(int(*t.ProcessConfig.ExitCode), *t.ProcessConfig.Status, *t.ContainerConfig.State, started, stopped)
+		if *t.ContainerConfig.State == "Running" {
+			status += healthStatus(t.ProcessConfig.Health)
+		}
 
 		ips, err := clientIPv4Addrs()
 		var ports []types.Port
@@ -1013,16 +1885,180 @@ func (c *Container) Containers(config *types.ContainerListOptions) ([]*types.Con
 			Status:  status,
 			Names:   names,
 			Command: cmd,
-			SizeRw:  *t.ContainerConfig.StorageSize,
 			Ports:   ports,
+			Labels:  labels.Labels,
+		}
+		if config.Size {
+			c.SizeRw = swag.Int64Value(t.ContainerConfig.StorageSize)
+			c.SizeRootFs = swag.Int64Value(t.ContainerConfig.RootFsSize)
 		}
 		containers = append(containers, c)
 	}
 	// sort on creation time
 	sort.Sort(sort.Reverse(containerByCreated(containers)))
+
+	if limit > 0 && int64(len(containers)) > limit {
+		containers = containers[:limit]
+	}
 	return containers, nil
 }
 
+// acceptedPsFilterTags are the filters docker ps supports
+var acceptedPsFilterTags = map[string]bool{
+	"ancestor": true,
+	"before":   true,
+	"exited":   true,
+	"id":       true,
+	"label":    true,
+	"name":     true,
+	"network":  true,
+	"since":    true,
+	"status":   true,
+	"volume":   true,
+}
+
+// containerStatuses are the statuses a docker container can have
+var containerStatuses = map[string]bool{
+	"created":    true,
+	"restarting": true,
+	"running":    true,
+	"removing":   true,
+	"paused":     true,
+	"exited":     true,
+	"dead":       true,
+}
+
+// portlayerStates maps the docker container statuses to the portlayer container states,
+// the statuses that are missing have no portlayer equivalent
+var portlayerStates = map[string]string{
+	"created":  "Created",
+	"running":  "Running",
+	"paused":   "Paused",
+	"exited":   "Stopped",
+	"removing": "Removing",
+}
+
+// containerListParams converts the docker list options to the parameters of the portlayer
+// list call, which filters the containers next to its cache. A nil result means that the
+// filters cannot match any container.
+func containerListParams(config *types.ContainerListOptions) (*containers.GetContainerListParams, error) {
+	if err := config.Filter.Validate(acceptedPsFilterTags); err != nil {
+		return nil, BadRequestError(err.Error())
+	}
+
+	params := containers.NewGetContainerListParamsWithContext(ctx).
+		WithID(config.Filter.Get("id")).
+		WithNetwork(config.Filter.Get("network")).
+		WithVolume(config.Filter.Get("volume")).
+		WithSize(swag.Bool(config.Size))
+
+	all := config.All
+
+	limit := config.Limit
+	if config.Latest {
+		limit = 1
+	}
+	if limit > 0 {
+		params.WithLimit(swag.Int64(int64(limit)))
+		all = true
+	}
+
+	// the portlayer does not know the leading slash docker gives container names
+	for _, name := range config.Filter.Get("name") {
+		switch {
+		case strings.HasPrefix(name, "^/"):
+			name = "^" + name[2:]
+		case strings.HasPrefix(name, "/"):
+			name = "^" + name[1:]
+		}
+		params.Name = append(params.Name, name)
+	}
+
+	for _, status := range config.Filter.Get("status") {
+		if !containerStatuses[status] {
+			return nil, BadRequestError(fmt.Sprintf("Unrecognised filter value for status: %s", status))
+		}
+		if state, ok := portlayerStates[status]; ok {
+			params.State = append(params.State, state)
+		}
+		all = true
+	}
+	if config.Filter.Include("status") && len(params.State) == 0 {
+		return nil, nil
+	}
+
+	for _, exited := range config.Filter.Get("exited") {
+		code, err := strconv.Atoi(exited)
+		if err != nil {
+			return nil, BadRequestError(fmt.Sprintf("Invalid filter value for exited: %s", exited))
+		}
+		params.Exited = append(params.Exited, int64(code))
+	}
+
+	// an image matches the containers created from it and from the images built on it
+	for _, ancestor := range config.Filter.Get("ancestor") {
+		image, err := cache.ImageCache().GetImage(ancestor)
+		if err != nil {
+			log.Warnf("Error while looking up image %s: %s", ancestor, err)
+			continue
+		}
+		params.Layer = append(params.Layer, descendantImageIDs(image.ID)...)
+	}
+	if config.Filter.Include("ancestor") && len(params.Layer) == 0 {
+		return nil, nil
+	}
+
+	since := config.Filter.Get("since")
+	if config.Since != "" {
+		since = append(since, config.Since)
+	}
+	before := config.Filter.Get("before")
+	if config.Before != "" {
+		before = append(before, config.Before)
+	}
+	for _, name := range since {
+		vc := cache.ContainerCache().GetContainer(name)
+		if vc == nil {
+			return nil, NotFoundError(name)
+		}
+		params.WithSince(swag.String(vc.ContainerID))
+		all = true
+	}
+	for _, name := range before {
+		vc := cache.ContainerCache().GetContainer(name)
+		if vc == nil {
+			return nil, NotFoundError(name)
+		}
+		params.WithBefore(swag.String(vc.ContainerID))
+		all = true
+	}
+
+	return params.WithAll(swag.Bool(all)), nil
+}
+
+// descendantImageIDs returns the ID of the image and those of the images in the cache that
+// were built on it
+func descendantImageIDs(id string) []string {
+	ids := []string{id}
+
+	for _, image := range cache.ImageCache().GetImages() {
+		for parent := image.Parent; parent != "" && image.ID != id; {
+			if parent == id {
+				ids = append(ids, image.ID)
+				break
+			}
+
+			p, err := cache.ImageCache().GetImage(parent)
+			if err != nil {
+				break
+			}
+			parent = p.Parent
+		}
+	}
+
+	return ids
+}
+
 // docker's container.attachBackend
 
 // ContainerAttach attaches to logs according to the config passed in. See ContainerAttachConfig.
@@ -1037,6 +2073,12 @@ func (c *Container) ContainerAttach(name string, ca *backend.ContainerAttachConf
 	}
 	id := vc.ContainerID
 
+	// the attach releases a held process once the output streams are in place
+	if ca.UseStdout || ca.UseStderr {
+		addPendingAttach(id)
+		defer removePendingAttach(id)
+	}
+
 	clStdin, clStdout, clStderr, err := ca.GetStreams()
 	if err != nil {
 		return InternalServerError("Unable to get stdio streams for calling client")
@@ -1085,7 +2127,14 @@ func (c *Container) ContainerAttach(name string, ca *backend.ContainerAttachConf
 		}
 	}
 
-	err = c.containerProxy.AttachStreams(context.Background(), vc, clStdin, clStdout, clStderr, ca)
+	ac := &AttachConfig{
+		ContainerAttachConfig: ca,
+		ID:                    id,
+		UseTty:                vc.Config.Tty,
+		StdinOnce:             vc.Config.StdinOnce,
+	}
+
+	err = c.containerProxy.AttachStreams(context.Background(), ac, clStdin, clStdout, clStderr)
 	if err != nil {
 		if _, ok := err.(DetachError); ok {
 			log.Infof("Detach detected, tearing down connection")
@@ -1159,6 +2208,9 @@ func setCreateConfigOptions(config, imageConfig *containertypes.Config) {
 	if config.WorkingDir == "" {
 		config.WorkingDir = imageConfig.WorkingDir
 	}
+	if config.User == "" {
+		config.User = imageConfig.User
+	}
 	if len(config.Entrypoint) == 0 {
 		config.Entrypoint = imageConfig.Entrypoint
 	}
@@ -1250,22 +2302,21 @@ func validateCreateConfig(config *types.ContainerCreateConfig) error {
 	if config.HostConfig != nil {
 		for _, pbs := range config.HostConfig.PortBindings {
 			for _, pb := range pbs {
-				if pb.HostIP != "" && pb.HostIP != "0.0.0.0" {
-					return InternalServerError("host IP is not supported for port bindings")
+				if pb.HostIP != "" && net.ParseIP(pb.HostIP) == nil {
+					return BadRequestError(fmt.Sprintf("invalid host IP %s for port bindings", pb.HostIP))
 				}
 
-				start, end, _ := nat.ParsePortRangeToInt(pb.HostPort)
-				if start != end {
-					return InternalServerError("host port ranges are not supported for port bindings")
+				if pb.HostPort != "" {
+					if _, _, err := nat.ParsePortRangeToInt(pb.HostPort); err != nil {
+						return BadRequestError(fmt.Sprintf("invalid host port %s for port bindings", pb.HostPort))
+					}
 				}
 			}
 		}
 	}
 
-	// TODO(jzt): users other than root are not currently supported
-	// We should check for USER in config.Config.Env once we support Dockerfiles.
-	if config.Config.User != "" && config.Config.User != "root" {
-		return InternalServerError("Failed to create container - users other than root are not currently supported")
+	if _, err := restartPolicyFromHostConfig(config.HostConfig); err != nil {
+		return BadRequestError(err.Error())
 	}
 
 	// https://github.com/vmware/vic/issues/1378
@@ -1283,6 +2334,103 @@ func validateCreateConfig(config *types.ContainerCreateConfig) error {
 	return nil
 }
 
+// updateConfigFromHostConfig validates the settings given to docker update and converts
+// those that apply to a container VM to the portlayer update config. A warning is returned
+// for each setting that is not supported.
+func updateConfigFromHostConfig(hostConfig *containertypes.HostConfig) (*models.ContainerUpdateConfig, []string, error) {
+	if hostConfig == nil {
+		return nil, nil, fmt.Errorf("No update configuration specified")
+	}
+
+	r := hostConfig.Resources
+	config := &models.ContainerUpdateConfig{
+		Resources: &models.ResourceConfig{},
+	}
+	var warnings []string
+
+	if r.CPUShares < 0 {
+		return nil, nil, fmt.Errorf("Invalid CPU shares %d", r.CPUShares)
+	}
+	if r.CPUShares > 0 {
+		config.Resources.CPUShares = swag.Int32(int32(r.CPUShares))
+	}
+
+	// the container VM is given as many virtual CPUs as there are CPUs in the set
+	if r.CpusetCpus != "" {
+		cpus, err := parsers.ParseUintList(r.CpusetCpus)
+		if err != nil || len(cpus) == 0 {
+			return nil, nil, fmt.Errorf("Invalid value %s for cpuset cpus", r.CpusetCpus)
+		}
+		config.Resources.CPUCount = swag.Int32(int32(len(cpus)))
+	}
+
+	if r.Memory > 0 && r.Memory < minMemoryLimit {
+		return nil, nil, fmt.Errorf("Minimum memory limit allowed is 4MB")
+	}
+	if r.MemoryReservation > 0 && r.MemoryReservation < minMemoryLimit {
+		return nil, nil, fmt.Errorf("Minimum memory reservation allowed is 4MB")
+	}
+	if r.Memory > 0 && r.MemoryReservation > 0 && r.Memory < r.MemoryReservation {
+		return nil, nil, fmt.Errorf("Minimum memory limit can not be less than memory reservation limit, see usage")
+	}
+
+	// the memory limit is rounded up to the granularity of VM memory
+	if r.Memory > 0 {
+		config.Resources.MemoryLimit = swag.Int64((r.Memory + minMemoryLimit - 1) / minMemoryLimit * (minMemoryLimit / units.MiB))
+	}
+	if r.MemoryReservation > 0 {
+		config.Resources.MemoryReservation = swag.Int64((r.MemoryReservation + units.MiB - 1) / units.MiB)
+	}
+
+	unsupported := []struct {
+		flag string
+		set  bool
+	}{
+		{"--blkio-weight", r.BlkioWeight != 0},
+		{"--cpu-period", r.CPUPeriod != 0},
+		{"--cpu-quota", r.CPUQuota != 0},
+		{"--cpuset-mems", r.CpusetMems != ""},
+		{"--kernel-memory", r.KernelMemory != 0},
+		{"--memory-swap", r.MemorySwap != 0},
+	}
+	for _, u := range unsupported {
+		if u.set {
+			warnings = append(warnings, fmt.Sprintf("%s does not support %s. Limitation discarded.", ProductName(), u.flag))
+		}
+	}
+
+	p, err := restartPolicyFromHostConfig(hostConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	config.RestartPolicy = p
+
+	return config, warnings, nil
+}
+
+// restartPolicyFromHostConfig validates the restart policy of the host config and converts
+// it to the portlayer restart policy, which is nil if no policy is given.
+func restartPolicyFromHostConfig(hostConfig *containertypes.HostConfig) (*models.RestartPolicy, error) {
+	p := hostConfig.RestartPolicy
+	if p.Name == "" {
+		return nil, nil
+	}
+
+	switch {
+	case p.Name != "no" && p.Name != "always" && p.Name != "unless-stopped" && p.Name != "on-failure":
+		return nil, fmt.Errorf("Invalid restart policy '%s'", p.Name)
+	case p.MaximumRetryCount < 0:
+		return nil, fmt.Errorf("Maximum restart count cannot be negative")
+	case p.MaximumRetryCount != 0 && !p.IsOnFailure():
+		return nil, fmt.Errorf("Maximum restart count cannot be used with restart policy '%s'", p.Name)
+	}
+
+	return &models.RestartPolicy{
+		Name:              swag.String(p.Name),
+		MaximumRetryCount: swag.Int32(int32(p.MaximumRetryCount)),
+	}, nil
+}
+
 func copyConfigOverrides(vc *viccontainer.VicContainer, config types.ContainerCreateConfig) {
 	// Copy the create overrides to our new container
 	vc.Name = config.Name
@@ -1314,6 +2462,23 @@ func clientIPv4Addrs() ([]netlink.Addr, error) {
 	return ips, nil
 }
 
+// checkClientAddr returns an error if the ip is not an address of the client interface,
+// as ports published on any other address cannot be reached
+func checkClientAddr(ip net.IP) error {
+	addrs, err := clientIPv4Addrs()
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("host IP %s is not an address of the %s interface", ip, clientIfaceName)
+}
+
 // returns port bindings as a slice of Docker Ports for return to the client
 // returns empty slice on error
 func portInformation(t *models.ContainerInfo, ips []netlink.Addr) []types.Port {
@@ -1335,7 +2500,7 @@ func portInformation(t *models.ContainerInfo, ips []netlink.Addr) []types.Port {
 	var resultPorts []types.Port
 	var err error
 
-	for _, port := range ports {
+	for j, port := range ports {
 		for portBindingPrivatePort, hostPortBindings := range portBindings {
 			portAndType := strings.SplitN(string(portBindingPrivatePort), "/", 2)
 			port.PrivatePort, err = strconv.Atoi(portAndType[0])
@@ -1352,6 +2517,15 @@ func portInformation(t *models.ContainerInfo, ips []netlink.Addr) []types.Port {
 					log.Infof("Got an error trying to convert public port number to an int")
 					continue
 				}
+
+				// a binding on a specific address is reported once, for that address
+				if hostIP := net.ParseIP(hostPortBindings[i].HostIP); hostIP != nil && !hostIP.IsUnspecified() {
+					if j > 0 {
+						continue
+					}
+					newport.IP = hostIP.String()
+				}
+
 				// sanity check -- sometimes these come back as 0 when no binding actually exists
 				// that doesn't make sense, so in that case we don't want to report these bindings
 				if newport.PublicPort != 0 && newport.PrivatePort != 0 {
@@ -1371,41 +2545,38 @@ func portInformation(t *models.ContainerInfo, ips []netlink.Addr) []types.Port {
 // backend.ContainerLogsConfig object we're given.
 //
 // returns:
-//	tail lines, since (in unix time), error
-func (c *Container) validateContainerLogsConfig(vc *viccontainer.VicContainer, config *backend.ContainerLogsConfig) (int64, int64, error) {
+//	tail lines, since and until (in unix time), error
+func (c *Container) validateContainerLogsConfig(vc *viccontainer.VicContainer, config *backend.ContainerLogsConfig, until string) (int64, int64, int64, error) {
 	if !(config.ShowStdout || config.ShowStderr) {
-		return 0, 0, fmt.Errorf("You must choose at least one stream")
-	}
-
-	unsupported := func(opt string) (int64, int64, error) {
-		return 0, 0, fmt.Errorf("%s does not yet support '--%s'", ProductName(), opt)
+		return 0, 0, 0, fmt.Errorf("You must choose at least one stream")
 	}
 
 	tailLines := int64(-1)
 	if config.Tail != "" && config.Tail != "all" {
 		n, err := strconv.ParseInt(config.Tail, 10, 64)
 		if err != nil {
-			return 0, 0, fmt.Errorf("error parsing tail option: %s", err)
+			return 0, 0, 0, fmt.Errorf("error parsing tail option: %s", err)
 		}
 		tailLines = n
 	}
 
-	var since time.Time
+	// since and until are passed on in unix seconds, with zero leaving the log unbounded
+	var since, untilTime int64
 	if config.Since != "" {
-		s, n, err := timetypes.ParseTimestamps(config.Since, 0)
+		s, _, err := timetypes.ParseTimestamps(config.Since, 0)
 		if err != nil {
-			return 0, 0, err
+			return 0, 0, 0, err
 		}
-		since = time.Unix(s, n)
-	}
-
-	if config.Timestamps {
-		return unsupported("timestamps")
+		since = s
 	}
 
-	if config.Since != "" {
-		return unsupported("since")
+	if until != "" {
+		u, _, err := timetypes.ParseTimestamps(until, 0)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		untilTime = u
 	}
 
-	return tailLines, since.Unix(), nil
+	return tailLines, since, untilTime, nil
 }
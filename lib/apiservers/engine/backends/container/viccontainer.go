@@ -15,7 +15,10 @@
 package container
 
 import (
+	"github.com/docker/engine-api/types"
 	containertypes "github.com/docker/engine-api/types/container"
+
+	"github.com/vmware/vic/lib/metadata"
 )
 
 // VicContainer is VIC's abridged version of Docker's container object.
@@ -25,6 +28,7 @@ type VicContainer struct {
 	ContainerID string
 	Config      *containertypes.Config //Working copy of config (with overrides from container create)
 	HostConfig  *containertypes.HostConfig
+	Healthcheck *metadata.HealthConfig // Health check merged from the image and the create request
 }
 
 // NewVicContainer returns a reference to a new VicContainer
@@ -33,3 +37,23 @@ func NewVicContainer() *VicContainer {
 		Config: &containertypes.Config{},
 	}
 }
+
+// VicExec is VIC's abridged version of Docker's exec config object.
+type VicExec struct {
+	ID          string
+	ContainerID string
+	Config      *types.ExecConfig
+
+	// Started is set once the exec has been handed to the portlayer. It is guarded by
+	// the exec cache lock.
+	Started bool
+}
+
+// NewVicExec returns a reference to a new VicExec
+func NewVicExec(id, containerID string, config *types.ExecConfig) *VicExec {
+	return &VicExec{
+		ID:          id,
+		ContainerID: containerID,
+		Config:      config,
+	}
+}
@@ -36,7 +36,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"syscall"
@@ -55,12 +57,15 @@ import (
 
 	"github.com/docker/docker/api/types/backend"
 	derr "github.com/docker/docker/errors"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/engine-api/types"
 	"github.com/docker/engine-api/types/container"
 	dnetwork "github.com/docker/engine-api/types/network"
 	"github.com/docker/engine-api/types/strslice"
 	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
 
 	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
 	viccontainer "github.com/vmware/vic/lib/apiservers/engine/backends/container"
@@ -71,6 +76,7 @@ import (
 	"github.com/vmware/vic/lib/apiservers/portlayer/client/logging"
 	"github.com/vmware/vic/lib/apiservers/portlayer/client/scopes"
 	"github.com/vmware/vic/lib/apiservers/portlayer/client/storage"
+	"github.com/vmware/vic/lib/apiservers/portlayer/client/tasks"
 	"github.com/vmware/vic/lib/apiservers/portlayer/models"
 	"github.com/vmware/vic/lib/metadata"
 	"github.com/vmware/vic/pkg/trace"
@@ -79,19 +85,30 @@ import (
 
 // VicContainerProxy interface
 type VicContainerProxy interface {
-	CreateContainerHandle(imageID string, config types.ContainerCreateConfig) (string, string, error)
+	CreateContainerHandle(imageID string, config types.ContainerCreateConfig, healthcheck *metadata.HealthConfig) (string, string, error)
 	AddContainerToScope(handle string, config types.ContainerCreateConfig) (string, error)
 	AddVolumesToContainer(handle string, config types.ContainerCreateConfig) (string, error)
 	AddLoggingToContainer(handle string, config types.ContainerCreateConfig) (string, error)
 	AddInteractionToContainer(handle string, config types.ContainerCreateConfig) (string, error)
+	BindInteraction(handle string, name string) (string, error)
 	CommitContainerHandle(handle, imageID string) error
-	StreamContainerLogs(name string, out io.Writer, started chan struct{}, showTimestamps bool, followLogs bool, since int64, tailLines int64) error
+	CreateExecTask(handle string, vc *viccontainer.VicContainer, ec *viccontainer.VicExec, hold bool) (string, error)
+	InspectExecTask(handle string, id string) (*models.TaskInspectResponse, error)
+	StreamContainerLogs(name string, stdout, stderr io.Writer, started chan struct{}, showTimestamps bool, followLogs bool, since, until, tailLines int64) error
+
+	StatPath(id string, path string) (*types.ContainerPathStat, error)
+	ArchiveExport(id string, path string, out io.Writer) error
+	ArchiveImport(id string, path string, noOverwriteDirNonDir bool, tar io.Reader) error
+	Export(id string, out io.Writer) error
+	Changes(id string) ([]archive.Change, error)
+	Stats(vc *viccontainer.VicContainer) (*models.ContainerStats, error)
+	ProcessList(id string, psArgs string) (*types.ContainerProcessList, error)
 
 	IsRunning(vc *viccontainer.VicContainer) (bool, error)
 	Wait(vc *viccontainer.VicContainer, timeout time.Duration) (exitCode int32, processStatus string, containerState string, reterr error)
 	Signal(vc *viccontainer.VicContainer, sig uint64) error
-	Resize(vc *viccontainer.VicContainer, height, width int32) error
-	AttachStreams(ctx context.Context, vc *viccontainer.VicContainer, clStdin io.ReadCloser, clStdout, clStderr io.Writer, ca *backend.ContainerAttachConfig) error
+	Resize(id string, height, width int32) error
+	AttachStreams(ctx context.Context, ac *AttachConfig, clStdin io.ReadCloser, clStdout, clStderr io.Writer) error
 
 	Client() *client.PortLayer
 }
@@ -103,6 +120,21 @@ type ContainerProxy struct {
 	portlayerName string
 }
 
+// AttachConfig wraps backend.ContainerAttachConfig and adds the details of the
+// session being attached to
+type AttachConfig struct {
+	*backend.ContainerAttachConfig
+
+	// ID of the session to attach to - the container ID for the primary process, the exec ID otherwise
+	ID string
+
+	// Tty is set if the session has a tty allocated
+	UseTty bool
+
+	// StdinOnce is set if stdin should be closed once the attach completes
+	StdinOnce bool
+}
+
 type volumeFields struct {
 	ID    string
 	Dest  string
@@ -115,6 +147,7 @@ const (
 	attachPLAttemptDiff    time.Duration = 10 * time.Second
 	attachPLAttemptTimeout time.Duration = attachAttemptTimeout - attachPLAttemptDiff //timeout for the portlayer before ditching an attempt
 	attachRequestTimeout   time.Duration = 2 * time.Hour                              //timeout to hold onto the attach connection
+	attachHoldTimeout      time.Duration = attachAttemptTimeout                       //timeout for the tether to hold an attached process waiting for the attach
 	attachStdinInitString                = "v1c#>"
 	swaggerSubstringEOF                  = "EOF"
 	forceLogType                         = "json-file" //Use in inspect to allow docker logs to work
@@ -126,6 +159,9 @@ const (
 	DriverArgImageKey     = "Image"
 )
 
+// defaultDetachKeys is the docker default detach sequence for a tty: ctrl-p ctrl-q
+var defaultDetachKeys = []byte{16, 17}
+
 // NewContainerProxy creates a new ContainerProxy
 func NewContainerProxy(plClient *client.PortLayer, portlayerAddr string, portlayerName string) *ContainerProxy {
 	return &ContainerProxy{client: plClient, portlayerAddr: portlayerAddr, portlayerName: portlayerName}
@@ -139,7 +175,7 @@ func (c *ContainerProxy) Client() *client.PortLayer {
 //
 // returns:
 //	(containerID, containerHandle, error)
-func (c *ContainerProxy) CreateContainerHandle(imageID string, config types.ContainerCreateConfig) (string, string, error) {
+func (c *ContainerProxy) CreateContainerHandle(imageID string, config types.ContainerCreateConfig, healthcheck *metadata.HealthConfig) (string, string, error) {
 	defer trace.End(trace.Begin(imageID))
 
 	if c.client == nil {
@@ -157,6 +193,7 @@ func (c *ContainerProxy) CreateContainerHandle(imageID string, config types.Cont
 	}
 
 	plCreateParams := dockerContainerCreateParamsToPortlayer(config, imageID, host)
+	plCreateParams.CreateConfig.Healthcheck = toModelsHealthConfig(healthcheck)
 	createResults, err := c.client.Containers.Create(plCreateParams)
 	if err != nil {
 		if _, ok := err.(*containers.CreateNotFound); ok {
@@ -363,6 +400,120 @@ func (c *ContainerProxy) AddInteractionToContainer(handle string, config types.C
 	return handle, nil
 }
 
+// BindInteraction enables the interaction (attach) connection for the container referenced
+// by the handle.
+//
+// returns:
+//	modified handle
+func (c *ContainerProxy) BindInteraction(handle string, name string) (string, error) {
+	defer trace.End(trace.Begin(handle))
+
+	if c.client == nil {
+		return "", InternalServerError("ContainerProxy.BindInteraction failed to get the portlayer client")
+	}
+
+	bind, err := c.client.Interaction.InteractionBind(interaction.NewInteractionBindParamsWithContext(ctx).
+		WithConfig(&models.InteractionBindConfig{
+			Handle: handle,
+		}))
+	if err != nil {
+		switch err := err.(type) {
+		case *interaction.InteractionBindInternalServerError:
+			return "", InternalServerError(err.Payload.Message)
+		default:
+			return "", InternalServerError(err.Error())
+		}
+	}
+	handle, ok := bind.Payload.Handle.(string)
+	if !ok {
+		return "", InternalServerError(fmt.Sprintf("Type assertion failed for %#+v", handle))
+	}
+
+	return handle, nil
+}
+
+// CreateExecTask adds an exec session to the container referenced by the handle. The
+// session is launched when the handle is committed, held until an attach releases it if
+// hold is set.
+//
+// returns:
+//	modified handle
+func (c *ContainerProxy) CreateExecTask(handle string, vc *viccontainer.VicContainer, ec *viccontainer.VicExec, hold bool) (string, error) {
+	defer trace.End(trace.Begin(ec.ID))
+
+	if c.client == nil {
+		return "", InternalServerError("ContainerProxy.CreateExecTask failed to get the portlayer client")
+	}
+
+	config := ec.Config
+	if len(config.Cmd) == 0 {
+		return "", BadRequestError("no exec command specified")
+	}
+
+	// exec inherits the environment and working directory of the container
+	joinconfig := &models.TaskJoinConfig{
+		Handle:     handle,
+		ID:         ec.ID,
+		Path:       config.Cmd[0],
+		Args:       config.Cmd,
+		Env:        vc.Config.Env,
+		WorkingDir: swag.String(vc.Config.WorkingDir),
+		User:       swag.String(config.User),
+		Tty:        swag.Bool(config.Tty),
+		Attach:     swag.Bool(config.AttachStdin || config.AttachStdout || config.AttachStderr),
+		OpenStdin:  swag.Bool(config.AttachStdin),
+	}
+
+	if hold {
+		joinconfig.AttachTimeout = swag.Int64(int64(attachHoldTimeout))
+	}
+
+	response, err := c.client.Tasks.TaskJoin(tasks.NewTaskJoinParamsWithContext(ctx).WithConfig(joinconfig))
+	if err != nil {
+		switch err := err.(type) {
+		case *tasks.TaskJoinNotFound:
+			return "", NotFoundError(err.Payload.Message)
+		case *tasks.TaskJoinInternalServerError:
+			return "", InternalServerError(err.Payload.Message)
+		default:
+			return "", InternalServerError(err.Error())
+		}
+	}
+	handle, ok := response.Payload.Handle.(string)
+	if !ok {
+		return "", InternalServerError(fmt.Sprintf("Type assertion failed for %#+v", handle))
+	}
+
+	return handle, nil
+}
+
+// InspectExecTask returns the state of the exec session in the container referenced by the handle
+func (c *ContainerProxy) InspectExecTask(handle string, id string) (*models.TaskInspectResponse, error) {
+	defer trace.End(trace.Begin(id))
+
+	if c.client == nil {
+		return nil, InternalServerError("ContainerProxy.InspectExecTask failed to get the portlayer client")
+	}
+
+	response, err := c.client.Tasks.TaskInspect(tasks.NewTaskInspectParamsWithContext(ctx).
+		WithConfig(&models.TaskInspectConfig{
+			Handle: handle,
+			ID:     id,
+		}))
+	if err != nil {
+		switch err := err.(type) {
+		case *tasks.TaskInspectNotFound:
+			return nil, derr.NewRequestNotFoundError(fmt.Errorf("No such exec instance: %s", id))
+		case *tasks.TaskInspectInternalServerError:
+			return nil, InternalServerError(err.Payload.Message)
+		default:
+			return nil, InternalServerError(err.Error())
+		}
+	}
+
+	return response.Payload, nil
+}
+
 // CommitContainerHandle commits any changes to container handle.
 func (c *ContainerProxy) CommitContainerHandle(handle, imageID string) error {
 	defer trace.End(trace.Begin(handle))
@@ -385,8 +536,8 @@ func (c *ContainerProxy) CommitContainerHandle(handle, imageID string) error {
 }
 
 // StreamContainerLogs reads the log stream from the portlayer rest server and writes
-// it directly to the io.Writer that is passed in.
-func (c *ContainerProxy) StreamContainerLogs(name string, out io.Writer, started chan struct{}, showTimestamps bool, followLogs bool, since int64, tailLines int64) error {
+// the standard output and standard error of the container to the writers passed in.
+func (c *ContainerProxy) StreamContainerLogs(name string, stdout, stderr io.Writer, started chan struct{}, showTimestamps bool, followLogs bool, since, until, tailLines int64) error {
 	defer trace.End(trace.Begin(""))
 
 	plClient, transport := c.createNewAttachClientWithTimeouts(attachConnectTimeout, 0, attachAttemptTimeout)
@@ -398,9 +549,24 @@ func (c *ContainerProxy) StreamContainerLogs(name string, out io.Writer, started
 		WithFollow(&followLogs).
 		WithTimestamp(&showTimestamps).
 		WithSince(&since).
+		WithUntil(&until).
 		WithTaillines(&tailLines)
-	_, err := plClient.Containers.GetContainerLogs(params, out)
-	if err != nil {
+
+	// the port layer keeps standard output and standard error apart in a multiplexed
+	// stream, which is split here so each can be presented as the caller asked
+	pr, pw := io.Pipe()
+	errs := make(chan error, 1)
+	go func() {
+		_, err := plClient.Containers.GetContainerLogs(params, pw)
+		pw.Close()
+		errs <- err
+	}()
+
+	_, cerr := stdcopy.StdCopy(stdout, stderr, pr)
+	// unblock the request if the copy stopped early
+	pr.Close()
+
+	if err := <-errs; err != nil && cerr == nil {
 		switch err := err.(type) {
 		case *containers.GetContainerLogsNotFound:
 			return NotFoundError(fmt.Sprintf("No such container: %s", name))
@@ -417,10 +583,225 @@ func (c *ContainerProxy) StreamContainerLogs(name string, out io.Writer, started
 		}
 	}
 
+	if cerr != nil {
+		return InternalServerError(fmt.Sprintf("Error streaming the logs: %s", cerr))
+	}
+
+	return nil
+}
+
+// StatPath returns the details of the path in the container filesystem
+func (c *ContainerProxy) StatPath(id string, path string) (*types.ContainerPathStat, error) {
+	defer trace.End(trace.Begin(id))
+
+	if c.client == nil {
+		return nil, InternalServerError("ContainerProxy.StatPath failed to get the portlayer client")
+	}
+
+	params := interaction.NewContainerStatPathParamsWithContext(ctx).
+		WithID(id).
+		WithPath(path)
+	resp, err := c.client.Interaction.ContainerStatPath(params)
+	if err != nil {
+		switch err := err.(type) {
+		case *interaction.ContainerStatPathNotFound:
+			return nil, derr.NewRequestNotFoundError(fmt.Errorf("Could not find the file %s in container %s", path, id))
+		case *interaction.ContainerStatPathInternalServerError:
+			return nil, InternalServerError(err.Payload.Message)
+		default:
+			return nil, InternalServerError(err.Error())
+		}
+	}
+
+	stat := &types.ContainerPathStat{
+		Name:       resp.Payload.Name,
+		Size:       swag.Int64Value(resp.Payload.Size),
+		Mtime:      time.Unix(0, swag.Int64Value(resp.Payload.Mtime)),
+		LinkTarget: swag.StringValue(resp.Payload.LinkTarget),
+	}
+	if resp.Payload.Mode != nil {
+		stat.Mode = os.FileMode(*resp.Payload.Mode)
+	}
+
+	return stat, nil
+}
+
+// ArchiveExport writes a tar archive of the path in the container filesystem to out
+func (c *ContainerProxy) ArchiveExport(id string, path string, out io.Writer) error {
+	defer trace.End(trace.Begin(id))
+
+	plClient, transport := c.createNewAttachClientWithTimeouts(attachConnectTimeout, 0, attachAttemptTimeout)
+	defer transport.Close()
+
+	params := interaction.NewContainerArchiveExportParamsWithContext(ctx).
+		WithID(id).
+		WithPath(path)
+	_, err := plClient.Interaction.ContainerArchiveExport(params, out)
+	if err != nil {
+		switch err := err.(type) {
+		case *interaction.ContainerArchiveExportNotFound:
+			return NotFoundError(id)
+		case *interaction.ContainerArchiveExportInternalServerError:
+			return InternalServerError(err.Payload.Message)
+		default:
+			//Check for EOF.  Since the connection, transport, and data handling are
+			//encapsulated inisde of Swagger, we can only detect EOF by checking the
+			//error string
+			if strings.Contains(err.Error(), swaggerSubstringEOF) {
+				return nil
+			}
+			return InternalServerError(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Export writes a tar archive of the container filesystem to out
+func (c *ContainerProxy) Export(id string, out io.Writer) error {
+	defer trace.End(trace.Begin(id))
+
+	plClient, transport := c.createNewAttachClientWithTimeouts(attachConnectTimeout, 0, attachAttemptTimeout)
+	defer transport.Close()
+
+	params := interaction.NewContainerExportParamsWithContext(ctx).WithID(id)
+	_, err := plClient.Interaction.ContainerExport(params, out)
+	if err != nil {
+		switch err := err.(type) {
+		case *interaction.ContainerExportNotFound:
+			return NotFoundError(id)
+		case *interaction.ContainerExportInternalServerError:
+			return InternalServerError(err.Payload.Message)
+		default:
+			// as for ArchiveExport, EOF can only be detected from the error string
+			if strings.Contains(err.Error(), swaggerSubstringEOF) {
+				return nil
+			}
+			return InternalServerError(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Changes returns the paths the container added, changed or deleted in the filesystem of its image
+func (c *ContainerProxy) Changes(id string) ([]archive.Change, error) {
+	defer trace.End(trace.Begin(id))
+
+	if c.client == nil {
+		return nil, InternalServerError("ContainerProxy.Changes failed to create a portlayer client")
+	}
+
+	resp, err := c.client.Storage.ContainerChanges(storage.NewContainerChangesParamsWithContext(ctx).WithID(id))
+	if err != nil {
+		switch err := err.(type) {
+		case *storage.ContainerChangesNotFound:
+			return nil, NotFoundError(id)
+		case *storage.ContainerChangesConflict:
+			return nil, derr.NewRequestConflictError(fmt.Errorf("%s does not support diff of running containers, stop container %s first", ProductName(), id))
+		case *storage.ContainerChangesDefault:
+			return nil, InternalServerError(err.Payload.Message)
+		default:
+			return nil, InternalServerError(err.Error())
+		}
+	}
+
+	changes := make([]archive.Change, len(resp.Payload))
+	for i, change := range resp.Payload {
+		changes[i] = archive.Change{
+			Path: change.Path,
+			Kind: archive.ChangeType(change.Kind),
+		}
+	}
+
+	return changes, nil
+}
+
+// ArchiveImport extracts the tar archive into the directory at path in the container filesystem
+func (c *ContainerProxy) ArchiveImport(id string, path string, noOverwriteDirNonDir bool, tar io.Reader) error {
+	defer trace.End(trace.Begin(id))
+
+	plClient, transport := c.createNewAttachClientWithTimeouts(attachConnectTimeout, 0, attachAttemptTimeout)
+	defer transport.Close()
+
+	params := interaction.NewContainerArchiveImportParamsWithContext(ctx).
+		WithID(id).
+		WithPath(path).
+		WithNoOverwriteDirNonDir(&noOverwriteDirNonDir).
+		WithArchive(ioutil.NopCloser(tar))
+	_, err := plClient.Interaction.ContainerArchiveImport(params)
+	if err != nil {
+		switch err := err.(type) {
+		case *interaction.ContainerArchiveImportNotFound:
+			return NotFoundError(id)
+		case *interaction.ContainerArchiveImportInternalServerError:
+			return InternalServerError(err.Payload.Message)
+		default:
+			return InternalServerError(err.Error())
+		}
+	}
+
 	return nil
 }
 
 // IsRunning returns true if the given container is running
+// Stats returns the most recent performance sample of the container, or nil if
+// the container is not running
+func (c *ContainerProxy) Stats(vc *viccontainer.VicContainer) (*models.ContainerStats, error) {
+	defer trace.End(trace.Begin(vc.ContainerID))
+
+	if c.client == nil {
+		return nil, InternalServerError("ContainerProxy.Stats failed to get the portlayer client")
+	}
+
+	resp, err := c.client.Containers.GetContainerStats(containers.NewGetContainerStatsParamsWithContext(ctx).WithID(vc.ContainerID))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.GetContainerStatsNotFound:
+			return nil, NotFoundError(vc.ContainerID)
+		case *containers.GetContainerStatsConflict:
+			return nil, nil
+		case *containers.GetContainerStatsInternalServerError:
+			return nil, InternalServerError(err.Payload.Message)
+		default:
+			return nil, InternalServerError(err.Error())
+		}
+	}
+
+	return resp.Payload, nil
+}
+
+// ProcessList returns the processes running in the container, formatted as for the ps arguments
+func (c *ContainerProxy) ProcessList(id string, psArgs string) (*types.ContainerProcessList, error) {
+	defer trace.End(trace.Begin(id))
+
+	if c.client == nil {
+		return nil, InternalServerError("ContainerProxy.ProcessList failed to get the portlayer client")
+	}
+
+	params := interaction.NewContainerProcessListParamsWithContext(ctx).
+		WithID(id).
+		WithArgs(swag.String(psArgs))
+	resp, err := c.client.Interaction.ContainerProcessList(params)
+	if err != nil {
+		switch err := err.(type) {
+		case *interaction.ContainerProcessListNotFound:
+			return nil, NotFoundError(id)
+		case *interaction.ContainerProcessListConflict:
+			return nil, derr.NewRequestConflictError(fmt.Errorf("Container %s is not running", id))
+		case *interaction.ContainerProcessListInternalServerError:
+			return nil, InternalServerError(err.Payload.Message)
+		default:
+			return nil, InternalServerError(err.Error())
+		}
+	}
+
+	return &types.ContainerProcessList{
+		Titles:    resp.Payload.Titles,
+		Processes: resp.Payload.Processes,
+	}, nil
+}
+
 func (c *ContainerProxy) IsRunning(vc *viccontainer.VicContainer) (bool, error) {
 	defer trace.End(trace.Begin(""))
 
@@ -558,8 +939,8 @@ func (c *ContainerProxy) createNewAttachClientWithTimeouts(connectTimeout, respo
 	return plClient, transport
 }
 
-func (c *ContainerProxy) Resize(vc *viccontainer.VicContainer, height, width int32) error {
-	defer trace.End(trace.Begin(vc.ContainerID))
+func (c *ContainerProxy) Resize(id string, height, width int32) error {
+	defer trace.End(trace.Begin(id))
 
 	if c.client == nil {
 		return derr.NewErrorWithStatusCode(fmt.Errorf("ContainerProxy failed to create a portlayer client"),
@@ -567,14 +948,14 @@ func (c *ContainerProxy) Resize(vc *viccontainer.VicContainer, height, width int
 	}
 
 	plResizeParam := interaction.NewContainerResizeParamsWithContext(ctx).
-		WithID(vc.ContainerID).
+		WithID(id).
 		WithHeight(height).
 		WithWidth(width)
 
 	_, err := c.client.Interaction.ContainerResize(plResizeParam)
 	if err != nil {
 		if _, isa := err.(*interaction.ContainerResizeNotFound); isa {
-			return ResourceNotFoundError(vc.ContainerID, "interaction connection")
+			return ResourceNotFoundError(id, "interaction connection")
 		}
 
 		// If we get here, most likely something went wrong with the port layer API server
@@ -587,8 +968,10 @@ func (c *ContainerProxy) Resize(vc *viccontainer.VicContainer, height, width int
 // attacheStreams takes the the hijacked connections from the calling client and attaches
 // them to the 3 streams from the portlayer's rest server.
 // clStdin, clStdout, clStderr are the hijacked connection
-func (c *ContainerProxy) AttachStreams(ctx context.Context, vc *viccontainer.VicContainer, clStdin io.ReadCloser, clStdout, clStderr io.Writer, ca *backend.ContainerAttachConfig) error {
-	defer clStdin.Close()
+func (c *ContainerProxy) AttachStreams(ctx context.Context, ac *AttachConfig, clStdin io.ReadCloser, clStdout, clStderr io.Writer) error {
+	if clStdin != nil {
+		defer clStdin.Close()
+	}
 
 	// Cancel will close the child connections.
 	ctx, cancel := context.WithCancel(ctx)
@@ -601,15 +984,15 @@ func (c *ContainerProxy) AttachStreams(ctx context.Context, vc *viccontainer.Vic
 	plClient, transport := createNewAttachClientWithTimeouts(attachConnectTimeout, 0, attachAttemptTimeout)
 	defer transport.Close()
 
-	if ca.UseStdin {
+	if ac.UseStdin {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := copyStdIn(ctx, plClient, vc, clStdin, ca.DetachKeys)
+			err := copyStdIn(ctx, plClient, ac, clStdin)
 			if err != nil {
-				log.Errorf("container attach: stdin (%s): %s", vc.ContainerID, err.Error())
+				log.Errorf("container attach: stdin (%s): %s", ac.ID, err.Error())
 			} else {
-				log.Infof("container attach: stdin (%s) done: %s", vc.ContainerID)
+				log.Infof("container attach: stdin (%s) done: %s", ac.ID)
 			}
 
 			// no need to take action if we are canceled
@@ -624,15 +1007,15 @@ func (c *ContainerProxy) AttachStreams(ctx context.Context, vc *viccontainer.Vic
 		}()
 	}
 
-	if ca.UseStdout {
+	if ac.UseStdout {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := copyStdOut(ctx, plClient, attachAttemptTimeout, vc, clStdout)
+			err := copyStdOut(ctx, plClient, attachAttemptTimeout, ac, clStdout)
 			if err != nil {
-				log.Errorf("container attach: stdout (%s): %s", vc.ContainerID, err.Error())
+				log.Errorf("container attach: stdout (%s): %s", ac.ID, err.Error())
 			} else {
-				log.Infof("container attach: stdout (%s) done: %s", vc.ContainerID)
+				log.Infof("container attach: stdout (%s) done: %s", ac.ID)
 			}
 
 			// no need to take action if we are canceled
@@ -647,15 +1030,15 @@ func (c *ContainerProxy) AttachStreams(ctx context.Context, vc *viccontainer.Vic
 		}()
 	}
 
-	if ca.UseStderr {
+	if ac.UseStderr {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := copyStdErr(ctx, plClient, vc, clStderr)
+			err := copyStdErr(ctx, plClient, ac, clStderr)
 			if err != nil {
-				log.Errorf("container attach: stderr (%s): %s", vc.ContainerID, err.Error())
+				log.Errorf("container attach: stderr (%s): %s", ac.ID, err.Error())
 			} else {
-				log.Infof("container attach: stderr (%s) done: %s", vc.ContainerID)
+				log.Infof("container attach: stderr (%s) done: %s", ac.ID)
 			}
 
 			// no need to take action if we are canceled
@@ -670,13 +1053,26 @@ func (c *ContainerProxy) AttachStreams(ctx context.Context, vc *viccontainer.Vic
 		}()
 	}
 
+	// The tether holds the process of a container created for attach until the output streams
+	// are in place, so release it now. The streams are bound to the port layer connection before
+	// the release is accepted, so anything written before the copies are reading is buffered.
+	if ac.UseStdout || ac.UseStderr {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := unblockAttach(ctx, plClient, ac); err != nil {
+				log.Errorf("container attach: unblock (%s): %s", ac.ID, err.Error())
+			}
+		}()
+	}
+
 	// Wait for all stream copy to exit
 	wg.Wait()
 
 	// close the channel so that we don't leak (if there is an error)/or get blocked (if there are no errors)
 	close(errors)
 
-	log.Infof("cleaned up connections to %s. Checking errors", vc.ContainerID)
+	log.Infof("cleaned up connections to %s. Checking errors", ac.ID)
 	for err := range errors {
 		if err != nil {
 			// check if we got DetachError
@@ -747,6 +1143,9 @@ func dockerContainerCreateParamsToPortlayer(cc types.ContainerCreateConfig, laye
 	// working dir
 	config.WorkingDir = swag.String(cc.Config.WorkingDir)
 
+	// user, which is resolved in the container as it is started
+	config.User = swag.String(cc.Config.User)
+
 	// attach
 	config.Attach = swag.Bool(cc.Config.AttachStdin || cc.Config.AttachStdout || cc.Config.AttachStderr)
 
@@ -756,6 +1155,9 @@ func dockerContainerCreateParamsToPortlayer(cc types.ContainerCreateConfig, laye
 	// container stop signal
 	config.StopSignal = swag.String(cc.Config.StopSignal)
 
+	// restart policy, which has been validated with the rest of the create config
+	config.RestartPolicy, _ = restartPolicyFromHostConfig(cc.HostConfig)
+
 	// Stuff the Docker labels into VIC container annotations
 	annotationsFromLabels(config, cc.Config.Labels)
 
@@ -985,6 +1387,11 @@ func ContainerInfoToDockerContainerInspect(vc *viccontainer.VicContainer, info *
 			if containerState.Status == "running" {
 				containerState.Running = true
 			}
+			// a paused container is still running as far as docker is concerned
+			if containerState.Status == "paused" {
+				containerState.Running = true
+				containerState.Paused = true
+			}
 		}
 		if info.ContainerConfig.LayerID != nil {
 			inspectJSON.Image = *info.ContainerConfig.LayerID
@@ -1028,17 +1435,34 @@ func hostConfigFromContainerInfo(vc *viccontainer.VicContainer, info *models.Con
 	//
 	// The values we fill out below is an abridged list of the original struct.
 	resourceConfig := container.Resources{
-	// Applicable to all platforms
-	//			CPUShares int64 `json:"CpuShares"` // CPU shares (relative weight vs. other containers)
-	//			Memory    int64 // Memory limit (in bytes)
-
 	//			// Applicable to UNIX platforms
 	//			DiskQuota            int64           // Disk limit (in bytes)
 	}
 
+	if r := info.ContainerConfig.Resources; r != nil {
+		resourceConfig.CPUShares = int64(swag.Int32Value(r.CPUShares))
+		resourceConfig.Memory = swag.Int64Value(r.MemoryLimit) * units.MiB
+		resourceConfig.MemoryReservation = swag.Int64Value(r.MemoryReservation) * units.MiB
+
+		// the virtual CPUs of the container VM are presented as a cpuset
+		if cpus := swag.Int32Value(r.CPUCount); cpus > 1 {
+			resourceConfig.CpusetCpus = fmt.Sprintf("0-%d", cpus-1)
+		} else if cpus == 1 {
+			resourceConfig.CpusetCpus = "0"
+		}
+	}
+
 	hostConfig.VolumeDriver = portlayerName
 	hostConfig.Resources = resourceConfig
 
+	// containers without a policy in the portlayer keep the one they were created with
+	if p := info.ContainerConfig.RestartPolicy; p != nil && swag.StringValue(p.Name) != "" {
+		hostConfig.RestartPolicy = container.RestartPolicy{
+			Name:              swag.StringValue(p.Name),
+			MaximumRetryCount: int(swag.Int32Value(p.MaximumRetryCount)),
+		}
+	}
+
 	if len(info.ScopeConfig) > 0 {
 		if info.ScopeConfig[0].DNS != nil {
 			hostConfig.DNS = info.ScopeConfig[0].DNS
@@ -1218,7 +1642,7 @@ func portMapFromVicContainer(vc *viccontainer.VicContainer) nat.PortMap {
 			for _, binding := range portbindings {
 				nb := binding
 
-				// Check host IP.  VIC only support 0.0.0.0
+				// Bindings without a host IP are on all addresses
 				if nb.HostIP == "" {
 					nb.HostIP = "0.0.0.0"
 				}
@@ -1350,67 +1774,58 @@ func createNewAttachClientWithTimeouts(connectTimeout, responseTimeout, response
 	return plClient, transport
 }
 
-func copyStdIn(ctx context.Context, pl *client.PortLayer, vc *viccontainer.VicContainer, clStdin io.ReadCloser, keys []byte) error {
-	// Pipe for stdin so we can interject and watch the input streams for detach keys.
+func copyStdIn(ctx context.Context, pl *client.PortLayer, ac *AttachConfig, clStdin io.ReadCloser) error {
+	// Pipe for stdin so the init bytes can be written ahead of the client stdin
 	stdinReader, stdinWriter := io.Pipe()
 	defer stdinWriter.Close()
 
-	var detach bool
-
 	go func() {
 		defer stdinReader.Close()
 
-		// Copy the stdin from the CLI and write to a pipe.  We need to do this so we can
-		// watch the stdin stream for the detach keys.
-		var err error
-
 		// Write some init bytes into the pipe to force Swagger to make the initial
 		// call to the portlayer, prior to any user input in whatever attach client
 		// he/she is using.
 		log.Debugf("copyStdIn writing primer bytes")
 		stdinWriter.Write([]byte(attachStdinInitString))
-		if vc.Config.Tty {
-			_, err = copyEscapable(stdinWriter, clStdin, keys)
-		} else {
-			_, err = io.Copy(stdinWriter, clStdin)
-		}
-
-		if err != nil {
-			if _, ok := err.(DetachError); ok {
-				log.Infof("stdin detach detected")
-				detach = true
-			} else {
-				log.Errorf("stdin err: %s", err)
-			}
+		// the pipe is closed under the copy if the client detaches
+		if _, err := io.Copy(stdinWriter, clStdin); err != nil && err != io.ErrClosedPipe {
+			log.Errorf("stdin err: %s", err)
 		}
 	}()
 
+	// The portlayer watches the stream for the detach keys. As in docker, a tty defaults
+	// to ctrl-p ctrl-q while other sessions only detach on keys given for the attach.
+	keys := ac.DetachKeys
+	if len(keys) == 0 && ac.UseTty {
+		keys = defaultDetachKeys
+	}
+
+	// As in docker, the stdin of a tty is left open so that detaching doesn't end the shell
+	stdinOnce := ac.StdinOnce && !ac.UseTty
+
 	// Swagger wants an io.reader so give it the reader pipe.  Also, the swagger call
 	// to set the stdin is synchronous so we need to run in a goroutine
-	setStdinParams := interaction.NewContainerSetStdinParamsWithContext(ctx).WithID(vc.ContainerID)
-	setStdinParams = setStdinParams.WithRawStream(stdinReader)
-
-	_, err := pl.Interaction.ContainerSetStdin(setStdinParams)
-	if vc.Config.StdinOnce && !vc.Config.Tty {
-		// Close the stdin connection.  Mimicing Docker's behavior.
-		// FIXME: If we close this stdin connection.  The portlayer does
-		// not really close stdin.  This is diff from current Docker
-		// behavior.  However, we're not sure why Docker even has this
-		// behavior where you connect to stdin on the first time only.
-		// If we really want to add this behavior, we need to add support
-		// in the tether in the portlayer.
-		log.Errorf("Attach stream has stdinOnce set.  VIC does not yet support this.")
-	}
-
-	// ignore the portlayer error when it is DetachError as that is what we should return to the caller when we detach
-	if detach {
+	setStdinParams := interaction.NewContainerSetStdinParamsWithContext(ctx).WithID(ac.ID)
+	setStdinParams = setStdinParams.WithRawStream(stdinReader).WithStdinOnce(swag.Bool(stdinOnce))
+	if len(keys) > 0 {
+		setStdinParams = setStdinParams.WithDetachKeys(swag.String(string(keys)))
+	}
+
+	resp, err := pl.Interaction.ContainerSetStdin(setStdinParams)
+	if err != nil {
+		return err
+	}
+
+	// return DetachError to the caller when the client detached
+	if resp.Payload != nil && swag.BoolValue(resp.Payload.Detached) {
+		log.Infof("stdin detach detected")
 		return DetachError{}
 	}
-	return err
+	return nil
 }
 
-func copyStdOut(ctx context.Context, pl *client.PortLayer, attemptTimeout time.Duration, vc *viccontainer.VicContainer, clStdout io.Writer) error {
-	id := vc.ContainerID
+func copyStdOut(ctx context.Context, pl *client.PortLayer, attemptTimeout time.Duration, ac *AttachConfig, clStdout io.Writer) error {
+	id := ac.ID
 	//Calculate how much time to let portlayer attempt
 	plAttemptTimeout := attemptTimeout - attachPLAttemptDiff //assumes personality deadline longer than portlayer's deadline
 	plAttemptDeadline := time.Now().Add(plAttemptTimeout)
@@ -1433,8 +1848,8 @@ func copyStdOut(ctx context.Context, pl *client.PortLayer, attemptTimeout time.D
 	return nil
 }
 
-func copyStdErr(ctx context.Context, pl *client.PortLayer, vc *viccontainer.VicContainer, clStderr io.Writer) error {
-	name := vc.ContainerID
+func copyStdErr(ctx context.Context, pl *client.PortLayer, ac *AttachConfig, clStderr io.Writer) error {
+	name := ac.ID
 	getStderrParams := interaction.NewContainerGetStderrParamsWithContext(ctx).WithID(name)
 
 	_, err := pl.Interaction.ContainerGetStderr(getStderrParams, clStderr)
@@ -1449,11 +1864,22 @@ func copyStdErr(ctx context.Context, pl *client.PortLayer, vc *viccontainer.VicC
 	return nil
 }
 
-// FIXME: Move this function to a pkg to show it's origination from Docker once
-// we have ignore capabilities in our header-check.sh that checks for copyright
-// header.
-// Code c/c from io.Copy() modified by Docker to handle escape sequence
-// Begin
+// unblockAttach releases the container process if its launch is held waiting for the attach
+func unblockAttach(ctx context.Context, pl *client.PortLayer, ac *AttachConfig) error {
+	deadline := strfmt.DateTime(time.Now().Add(attachPLAttemptTimeout))
+	params := interaction.NewContainerUnblockParamsWithContext(ctx).WithID(ac.ID).WithDeadline(&deadline)
+
+	_, err := pl.Interaction.ContainerUnblock(params)
+	if err != nil {
+		if _, ok := err.(*interaction.ContainerUnblockNotFound); ok {
+			return ResourceNotFoundError(ac.ID, "interaction connection")
+		}
+
+		return InternalServerError(err.Error())
+	}
+
+	return nil
+}
 
 // DetachError is special error which returned in case of container detach.
 type DetachError struct{}
@@ -1461,59 +1887,3 @@ type DetachError struct{}
 func (DetachError) Error() string {
 	return "detached from container"
 }
-
-func copyEscapable(dst io.Writer, src io.ReadCloser, keys []byte) (written int64, err error) {
-	if len(keys) == 0 {
-		// Default keys : ctrl-p ctrl-q
-		keys = []byte{16, 17}
-	}
-	buf := make([]byte, 32*1024)
-	for {
-		nr, er := src.Read(buf)
-		if nr > 0 {
-			// ---- Docker addition
-			preservBuf := []byte{}
-			for i, key := range keys {
-				preservBuf = append(preservBuf, buf[0:nr]...)
-				if nr != 1 || buf[0] != key {
-					break
-				}
-				if i == len(keys)-1 {
-					src.Close()
-					return 0, DetachError{}
-				}
-				nr, er = src.Read(buf)
-			}
-			var nw int
-			var ew error
-			if len(preservBuf) > 0 {
-				nw, ew = dst.Write(preservBuf)
-				nr = len(preservBuf)
-			} else {
-				// ---- End of docker
-				nw, ew = dst.Write(buf[0:nr])
-			}
-			if nw > 0 {
-				written += int64(nw)
-			}
-			if ew != nil {
-				err = ew
-				break
-			}
-			if nr != nw {
-				err = io.ErrShortWrite
-				break
-			}
-		}
-		if er == io.EOF {
-			break
-		}
-		if er != nil {
-			err = er
-			break
-		}
-	}
-	return written, err
-}
-
-// End
@@ -16,9 +16,13 @@ package backends
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,12 +30,16 @@ import (
 
 	"github.com/docker/docker/api/types/backend"
 	derr "github.com/docker/docker/errors"
+	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/reference"
 	"github.com/docker/engine-api/types"
 	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/filters"
 	dnetwork "github.com/docker/engine-api/types/network"
+	"github.com/docker/engine-api/types/strslice"
 	"github.com/docker/go-connections/nat"
 	"github.com/go-swagger/go-swagger/client"
+	httptransport "github.com/go-swagger/go-swagger/httpkit/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/vishvananda/netlink"
 
@@ -198,7 +206,7 @@ func (m *MockContainerProxy) SetMockDataResponse(createHandleResp int, addToScop
 	m.mockRespIndices[5] = commitContainerResp
 }
 
-func (m *MockContainerProxy) CreateContainerHandle(imageID string, config types.ContainerCreateConfig) (string, string, error) {
+func (m *MockContainerProxy) CreateContainerHandle(imageID string, config types.ContainerCreateConfig, healthcheck *metadata.HealthConfig) (string, string, error) {
 	respIdx := m.mockRespIndices[0]
 
 	if respIdx >= len(m.mockCreateHandleData) {
@@ -261,7 +269,7 @@ func (m *MockContainerProxy) Client() *plclient.PortLayer {
 	return nil
 }
 
-func (m *MockContainerProxy) StreamContainerLogs(name string, out io.Writer, started chan struct{}, showTimestamps bool, followLogs bool, since int64, tailLines int64) error {
+func (m *MockContainerProxy) StreamContainerLogs(name string, stdout, stderr io.Writer, started chan struct{}, showTimestamps bool, followLogs bool, since, until, tailLines int64) error {
 	var lineCount int64 = 10
 
 	close(started)
@@ -274,12 +282,59 @@ func (m *MockContainerProxy) StreamContainerLogs(name string, out io.Writer, sta
 			time.Sleep(500 * time.Millisecond)
 		}
 
-		fmt.Fprintf(out, "line %d\n", i)
+		fmt.Fprintf(stdout, "line %d\n", i)
 	}
 
 	return nil
 }
 
+func (m *MockContainerProxy) StatPath(id string, path string) (*types.ContainerPathStat, error) {
+	return &types.ContainerPathStat{Name: path}, nil
+}
+
+func (m *MockContainerProxy) ArchiveExport(id string, path string, out io.Writer) error {
+	return nil
+}
+
+func (m *MockContainerProxy) ArchiveImport(id string, path string, noOverwriteDirNonDir bool, tar io.Reader) error {
+	return nil
+}
+
+func (m *MockContainerProxy) Export(id string, out io.Writer) error {
+	return nil
+}
+
+func (m *MockContainerProxy) Changes(id string) ([]archive.Change, error) {
+	return nil, nil
+}
+
+func (m *MockContainerProxy) Stats(vc *viccontainer.VicContainer) (*plmodels.ContainerStats, error) {
+	cpus := int32(2)
+	interval := int32(20)
+	// a quarter of the capacity of both vCPUs
+	usage := int64(2500)
+	memory := int64(1024)
+	limit := int64(2048 * 1024)
+	rx := int64(1)
+
+	return &plmodels.ContainerStats{
+		Read:         time.Now().UnixNano(),
+		Interval:     &interval,
+		CPUCount:     &cpus,
+		CPUUsage:     &usage,
+		MemoryActive: &memory,
+		MemoryLimit:  &limit,
+		NetworkRx:    &rx,
+	}, nil
+}
+
+func (m *MockContainerProxy) ProcessList(id string, psArgs string) (*types.ContainerProcessList, error) {
+	return &types.ContainerProcessList{
+		Titles:    []string{"PID", "CMD"},
+		Processes: [][]string{{"1", "/bin/sh"}},
+	}, nil
+}
+
 func (m *MockContainerProxy) IsRunning(vc *viccontainer.VicContainer) (bool, error) {
 	// Assume container is running if container in cache.  If we need other conditions
 	// in the future, we can add it, but for now, just assume running.
@@ -300,14 +355,27 @@ func (m *MockContainerProxy) Signal(vc *viccontainer.VicContainer, sig uint64) e
 	return nil
 }
 
-func (m *MockContainerProxy) Resize(vc *viccontainer.VicContainer, height, width int32) error {
+func (m *MockContainerProxy) Resize(id string, height, width int32) error {
 	return nil
 }
 
-func (m *MockContainerProxy) AttachStreams(ctx context.Context, vc *viccontainer.VicContainer, clStdin io.ReadCloser, clStdout, clStderr io.Writer, ca *backend.ContainerAttachConfig) error {
+func (m *MockContainerProxy) AttachStreams(ctx context.Context, ac *AttachConfig, clStdin io.ReadCloser, clStdout, clStderr io.Writer) error {
 	return nil
 }
 
+func (m *MockContainerProxy) BindInteraction(handle string, name string) (string, error) {
+	return handle, nil
+}
+
+func (m *MockContainerProxy) CreateExecTask(handle string, vc *viccontainer.VicContainer, ec *viccontainer.VicExec, hold bool) (string, error) {
+	return handle, nil
+}
+
+func (m *MockContainerProxy) InspectExecTask(handle string, id string) (*plmodels.TaskInspectResponse, error) {
+	running := true
+	return &plmodels.TaskInspectResponse{ID: id, Running: &running}, nil
+}
+
 func AddMockImageToCache() {
 	mockImage := &metadata.ImageConfig{
 		ImageID:   "e732471cb81a564575aad46b9510161c5945deaf18e9be3db344333d72f0b4b2",
@@ -567,6 +635,29 @@ func TestContainerLogs(t *testing.T) {
 			ExpectedSuccess: true,
 			ExpectedFollow:  true,
 		},
+		{
+			Config: backend.ContainerLogsConfig{
+				ContainerLogsOptions: types.ContainerLogsOptions{
+					ShowStdout: true,
+					Timestamps: true,
+					Since:      "1475323200",
+				},
+				OutStream: &writer,
+			},
+			ExpectedSuccess: true,
+			ExpectedFollow:  false,
+		},
+		{
+			Config: backend.ContainerLogsConfig{
+				ContainerLogsOptions: types.ContainerLogsOptions{
+					ShowStdout: true,
+					Since:      "yesterday",
+				},
+				OutStream: &writer,
+			},
+			ExpectedSuccess: false,
+			ExpectedFollow:  false,
+		},
 	}
 
 	for _, containerID := range dummyContainers {
@@ -656,3 +747,332 @@ func TestPortInformation(t *testing.T) {
 	ports = portInformation(mockContainerInfo, ips)
 	assert.Equal(t, len(ports), 2, "Expected 2 port binding, found %d", len(ports))
 }
+
+func TestContainerExecCreate(t *testing.T) {
+	mockContainerProxy := NewMockContainerProxy()
+
+	// Create our personality Container backend
+	cb := &Container{
+		containerProxy: mockContainerProxy,
+	}
+
+	// Prepopulate our image and container cache with dummy data
+	AddMockContainerToCache()
+
+	// exec into a container that does not exist
+	_, err := cb.ContainerExecCreate(&types.ExecConfig{Container: "nonexistent", Cmd: []string{"ls"}})
+	assert.Error(t, err, "Expected exec create against a missing container to fail")
+
+	// invalid detach keys
+	_, err = cb.ContainerExecCreate(&types.ExecConfig{Container: dummyContainerID, Cmd: []string{"ls"}, DetachKeys: "ctrl-"})
+	assert.Error(t, err, "Expected exec create with invalid detach keys to fail")
+
+	id, err := cb.ContainerExecCreate(&types.ExecConfig{Container: dummyContainerID, Cmd: []string{"ls", "-l"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, id, "Expected an exec ID")
+
+	exists, err := cb.ExecExists(id)
+	assert.NoError(t, err)
+	assert.True(t, exists, "Expected exec %s to exist", id)
+
+	exists, err = cb.ExecExists("nonexistent")
+	assert.Error(t, err)
+	assert.False(t, exists, "Expected nonexistent exec to not exist")
+
+	// inspect before start should not contact the portlayer
+	inspect, err := cb.ContainerExecInspect(id)
+	assert.NoError(t, err)
+	assert.Equal(t, dummyContainerID, inspect.ContainerID)
+	assert.Equal(t, "ls", inspect.ProcessConfig.Entrypoint)
+	assert.Equal(t, []string{"-l"}, inspect.ProcessConfig.Arguments)
+	assert.False(t, inspect.Running, "Expected exec to not be running before start")
+}
+
+func TestContainerArchiveNotFound(t *testing.T) {
+	mockContainerProxy := NewMockContainerProxy()
+
+	// Create our personality Container backend
+	cb := &Container{
+		containerProxy: mockContainerProxy,
+	}
+
+	_, err := cb.ContainerStatPath("nonexistent", "/etc/hosts")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No such container")
+
+	_, _, err = cb.ContainerArchivePath("nonexistent", "/etc/hosts")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No such container")
+
+	err = cb.ContainerExtractToDir("nonexistent", "/etc", false, bytes.NewReader(nil))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No such container")
+
+	_, err = cb.ContainerChanges("nonexistent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No such container")
+}
+
+func TestContainerStats(t *testing.T) {
+	mockContainerProxy := NewMockContainerProxy()
+
+	// Create our personality Container backend
+	cb := &Container{
+		containerProxy: mockContainerProxy,
+	}
+
+	AddMockContainerToCache()
+
+	var writer bytes.Buffer
+	config := &backend.ContainerStatsConfig{
+		Stream:    false,
+		OutStream: &writer,
+		Version:   "1.24",
+	}
+
+	err := cb.ContainerStats(dummyContainerID, config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var stats types.StatsJSON
+	if !assert.NoError(t, json.NewDecoder(&writer).Decode(&stats)) {
+		return
+	}
+
+	// the single frame covers the whole sample so has meaningful deltas
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
+	percent := cpuDelta / systemDelta * float64(len(stats.CPUStats.CPUUsage.PercpuUsage)) * 100
+
+	assert.InDelta(t, 50, percent, 0.1)
+	assert.Len(t, stats.CPUStats.CPUUsage.PercpuUsage, 2)
+	assert.Equal(t, uint64(1024*1024), stats.MemoryStats.Usage)
+	assert.Equal(t, uint64(2048*1024*1024), stats.MemoryStats.Limit)
+	assert.InDelta(t, 20*1024, stats.Networks[statsNetwork].RxBytes, 100)
+
+	err = cb.ContainerStats("nonexistent", config)
+	assert.Error(t, err)
+}
+
+func TestUpdateConfigFromHostConfig(t *testing.T) {
+	hostConfig := &container.HostConfig{
+		Resources: container.Resources{
+			CPUShares:         512,
+			CpusetCpus:        "0-3",
+			Memory:            1000 * 1024 * 1024,
+			MemoryReservation: 512 * 1024 * 1024,
+			CPUQuota:          50000,
+		},
+		RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+	}
+
+	config, warnings, err := updateConfigFromHostConfig(hostConfig)
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1, "--cpu-quota should be discarded with a warning")
+
+	assert.Equal(t, int32(512), *config.Resources.CPUShares)
+	assert.Equal(t, int32(4), *config.Resources.CPUCount)
+	assert.Equal(t, int64(1000), *config.Resources.MemoryLimit)
+	assert.Equal(t, int64(512), *config.Resources.MemoryReservation)
+	assert.Equal(t, "on-failure", *config.RestartPolicy.Name)
+	assert.Equal(t, int32(3), *config.RestartPolicy.MaximumRetryCount)
+
+	// the memory of a container VM is rounded up to a multiple of 4MB
+	config, _, err = updateConfigFromHostConfig(&container.HostConfig{Resources: container.Resources{Memory: 1001 * 1024 * 1024}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1004), *config.Resources.MemoryLimit)
+	assert.Nil(t, config.RestartPolicy)
+
+	invalid := []container.HostConfig{
+		{Resources: container.Resources{Memory: 1024}},
+		{Resources: container.Resources{Memory: 64 * 1024 * 1024, MemoryReservation: 128 * 1024 * 1024}},
+		{Resources: container.Resources{CpusetCpus: "a-b"}},
+		{RestartPolicy: container.RestartPolicy{Name: "sometimes"}},
+		{RestartPolicy: container.RestartPolicy{Name: "always", MaximumRetryCount: 3}},
+	}
+	for i := range invalid {
+		_, _, err = updateConfigFromHostConfig(&invalid[i])
+		assert.Error(t, err, "%#v should be rejected", invalid[i])
+	}
+}
+
+func TestSetCreateConfigOptions(t *testing.T) {
+	imageConfig := &container.Config{
+		User:       "nobody",
+		WorkingDir: "/srv",
+		Cmd:        strslice.StrSlice{"/bin/sh"},
+	}
+
+	// the image provides what the create request leaves unset
+	config := &container.Config{}
+	setCreateConfigOptions(config, imageConfig)
+	assert.Equal(t, "nobody", config.User)
+	assert.Equal(t, "/srv", config.WorkingDir)
+	assert.Equal(t, strslice.StrSlice{"/bin/sh"}, config.Cmd)
+
+	// and --user overrides the USER of the image
+	config = &container.Config{User: "root"}
+	setCreateConfigOptions(config, imageConfig)
+	assert.Equal(t, "root", config.User)
+}
+
+func TestContainerListParams(t *testing.T) {
+	AddMockContainerToCache()
+
+	args := filters.NewArgs()
+	args.Add("status", "exited")
+	args.Add("status", "restarting")
+	args.Add("name", "^/web")
+	args.Add("exited", "137")
+	args.Add("since", dummyContainerID)
+
+	params, err := containerListParams(&types.ContainerListOptions{Filter: args, Size: true})
+	if assert.NoError(t, err) && assert.NotNil(t, params) {
+		// filtering on status or on other containers includes the stopped ones
+		assert.True(t, *params.All)
+		assert.Equal(t, []string{"Stopped"}, params.State)
+		assert.Equal(t, []string{"^web"}, params.Name)
+		assert.Equal(t, []int64{137}, params.Exited)
+		assert.Equal(t, dummyContainerID, *params.Since)
+		assert.True(t, *params.Size)
+		assert.Nil(t, params.Limit)
+	}
+
+	params, err = containerListParams(&types.ContainerListOptions{Latest: true, Filter: filters.NewArgs()})
+	if assert.NoError(t, err) && assert.NotNil(t, params) {
+		assert.True(t, *params.All)
+		assert.Equal(t, int64(1), *params.Limit)
+	}
+
+	// no container can have a status the portlayer does not know
+	args = filters.NewArgs()
+	args.Add("status", "dead")
+	params, err = containerListParams(&types.ContainerListOptions{Filter: args})
+	assert.NoError(t, err)
+	assert.Nil(t, params)
+
+	invalid := []struct{ name, value string }{
+		{"status", "sleeping"},
+		{"exited", "none"},
+		{"before", "no-such-container"},
+		{"isolation", "hyperv"},
+	}
+	for _, f := range invalid {
+		args = filters.NewArgs()
+		args.Add(f.name, f.value)
+		_, err = containerListParams(&types.ContainerListOptions{Filter: args})
+		assert.Error(t, err, "%s=%s should be rejected", f.name, f.value)
+	}
+}
+
+func TestPendingAttach(t *testing.T) {
+	id := "attached"
+
+	assert.False(t, attachPending(id))
+
+	// concurrent attaches keep the attach pending until the last of them ends
+	addPendingAttach(id)
+	addPendingAttach(id)
+	assert.True(t, attachPending(id))
+	assert.False(t, attachPending("other"))
+
+	removePendingAttach(id)
+	assert.True(t, attachPending(id))
+
+	removePendingAttach(id)
+	assert.False(t, attachPending(id))
+	assert.NotContains(t, pendingAttaches.ids, id)
+}
+
+// pausePortLayer serves the port layer calls made to pause and unpause a container
+type pausePortLayer struct {
+	state   string
+	changes []string
+	commits int
+}
+
+func (p *pausePortLayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/containers/info/"):
+		json.NewEncoder(w).Encode(&plmodels.ContainerInfo{
+			ContainerConfig: &plmodels.ContainerConfig{State: &p.state},
+		})
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/containers/"):
+		json.NewEncoder(w).Encode("handle")
+	case r.Method == "PUT" && strings.HasSuffix(r.URL.Path, "/state"):
+		var state string
+		json.NewDecoder(r.Body).Decode(&state)
+		p.changes = append(p.changes, state)
+		json.NewEncoder(w).Encode("handle")
+	case r.Method == "PUT":
+		p.commits++
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// pauseContainerProxy is a mock proxy whose client talks to the given server
+type pauseContainerProxy struct {
+	*MockContainerProxy
+
+	client *plclient.PortLayer
+}
+
+func (p *pauseContainerProxy) Client() *plclient.PortLayer {
+	return p.client
+}
+
+func TestContainerPause(t *testing.T) {
+	AddMockContainerToCache()
+
+	pl := &pausePortLayer{state: "Running"}
+	server := httptest.NewServer(pl)
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	cb := &Container{
+		containerProxy: &pauseContainerProxy{
+			MockContainerProxy: NewMockContainerProxy(),
+			client:             plclient.New(httptransport.New(u.Host, "/", []string{"http"}), nil),
+		},
+	}
+
+	assert.NoError(t, cb.ContainerPause(dummyContainerID))
+	assert.Equal(t, []string{"PAUSED"}, pl.changes)
+	assert.Equal(t, 1, pl.commits)
+
+	// only a running container can be paused and only a paused one unpaused
+	err := cb.ContainerUnpause(dummyContainerID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not paused")
+
+	pl.state = "Paused"
+	err = cb.ContainerPause(dummyContainerID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is already paused")
+
+	assert.NoError(t, cb.ContainerUnpause(dummyContainerID))
+	assert.Equal(t, []string{"PAUSED", "RUNNING"}, pl.changes)
+	assert.Equal(t, 2, pl.commits)
+
+	pl.state = "Stopped"
+	err = cb.ContainerPause(dummyContainerID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not running")
+	assert.Equal(t, 2, pl.commits)
+
+	assert.Error(t, cb.ContainerPause("no-such-container"))
+}
+
+func TestDockerStatusPaused(t *testing.T) {
+	started := time.Now().UTC().Add(-time.Hour)
+
+	_, status := dockerStatus(0, "", "Paused", started, time.Time{})
+	assert.Equal(t, "Up About an hour (Paused)", status)
+
+	_, status = dockerStatus(0, "", "Running", started, time.Time{})
+	assert.Equal(t, "Up About an hour", status)
+}
@@ -15,12 +15,18 @@
 package backends
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	derr "github.com/docker/docker/errors"
 )
 
+// ErrExtractPointNotDirectory is used to convey that the operation to extract
+// a tar archive to a directory in a container has failed because the specified
+// path does not refer to a directory.
+var ErrExtractPointNotDirectory = errors.New("extraction point is not a directory")
+
 // InvalidVolumeError is returned when the user specifies a client directory as a volume.
 type InvalidVolumeError struct {
 }
@@ -57,6 +63,11 @@ func ResourceNotFoundError(cid, res string) error {
 	return derr.NewRequestNotFoundError(fmt.Errorf("No such %s for container: %s", res, cid))
 }
 
+// ExecNotFoundError returns a 404 docker error when an exec instance is not found.
+func ExecNotFoundError(id string) error {
+	return derr.NewRequestNotFoundError(fmt.Errorf("No such exec instance '%s' found in daemon", id))
+}
+
 // NotFoundError returns a 404 docker error when a container is not found.
 func NotFoundError(msg string) error {
 	return derr.NewRequestNotFoundError(fmt.Errorf("No such container: %s", msg))
@@ -0,0 +1,138 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backends
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/go-swagger/go-swagger/swag"
+
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+	"github.com/vmware/vic/lib/metadata"
+)
+
+// The docker version we vendor predates health checks, so the types docker uses to
+// report them are declared here
+
+// Health is the health of a container as docker reports it in State.Health
+type Health struct {
+	Status        string
+	FailingStreak int
+	Log           []*HealthcheckResult
+}
+
+// HealthcheckResult is the result of a single health probe
+type HealthcheckResult struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
+}
+
+// ContainerState is the docker container state with the health of the container
+type ContainerState struct {
+	*types.ContainerState
+	Health *Health `json:",omitempty"`
+}
+
+// ContainerJSON is the docker inspect result for a container with a health check, whose
+// state shadows the state of the embedded result
+type ContainerJSON struct {
+	*types.ContainerJSON
+	State *ContainerState
+}
+
+// mergeHealthcheck returns the health check of a container given the one it was created
+// with and the one of its image, the former taking precedence field by field as in docker
+func mergeHealthcheck(config, image *metadata.HealthConfig) *metadata.HealthConfig {
+	if config == nil {
+		return image
+	}
+	if image == nil {
+		return config
+	}
+
+	merged := *config
+	if len(merged.Test) == 0 {
+		merged.Test = image.Test
+	}
+	if merged.Interval == 0 {
+		merged.Interval = image.Interval
+	}
+	if merged.Timeout == 0 {
+		merged.Timeout = image.Timeout
+	}
+	if merged.Retries == 0 {
+		merged.Retries = image.Retries
+	}
+
+	return &merged
+}
+
+// toModelsHealthConfig converts a health check to the port layer model
+func toModelsHealthConfig(hc *metadata.HealthConfig) *models.HealthConfig {
+	if hc == nil {
+		return nil
+	}
+
+	return &models.HealthConfig{
+		Test:     hc.Test,
+		Interval: swag.Int64(int64(hc.Interval)),
+		Timeout:  swag.Int64(int64(hc.Timeout)),
+		Retries:  swag.Int32(int32(hc.Retries)),
+	}
+}
+
+// dockerHealth converts the health reported by the port layer to the docker form
+func dockerHealth(h *models.Health) *Health {
+	if h == nil || swag.StringValue(h.Status) == "" {
+		return nil
+	}
+
+	health := &Health{
+		Status:        swag.StringValue(h.Status),
+		FailingStreak: int(swag.Int32Value(h.FailingStreak)),
+	}
+
+	for _, l := range h.Log {
+		health.Log = append(health.Log, &HealthcheckResult{
+			Start:    time.Unix(0, swag.Int64Value(l.Start)),
+			End:      time.Unix(0, swag.Int64Value(l.End)),
+			ExitCode: int(swag.Int32Value(l.ExitCode)),
+			Output:   swag.StringValue(l.Output),
+		})
+	}
+
+	return health
+}
+
+// healthStatus returns the suffix docker adds to the status of a running container that
+// has a health check in container lists
+func healthStatus(h *models.Health) string {
+	if h == nil {
+		return ""
+	}
+
+	switch status := swag.StringValue(h.Status); status {
+	case "":
+		return ""
+	case "starting":
+		return " (health: starting)"
+	default:
+		return fmt.Sprintf(" (%s)", status)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backends
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/go-swagger/go-swagger/swag"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+	"github.com/vmware/vic/lib/metadata"
+)
+
+func TestMergeHealthcheck(t *testing.T) {
+	image := &metadata.HealthConfig{
+		Test:     []string{"CMD-SHELL", "curl -f http://localhost/"},
+		Interval: time.Minute,
+		Retries:  5,
+	}
+
+	assert.Nil(t, mergeHealthcheck(nil, nil))
+	assert.Equal(t, image, mergeHealthcheck(nil, image))
+
+	merged := mergeHealthcheck(&metadata.HealthConfig{Interval: time.Second, Timeout: 2 * time.Second}, image)
+	assert.Equal(t, image.Test, merged.Test)
+	assert.Equal(t, time.Second, merged.Interval)
+	assert.Equal(t, 2*time.Second, merged.Timeout)
+	assert.Equal(t, 5, merged.Retries)
+
+	// a container can disable the health check of its image
+	merged = mergeHealthcheck(&metadata.HealthConfig{Test: []string{"NONE"}}, image)
+	assert.Equal(t, []string{"NONE"}, merged.Test)
+}
+
+func TestDockerHealth(t *testing.T) {
+	assert.Nil(t, dockerHealth(nil))
+	assert.Nil(t, dockerHealth(&models.Health{}))
+
+	start := time.Unix(1000, 0)
+	h := &models.Health{
+		Status:        swag.String("unhealthy"),
+		FailingStreak: swag.Int32(3),
+		Log: []*models.HealthLog{
+			{
+				Start:    swag.Int64(start.UnixNano()),
+				End:      swag.Int64(start.Add(time.Second).UnixNano()),
+				ExitCode: swag.Int32(1),
+				Output:   swag.String("connection refused"),
+			},
+		},
+	}
+
+	health := dockerHealth(h)
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Equal(t, 3, health.FailingStreak)
+	if assert.Len(t, health.Log, 1) {
+		assert.True(t, start.Equal(health.Log[0].Start))
+		assert.Equal(t, 1, health.Log[0].ExitCode)
+		assert.Equal(t, "connection refused", health.Log[0].Output)
+	}
+
+	assert.Equal(t, " (health: starting)", healthStatus(&models.Health{Status: swag.String("starting")}))
+	assert.Equal(t, " (unhealthy)", healthStatus(h))
+	assert.Equal(t, "", healthStatus(nil))
+
+	// the state with the health replaces the state of the inspect result
+	inspect := &ContainerJSON{
+		ContainerJSON: &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:    "abc123",
+				State: &types.ContainerState{Running: true},
+			},
+		},
+	}
+	inspect.State = &ContainerState{ContainerState: inspect.ContainerJSON.State, Health: health}
+
+	out, err := json.Marshal(inspect)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out, &decoded))
+	state := decoded["State"].(map[string]interface{})
+	assert.Equal(t, true, state["Running"])
+	assert.Equal(t, "unhealthy", state["Health"].(map[string]interface{})["Status"])
+	assert.Equal(t, "abc123", decoded["Id"])
+}
@@ -15,20 +15,32 @@
 package backends
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 
 	"golang.org/x/net/context"
 
+	"github.com/docker/docker/dockerversion"
+	derr "github.com/docker/docker/errors"
+	docker "github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/httputils"
+	"github.com/docker/docker/pkg/progress"
 	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/reference"
+	dockerRegistry "github.com/docker/docker/registry"
 	"github.com/docker/engine-api/types"
 	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/filters"
 	"github.com/docker/engine-api/types/registry"
 
 	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
@@ -37,6 +49,7 @@ import (
 	"github.com/vmware/vic/lib/metadata"
 	"github.com/vmware/vic/pkg/trace"
 	"github.com/vmware/vic/pkg/uid"
+	"github.com/vmware/vic/pkg/version"
 	"github.com/vmware/vic/pkg/vsphere/sys"
 )
 
@@ -51,8 +64,117 @@ func (r byCreated) Less(i, j int) bool { return r[i].Created < r[j].Created }
 type Image struct {
 }
 
+// Commit creates a new image from the changes a stopped container made to its
+// image. The image can optionally be tagged into a repository.
 func (i *Image) Commit(name string, config *types.ContainerCommitConfig) (imageID string, err error) {
-	return "", fmt.Errorf("%s does not implement image.Commit", ProductName())
+	defer trace.End(trace.Begin(name))
+
+	vc := cache.ContainerCache().GetContainer(name)
+	if vc == nil {
+		return "", NotFoundError(name)
+	}
+
+	// the container references the layer of its image
+	parent, err := cache.ImageCache().GetImage(cache.RepositoryCache().GetImageID(vc.ImageID))
+	if err != nil {
+		return "", fmt.Errorf("Unable to find the image of container %s: %s", name, err)
+	}
+
+	containerConfig := vc.Config
+	if containerConfig == nil {
+		containerConfig = &container.Config{}
+	}
+
+	if config.Config == nil {
+		config.Config = &container.Config{}
+	}
+	if config.MergeConfigs {
+		mergeCommitConfig(config.Config, containerConfig)
+	}
+
+	var ref reference.Named
+	if config.Repo != "" {
+		if ref, err = reference.WithName(config.Repo); err != nil {
+			return "", err
+		}
+		if config.Tag != "" {
+			if ref, err = reference.WithTag(ref, config.Tag); err != nil {
+				return "", err
+			}
+		}
+		ref = reference.WithDefaultTag(ref)
+	}
+
+	imageConfig, err := commitContainer(name, vc.ContainerID, parent, containerConfig, config, ref)
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256:" + imageConfig.ImageID, nil
+}
+
+// commitContainer writes the changes the stopped container made to the parent image as a
+// new image layer, recording containerConfig as the config the container ran with, and adds
+// the image to the caches.
+func commitContainer(name, containerID string, parent *metadata.ImageConfig, containerConfig *container.Config, config *types.ContainerCommitConfig, ref reference.Named) (*metadata.ImageConfig, error) {
+	layerID := stringid.GenerateRandomID()
+	imageConfig, err := commitImageConfig(parent, containerID, containerConfig, config, layerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref != nil {
+		imageConfig.Name = ref.Name()
+		imageConfig.Reference = ref.String()
+		if tagged, ok := ref.(reference.NamedTagged); ok {
+			imageConfig.Tags = []string{tagged.Tag()}
+		}
+	}
+
+	blob, err := json.Marshal(imageConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal image metadata: %s", err)
+	}
+
+	// needed for image store
+	host, err := sys.UUID()
+	if err != nil {
+		return nil, err
+	}
+
+	key := metadata.MetaDataKey
+	val := string(blob)
+
+	params := storage.NewCommitContainerParamsWithContext(ctx).
+		WithStoreName(host).
+		WithID(containerID).
+		WithImageID(layerID).
+		WithMetadatakey(&key).
+		WithMetadataval(&val)
+
+	if _, err = PortLayerClient().Storage.CommitContainer(params); err != nil {
+		switch err := err.(type) {
+		case *storage.CommitContainerNotFound:
+			return nil, NotFoundError(name)
+		case *storage.CommitContainerConflict:
+			return nil, derr.NewRequestConflictError(fmt.Errorf("%s must be stopped to be committed: %s", name, err.Payload.Message))
+		case *storage.CommitContainerDefault:
+			return nil, InternalServerError(err.Payload.Message)
+		default:
+			return nil, InternalServerError(err.Error())
+		}
+	}
+
+	cache.ImageCache().AddImage(imageConfig)
+	cache.LayerCache().AddExisting(layerID)
+
+	if ref != nil {
+		if err = cache.RepositoryCache().AddReference(ref, imageConfig.ImageID, true, layerID, true); err != nil {
+			return nil, fmt.Errorf("Unable to add image reference %s: %s", ref, err)
+		}
+	}
+
+	return imageConfig, nil
 }
 
 func (i *Image) Exists(containerName string) bool {
@@ -150,7 +272,99 @@ func (i *Image) ImageDelete(imageRef string, force, prune bool) ([]types.ImageDe
 }
 
 func (i *Image) ImageHistory(imageName string) ([]*types.ImageHistory, error) {
-	return nil, fmt.Errorf("%s does not implement image.History", ProductName())
+	defer trace.End(trace.Begin(imageName))
+
+	image, err := cache.ImageCache().GetImage(imageName)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := sys.UUID()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to return host name: %s", err)
+	}
+
+	options := imagec.Options{
+		Timeout: imagec.DefaultHTTPTimeout,
+	}
+
+	if portLayerServer := PortLayerServer(); portLayerServer != "" {
+		options.Host = portLayerServer
+	}
+
+	// the parent chain of the image is kept by the image store
+	ic := imagec.NewImageC(options, streamformatter.NewJSONStreamFormatter())
+	ic.Storename = host
+
+	layers, err := ic.LayersToPush(image)
+	if err != nil {
+		return nil, err
+	}
+
+	layerIDs := make([]string, len(layers))
+	for i, layer := range layers {
+		layerIDs[i] = layer.ID
+	}
+
+	// layers that are themselves images are listed with their ID and tags
+	images := make(map[string]*metadata.ImageConfig)
+	for _, img := range cache.ImageCache().GetImages() {
+		images[img.ID] = img
+	}
+
+	return imageHistory(image, layerIDs, images), nil
+}
+
+// imageHistory returns the history of the image from the newest entry, given
+// the IDs of its layers from the image layer to the root and the images in the
+// cache keyed by the ID of their image layer. Entries that did not create a
+// layer, and layers that are not images, are listed as missing as docker does.
+func imageHistory(image *metadata.ImageConfig, layerIDs []string, images map[string]*metadata.ImageConfig) []*types.ImageHistory {
+	history := image.History
+	if len(history) == 0 {
+		history = []docker.History{
+			{
+				Created:   image.Created,
+				Author:    image.Author,
+				CreatedBy: strings.Join(image.ContainerConfig.Cmd, " "),
+				Comment:   image.Comment,
+			},
+		}
+	}
+
+	result := make([]*types.ImageHistory, 0, len(history))
+	layer := 0
+
+	for i := len(history) - 1; i >= 0; i-- {
+		h := history[i]
+		entry := &types.ImageHistory{
+			ID:        "<missing>",
+			Created:   h.Created.Unix(),
+			CreatedBy: h.CreatedBy,
+			Comment:   h.Comment,
+		}
+
+		if !h.EmptyLayer && layer < len(layerIDs) {
+			id := layerIDs[layer]
+			entry.Size = image.LayerSizes[id]
+
+			if img, ok := images[id]; ok {
+				entry.ID = "sha256:" + img.ImageID
+				entry.Tags = img.Tags
+			}
+			layer++
+		}
+
+		result = append(result, entry)
+	}
+
+	// the newest entry is the image itself
+	if len(result) > 0 && result[0].ID == "<missing>" {
+		result[0].ID = "sha256:" + image.ImageID
+		result[0].Tags = image.Tags
+	}
+
+	return result
 }
 
 func (i *Image) Images(filterArgs string, filter string, all bool) ([]*types.Image, error) {
@@ -184,19 +398,121 @@ func (i *Image) LookupImage(name string) (*types.ImageInspect, error) {
 }
 
 func (i *Image) TagImage(newTag reference.Named, imageName string) error {
-	return fmt.Errorf("%s does not implement image.Tag", ProductName())
+	defer trace.End(trace.Begin(fmt.Sprintf("%s as %s", imageName, newTag.String())))
+
+	image, err := cache.ImageCache().GetImage(imageName)
+	if err != nil {
+		return err
+	}
+
+	newTag = reference.WithDefaultTag(newTag)
+
+	// the tag is taken from any image that has it, as docker does, and saved so
+	// that it persists across restarts
+	if err = cache.RepositoryCache().AddReference(newTag, image.ImageID, true, image.ID, true); err != nil {
+		return fmt.Errorf("Unable to Add Image Reference(%s): %s", newTag.String(), err)
+	}
+
+	return nil
 }
 
 func (i *Image) LoadImage(inTar io.ReadCloser, outStream io.Writer, quiet bool) error {
-	return fmt.Errorf("%s does not implement image.LoadImage", ProductName())
+	defer trace.End(trace.Begin(""))
+
+	options := imagec.Options{
+		Destination: os.TempDir(),
+		Timeout:     imagec.DefaultHTTPTimeout,
+		Outstream:   outStream,
+	}
+
+	if portLayerServer := PortLayerServer(); portLayerServer != "" {
+		options.Host = portLayerServer
+	}
+
+	ic := imagec.NewImageC(options, streamformatter.NewJSONStreamFormatter())
+	return ic.LoadImages(inTar, quiet)
 }
 
 func (i *Image) ImportImage(src string, newRef reference.Named, msg string, inConfig io.ReadCloser, outStream io.Writer, config *container.Config) error {
-	return fmt.Errorf("%s does not implement image.ImportImage", ProductName())
+	defer trace.End(trace.Begin(src))
+
+	sf := streamformatter.NewJSONStreamFormatter()
+
+	// the changes given to docker import have already been applied to config
+	var rc io.ReadCloser
+	if src == "-" {
+		rc = inConfig
+	} else {
+		inConfig.Close()
+		if src == "" {
+			return derr.NewBadRequestError(fmt.Errorf("src is not set"))
+		}
+
+		u, err := url.Parse(src)
+		if err != nil {
+			return derr.NewBadRequestError(err)
+		}
+		if u.Scheme == "" {
+			u.Scheme = "http"
+			u.Host = src
+			u.Path = ""
+		}
+
+		outStream.Write(sf.FormatStatus("", "Downloading from %s", u))
+		resp, err := httputils.Download(u.String())
+		if err != nil {
+			return err
+		}
+
+		progressOutput := sf.NewProgressOutput(outStream, true)
+		rc = progress.NewProgressReader(resp.Body, progressOutput, resp.ContentLength, "", "Importing")
+	}
+	defer rc.Close()
+
+	if msg == "" {
+		msg = "Imported from " + src
+	}
+
+	options := imagec.Options{
+		Destination: os.TempDir(),
+		Timeout:     imagec.DefaultHTTPTimeout,
+		Outstream:   outStream,
+	}
+
+	if portLayerServer := PortLayerServer(); portLayerServer != "" {
+		options.Host = portLayerServer
+	}
+
+	ic := imagec.NewImageC(options, sf)
+	id, err := ic.ImportImage(rc, newRef, msg, config)
+	if err != nil {
+		return err
+	}
+
+	outStream.Write(sf.FormatStatus("", "%s", id))
+
+	return nil
 }
 
 func (i *Image) ExportImage(names []string, outStream io.Writer) error {
-	return fmt.Errorf("%s does not implement image.ExportImage", ProductName())
+	defer trace.End(trace.Begin(strings.Join(names, ",")))
+
+	images, err := imagesToSave(names)
+	if err != nil {
+		return err
+	}
+
+	options := imagec.Options{
+		Destination: os.TempDir(),
+		Timeout:     imagec.DefaultHTTPTimeout,
+	}
+
+	if portLayerServer := PortLayerServer(); portLayerServer != "" {
+		options.Host = portLayerServer
+	}
+
+	ic := imagec.NewImageC(options, streamformatter.NewJSONStreamFormatter())
+	return ic.SaveImages(images, outStream)
 }
 
 func (i *Image) PullImage(ctx context.Context, ref reference.Named, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
@@ -249,15 +565,377 @@ func (i *Image) PullImage(ctx context.Context, ref reference.Named, metaHeaders
 }
 
 func (i *Image) PushImage(ctx context.Context, ref reference.Named, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
-	return fmt.Errorf("%s does not implement image.PushImage", ProductName())
+	defer trace.End(trace.Begin(ref.String()))
+
+	log.Debugf("PushImage: ref = %+v, metaheaders = %+v\n", ref, metaHeaders)
+
+	// every tag of the repository is pushed if none is given
+	refs := []reference.Named{ref}
+	if reference.IsNameOnly(ref) {
+		refs = refs[:0]
+		for _, association := range cache.RepositoryCache().ReferencesByName(ref) {
+			if _, ok := association.Ref.(reference.NamedTagged); ok {
+				refs = append(refs, association.Ref)
+			}
+		}
+		if len(refs) == 0 {
+			return fmt.Errorf("An image does not exist locally with the tag: %s", ref.Name())
+		}
+	}
+
+	for _, ref := range refs {
+		options := imagec.Options{
+			Destination: os.TempDir(),
+			Reference:   ref.String(),
+			Timeout:     imagec.DefaultHTTPTimeout,
+			Outstream:   outStream,
+		}
+
+		if authConfig != nil {
+			if len(authConfig.Username) > 0 {
+				options.Username = authConfig.Username
+			}
+			if len(authConfig.Password) > 0 {
+				options.Password = authConfig.Password
+			}
+		}
+
+		portLayerServer := PortLayerServer()
+
+		if portLayerServer != "" {
+			options.Host = portLayerServer
+		}
+
+		insecureRegistries := InsecureRegistries()
+		for _, registry := range insecureRegistries {
+			if registry == ref.Hostname() {
+				options.InsecureAllowHTTP = true
+				break
+			}
+		}
+
+		log.Infof("PushImage: reference: %s, %s, portlayer: %#v",
+			options.Reference,
+			options.Host,
+			portLayerServer)
+
+		ic := imagec.NewImageC(options, streamformatter.NewJSONStreamFormatter())
+		if err := ic.PushImage(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (i *Image) SearchRegistryForImages(ctx context.Context, term string, authConfig *types.AuthConfig, metaHeaders map[string][]string) (*registry.SearchResults, error) {
-	return nil, fmt.Errorf("%s does not implement image.SearchRegistryForImages", ProductName())
+	return i.SearchImages(ctx, "", term, imagec.DefaultSearchLimit, authConfig, metaHeaders)
+}
+
+// SearchImages searches the registry named in the term, or Docker Hub, for images
+// matching the term. Registries without the v1 search API are searched through
+// their v2 catalog. The filters given to docker search are applied to the results
+// as the registries do not apply them.
+func (i *Image) SearchImages(ctx context.Context, filterArgs string, term string, limit int, authConfig *types.AuthConfig, metaHeaders map[string][]string) (*registry.SearchResults, error) {
+	defer trace.End(trace.Begin(term))
+
+	searchFilters, err := filters.FromParam(filterArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	indexName, remoteName := splitSearchTerm(term)
+
+	index, err := RegistryService.ResolveIndex(indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{index.Name}
+	if index.Official {
+		names = append(names, IndexServerAddress, "index.docker.io")
+	}
+	if !RegistryAllowed(names...) {
+		return nil, fmt.Errorf("Access denied to unauthorized registry (%s) while VCH is in whitelist mode or the registry is blacklisted", index.Name)
+	}
+
+	if authConfig == nil {
+		authConfig = &types.AuthConfig{}
+	}
+
+	results, err := RegistryService.Search(term, authConfig, dockerversion.DockerUserAgent(ctx), metaHeaders)
+	if err != nil {
+		if index.Official {
+			return nil, err
+		}
+
+		// private registries commonly only expose the v2 API
+		log.Debugf("v1 search of %s failed, falling back to the catalog: %s", index.Name, err)
+
+		results, err = searchCatalog(ctx, index.Name, remoteName, authConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if results.Results, err = imagec.FilterSearchResults(results.Results, searchFilters, limit); err != nil {
+		return nil, err
+	}
+	results.NumResults = len(results.Results)
+
+	return results, nil
 }
 
 // Utility functions
 
+// splitSearchTerm splits the term given to docker search into the name of the
+// registry to search and the term to search it for, as docker does
+func splitSearchTerm(term string) (string, string) {
+	parts := strings.SplitN(term, "/", 2)
+	if len(parts) == 1 || (!strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost") {
+		return dockerRegistry.IndexName, term
+	}
+	return parts[0], parts[1]
+}
+
+// searchCatalog searches the v2 catalog of the registry for repositories whose
+// names contain the term
+func searchCatalog(ctx context.Context, index, term string, authConfig *types.AuthConfig) (*registry.SearchResults, error) {
+	options := imagec.Options{
+		Registry: index,
+		Timeout:  imagec.DefaultHTTPTimeout,
+		Username: authConfig.Username,
+		Password: authConfig.Password,
+	}
+
+	insecureRegistries := InsecureRegistries()
+	for _, registry := range insecureRegistries {
+		if registry == index {
+			options.InsecureAllowHTTP = true
+			break
+		}
+	}
+
+	url, err := imagec.LearnRegistryURL(options)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to search %s: %s", index, err)
+	}
+	options.Registry = url
+
+	results, err := imagec.SearchCatalog(ctx, options, index, term)
+	if err != nil {
+		return nil, err
+	}
+
+	return &registry.SearchResults{
+		Query:      term,
+		NumResults: len(results),
+		Results:    results,
+	}, nil
+}
+
+// imagesToSave resolves the names given to docker save to the images to save and
+// the tags to save them with. A repository name saves every tag of the repository
+// and an image ID saves the image without tags.
+func imagesToSave(names []string) ([]imagec.SavedImage, error) {
+	var images []imagec.SavedImage
+	index := make(map[string]int)
+
+	add := func(idOrRef string, tagged reference.NamedTagged) error {
+		image, err := cache.ImageCache().GetImage(idOrRef)
+		if err != nil {
+			return err
+		}
+
+		i, ok := index[image.ImageID]
+		if !ok {
+			i = len(images)
+			index[image.ImageID] = i
+			images = append(images, imagec.SavedImage{Image: image})
+		}
+
+		if tagged == nil {
+			return nil
+		}
+		for _, t := range images[i].RepoTags {
+			if t.String() == tagged.String() {
+				return nil
+			}
+		}
+		images[i].RepoTags = append(images[i].RepoTags, tagged)
+
+		return nil
+	}
+
+	for _, name := range names {
+		// names that are not references to images are image IDs or digests
+		named, err := reference.ParseNamed(name)
+		if err != nil {
+			if err := add(name, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var tags []reference.NamedTagged
+		if reference.IsNameOnly(named) {
+			for _, association := range cache.RepositoryCache().ReferencesByName(named) {
+				if tagged, ok := association.Ref.(reference.NamedTagged); ok {
+					tags = append(tags, tagged)
+				}
+			}
+		} else if tagged, ok := named.(reference.NamedTagged); ok {
+			if _, err := cache.RepositoryCache().Get(tagged); err == nil {
+				tags = append(tags, tagged)
+			}
+		}
+
+		if len(tags) == 0 {
+			if err := add(name, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, tagged := range tags {
+			if err := add(tagged.String(), tagged); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// commitImageConfig returns the metadata of an image committed from the container,
+// which extends the parent image with a new layer.
+func commitImageConfig(parent *metadata.ImageConfig, containerID string, containerConfig *container.Config, config *types.ContainerCommitConfig, layerID string) (*metadata.ImageConfig, error) {
+	created := time.Now().UTC()
+
+	history := append([]docker.History(nil), parent.History...)
+	history = append(history, docker.History{
+		Author:    config.Author,
+		Created:   created,
+		CreatedBy: strings.Join(containerConfig.Cmd, " "),
+		Comment:   config.Comment,
+	})
+
+	v1 := docker.V1Image{
+		Comment:         config.Comment,
+		Created:         created,
+		Container:       containerID,
+		ContainerConfig: *containerConfig,
+		DockerVersion:   version.Version,
+		Author:          config.Author,
+		Config:          config.Config,
+		Architecture:    parent.Architecture,
+		OS:              parent.OS,
+	}
+
+	// the image ID is the sum of the image config; the diffID of the new layer is
+	// only known once it has been written so the config is identified by its content
+	// and creation time instead
+	bytes, err := json.Marshal(&docker.Image{V1Image: v1, History: history})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal image metadata: %s", err)
+	}
+
+	v1.ID = layerID
+	v1.Parent = parent.ID
+	v1.Size = parent.Size
+
+	// layers below the new one keep the digests they were pulled with
+	diffIDs := make(map[string]string, len(parent.DiffIDs))
+	for k, v := range parent.DiffIDs {
+		diffIDs[k] = v
+	}
+	blobSums := make(map[string]string, len(parent.BlobSums))
+	for k, v := range parent.BlobSums {
+		blobSums[k] = v
+	}
+	layerSizes := make(map[string]int64, len(parent.LayerSizes))
+	for k, v := range parent.LayerSizes {
+		layerSizes[k] = v
+	}
+
+	return &metadata.ImageConfig{
+		V1Image:     v1,
+		ImageID:     fmt.Sprintf("%x", sha256.Sum256(bytes)),
+		DiffIDs:     diffIDs,
+		History:     history,
+		BlobSums:    blobSums,
+		LayerSizes:  layerSizes,
+		Healthcheck: parent.Healthcheck,
+	}, nil
+}
+
+// mergeCommitConfig fills in the parts of the commit config that were not given
+// from the config of the container, as docker does.
+func mergeCommitConfig(userConf, containerConf *container.Config) {
+	if userConf.User == "" {
+		userConf.User = containerConf.User
+	}
+
+	if len(userConf.ExposedPorts) == 0 {
+		userConf.ExposedPorts = containerConf.ExposedPorts
+	} else {
+		for port := range containerConf.ExposedPorts {
+			if _, exists := userConf.ExposedPorts[port]; !exists {
+				userConf.ExposedPorts[port] = struct{}{}
+			}
+		}
+	}
+
+	if len(userConf.Env) == 0 {
+		userConf.Env = containerConf.Env
+	} else {
+		keys := make(map[string]bool, len(userConf.Env))
+		for _, env := range userConf.Env {
+			keys[strings.SplitN(env, "=", 2)[0]] = true
+		}
+		for _, env := range containerConf.Env {
+			if !keys[strings.SplitN(env, "=", 2)[0]] {
+				userConf.Env = append(userConf.Env, env)
+			}
+		}
+	}
+
+	labels := make(map[string]string, len(containerConf.Labels)+len(userConf.Labels))
+	for k, v := range containerConf.Labels {
+		labels[k] = v
+	}
+	for k, v := range userConf.Labels {
+		labels[k] = v
+	}
+	userConf.Labels = labels
+
+	if len(userConf.Entrypoint) == 0 {
+		if len(userConf.Cmd) == 0 {
+			userConf.Cmd = containerConf.Cmd
+		}
+
+		if userConf.Entrypoint == nil {
+			userConf.Entrypoint = containerConf.Entrypoint
+		}
+	}
+
+	if userConf.WorkingDir == "" {
+		userConf.WorkingDir = containerConf.WorkingDir
+	}
+
+	if len(userConf.Volumes) == 0 {
+		userConf.Volumes = containerConf.Volumes
+	} else {
+		for k, v := range containerConf.Volumes {
+			userConf.Volumes[k] = v
+		}
+	}
+
+	if userConf.StopSignal == "" {
+		userConf.StopSignal = containerConf.StopSignal
+	}
+}
+
 func convertV1ImageToDockerImage(image *metadata.ImageConfig) *types.Image {
 	var labels map[string]string
 	if image.Config != nil {
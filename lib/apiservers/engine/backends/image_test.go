@@ -20,6 +20,7 @@ import (
 	"time"
 
 	v1 "github.com/docker/docker/image"
+	"github.com/docker/engine-api/types"
 	"github.com/docker/engine-api/types/container"
 
 	"github.com/stretchr/testify/assert"
@@ -60,3 +61,123 @@ func TestConvertV1ImageToDockerImage(t *testing.T) {
 	assert.Equal(t, image.Digests[0], dockerImage.RepoDigests[0], "Error: expected digest %s, got %s", image.Digests[0], dockerImage.RepoDigests[0])
 	assert.Equal(t, image.Tags[0], dockerImage.RepoTags[0], "Error: expected tag %s, got %s", image.Tags[0], dockerImage.RepoTags[0])
 }
+
+func TestMergeCommitConfig(t *testing.T) {
+	containerConf := &container.Config{
+		User:       "nobody",
+		Env:        []string{"PATH=/bin", "HOME=/root"},
+		Cmd:        []string{"sh"},
+		WorkingDir: "/root",
+		Labels:     map[string]string{"a": "container", "b": "container"},
+	}
+
+	userConf := &container.Config{
+		Env:    []string{"HOME=/home"},
+		Labels: map[string]string{"b": "user"},
+	}
+
+	mergeCommitConfig(userConf, containerConf)
+
+	assert.Equal(t, "nobody", userConf.User)
+	assert.Equal(t, []string{"HOME=/home", "PATH=/bin"}, userConf.Env)
+	assert.Equal(t, []string{"sh"}, []string(userConf.Cmd))
+	assert.Equal(t, "/root", userConf.WorkingDir)
+	assert.Equal(t, map[string]string{"a": "container", "b": "user"}, userConf.Labels)
+}
+
+func TestCommitImageConfig(t *testing.T) {
+	parent := &metadata.ImageConfig{
+		V1Image: v1.V1Image{
+			ID:           "parentlayer",
+			Size:         1024,
+			Architecture: "amd64",
+			OS:           "linux",
+		},
+		ImageID:  "parent_id",
+		DiffIDs:  map[string]string{"sha256:parentdiff": "parentlayer"},
+		BlobSums: map[string]string{"parentlayer": "sha256:parentblob"},
+		History:  []v1.History{{CreatedBy: "/bin/sh -c #(nop) ADD file"}},
+	}
+
+	containerConf := &container.Config{Cmd: []string{"touch", "/file"}}
+	config := &types.ContainerCommitConfig{
+		Author:  "author",
+		Comment: "comment",
+		Config:  &container.Config{Cmd: []string{"sh"}},
+	}
+
+	image, err := commitImageConfig(parent, "containerid", containerConf, config, "newlayer")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "newlayer", image.ID)
+	assert.Equal(t, "parentlayer", image.Parent)
+	assert.Equal(t, "containerid", image.Container)
+	assert.Equal(t, config.Config, image.Config)
+	assert.NotEqual(t, parent.ImageID, image.ImageID)
+	assert.Equal(t, parent.BlobSums, image.BlobSums)
+
+	if assert.Len(t, image.History, 2) {
+		assert.Equal(t, "touch /file", image.History[1].CreatedBy)
+		assert.Equal(t, "comment", image.History[1].Comment)
+	}
+
+	// the parent is not modified
+	assert.Len(t, parent.History, 1)
+}
+
+func TestImageHistory(t *testing.T) {
+	now := time.Now()
+
+	parent := &metadata.ImageConfig{
+		V1Image: v1.V1Image{ID: "parentlayer"},
+		ImageID: "parent_id",
+		Tags:    []string{"parent:latest"},
+	}
+
+	image := &metadata.ImageConfig{
+		V1Image: v1.V1Image{
+			ID:     "imagelayer",
+			Parent: "parentlayer",
+		},
+		ImageID:    "image_id",
+		Tags:       []string{"image:latest"},
+		LayerSizes: map[string]int64{"rootlayer": 1024, "parentlayer": 512, "imagelayer": 256},
+		History: []v1.History{
+			{Created: now, CreatedBy: "/bin/sh -c #(nop) ADD file"},
+			{Created: now, CreatedBy: "/bin/sh -c touch /parent"},
+			{Created: now, CreatedBy: "/bin/sh -c #(nop) CMD [\"sh\"]", EmptyLayer: true},
+			{Created: now, CreatedBy: "touch /image", Comment: "comment"},
+		},
+	}
+
+	images := map[string]*metadata.ImageConfig{
+		parent.ID: parent,
+		image.ID:  image,
+	}
+
+	history := imageHistory(image, []string{"imagelayer", "parentlayer", "rootlayer"}, images)
+	if !assert.Len(t, history, 4) {
+		return
+	}
+
+	// the newest entry is first
+	assert.Equal(t, "sha256:image_id", history[0].ID)
+	assert.Equal(t, image.Tags, history[0].Tags)
+	assert.Equal(t, "touch /image", history[0].CreatedBy)
+	assert.Equal(t, "comment", history[0].Comment)
+	assert.Equal(t, int64(256), history[0].Size)
+	assert.Equal(t, now.Unix(), history[0].Created)
+
+	// entries that did not create a layer have no size
+	assert.Equal(t, "<missing>", history[1].ID)
+	assert.Equal(t, int64(0), history[1].Size)
+
+	assert.Equal(t, "sha256:parent_id", history[2].ID)
+	assert.Equal(t, parent.Tags, history[2].Tags)
+	assert.Equal(t, int64(512), history[2].Size)
+
+	assert.Equal(t, "<missing>", history[3].ID)
+	assert.Equal(t, int64(1024), history[3].Size)
+}
@@ -278,7 +278,73 @@ func (n *Network) DisconnectContainerFromNetwork(containerName string, network l
 	if vc != nil {
 		containerName = vc.ContainerID
 	}
-	return fmt.Errorf("%s does not implement network.DisconnectContainerFromNetwork", ProductName())
+
+	client := PortLayerClient()
+	getRes, err := client.Containers.Get(containers.NewGetParamsWithContext(ctx).WithID(containerName))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.GetNotFound:
+			if !force {
+				return derr.NewRequestNotFoundError(fmt.Errorf(err.Payload.Message))
+			}
+
+			// the container has already gone, so only its address and names in the network are released
+			_, err2 := client.Scopes.RemoveContainer(scopes.NewRemoveContainerParamsWithContext(ctx).
+				WithScope(network.Name()).
+				WithHandle(containerName).
+				WithForce(&force))
+			return removeContainerError(err2)
+
+		case *containers.GetDefault:
+			return derr.NewErrorWithStatusCode(fmt.Errorf(err.Payload.Message), http.StatusInternalServerError)
+
+		default:
+			return derr.NewErrorWithStatusCode(err, http.StatusInternalServerError)
+		}
+	}
+
+	// the commit removes the NIC of the container and has the tether tear down the
+	// interface, then releases its address and names in the network if it is running
+	removeRes, err := client.Scopes.RemoveContainer(scopes.NewRemoveContainerParamsWithContext(ctx).
+		WithScope(network.Name()).
+		WithHandle(getRes.Payload))
+	if err != nil {
+		return removeContainerError(err)
+	}
+
+	_, err = client.Containers.Commit(containers.NewCommitParamsWithContext(ctx).WithHandle(removeRes.Payload))
+	if err != nil {
+		switch err := err.(type) {
+		case *containers.CommitNotFound:
+			return derr.NewRequestNotFoundError(fmt.Errorf(err.Payload.Message))
+
+		case *containers.CommitDefault:
+			return derr.NewErrorWithStatusCode(fmt.Errorf(err.Payload.Message), http.StatusInternalServerError)
+
+		default:
+			return derr.NewErrorWithStatusCode(err, http.StatusInternalServerError)
+		}
+	}
+
+	return nil
+}
+
+// removeContainerError converts an error returned when removing a container from a scope
+func removeContainerError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch err := err.(type) {
+	case *scopes.RemoveContainerNotFound:
+		return derr.NewRequestNotFoundError(fmt.Errorf(err.Payload.Message))
+
+	case *scopes.RemoveContainerInternalServerError:
+		return derr.NewErrorWithStatusCode(fmt.Errorf(err.Payload.Message), http.StatusInternalServerError)
+
+	default:
+		return derr.NewErrorWithStatusCode(err, http.StatusInternalServerError)
+	}
 }
 
 func (n *Network) DeleteNetwork(name string) error {
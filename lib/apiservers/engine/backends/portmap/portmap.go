@@ -33,11 +33,28 @@ const (
 
 type PortMapper interface {
 	MapPort(op Operation, ip net.IP, port int, proto string, destIP string, destPort int, srcIface, destIface string) error
+	// MapPorts applies the operation to all of the bindings, or to none of them if
+	// any cannot be mapped, and returns them with the host port of each range resolved
+	MapPorts(op Operation, bindings []Binding, srcIface, destIface string) ([]Binding, error)
+}
+
+// Binding forwards a host port to a port of a container. The host port is on all
+// the addresses of the host if IP is nil, and is the first available one between
+// Port and EndPort if they differ.
+type Binding struct {
+	IP      net.IP
+	Port    int
+	EndPort int
+	Proto   string
+
+	DestIP   string
+	DestPort int
 }
 
 type bindKey struct {
-	ip   string
-	port int
+	ip    string
+	port  int
+	proto string
 }
 
 type portMapper struct {
@@ -50,17 +67,39 @@ func NewPortMapper() PortMapper {
 	return &portMapper{bindings: make(map[bindKey]interface{})}
 }
 
+// hostAddr returns the address of a binding, which is empty if it is on all addresses
+func hostAddr(ip net.IP) string {
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+
+	return ip.String()
+}
+
 func (p *portMapper) isPortAvailable(proto string, ip net.IP, port int) bool {
-	addr := ""
-	if ip != nil && !ip.IsUnspecified() {
-		addr = ip.String()
+	addr := hostAddr(ip)
+
+	// a binding on all addresses conflicts with any other on the same port
+	for k := range p.bindings {
+		if k.port == port && k.proto == proto && (addr == "" || k.ip == "" || k.ip == addr) {
+			return false
+		}
 	}
 
-	if _, ok := p.bindings[bindKey{addr, port}]; ok {
-		return false
+	hostPort := net.JoinHostPort(addr, strconv.Itoa(port))
+
+	// dialing udp succeeds whether or not anything is listening, so try to listen instead
+	if proto == "udp" {
+		c, err := net.ListenPacket(proto, hostPort)
+		if err != nil {
+			return false
+		}
+
+		c.Close()
+		return true
 	}
 
-	c, err := net.Dial(proto, net.JoinHostPort(addr, strconv.Itoa(port)))
+	c, err := net.Dial(proto, hostPort)
 	defer func() {
 		if c != nil {
 			c.Close()
@@ -74,42 +113,117 @@ func (p *portMapper) isPortAvailable(proto string, ip net.IP, port int) bool {
 	return false
 }
 
+// selectPort returns the host port of the binding, which is the first available port of a range
+func (p *portMapper) selectPort(b Binding) (int, error) {
+	end := b.EndPort
+	if end < b.Port {
+		end = b.Port
+	}
+
+	for port := b.Port; port <= end; port++ {
+		if p.isPortAvailable(b.Proto, b.IP, port) {
+			return port, nil
+		}
+	}
+
+	if end != b.Port {
+		return 0, fmt.Errorf("no port is available in range %d-%d", b.Port, end)
+	}
+	return 0, fmt.Errorf("port %d is not available", b.Port)
+}
+
 func (p *portMapper) MapPort(op Operation, ip net.IP, port int, proto string, destIP string, destPort int, srcIface, destIface string) error {
+	b := Binding{
+		IP:       ip,
+		Port:     port,
+		EndPort:  port,
+		Proto:    proto,
+		DestIP:   destIP,
+		DestPort: destPort,
+	}
+
+	_, err := p.MapPorts(op, []Binding{b}, srcIface, destIface)
+	return err
+}
+
+func (p *portMapper) MapPorts(op Operation, bindings []Binding, srcIface, destIface string) ([]Binding, error) {
 	p.Lock()
 	defer p.Unlock()
 
-	var action iptables.Action
 	switch op {
-	case Map:
-		// check if port is available
-		if !p.isPortAvailable(proto, ip, port) {
-			return fmt.Errorf("port %d is not available", port)
-		}
-		action = iptables.Append
-	case Unmap:
-		action = iptables.Delete
+	case Map, Unmap:
 	default:
-		return fmt.Errorf("invalid port mapping operation %d", op)
+		return nil, fmt.Errorf("invalid port mapping operation %d", op)
 	}
 
-	if port <= 0 {
-		return fmt.Errorf("source port must be specified")
+	for _, b := range bindings {
+		if b.Port <= 0 {
+			return nil, fmt.Errorf("source port must be specified")
+		}
+
+		if op == Unmap && b.EndPort > b.Port {
+			return nil, fmt.Errorf("port range %d-%d cannot be unmapped", b.Port, b.EndPort)
+		}
+
+		if b.DestIP == "" {
+			return nil, fmt.Errorf("destination IP is not specified")
+		}
 	}
 
-	if destPort <= 0 {
-		log.Infof("destination port not specified, using source port %d", port)
-		destPort = port
+	mapped := make([]Binding, 0, len(bindings))
+
+	if op == Unmap {
+		// remove as much of the mapping as possible
+		var err error
+		for _, b := range bindings {
+			if uerr := p.forward(iptables.Delete, b, srcIface, destIface); uerr != nil && err == nil {
+				err = uerr
+			}
+			mapped = append(mapped, b)
+		}
+
+		return mapped, err
 	}
 
-	if destIP == "" {
-		return fmt.Errorf("destination IP is not specified")
+	// the bindings are mapped one after the other, so those already mapped count
+	// against the availability of the rest and are removed again on failure
+	for _, b := range bindings {
+		port, err := p.selectPort(b)
+		if err == nil {
+			b.Port, b.EndPort = port, port
+
+			if b.DestPort <= 0 {
+				log.Infof("destination port not specified, using source port %d", port)
+				b.DestPort = port
+			}
+
+			err = p.forward(iptables.Append, b, srcIface, destIface)
+			if err != nil {
+				// remove whatever part of the failed binding was forwarded
+				p.forward(iptables.Delete, b, srcIface, destIface)
+			}
+		}
+
+		if err != nil {
+			for _, m := range mapped {
+				if uerr := p.forward(iptables.Delete, m, srcIface, destIface); uerr != nil {
+					log.Warnf("failed to remove mapping of port %d: %s", m.Port, uerr)
+				}
+			}
+
+			return nil, err
+		}
+
+		mapped = append(mapped, b)
 	}
 
-	return p.forward(action, ip, port, proto, destIP, destPort, srcIface, destIface)
+	return mapped, nil
 }
 
 // adapted from https://github.com/docker/libnetwork/blob/master/iptables/iptables.go
-func (p *portMapper) forward(action iptables.Action, ip net.IP, port int, proto, destAddr string, destPort int, srcIface, destIface string) error {
+func (p *portMapper) forward(action iptables.Action, b Binding, srcIface, destIface string) error {
+	ip, port, proto, destAddr, destPort := b.IP, b.Port, b.Proto, b.DestIP, b.DestPort
+
 	daddr := ip.String()
 	if ip == nil || ip.IsUnspecified() {
 		// iptables interprets "0.0.0.0" as "0.0.0.0/32", whereas we
@@ -130,17 +244,14 @@ func (p *portMapper) forward(action iptables.Action, ip net.IP, port int, proto,
 		return iptables.ChainError{Chain: "FORWARD", Output: output}
 	}
 
-	ipStr := ""
-	if ip != nil && !ip.IsUnspecified() {
-		ipStr = ip.String()
-	}
+	key := bindKey{hostAddr(ip), port, proto}
 
 	switch action {
 	case iptables.Append:
-		p.bindings[bindKey{ipStr, port}] = nil
+		p.bindings[key] = nil
 
 	case iptables.Delete:
-		delete(p.bindings, bindKey{ipStr, port})
+		delete(p.bindings, key)
 	}
 
 	if output, err := iptables.Raw("-t", string(iptables.Filter), string(action), "VIC",
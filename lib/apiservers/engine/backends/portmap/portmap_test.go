@@ -0,0 +1,89 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portmap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPortAvailable(t *testing.T) {
+	p := NewPortMapper().(*portMapper)
+
+	addr := net.ParseIP("127.0.0.2")
+	other := net.ParseIP("127.0.0.3")
+
+	p.bindings[bindKey{"127.0.0.2", 65500, "tcp"}] = nil
+
+	// the same port is free on another address and for another protocol
+	assert.False(t, p.isPortAvailable("tcp", addr, 65500))
+	assert.True(t, p.isPortAvailable("tcp", other, 65500))
+	assert.True(t, p.isPortAvailable("udp", addr, 65500))
+
+	// but not on all addresses
+	assert.False(t, p.isPortAvailable("tcp", nil, 65500))
+	assert.False(t, p.isPortAvailable("tcp", net.IPv4zero, 65500))
+
+	// and a binding on all addresses takes the port on each of them
+	p.bindings[bindKey{"", 65501, "tcp"}] = nil
+	assert.False(t, p.isPortAvailable("tcp", other, 65501))
+	assert.False(t, p.isPortAvailable("tcp", nil, 65501))
+}
+
+func TestSelectPort(t *testing.T) {
+	p := NewPortMapper().(*portMapper)
+
+	p.bindings[bindKey{"", 65500, "tcp"}] = nil
+	p.bindings[bindKey{"", 65501, "tcp"}] = nil
+
+	port, err := p.selectPort(Binding{Port: 65500, EndPort: 65502, Proto: "tcp"})
+	assert.NoError(t, err)
+	assert.Equal(t, 65502, port)
+
+	port, err = p.selectPort(Binding{Port: 65500, EndPort: 65500, Proto: "udp"})
+	assert.NoError(t, err)
+	assert.Equal(t, 65500, port)
+
+	_, err = p.selectPort(Binding{Port: 65500, EndPort: 65501, Proto: "tcp"})
+	assert.Error(t, err)
+
+	_, err = p.selectPort(Binding{Port: 65501, Proto: "tcp"})
+	assert.Error(t, err)
+}
+
+func TestMapPortsInvalid(t *testing.T) {
+	p := NewPortMapper()
+
+	// nothing is mapped if any binding is invalid
+	bindings := []Binding{
+		{Port: 65500, Proto: "tcp", DestIP: "172.16.0.2", DestPort: 80},
+		{Port: 65501, Proto: "tcp"},
+	}
+	_, err := p.MapPorts(Map, bindings, "client", "bridge")
+	assert.Error(t, err)
+	assert.Empty(t, p.(*portMapper).bindings)
+
+	// ranges are resolved when they are mapped, so cannot be unmapped
+	bindings = []Binding{
+		{Port: 65500, EndPort: 65510, Proto: "tcp", DestIP: "172.16.0.2", DestPort: 80},
+	}
+	_, err = p.MapPorts(Unmap, bindings, "client", "bridge")
+	assert.Error(t, err)
+
+	_, err = p.MapPorts(Operation(5), bindings, "client", "bridge")
+	assert.Error(t, err)
+}
@@ -27,10 +27,13 @@ package backends
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -40,10 +43,12 @@ import (
 	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
 	"github.com/vmware/vic/lib/apiservers/portlayer/client"
 	"github.com/vmware/vic/lib/apiservers/portlayer/client/storage"
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
 	urlfetcher "github.com/vmware/vic/pkg/fetcher"
 	"github.com/vmware/vic/pkg/trace"
 	"github.com/vmware/vic/pkg/version"
 
+	dockerevents "github.com/docker/docker/daemon/events"
 	"github.com/docker/docker/pkg/platform"
 	"github.com/docker/engine-api/types"
 	"github.com/docker/engine-api/types/events"
@@ -53,6 +58,10 @@ import (
 
 type System struct {
 	systemProxy VicSystemProxy
+
+	// subscribers maps each events listener to the cancel func of its stream
+	subscribersLock sync.Mutex
+	subscribers     map[chan interface{}]context.CancelFunc
 }
 
 const (
@@ -63,11 +72,22 @@ const (
 	systemProductName  = " VMware Product"
 	volumeStoresID     = "VolumeStores"
 	loginTimeout       = 20 * time.Second
+
+	// eventsBuffer is the number of events queued for a slow events listener
+	eventsBuffer = 64
+
+	// eventsReconnectAttempts is the number of times a broken events stream is
+	// reconnected without receiving an event before the listener is given up on
+	eventsReconnectAttempts = 5
 )
 
+// eventsReconnectDelay is the time between attempts to reconnect a broken events stream
+var eventsReconnectDelay = 2 * time.Second
+
 func NewSystemBackend() *System {
 	return &System{
 		systemProxy: &SystemProxy{},
+		subscribers: make(map[chan interface{}]context.CancelFunc),
 	}
 }
 
@@ -235,12 +255,156 @@ func (s *System) SystemVersion() types.Version {
 	return version
 }
 
+// SubscribeToEvents returns the recorded events matching the filters created
+// since the given time and a channel on which subsequent matching events are
+// sent. Without a since time only subsequent events are returned.
 func (s *System) SubscribeToEvents(since, sinceNano int64, ef filters.Args) ([]events.Message, chan interface{}) {
-	return make([]events.Message, 0, 0), make(chan interface{})
+	defer trace.End(trace.Begin(""))
+
+	filter := dockerevents.NewFilter(ef)
+	actors := make(map[string]map[string]string)
+	listener := make(chan interface{}, eventsBuffer)
+
+	start := time.Now()
+	if since != 0 || sinceNano != 0 {
+		start = time.Unix(since, sinceNano)
+	}
+
+	buffered := make([]events.Message, 0)
+
+	history, last, err := s.systemProxy.EventHistory(start)
+	if err != nil {
+		log.Errorf("Unable to retrieve the event history: %s", err)
+		return buffered, listener
+	}
+
+	for _, e := range history {
+		for _, msg := range DockerEvents(e, eventAttributes(e, actors)) {
+			if filter.Include(msg) {
+				buffered = append(buffered, msg)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.subscribersLock.Lock()
+	s.subscribers[listener] = cancel
+	s.subscribersLock.Unlock()
+
+	go s.streamEvents(ctx, last, filter, actors, listener)
+
+	return buffered, listener
+}
+
+// UnsubscribeFromEvents stops the events stream of the listener
+func (s *System) UnsubscribeFromEvents(listener chan interface{}) {
+	defer trace.End(trace.Begin(""))
+
+	s.subscribersLock.Lock()
+	cancel, ok := s.subscribers[listener]
+	delete(s.subscribers, listener)
+	s.subscribersLock.Unlock()
+
+	if ok {
+		cancel()
+	}
 }
 
-func (s *System) UnsubscribeFromEvents(chan interface{}) {
+// streamEvents sends the events published after the given position that match
+// the filter to the listener until the context is canceled. A broken stream is
+// reconnected from the last event received, and the listener is unsubscribed
+// if the port layer cannot be reached again.
+func (s *System) streamEvents(ctx context.Context, after int64, filter *dockerevents.Filter, actors map[string]map[string]string, listener chan interface{}) {
+	failures := 0
+	for {
+		last, err := s.streamEventsAfter(ctx, after, filter, actors, listener)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// only consecutive failures to receive anything count against the attempts
+		if last != after {
+			after = last
+			failures = 0
+		}
+
+		failures++
+		if failures > eventsReconnectAttempts {
+			log.Errorf("Unable to reconnect the events stream from the port layer, ending the stream: %s", err)
+			s.UnsubscribeFromEvents(listener)
+			return
+		}
+
+		log.Warnf("Events stream from the port layer ended, reconnecting after event %d: %s", after, err)
+
+		select {
+		case <-time.After(eventsReconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// streamEventsAfter sends the events published after the given position that match
+// the filter to the listener until the stream ends. It returns the position of the
+// last event received and the reason the stream ended.
+func (s *System) streamEventsAfter(ctx context.Context, after int64, filter *dockerevents.Filter, actors map[string]map[string]string, listener chan interface{}) (int64, error) {
+	r, w := io.Pipe()
+	defer r.Close()
+
+	go func() {
+		w.CloseWithError(s.systemProxy.StreamEvents(ctx, after, w))
+	}()
+
+	dec := json.NewDecoder(r)
+	for {
+		e := &models.Event{}
+		if err := dec.Decode(e); err != nil {
+			return after, err
+		}
+		after = e.ID
+
+		for _, msg := range DockerEvents(e, eventAttributes(e, actors)) {
+			if !filter.Include(msg) {
+				continue
+			}
+
+			select {
+			case listener <- msg:
+			case <-ctx.Done():
+				return after, ctx.Err()
+			}
+		}
+	}
+}
+
+// eventAttributes returns the name, image and labels of the container the event
+// is about. They are remembered in actors so are still available once the
+// container has been removed from the cache.
+func eventAttributes(e *models.Event, actors map[string]map[string]string) map[string]string {
+	if e.Ref == nil {
+		return nil
+	}
+	id := *e.Ref
+
+	if vc := cache.ContainerCache().GetContainer(id); vc != nil {
+		attributes := map[string]string{"name": vc.Name}
+		if vc.Config != nil {
+			for k, v := range vc.Config.Labels {
+				attributes[k] = v
+			}
+			attributes["image"] = vc.Config.Image
+		}
+		actors[id] = attributes
+	}
+
+	attributes := actors[id]
+	if e.Event == "Removed" {
+		delete(actors, id)
+	}
 
+	return attributes
 }
 
 func (s *System) AuthenticateToRegistry(ctx context.Context, authConfig *types.AuthConfig) (string, string, error) {
@@ -33,12 +33,19 @@ package backends
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
 
 	log "github.com/Sirupsen/logrus"
 	derr "github.com/docker/docker/errors"
+	"github.com/docker/engine-api/types/events"
+	"github.com/go-swagger/go-swagger/swag"
 
 	"github.com/vmware/vic/lib/apiservers/portlayer/client/containers"
+	plevents "github.com/vmware/vic/lib/apiservers/portlayer/client/events"
 	"github.com/vmware/vic/lib/apiservers/portlayer/client/misc"
 	"github.com/vmware/vic/lib/apiservers/portlayer/models"
 	"github.com/vmware/vic/pkg/trace"
@@ -48,6 +55,26 @@ type VicSystemProxy interface {
 	PingPortlayer() bool
 	ContainerCount() (int, int, int, error)
 	VCHInfo() (*models.VCHInfo, error)
+	EventHistory(since time.Time) ([]*models.Event, int64, error)
+	StreamEvents(ctx context.Context, after int64, out io.Writer) error
+}
+
+// containerEventActions maps the port layer container events to the docker
+// event actions they represent
+var containerEventActions = map[string][]string{
+	"Created":      {"create"},
+	"Started":      {"start"},
+	"PoweredOn":    {"start"},
+	"Stopped":      {"die", "stop"},
+	"PoweredOff":   {"die"},
+	"Shutdown":     {"die"},
+	"Suspended":    {"pause"},
+	"Resumed":      {"unpause"},
+	"Removed":      {"destroy"},
+	"Reconfigured": {"update"},
+	"Renamed":      {"rename"},
+	"Healthy":      {"health_status: healthy"},
+	"Unhealthy":    {"health_status: unhealthy"},
 }
 
 type SystemProxy struct{}
@@ -96,6 +123,8 @@ func (s *SystemProxy) ContainerCount() (int, int, int, error) {
 	for _, t := range containList.Payload {
 		if *t.ContainerConfig.State == "Running" {
 			running++
+		} else if *t.ContainerConfig.State == "Paused" {
+			paused++
 		} else if *t.ContainerConfig.State == "Stopped" || *t.ContainerConfig.State == "Created" {
 			stopped++
 		}
@@ -124,3 +153,79 @@ func (s *SystemProxy) VCHInfo() (*models.VCHInfo, error) {
 
 	return resp.Payload, nil
 }
+
+// EventHistory returns the port layer events created since the given time along
+// with the position of the last event published
+func (s *SystemProxy) EventHistory(since time.Time) ([]*models.Event, int64, error) {
+	defer trace.End(trace.Begin("EventHistory"))
+
+	plClient := PortLayerClient()
+	if plClient == nil {
+		return nil, 0, derr.NewErrorWithStatusCode(fmt.Errorf("EventHistory failed to create a portlayer client"),
+			http.StatusInternalServerError)
+	}
+
+	params := plevents.NewGetEventHistoryParamsWithContext(ctx).WithSince(swag.Int64(since.UnixNano()))
+	resp, err := plClient.Events.GetEventHistory(params)
+	if err != nil {
+		return nil, 0, derr.NewErrorWithStatusCode(fmt.Errorf("Unknown error from port layer: %s", err),
+			http.StatusInternalServerError)
+	}
+
+	return resp.Payload.Events, swag.Int64Value(resp.Payload.Last), nil
+}
+
+// StreamEvents writes the port layer events published after the given position
+// to out, one JSON object per event, until the context is canceled
+func (s *SystemProxy) StreamEvents(ctx context.Context, after int64, out io.Writer) error {
+	defer trace.End(trace.Begin("StreamEvents"))
+
+	// there can be a long time between events so there is no timeout for response
+	plClient, transport := createNewAttachClientWithTimeouts(attachConnectTimeout, 0, attachAttemptTimeout)
+	defer transport.Close()
+
+	params := plevents.NewGetEventsParamsWithContext(ctx).WithAfter(swag.Int64(after))
+	_, err := plClient.Events.GetEvents(params, out)
+	if err != nil && ctx.Err() == nil {
+		return InternalServerError(err.Error())
+	}
+
+	return nil
+}
+
+// DockerEvents converts a port layer event to the docker events it represents.
+// The attributes, e.g. the name and image of a container, are added to the actor.
+func DockerEvents(e *models.Event, attributes map[string]string) []events.Message {
+	if e.Type != events.ContainerEventType {
+		return nil
+	}
+
+	var created time.Time
+	if e.Created != nil {
+		created = time.Unix(0, *e.Created)
+	} else {
+		created = time.Now()
+	}
+
+	id := swag.StringValue(e.Ref)
+	actions := containerEventActions[e.Event]
+
+	msgs := make([]events.Message, len(actions))
+	for i, action := range actions {
+		msgs[i] = events.Message{
+			Status: action,
+			ID:     id,
+			From:   attributes["image"],
+			Type:   events.ContainerEventType,
+			Action: action,
+			Actor: events.Actor{
+				ID:         id,
+				Attributes: attributes,
+			},
+			Time:     created.Unix(),
+			TimeNano: created.UnixNano(),
+		}
+	}
+
+	return msgs
+}
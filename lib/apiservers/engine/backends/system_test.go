@@ -0,0 +1,189 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backends
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/go-swagger/go-swagger/swag"
+	"github.com/stretchr/testify/assert"
+
+	dockerevents "github.com/docker/docker/daemon/events"
+	"github.com/docker/engine-api/types/events"
+	"github.com/docker/engine-api/types/filters"
+
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+)
+
+func TestDockerEvents(t *testing.T) {
+	created := time.Now()
+	attributes := map[string]string{"name": "web", "image": "nginx"}
+
+	e := &models.Event{
+		ID:      1,
+		Type:    events.ContainerEventType,
+		Event:   "Stopped",
+		Ref:     swag.String("abc123"),
+		Created: swag.Int64(created.UnixNano()),
+	}
+
+	msgs := DockerEvents(e, attributes)
+	if !assert.Len(t, msgs, 2) {
+		return
+	}
+
+	assert.Equal(t, "die", msgs[0].Action)
+	assert.Equal(t, "stop", msgs[1].Action)
+	assert.Equal(t, "abc123", msgs[1].Actor.ID)
+	assert.Equal(t, "nginx", msgs[1].From)
+	assert.Equal(t, created.UnixNano(), msgs[1].TimeNano)
+
+	ef := filters.NewArgs()
+	ef.Add("container", "web")
+	ef.Add("event", "stop")
+	filter := dockerevents.NewFilter(ef)
+	assert.False(t, filter.Include(msgs[0]))
+	assert.True(t, filter.Include(msgs[1]))
+
+	e.Event = "Unhealthy"
+	msgs = DockerEvents(e, attributes)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "health_status: unhealthy", msgs[0].Action)
+	}
+
+	// events the docker api has no equivalent for are dropped
+	e.Event = "Unknown"
+	assert.Empty(t, DockerEvents(e, attributes))
+	e.Type = "network"
+	e.Event = "Created"
+	assert.Empty(t, DockerEvents(e, attributes))
+}
+
+// streamingSystemProxy serves each events stream from its list of events, breaking
+// the stream after the number of events given for the connection
+type streamingSystemProxy struct {
+	SystemProxy
+
+	m      sync.Mutex
+	events []*models.Event
+	breaks []int
+	afters []int64
+}
+
+func (p *streamingSystemProxy) StreamEvents(ctx context.Context, after int64, out io.Writer) error {
+	p.m.Lock()
+	p.afters = append(p.afters, after)
+	limit := -1
+	if len(p.breaks) > 0 {
+		limit, p.breaks = p.breaks[0], p.breaks[1:]
+	}
+	p.m.Unlock()
+
+	enc := json.NewEncoder(out)
+	sent := 0
+	for _, e := range p.events {
+		if e.ID <= after {
+			continue
+		}
+		if sent == limit {
+			return errors.New("connection reset")
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		sent++
+	}
+
+	if limit >= 0 {
+		return errors.New("connection reset")
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func TestStreamEventsReconnect(t *testing.T) {
+	delay := eventsReconnectDelay
+	eventsReconnectDelay = time.Millisecond
+	defer func() { eventsReconnectDelay = delay }()
+
+	event := func(id int64, ref string) *models.Event {
+		return &models.Event{ID: id, Type: events.ContainerEventType, Event: "Started", Ref: swag.String(ref)}
+	}
+
+	// the stream breaks after the first event, then fails to deliver anything once
+	proxy := &streamingSystemProxy{
+		events: []*models.Event{event(1, "a"), event(2, "b"), event(3, "c")},
+		breaks: []int{1, 0},
+	}
+	s := &System{
+		systemProxy: proxy,
+		subscribers: make(map[chan interface{}]context.CancelFunc),
+	}
+
+	listener := make(chan interface{}, eventsBuffer)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.subscribers[listener] = cancel
+	go s.streamEvents(ctx, 0, dockerevents.NewFilter(filters.NewArgs()), make(map[string]map[string]string), listener)
+
+	var ids []string
+	for len(ids) < 3 {
+		select {
+		case msg := <-listener:
+			ids = append(ids, msg.(events.Message).ID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, received %v", ids)
+		}
+	}
+	s.UnsubscribeFromEvents(listener)
+
+	// each event is received once, resuming after the last one received
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+	proxy.m.Lock()
+	assert.Equal(t, []int64{0, 1, 1}, proxy.afters)
+	proxy.m.Unlock()
+
+	// the listener is unsubscribed once the stream cannot be reconnected
+	proxy = &streamingSystemProxy{breaks: make([]int, eventsReconnectAttempts+1)}
+	s.systemProxy = proxy
+
+	listener = make(chan interface{}, eventsBuffer)
+	ctx, cancel = context.WithCancel(context.Background())
+	s.subscribers[listener] = cancel
+
+	done := make(chan struct{})
+	go func() {
+		s.streamEvents(ctx, 0, dockerevents.NewFilter(filters.NewArgs()), make(map[string]map[string]string), listener)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream to be given up on")
+	}
+
+	s.subscribersLock.Lock()
+	assert.NotContains(t, s.subscribers, listener)
+	s.subscribersLock.Unlock()
+	assert.Len(t, proxy.afters, eventsReconnectAttempts+1)
+}
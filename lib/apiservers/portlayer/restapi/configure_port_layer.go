@@ -51,6 +51,8 @@ var portlayerhandlers = []handler{
 	&handlers.InteractionHandlersImpl{},
 	&handlers.LoggingHandlersImpl{},
 	&handlers.KvHandlersImpl{},
+	&handlers.TaskHandlersImpl{},
+	&handlers.EventsHandlersImpl{},
 }
 
 func configureFlags(api *operations.PortLayerAPI) {
@@ -20,9 +20,11 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"regexp"
 	"time"
 
 	middleware "github.com/go-swagger/go-swagger/httpkit/middleware"
+	"github.com/go-swagger/go-swagger/swag"
 	"golang.org/x/net/context"
 
 	log "github.com/Sirupsen/logrus"
@@ -34,6 +36,9 @@ import (
 	"github.com/vmware/vic/lib/apiservers/portlayer/restapi/operations/containers"
 	"github.com/vmware/vic/lib/config/executor"
 	"github.com/vmware/vic/lib/portlayer/exec"
+	"github.com/vmware/vic/lib/portlayer/metrics"
+	"github.com/vmware/vic/lib/portlayer/network"
+	"github.com/vmware/vic/pkg/iolog"
 	"github.com/vmware/vic/pkg/trace"
 	"github.com/vmware/vic/pkg/uid"
 	"github.com/vmware/vic/pkg/version"
@@ -44,6 +49,9 @@ const containerWaitTimeout = 3 * time.Minute
 // ContainersHandlersImpl is the receiver for all of the exec handler methods
 type ContainersHandlersImpl struct {
 	handlerCtx *HandlerContext
+
+	// samples the performance counters of container VMs
+	collector *metrics.Collector
 }
 
 // Configure assigns functions to all the exec api handlers
@@ -59,8 +67,17 @@ func (handler *ContainersHandlersImpl) Configure(api *operations.PortLayerAPI, h
 	api.ContainersContainerSignalHandler = containers.ContainerSignalHandlerFunc(handler.ContainerSignalHandler)
 	api.ContainersGetContainerLogsHandler = containers.GetContainerLogsHandlerFunc(handler.GetContainerLogsHandler)
 	api.ContainersContainerWaitHandler = containers.ContainerWaitHandlerFunc(handler.ContainerWaitHandler)
+	api.ContainersGetContainerStatsHandler = containers.GetContainerStatsHandlerFunc(handler.GetContainerStatsHandler)
+	api.ContainersContainerRenameHandler = containers.ContainerRenameHandlerFunc(handler.RenameContainerHandler)
+	api.ContainersContainerUpdateHandler = containers.ContainerUpdateHandlerFunc(handler.UpdateContainerHandler)
 
 	handler.handlerCtx = handlerCtx
+
+	// stats are unavailable if this fails, but that shouldn't prevent startup
+	var err error
+	if handler.collector, err = metrics.NewCollector(handlerCtx.Session); err != nil {
+		log.Errorf("Unable to access performance counters: %s", err)
+	}
 }
 
 // CreateHandler creates a new container
@@ -112,12 +129,27 @@ func (handler *ContainersHandlersImpl) CreateHandler(params containers.CreatePar
 					Args: append([]string{*params.CreateConfig.Path}, params.CreateConfig.Args...),
 				},
 				StopSignal: *params.CreateConfig.StopSignal,
+				User:       swag.StringValue(params.CreateConfig.User),
 			},
 		},
 		Key:      pem.EncodeToMemory(&privateKeyBlock),
 		LayerID:  *params.CreateConfig.Image,
 		RepoName: *params.CreateConfig.RepoName,
 	}
+	if p := params.CreateConfig.RestartPolicy; p != nil {
+		m.RestartPolicy = executor.RestartPolicy{
+			Name:              swag.StringValue(p.Name),
+			MaximumRetryCount: int(swag.Int32Value(p.MaximumRetryCount)),
+		}
+	}
+	if hc := params.CreateConfig.Healthcheck; hc != nil {
+		m.Sessions[id].Healthcheck = executor.HealthConfig{
+			Test:     hc.Test,
+			Interval: time.Duration(swag.Int64Value(hc.Interval)),
+			Timeout:  time.Duration(swag.Int64Value(hc.Timeout)),
+			Retries:  int(swag.Int32Value(hc.Retries)),
+		}
+	}
 	if params.CreateConfig.Annotations != nil && len(params.CreateConfig.Annotations) > 0 {
 		m.Annotations = make(map[string]string)
 		for k, v := range params.CreateConfig.Annotations {
@@ -161,12 +193,19 @@ func (handler *ContainersHandlersImpl) StateChangeHandler(params containers.Stat
 		state = exec.StateRunning
 	case "STOPPED":
 		state = exec.StateStopped
+	case "PAUSED":
+		state = exec.StatePaused
 	case "CREATED":
 		state = exec.StateCreated
 	default:
 		return containers.NewStateChangeDefault(http.StatusServiceUnavailable).WithPayload(&models.Error{Message: "unknown state"})
 	}
 
+	// a container that is started on request starts counting its restarts afresh
+	if state == exec.StateRunning && h.Container.CurrentState() != exec.StateRunning {
+		h.ExecConfig.RestartCount = 0
+	}
+
 	h.SetState(state)
 	return containers.NewStateChangeOK().WithPayload(h.String())
 }
@@ -187,6 +226,9 @@ func (handler *ContainersHandlersImpl) GetStateHandler(params containers.GetStat
 	case exec.StateStopped:
 		state = "STOPPED"
 
+	case exec.StatePaused:
+		state = "PAUSED"
+
 	case exec.StateCreated:
 		state = "CREATED"
 
@@ -219,7 +261,7 @@ func (handler *ContainersHandlersImpl) CommitHandler(params containers.CommitPar
 	if err := h.Commit(context.Background(), handler.handlerCtx.Session, params.WaitTime); err != nil {
 		log.Errorf("CommitHandler error on handle(%s) for %s: %#v", h.String(), h.ExecConfig.ID, err)
 		switch err := err.(type) {
-		case exec.ConcurrentAccessError:
+		case exec.ConcurrentAccessError, exec.DiskInUseError:
 			return containers.NewCommitConflict().WithPayload(&models.Error{Message: err.Error()})
 		default:
 			return containers.NewCommitDefault(http.StatusServiceUnavailable).WithPayload(&models.Error{Message: err.Error()})
@@ -254,6 +296,72 @@ func (handler *ContainersHandlersImpl) RemoveContainerHandler(params containers.
 	return containers.NewContainerRemoveOK()
 }
 
+// RenameContainerHandler renames a container, along with its VM and network names
+func (handler *ContainersHandlersImpl) RenameContainerHandler(params containers.ContainerRenameParams) middleware.Responder {
+	defer trace.End(trace.Begin(fmt.Sprintf("%s -> %s", params.ID, params.Name)))
+
+	ctx := context.Background()
+
+	h := exec.GetContainer(ctx, uid.Parse(params.ID))
+	if h == nil {
+		return containers.NewContainerRenameNotFound().WithPayload(&models.Error{Message: fmt.Sprintf("container %s not found", params.ID)})
+	}
+	defer h.Close()
+
+	// the network context moves the DNS names of the container along with the commit
+	var err error
+	if network.DefaultContext != nil {
+		err = network.DefaultContext.RenameContainer(ctx, handler.handlerCtx.Session, h, params.Name)
+	} else {
+		err = h.Rename(params.Name).Commit(ctx, handler.handlerCtx.Session, nil)
+	}
+
+	if err != nil {
+		log.Errorf("RenameContainerHandler error for %s: %s", params.ID, err)
+		switch err.(type) {
+		case network.DuplicateResourceError, exec.ConcurrentAccessError:
+			return containers.NewContainerRenameConflict().WithPayload(&models.Error{Message: err.Error()})
+		default:
+			return containers.NewContainerRenameInternalServerError().WithPayload(&models.Error{Message: err.Error()})
+		}
+	}
+
+	return containers.NewContainerRenameOK()
+}
+
+// UpdateContainerHandler sets the resources and restart policy of the container on the handle
+func (handler *ContainersHandlersImpl) UpdateContainerHandler(params containers.ContainerUpdateParams) middleware.Responder {
+	defer trace.End(trace.Begin(fmt.Sprintf("handle(%s)", params.Handle)))
+
+	h := exec.GetHandle(params.Handle)
+	if h == nil {
+		return containers.NewContainerUpdateNotFound().WithPayload(&models.Error{Message: "container not found"})
+	}
+
+	var warnings []string
+	if r := params.Config.Resources; r != nil {
+		warnings = h.UpdateResources(exec.Resources{
+			CPUShares:         swag.Int32Value(r.CPUShares),
+			NumCPUs:           swag.Int32Value(r.CPUCount),
+			MemoryMB:          swag.Int64Value(r.MemoryLimit),
+			MemoryReservation: swag.Int64Value(r.MemoryReservation),
+		})
+	}
+
+	if p := params.Config.RestartPolicy; p != nil {
+		h.ExecConfig.RestartPolicy = executor.RestartPolicy{
+			Name:              swag.StringValue(p.Name),
+			MaximumRetryCount: int(swag.Int32Value(p.MaximumRetryCount)),
+		}
+	}
+
+	handle := h.String()
+	return containers.NewContainerUpdateOK().WithPayload(&models.ContainerUpdateResponse{
+		Handle:   &handle,
+		Warnings: warnings,
+	})
+}
+
 func (handler *ContainersHandlersImpl) GetContainerInfoHandler(params containers.GetContainerInfoParams) middleware.Responder {
 	defer trace.End(trace.Begin(params.ID))
 
@@ -271,18 +379,56 @@ func (handler *ContainersHandlersImpl) GetContainerInfoHandler(params containers
 func (handler *ContainersHandlersImpl) GetContainerListHandler(params containers.GetContainerListParams) middleware.Responder {
 	defer trace.End(trace.Begin(""))
 
-	var state *exec.State
-	if params.All != nil && !*params.All {
-		state = new(exec.State)
-		*state = exec.StateRunning
+	filter := &exec.ContainerFilter{
+		All:      params.All == nil || *params.All,
+		IDs:      params.ID,
+		States:   params.State,
+		LayerIDs: params.Layer,
+		Networks: params.Network,
+		Volumes:  params.Volume,
+		Limit:    int(swag.Int64Value(params.Limit)),
+	}
+
+	for _, name := range params.Name {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			return containers.NewGetContainerListBadRequest().WithPayload(&models.Error{Message: fmt.Sprintf("invalid name filter %q: %s", name, err)})
+		}
+		filter.Names = append(filter.Names, re)
+	}
+
+	for _, code := range params.Exited {
+		filter.ExitCodes = append(filter.ExitCodes, int(code))
+	}
+
+	if params.Since != nil {
+		if filter.Since = exec.Containers.Container(*params.Since); filter.Since == nil {
+			return containers.NewGetContainerListNotFound().WithPayload(&models.Error{Message: fmt.Sprintf("container %s not found", *params.Since)})
+		}
+	}
+	if params.Before != nil {
+		if filter.Before = exec.Containers.Container(*params.Before); filter.Before == nil {
+			return containers.NewGetContainerListNotFound().WithPayload(&models.Error{Message: fmt.Sprintf("container %s not found", *params.Before)})
+		}
 	}
 
-	containerVMs := exec.Containers.Containers(state)
+	containerVMs := exec.Containers.Filter(filter)
 	containerList := make([]*models.ContainerInfo, 0, len(containerVMs))
 
 	for _, container := range containerVMs {
 		// convert to return model
 		info := convertContainerToContainerInfo(container)
+
+		if swag.BoolValue(params.Size) {
+			rw, rootfs, err := container.DiskSize(context.Background())
+			if err != nil {
+				log.Warnf("Unable to get the disk size of container %s: %s", container.ExecConfig.ID, err)
+			} else {
+				info.ContainerConfig.StorageSize = &rw
+				info.ContainerConfig.RootFsSize = &rootfs
+			}
+		}
+
 		containerList = append(containerList, info)
 	}
 	return containers.NewGetContainerListOK().WithPayload(containerList)
@@ -315,17 +461,32 @@ func (handler *ContainersHandlersImpl) GetContainerLogsHandler(params containers
 	}
 
 	follow := false
-	tail := -1
+	opts := iolog.Options{
+		Tail: -1,
+	}
 
 	if params.Follow != nil {
 		follow = *params.Follow
 	}
 
 	if params.Taillines != nil {
-		tail = int(*params.Taillines)
+		opts.Tail = int(*params.Taillines)
 	}
 
-	reader, err := h.Container.LogReader(context.Background(), tail, follow)
+	// since and until are in unix seconds, with zero leaving the time unbounded
+	if params.Since != nil && *params.Since > 0 {
+		opts.Since = time.Unix(*params.Since, 0)
+	}
+
+	if params.Until != nil && *params.Until > 0 {
+		opts.Until = time.Unix(*params.Until, 0)
+	}
+
+	if params.Timestamp != nil {
+		opts.Timestamps = *params.Timestamp
+	}
+
+	reader, err := h.Container.LogReader(context.Background(), follow, opts)
 	if err != nil {
 		return containers.NewGetContainerLogsInternalServerError().WithPayload(&models.Error{Message: err.Error()})
 	}
@@ -369,6 +530,77 @@ func (handler *ContainersHandlersImpl) ContainerWaitHandler(params containers.Co
 	}
 }
 
+// GetContainerStatsHandler returns the most recent performance sample of a running container
+func (handler *ContainersHandlersImpl) GetContainerStatsHandler(params containers.GetContainerStatsParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	c := exec.Containers.Container(uid.Parse(params.ID).String())
+	if c == nil {
+		return containers.NewGetContainerStatsNotFound().WithPayload(&models.Error{
+			Message: fmt.Sprintf("container %s not found", params.ID),
+		})
+	}
+
+	ref := c.VMReference()
+	if c.CurrentState() != exec.StateRunning || ref == nil {
+		return containers.NewGetContainerStatsConflict().WithPayload(&models.Error{
+			Message: fmt.Sprintf("container %s is not running", params.ID),
+		})
+	}
+
+	if handler.collector == nil {
+		return containers.NewGetContainerStatsInternalServerError().WithPayload(&models.Error{
+			Message: "performance counters are not available",
+		})
+	}
+
+	sample, err := handler.collector.Sample(context.Background(), *ref)
+	if err != nil {
+		return containers.NewGetContainerStatsInternalServerError().WithPayload(&models.Error{Message: err.Error()})
+	}
+
+	stats := &models.ContainerStats{
+		Read:         sample.Timestamp.UnixNano(),
+		Interval:     &sample.Interval,
+		CPUUsage:     &sample.CPUUsage,
+		MemoryActive: &sample.MemoryActive,
+		NetworkRx:    &sample.NetworkRx,
+		NetworkTx:    &sample.NetworkTx,
+		DiskRead:     &sample.DiskRead,
+		DiskWrite:    &sample.DiskWrite,
+	}
+
+	// the limits are those of the container VM
+	if c.Config != nil {
+		cpus := c.Config.Hardware.NumCPU
+		memory := int64(c.Config.Hardware.MemoryMB) * 1024
+
+		stats.CPUCount = &cpus
+		stats.MemoryLimit = &memory
+	}
+
+	return containers.NewGetContainerStatsOK().WithPayload(stats)
+}
+
+// convertHealth converts the health of a session to the API model
+func convertHealth(health executor.Health) *models.Health {
+	h := &models.Health{
+		Status:        swag.String(health.Status),
+		FailingStreak: swag.Int32(int32(health.FailingStreak)),
+	}
+
+	for _, l := range health.Log {
+		h.Log = append(h.Log, &models.HealthLog{
+			Start:    swag.Int64(l.Start.UnixNano()),
+			End:      swag.Int64(l.End.UnixNano()),
+			ExitCode: swag.Int32(int32(l.ExitCode)),
+			Output:   swag.String(l.Output),
+		})
+	}
+
+	return h
+}
+
 // utility function to convert from a Container type to the API Model ContainerInfo (which should prob be called ContainerDetail)
 func convertContainerToContainerInfo(container *exec.Container) *models.ContainerInfo {
 	defer trace.End(trace.Begin(container.ExecConfig.ID))
@@ -385,7 +617,8 @@ func convertContainerToContainerInfo(container *exec.Container) *models.Containe
 	info.ContainerConfig.CreateTime = &container.ExecConfig.CreateTime
 	info.ContainerConfig.Names = []string{container.ExecConfig.Name}
 
-	restart := int32(container.ExecConfig.Diagnostics.ResurrectionCount)
+	// the tether restarts the process within the VM, the port layer restarts the VM itself
+	restart := int32(container.ExecConfig.RestartCount + container.ExecConfig.Diagnostics.ResurrectionCount)
 	info.ContainerConfig.RestartCount = &restart
 
 	tty := container.ExecConfig.Sessions[ccid].Tty
@@ -429,6 +662,28 @@ func convertContainerToContainerInfo(container *exec.Container) *models.Containe
 	status := container.ExecConfig.Sessions[ccid].Started
 	info.ProcessConfig.Status = &status
 
+	if health := container.ExecConfig.Sessions[ccid].Health; health.Status != "" {
+		info.ProcessConfig.Health = convertHealth(health)
+	}
+
+	if container.Config != nil {
+		info.ContainerConfig.Resources = &models.ResourceConfig{
+			CPUCount:    &container.Config.Hardware.NumCPU,
+			MemoryLimit: swag.Int64(int64(container.Config.Hardware.MemoryMB)),
+		}
+		if a := container.Config.CpuAllocation; a != nil && a.GetResourceAllocationInfo().Shares != nil {
+			info.ContainerConfig.Resources.CPUShares = &a.GetResourceAllocationInfo().Shares.Shares
+		}
+		if a := container.Config.MemoryAllocation; a != nil {
+			info.ContainerConfig.Resources.MemoryReservation = &a.GetResourceAllocationInfo().Reservation
+		}
+	}
+
+	info.ContainerConfig.RestartPolicy = &models.RestartPolicy{
+		Name:              &container.ExecConfig.RestartPolicy.Name,
+		MaximumRetryCount: swag.Int32(int32(container.ExecConfig.RestartPolicy.MaximumRetryCount)),
+	}
+
 	info.HostConfig = &models.HostConfig{}
 	for _, endpoint := range container.ExecConfig.Networks {
 		if len(endpoint.Ports) > 0 {
@@ -0,0 +1,181 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/go-swagger/go-swagger/httpkit"
+	middleware "github.com/go-swagger/go-swagger/httpkit/middleware"
+	"github.com/go-swagger/go-swagger/swag"
+
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+	"github.com/vmware/vic/lib/apiservers/portlayer/restapi/operations"
+	"github.com/vmware/vic/lib/apiservers/portlayer/restapi/operations/events"
+	"github.com/vmware/vic/lib/portlayer/event"
+	portlayer "github.com/vmware/vic/lib/portlayer/event/events"
+	"github.com/vmware/vic/lib/portlayer/exec"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+const (
+	// eventHistoryLimit is the number of events kept for clients catching up
+	eventHistoryLimit = 1000
+
+	// eventTypeContainer is the type of events about containers
+	eventTypeContainer = "container"
+)
+
+// EventsHandlersImpl is the receiver for all of the events handler methods
+type EventsHandlersImpl struct {
+	history *event.History
+}
+
+// Configure initializes the handler
+func (i *EventsHandlersImpl) Configure(api *operations.PortLayerAPI, _ *HandlerContext) {
+	api.EventsGetEventsHandler = events.GetEventsHandlerFunc(i.GetEventsHandler)
+	api.EventsGetEventHistoryHandler = events.GetEventHistoryHandlerFunc(i.GetEventHistoryHandler)
+
+	i.history = event.NewHistory(eventHistoryLimit)
+
+	if exec.Config.EventManager == nil {
+		log.Errorf("Event manager is not available, events will not be recorded")
+		return
+	}
+
+	topic := portlayer.NewEventType(portlayer.ContainerEvent{}).Topic()
+	exec.Config.EventManager.Subscribe(topic, "events", i.history.Add)
+}
+
+// GetEventsHandler streams the events published after the requested position
+func (i *EventsHandlersImpl) GetEventsHandler(params events.GetEventsParams) middleware.Responder {
+	defer trace.End(trace.Begin(""))
+
+	after := swag.Int64Value(params.After)
+	backlog, sub := i.history.Subscribe(after)
+
+	return &EventStreamHandler{
+		history: i.history,
+		backlog: backlog,
+		sub:     sub,
+	}
+}
+
+// GetEventHistoryHandler returns the recorded events created within the requested range
+func (i *EventsHandlersImpl) GetEventHistoryHandler(params events.GetEventHistoryParams) middleware.Responder {
+	defer trace.End(trace.Begin(""))
+
+	var since, until time.Time
+	if params.Since != nil {
+		since = time.Unix(0, *params.Since)
+	}
+	if params.Until != nil {
+		until = time.Unix(0, *params.Until)
+	}
+
+	entries, last := i.history.Events(since, until)
+
+	list := &models.EventList{
+		Events: make([]*models.Event, len(entries)),
+		Last:   swag.Int64(last),
+	}
+	for j := range entries {
+		list.Events[j] = convertEvent(entries[j])
+	}
+
+	return events.NewGetEventHistoryOK().WithPayload(list)
+}
+
+// convertEvent returns the swagger model of the history entry
+func convertEvent(entry event.Entry) *models.Event {
+	e := &models.Event{
+		ID:    entry.ID,
+		Type:  eventTypeContainer,
+		Event: entry.Event.String(),
+	}
+
+	if ref := entry.Event.Reference(); ref != "" {
+		e.Ref = swag.String(ref)
+	}
+	if created := entry.Event.Created(); !created.IsZero() {
+		e.Created = swag.Int64(created.UnixNano())
+	}
+	if detail := entry.Event.Message(); detail != "" {
+		e.Detail = swag.String(detail)
+	}
+
+	return e
+}
+
+// EventStreamHandler writes events to the client as a stream of JSON objects
+// until the client goes away
+type EventStreamHandler struct {
+	history *event.History
+	backlog []event.Entry
+	sub     chan event.Entry
+}
+
+// WriteResponse to the client
+func (e *EventStreamHandler) WriteResponse(rw http.ResponseWriter, producer httpkit.Producer) {
+	defer e.history.Unsubscribe(e.sub)
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	rw.WriteHeader(http.StatusOK)
+
+	flusher, _ := rw.(http.Flusher)
+	var closed <-chan bool
+	if notifier, ok := rw.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	enc := json.NewEncoder(rw)
+	write := func(entry event.Entry) bool {
+		if err := enc.Encode(convertEvent(entry)); err != nil {
+			log.Debugf("Error writing event stream: %s", err)
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	for _, entry := range e.backlog {
+		if !write(entry) {
+			return
+		}
+	}
+
+	// flush so the client knows the stream is established even with no backlog
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case entry, ok := <-e.sub:
+			if !ok || !write(entry) {
+				return
+			}
+		case <-closed:
+			log.Debugf("Event stream client went away")
+			return
+		}
+	}
+}
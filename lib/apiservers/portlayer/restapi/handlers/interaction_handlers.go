@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"time"
 
 	"golang.org/x/net/context"
@@ -27,6 +28,7 @@ import (
 
 	"github.com/go-swagger/go-swagger/httpkit"
 	middleware "github.com/go-swagger/go-swagger/httpkit/middleware"
+	"github.com/go-swagger/go-swagger/swag"
 
 	"github.com/vmware/vic/lib/apiservers/portlayer/models"
 	"github.com/vmware/vic/lib/apiservers/portlayer/restapi/operations"
@@ -34,12 +36,47 @@ import (
 	"github.com/vmware/vic/lib/portlayer/attach"
 	"github.com/vmware/vic/lib/portlayer/constants"
 	"github.com/vmware/vic/lib/portlayer/exec"
+	vsphereSpl "github.com/vmware/vic/lib/portlayer/storage/vsphere"
+	"github.com/vmware/vic/pkg/archive"
 	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/uid"
 )
 
 // InteractionHandlersImpl is the receiver for all of the interaction handler methods
 type InteractionHandlersImpl struct {
 	attachServer *attach.Server
+
+	// provides access to the filesystem of containers that are not running
+	containerStore *vsphereSpl.ContainerStore
+}
+
+// containerFilesystem provides archive access to the filesystem of a container
+type containerFilesystem interface {
+	StatPath(path string) (*archive.PathStat, error)
+	ArchiveExport(path string) (io.ReadCloser, error)
+	ArchiveImport(path string, noOverwriteDirNonDir bool, tar io.Reader) error
+	ExportFilesystem() (io.ReadCloser, error)
+}
+
+// mountedFilesystem is the filesystem of a container mounted on the appliance
+type mountedFilesystem struct {
+	root string
+}
+
+func (m *mountedFilesystem) StatPath(path string) (*archive.PathStat, error) {
+	return archive.Stat(m.root, path)
+}
+
+func (m *mountedFilesystem) ArchiveExport(path string) (io.ReadCloser, error) {
+	return archive.Export(m.root, path)
+}
+
+func (m *mountedFilesystem) ArchiveImport(path string, noOverwriteDirNonDir bool, tar io.Reader) error {
+	return archive.Import(m.root, path, noOverwriteDirNonDir, tar)
+}
+
+func (m *mountedFilesystem) ExportFilesystem() (io.ReadCloser, error) {
+	return archive.ExportFilesystem(m.root)
 }
 
 const (
@@ -47,7 +84,7 @@ const (
 	attachStdinInitString               = "v1c#>"
 )
 
-func (i *InteractionHandlersImpl) Configure(api *operations.PortLayerAPI, _ *HandlerContext) {
+func (i *InteractionHandlersImpl) Configure(api *operations.PortLayerAPI, handlerCtx *HandlerContext) {
 
 	api.InteractionInteractionJoinHandler = interaction.InteractionJoinHandlerFunc(i.JoinHandler)
 	api.InteractionInteractionBindHandler = interaction.InteractionBindHandlerFunc(i.BindHandler)
@@ -59,12 +96,27 @@ func (i *InteractionHandlersImpl) Configure(api *operations.PortLayerAPI, _ *Han
 	api.InteractionContainerGetStderrHandler = interaction.ContainerGetStderrHandlerFunc(i.ContainerGetStderrHandler)
 
 	api.InteractionContainerCloseStdinHandler = interaction.ContainerCloseStdinHandlerFunc(i.ContainerCloseStdinHandler)
+	api.InteractionContainerUnblockHandler = interaction.ContainerUnblockHandlerFunc(i.ContainerUnblockHandler)
+
+	api.InteractionContainerStatPathHandler = interaction.ContainerStatPathHandlerFunc(i.ContainerStatPathHandler)
+	api.InteractionContainerArchiveExportHandler = interaction.ContainerArchiveExportHandlerFunc(i.ContainerArchiveExportHandler)
+	api.InteractionContainerArchiveImportHandler = interaction.ContainerArchiveImportHandlerFunc(i.ContainerArchiveImportHandler)
+	api.InteractionContainerExportHandler = interaction.ContainerExportHandlerFunc(i.ContainerExportHandler)
+
+	api.InteractionContainerProcessListHandler = interaction.ContainerProcessListHandlerFunc(i.ContainerProcessListHandler)
 
 	i.attachServer = attach.NewAttachServer(constants.ManagementHostName, 0)
 
 	if err := i.attachServer.Start(false); err != nil {
 		log.Fatalf("Attach server unable to start: %s", err)
 	}
+
+	// copying to and from stopped containers is unavailable if this fails, but that shouldn't prevent startup
+	var err error
+	op := trace.NewOperation(context.Background(), "configure interaction")
+	if i.containerStore, err = vsphereSpl.NewContainerStore(op, handlerCtx.Session); err != nil {
+		log.Errorf("Unable to access container disks: %s", err)
+	}
 }
 
 // JoinHandler calls the Join
@@ -101,6 +153,10 @@ func (i *InteractionHandlersImpl) BindHandler(params interaction.InteractionBind
 		return interaction.NewInteractionBindInternalServerError().WithPayload(err)
 	}
 
+	if params.Config.AttachTimeout != nil {
+		handle.HoldLaunch(time.Duration(*params.Config.AttachTimeout))
+	}
+
 	handleprime, err := attach.Bind(handle)
 	if err != nil {
 		log.Errorf("%s", err.Error())
@@ -203,7 +259,15 @@ func (i *InteractionHandlersImpl) ContainerSetStdinHandler(params interaction.Co
 	defer i.attachServer.Remove(params.ID)
 
 	detachableIn := NewFlushingReaderWithInitBytes(params.RawStream, []byte(attachStdinInitString))
-	_, err = io.Copy(session.Stdin(), detachableIn)
+	keys := []byte(swag.StringValue(params.DetachKeys))
+	err = attach.CopyStdin(session, detachableIn, keys, swag.BoolValue(params.StdinOnce))
+	if err == attach.ErrDetached {
+		log.Debugf("Detached from stdin of %s", params.ID)
+
+		return interaction.NewContainerSetStdinOK().WithPayload(
+			&models.ContainerSetStdinResponse{Detached: swag.Bool(true)},
+		)
+	}
 	if err != nil {
 		log.Errorf("%s", err.Error())
 
@@ -245,6 +309,40 @@ func (i *InteractionHandlersImpl) ContainerCloseStdinHandler(params interaction.
 	return interaction.NewContainerCloseStdinOK()
 }
 
+// ContainerUnblockHandler releases the container process if its launch is held waiting for the attach,
+// waiting for the connection to the tether if it is not yet established
+func (i *InteractionHandlersImpl) ContainerUnblockHandler(params interaction.ContainerUnblockParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	timeout := interactionTimeout
+	if params.Deadline != nil {
+		timeout = time.Time(*params.Deadline).Sub(time.Now())
+		if timeout < 0 {
+			e := &models.Error{Message: fmt.Sprintf("Deadline for unblock already passed for container %s", params.ID)}
+			return interaction.NewContainerUnblockInternalServerError().WithPayload(e)
+		}
+	}
+
+	session, err := i.attachServer.Get(context.Background(), params.ID, timeout)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		e := &models.Error{
+			Message: fmt.Sprintf("No unblock connection found (id: %s): %s", params.ID, err.Error()),
+		}
+		return interaction.NewContainerUnblockNotFound().WithPayload(e)
+	}
+
+	if err = session.Unblock(); err != nil {
+		log.Errorf("%s", err.Error())
+
+		return interaction.NewContainerUnblockInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+	return interaction.NewContainerUnblockOK()
+}
+
 // ContainerGetStdoutHandler returns the stdout
 func (i *InteractionHandlersImpl) ContainerGetStdoutHandler(params interaction.ContainerGetStdoutParams) middleware.Responder {
 	defer trace.End(trace.Begin(params.ID))
@@ -331,6 +429,224 @@ func (i *InteractionHandlersImpl) ContainerGetStderrHandler(params interaction.C
 	)
 }
 
+// ContainerProcessListHandler returns the process table of a running container
+func (i *InteractionHandlersImpl) ContainerProcessListHandler(params interaction.ContainerProcessListParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	h := exec.GetContainer(context.Background(), uid.Parse(params.ID))
+	if h == nil {
+		return interaction.NewContainerProcessListNotFound().WithPayload(
+			&models.Error{Message: fmt.Sprintf("container %s not found", params.ID)},
+		)
+	}
+
+	if h.Container.CurrentState() != exec.StateRunning {
+		return interaction.NewContainerProcessListConflict().WithPayload(
+			&models.Error{Message: fmt.Sprintf("container %s is not running", params.ID)},
+		)
+	}
+
+	session, err := i.attachServer.Get(context.Background(), params.ID, interactionTimeout)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		return interaction.NewContainerProcessListInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	titles, processes, err := session.ProcessList(swag.StringValue(params.Args))
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		return interaction.NewContainerProcessListInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	return interaction.NewContainerProcessListOK().WithPayload(
+		&models.ProcessList{Titles: titles, Processes: processes},
+	)
+}
+
+// filesystem returns archive access to the filesystem of the container along with
+// a function to release it. The filesystem of a running container is accessed via
+// the tether, otherwise the container's disk is mounted on the appliance.
+func (i *InteractionHandlersImpl) filesystem(id string, readonly bool) (containerFilesystem, func(), error) {
+	h := exec.GetContainer(context.Background(), uid.Parse(id))
+	if h == nil {
+		return nil, nil, exec.NotFoundError{}
+	}
+
+	// the disk of a paused container is still in use by its VM
+	if state := h.Container.CurrentState(); state == exec.StateRunning || state == exec.StatePaused {
+		session, err := i.attachServer.Get(context.Background(), id, interactionTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return session, func() {}, nil
+	}
+
+	if i.containerStore == nil {
+		return nil, nil, fmt.Errorf("filesystem of stopped container %s is not available", id)
+	}
+
+	op := trace.NewOperation(context.Background(), fmt.Sprintf("mount %s", id))
+	root, release, err := i.containerStore.Mount(op, h, readonly)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &mountedFilesystem{root: root}, release, nil
+}
+
+// ContainerStatPathHandler returns the details of a path in the container filesystem
+func (i *InteractionHandlersImpl) ContainerStatPathHandler(params interaction.ContainerStatPathParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	fs, release, err := i.filesystem(params.ID, true)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		if _, ok := err.(exec.NotFoundError); ok {
+			return interaction.NewContainerStatPathNotFound().WithPayload(
+				&models.Error{Message: fmt.Sprintf("container %s not found", params.ID)},
+			)
+		}
+		return interaction.NewContainerStatPathInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+	defer release()
+
+	stat, err := fs.StatPath(params.Path)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		if os.IsNotExist(err) {
+			return interaction.NewContainerStatPathNotFound().WithPayload(
+				&models.Error{Message: fmt.Sprintf("path %s not found in container %s", params.Path, params.ID)},
+			)
+		}
+		return interaction.NewContainerStatPathInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	mode := uint32(stat.Mode)
+	res := &models.PathStat{
+		Name:       stat.Name,
+		Size:       swag.Int64(stat.Size),
+		Mode:       &mode,
+		Mtime:      swag.Int64(stat.Mtime.UnixNano()),
+		LinkTarget: swag.String(stat.LinkTarget),
+	}
+	return interaction.NewContainerStatPathOK().WithPayload(res)
+}
+
+// ContainerArchiveExportHandler returns a tar archive of a path in the container filesystem
+func (i *InteractionHandlersImpl) ContainerArchiveExportHandler(params interaction.ContainerArchiveExportParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	fs, release, err := i.filesystem(params.ID, true)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		if _, ok := err.(exec.NotFoundError); ok {
+			return interaction.NewContainerArchiveExportNotFound().WithPayload(
+				&models.Error{Message: fmt.Sprintf("container %s not found", params.ID)},
+			)
+		}
+		return interaction.NewContainerArchiveExportInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	tar, err := fs.ArchiveExport(params.Path)
+	if err != nil {
+		release()
+		log.Errorf("%s", err.Error())
+
+		return interaction.NewContainerArchiveExportInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	return &ArchiveOutputHandler{
+		archive: tar,
+		release: release,
+		id:      params.ID,
+	}
+}
+
+// ContainerExportHandler returns a tar archive of the container filesystem
+func (i *InteractionHandlersImpl) ContainerExportHandler(params interaction.ContainerExportParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	fs, release, err := i.filesystem(params.ID, true)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		if _, ok := err.(exec.NotFoundError); ok {
+			return interaction.NewContainerExportNotFound().WithPayload(
+				&models.Error{Message: fmt.Sprintf("container %s not found", params.ID)},
+			)
+		}
+		return interaction.NewContainerExportInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	tar, err := fs.ExportFilesystem()
+	if err != nil {
+		release()
+		log.Errorf("%s", err.Error())
+
+		return interaction.NewContainerExportInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	return &ArchiveOutputHandler{
+		archive: tar,
+		release: release,
+		id:      params.ID,
+	}
+}
+
+// ContainerArchiveImportHandler extracts a tar archive into a directory in the container filesystem
+func (i *InteractionHandlersImpl) ContainerArchiveImportHandler(params interaction.ContainerArchiveImportParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	defer params.Archive.Close()
+
+	fs, release, err := i.filesystem(params.ID, false)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		if _, ok := err.(exec.NotFoundError); ok {
+			return interaction.NewContainerArchiveImportNotFound().WithPayload(
+				&models.Error{Message: fmt.Sprintf("container %s not found", params.ID)},
+			)
+		}
+		return interaction.NewContainerArchiveImportInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+	defer release()
+
+	noOverwriteDirNonDir := params.NoOverwriteDirNonDir != nil && *params.NoOverwriteDirNonDir
+	if err = fs.ArchiveImport(params.Path, noOverwriteDirNonDir, params.Archive); err != nil {
+		log.Errorf("%s", err.Error())
+
+		return interaction.NewContainerArchiveImportInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	return interaction.NewContainerArchiveImportOK()
+}
+
 // GenericFlusher is a custom reader to allow us to detach cleanly during an io.Copy
 type GenericFlusher interface {
 	Flush()
@@ -470,3 +786,28 @@ func (c *ContainerOutputHandler) WriteResponse(rw http.ResponseWriter, producer
 		log.Debugf("Finished copying %s stream for container %s", c.outputName, c.containerID)
 	}
 }
+
+// ArchiveOutputHandler streams a tar archive to the client and releases the
+// container or image filesystem once done
+type ArchiveOutputHandler struct {
+	archive io.ReadCloser
+	release func()
+	id      string
+}
+
+// WriteResponse to the client
+func (a *ArchiveOutputHandler) WriteResponse(rw http.ResponseWriter, producer httpkit.Producer) {
+	if a.release != nil {
+		defer a.release()
+	}
+	defer a.archive.Close()
+
+	rw.WriteHeader(http.StatusOK)
+	_, err := io.Copy(rw, a.archive)
+
+	if err != nil {
+		log.Debugf("Error copying archive for %s: %s", a.id, err)
+	} else {
+		log.Debugf("Finished copying archive for %s", a.id)
+	}
+}
@@ -235,11 +235,24 @@ func (handler *ScopesHandlersImpl) ScopesRemoveContainer(params scopes.RemoveCon
 
 	h := exec.GetHandle(params.Handle)
 	if h == nil {
-		return scopes.NewRemoveContainerNotFound().WithPayload(&models.Error{Message: "container not found"})
+		if params.Force == nil || !*params.Force {
+			return scopes.NewRemoveContainerNotFound().WithPayload(&models.Error{Message: "container not found"})
+		}
+
+		// the container is gone so there is nothing to reconfigure, only its address and names to release
+		if err := handler.netCtx.ReleaseContainer(params.Handle, params.Scope); err != nil {
+			if _, ok := err.(network.ResourceNotFoundError); ok {
+				return scopes.NewRemoveContainerNotFound().WithPayload(errorPayload(err))
+			}
+
+			return scopes.NewRemoveContainerInternalServerError().WithPayload(errorPayload(err))
+		}
+
+		return scopes.NewRemoveContainerOK()
 	}
 
 	if err := handler.netCtx.RemoveContainer(h, params.Scope); err != nil {
-		if _, ok := err.(*network.ResourceNotFoundError); ok {
+		if _, ok := err.(network.ResourceNotFoundError); ok {
 			return scopes.NewRemoveContainerNotFound().WithPayload(errorPayload(err))
 		}
 
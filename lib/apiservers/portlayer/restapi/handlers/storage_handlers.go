@@ -15,11 +15,15 @@
 package handlers
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/archive"
 	"github.com/go-swagger/go-swagger/httpkit/middleware"
 	"github.com/go-swagger/go-swagger/swag"
 
@@ -29,6 +33,7 @@ import (
 	"github.com/vmware/vic/lib/apiservers/portlayer/restapi/options"
 
 	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/uid"
 	"github.com/vmware/vic/pkg/vsphere/datastore"
 	"github.com/vmware/vic/pkg/vsphere/session"
 
@@ -44,6 +49,16 @@ import (
 type StorageHandlersImpl struct {
 	imageCache  *spl.NameLookupCache
 	volumeCache *spl.VolumeLookupCache
+
+	// provides the changes containers made to their images
+	containerStore containerDiffer
+}
+
+// containerDiffer provides the changes a container made to its image, as a tar
+// stream or as the list of changed paths
+type containerDiffer interface {
+	Diff(op trace.Operation, handle *epl.Handle) (io.ReadCloser, error)
+	Changes(op trace.Operation, handle *epl.Handle) ([]archive.Change, error)
 }
 
 // Configure assigns functions to all the storage api handlers
@@ -114,12 +129,21 @@ func (h *StorageHandlersImpl) Configure(api *operations.PortLayerAPI, handlerCtx
 		log.Panicf("Cannot instantiate the Volume Lookup cache: %s", err)
 	}
 
+	// committing and diffing containers is unavailable if this fails, but that shouldn't prevent startup
+	if cs, err := vsphereSpl.NewContainerStore(op, storageSession); err != nil {
+		log.Errorf("Unable to access container disks: %s", err)
+	} else {
+		h.containerStore = cs
+	}
+
 	api.StorageCreateImageStoreHandler = storage.CreateImageStoreHandlerFunc(h.CreateImageStore)
 	api.StorageGetImageHandler = storage.GetImageHandlerFunc(h.GetImage)
 	api.StorageGetImageTarHandler = storage.GetImageTarHandlerFunc(h.GetImageTar)
 	api.StorageListImagesHandler = storage.ListImagesHandlerFunc(h.ListImages)
 	api.StorageWriteImageHandler = storage.WriteImageHandlerFunc(h.WriteImage)
 	api.StorageDeleteImageHandler = storage.DeleteImageHandlerFunc(h.DeleteImage)
+	api.StorageCommitContainerHandler = storage.CommitContainerHandlerFunc(h.CommitContainer)
+	api.StorageContainerChangesHandler = storage.ContainerChangesHandlerFunc(h.ContainerChanges)
 
 	api.StorageVolumeStoresListHandler = storage.VolumeStoresListHandlerFunc(h.VolumeStoresList)
 	api.StorageCreateVolumeHandler = storage.CreateVolumeHandlerFunc(h.CreateVolume)
@@ -217,7 +241,38 @@ func (h *StorageHandlersImpl) DeleteImage(params storage.DeleteImageParams) midd
 
 // GetImageTar returns an image tar file
 func (h *StorageHandlersImpl) GetImageTar(params storage.GetImageTarParams) middleware.Responder {
-	return middleware.NotImplemented("operation storage.GetImageTar has not yet been implemented")
+	defer trace.End(trace.Begin(params.ID))
+
+	url, err := util.ImageStoreNameToURL(params.StoreName)
+	if err != nil {
+		return storage.NewGetImageTarDefault(http.StatusInternalServerError).WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusInternalServerError),
+				Message: err.Error(),
+			})
+	}
+
+	op := trace.NewOperation(context.Background(), fmt.Sprintf("GetImageTar(%s)", params.ID))
+	image, err := h.imageCache.GetImage(op, url, params.ID)
+	if err != nil {
+		log.Errorf("GetImageTar: %s", err)
+		return storage.NewGetImageTarNotFound()
+	}
+
+	tar, err := h.imageCache.GetImageTar(op, image)
+	if err != nil {
+		return storage.NewGetImageTarDefault(http.StatusInternalServerError).WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusInternalServerError),
+				Message: err.Error(),
+			})
+	}
+
+	// the image disks are released when the stream is closed
+	return &ArchiveOutputHandler{
+		archive: tar,
+		id:      params.ID,
+	}
 }
 
 // ListImages returns a list of images in a store
@@ -284,6 +339,168 @@ func (h *StorageHandlersImpl) WriteImage(params storage.WriteImageParams) middle
 	return storage.NewWriteImageCreated().WithPayload(i)
 }
 
+// CommitContainer writes the changes a stopped container made to its image as a new image layer
+func (h *StorageHandlersImpl) CommitContainer(params storage.CommitContainerParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	ferr := func(err error, code int) middleware.Responder {
+		log.Errorf("CommitContainer: error %s", err.Error())
+		return storage.NewCommitContainerDefault(code).WithPayload(
+			&models.Error{
+				Code:    swag.Int64(int64(code)),
+				Message: err.Error(),
+			})
+	}
+
+	handle := epl.GetContainer(context.Background(), uid.Parse(params.ID))
+	if handle == nil {
+		return storage.NewCommitContainerNotFound().WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusNotFound),
+				Message: fmt.Sprintf("container %s not found", params.ID),
+			})
+	}
+
+	// the read-write layer cannot be read consistently while the container VM is powered on,
+	// which it still is while paused, nor while it is in use by another operation
+	if state := handle.Container.CurrentState(); state != epl.StateStopped && state != epl.StateCreated {
+		return storage.NewCommitContainerConflict().WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusConflict),
+				Message: fmt.Sprintf("container %s is not stopped (state: %s)", params.ID, state),
+			})
+	}
+
+	if h.containerStore == nil {
+		return ferr(fmt.Errorf("filesystem of container %s is not available", params.ID), http.StatusInternalServerError)
+	}
+
+	u, err := util.ImageStoreNameToURL(params.StoreName)
+	if err != nil {
+		return ferr(err, http.StatusInternalServerError)
+	}
+
+	op := trace.NewOperation(context.Background(), fmt.Sprintf("CommitContainer(%s, %s)", params.ID, params.ImageID))
+	parent, err := h.imageCache.GetImage(op, u, handle.ExecConfig.LayerID)
+	if err != nil {
+		return storage.NewCommitContainerNotFound().WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusNotFound),
+				Message: err.Error(),
+			})
+	}
+
+	var meta map[string][]byte
+
+	if params.Metadatakey != nil && params.Metadataval != nil {
+		meta = map[string][]byte{*params.Metadatakey: []byte(*params.Metadataval)}
+	}
+
+	tar, err := h.containerStore.Diff(op, handle)
+	if err != nil {
+		if _, ok := err.(epl.DiskInUseError); ok {
+			return storage.NewCommitContainerConflict().WithPayload(
+				&models.Error{
+					Code:    swag.Int64(http.StatusConflict),
+					Message: err.Error(),
+				})
+		}
+		return ferr(err, http.StatusInternalServerError)
+	}
+
+	image, err := writeLayer(op, h.imageCache, parent, params.ImageID, meta, tar)
+	if err != nil {
+		return ferr(err, http.StatusInternalServerError)
+	}
+
+	return storage.NewCommitContainerCreated().WithPayload(convertImage(image))
+}
+
+// ContainerChanges lists the paths the container added, changed or deleted in its image
+func (h *StorageHandlersImpl) ContainerChanges(params storage.ContainerChangesParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.ID))
+
+	handle := epl.GetContainer(context.Background(), uid.Parse(params.ID))
+	if handle == nil {
+		return storage.NewContainerChangesNotFound().WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusNotFound),
+				Message: fmt.Sprintf("container %s not found", params.ID),
+			})
+	}
+	defer handle.Close()
+
+	// the disk of a running container is attached to its VM and cannot be mounted
+	if handle.Container.CurrentState() == epl.StateRunning {
+		return storage.NewContainerChangesConflict().WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusConflict),
+				Message: fmt.Sprintf("container %s is running", params.ID),
+			})
+	}
+
+	if h.containerStore == nil {
+		return storage.NewContainerChangesDefault(http.StatusInternalServerError).WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusInternalServerError),
+				Message: fmt.Sprintf("filesystem of container %s is not available", params.ID),
+			})
+	}
+
+	op := trace.NewOperation(context.Background(), fmt.Sprintf("ContainerChanges(%s)", params.ID))
+	changes, err := h.containerStore.Changes(op, handle)
+	if err != nil {
+		log.Errorf("ContainerChanges: error %s", err.Error())
+		return storage.NewContainerChangesDefault(http.StatusInternalServerError).WithPayload(
+			&models.Error{
+				Code:    swag.Int64(http.StatusInternalServerError),
+				Message: err.Error(),
+			})
+	}
+
+	payload := make([]*models.FilesystemChange, len(changes))
+	for i, change := range changes {
+		payload[i] = &models.FilesystemChange{
+			Path: change.Path,
+			Kind: int32(change.Kind),
+		}
+	}
+
+	return storage.NewContainerChangesOK().WithPayload(payload)
+}
+
+// writeLayer writes the layer as a new image. The image store verifies the sum
+// of the layer as it is written, so the layer is first staged in a temporary file
+// to learn it. The layer is closed once it has been staged.
+func writeLayer(op trace.Operation, store spl.ImageStorer, parent *spl.Image, ID string, meta map[string][]byte, layer io.ReadCloser) (*spl.Image, error) {
+	f, err := ioutil.TempFile("", "layer-"+ID)
+	if err != nil {
+		layer.Close()
+		return nil, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	sum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, sum), layer)
+
+	// release whatever the layer was read from before writing the image
+	if cerr := layer.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return store.WriteImage(op, parent, ID, meta, fmt.Sprintf("sha256:%x", sum.Sum(nil)), f)
+}
+
 // VolumeStoresList lists the configured volume stores and their datastore path URIs.
 func (h *StorageHandlersImpl) VolumeStoresList() middleware.Responder {
 	defer trace.End(trace.Begin("storage_handlers.VolumeStoresList"))
@@ -15,8 +15,11 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -181,6 +184,11 @@ func (c *MockDataStore) GetImage(op trace.Operation, store *url.URL, ID string)
 	return nil, fmt.Errorf("store (%s) doesn't have image %s", store.String(), ID)
 }
 
+// GetImageTar returns an empty tar stream for the image
+func (c *MockDataStore) GetImageTar(op trace.Operation, image *spl.Image) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(make([]byte, 1024))), nil
+}
+
 // ListImages resturns a list of Images for a list of IDs, or all if no IDs are passed
 func (c *MockDataStore) ListImages(op trace.Operation, store *url.URL, IDs []string) ([]*spl.Image, error) {
 	return nil, fmt.Errorf("store (%s) doesn't exist", store.String())
@@ -331,6 +339,42 @@ func TestGetImage(t *testing.T) {
 	}
 }
 
+func TestGetImageTar(t *testing.T) {
+	s := &StorageHandlersImpl{
+		imageCache: spl.NewLookupCache(&MockDataStore{}),
+	}
+
+	op := trace.NewOperation(context.Background(), "test")
+	if _, err := s.imageCache.CreateImageStore(op, testStoreName); !assert.NoError(t, err) {
+		return
+	}
+
+	params := storage.GetImageTarParams{
+		ID:        testImageID,
+		StoreName: testStoreName,
+	}
+
+	// expect 404 since no image exists by that name in that store
+	result := s.GetImageTar(params)
+	assert.Equal(t, storage.NewGetImageTarNotFound(), result)
+
+	parent := spl.Image{
+		ID:    "scratch",
+		Store: &testStoreURL,
+	}
+
+	if _, err := s.imageCache.WriteImage(op, &parent, testImageID, nil, testImageSum, nil); !assert.NoError(t, err) {
+		return
+	}
+
+	result = s.GetImageTar(params)
+	handler, ok := result.(*ArchiveOutputHandler)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, testImageID, handler.id)
+}
+
 func TestListImages(t *testing.T) {
 
 	s := &StorageHandlersImpl{
@@ -486,6 +530,53 @@ func TestWriteImage(t *testing.T) {
 	}
 }
 
+// sumCheckingDataStore verifies the sum of the images written to it
+type sumCheckingDataStore struct {
+	MockDataStore
+}
+
+func (c *sumCheckingDataStore) WriteImage(op trace.Operation, parent *spl.Image, ID string, meta map[string][]byte, sum string, r io.Reader) (*spl.Image, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+
+	if actual := fmt.Sprintf("sha256:%x", h.Sum(nil)); actual != sum {
+		return nil, fmt.Errorf("Failed to validate image checksum. Expected %s, got %s", sum, actual)
+	}
+
+	return c.MockDataStore.WriteImage(op, parent, ID, meta, sum, r)
+}
+
+// closeRecorder records whether the reader was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWriteLayer(t *testing.T) {
+	op := trace.NewOperation(context.Background(), "test")
+
+	parent := &spl.Image{
+		ID:    spl.Scratch.ID,
+		Store: &testStoreURL,
+	}
+	layer := &closeRecorder{Reader: bytes.NewReader(make([]byte, 1024))}
+
+	image, err := writeLayer(op, &sumCheckingDataStore{}, parent, testImageID, nil, layer)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, testImageID, image.ID)
+	assert.True(t, layer.closed, "layer should be closed once staged")
+}
+
 func TestVolumeCreate(t *testing.T) {
 
 	testStore := NewMockVolumeStore()
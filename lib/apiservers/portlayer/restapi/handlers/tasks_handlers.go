@@ -0,0 +1,128 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	middleware "github.com/go-swagger/go-swagger/httpkit/middleware"
+	"github.com/go-swagger/go-swagger/swag"
+
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+	"github.com/vmware/vic/lib/apiservers/portlayer/restapi/operations"
+	"github.com/vmware/vic/lib/apiservers/portlayer/restapi/operations/tasks"
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/lib/portlayer/exec"
+	"github.com/vmware/vic/lib/portlayer/task"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// TaskHandlersImpl is the receiver for all of the task handler methods
+type TaskHandlersImpl struct {
+}
+
+// Configure initializes the handler
+func (t *TaskHandlersImpl) Configure(api *operations.PortLayerAPI, _ *HandlerContext) {
+	api.TasksTaskJoinHandler = tasks.TaskJoinHandlerFunc(t.JoinHandler)
+	api.TasksTaskInspectHandler = tasks.TaskInspectHandlerFunc(t.InspectHandler)
+}
+
+// JoinHandler adds a new session to the handle
+func (t *TaskHandlersImpl) JoinHandler(params tasks.TaskJoinParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.Config.ID))
+
+	handle := exec.HandleFromInterface(params.Config.Handle)
+	if handle == nil {
+		err := &models.Error{Message: "Failed to get the Handle"}
+		return tasks.NewTaskJoinNotFound().WithPayload(err)
+	}
+
+	session := &executor.SessionConfig{
+		Cmd: executor.Cmd{
+			Path: params.Config.Path,
+			Args: params.Config.Args,
+			Env:  params.Config.Env,
+			Dir:  swag.StringValue(params.Config.WorkingDir),
+		},
+		User:      swag.StringValue(params.Config.User),
+		Tty:       swag.BoolValue(params.Config.Tty),
+		Attach:    swag.BoolValue(params.Config.Attach),
+		OpenStdin: swag.BoolValue(params.Config.OpenStdin),
+
+		AttachTimeout: time.Duration(swag.Int64Value(params.Config.AttachTimeout)),
+	}
+	session.ID = params.Config.ID
+	session.Name = params.Config.ID
+
+	handleprime, err := task.Join(handle, session)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		return tasks.NewTaskJoinInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	res := &models.TaskJoinResponse{
+		Handle: exec.ReferenceFromHandle(handleprime),
+		ID:     session.ID,
+	}
+	return tasks.NewTaskJoinOK().WithPayload(res)
+}
+
+// InspectHandler returns the state of a session in the handle
+func (t *TaskHandlersImpl) InspectHandler(params tasks.TaskInspectParams) middleware.Responder {
+	defer trace.End(trace.Begin(params.Config.ID))
+
+	handle := exec.HandleFromInterface(params.Config.Handle)
+	if handle == nil {
+		err := &models.Error{Message: "Failed to get the Handle"}
+		return tasks.NewTaskInspectNotFound().WithPayload(err)
+	}
+
+	session, err := task.Inspect(handle, params.Config.ID)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+
+		if _, ok := err.(task.TaskNotFoundError); ok {
+			return tasks.NewTaskInspectNotFound().WithPayload(
+				&models.Error{Message: err.Error()},
+			)
+		}
+
+		return tasks.NewTaskInspectInternalServerError().WithPayload(
+			&models.Error{Message: err.Error()},
+		)
+	}
+
+	// a task is running once the tether reports a successful launch and until it records the stop
+	running := session.Started == "true" && session.StopTime == 0
+	exitCode := int32(session.ExitStatus)
+
+	res := &models.TaskInspectResponse{
+		ID:        session.ID,
+		Running:   &running,
+		ExitCode:  &exitCode,
+		Started:   &session.Started,
+		Tty:       &session.Tty,
+		OpenStdin: &session.OpenStdin,
+		User:      &session.User,
+		Path:      &session.Cmd.Path,
+		Args:      session.Cmd.Args,
+	}
+	return tasks.NewTaskInspectOK().WithPayload(res)
+}
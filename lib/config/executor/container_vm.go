@@ -29,6 +29,15 @@ const (
 	KILLED
 )
 
+const (
+	// HealthStarting is the health status of a session until its first successful probe
+	HealthStarting = "starting"
+	// Healthy is the health status of a session after a successful probe
+	Healthy = "healthy"
+	// Unhealthy is the health status of a session after its probe has failed too many times in a row
+	Unhealthy = "unhealthy"
+)
+
 // Common data between managed entities, across execution environments
 type Common struct {
 	// A reference to the components hosting execution environment, if any
@@ -64,6 +73,65 @@ type ExitLog struct {
 	Message    string
 }
 
+// HealthConfig describes the probe that checks whether the process of a session is healthy, using
+// the forms docker gives its HEALTHCHECK test
+type HealthConfig struct {
+	// Test is the probe to run, one of:
+	// {} - no probe
+	// {"NONE"} - probes are disabled
+	// {"CMD", args...} - run the command
+	// {"CMD-SHELL", command} - run the command with /bin/sh -c
+	Test []string `vic:"0.1" scope:"read-only" key:"test"`
+
+	// Interval is the time to wait between probes
+	Interval time.Duration `vic:"0.1" scope:"read-only" key:"interval"`
+
+	// Timeout is the time after which a probe that has not completed is considered to have failed
+	Timeout time.Duration `vic:"0.1" scope:"read-only" key:"timeout"`
+
+	// Retries is the number of consecutive failures needed to consider the session unhealthy
+	Retries int `vic:"0.1" scope:"read-only" key:"retries"`
+}
+
+// Health records the result of the health probes of a session
+type Health struct {
+	// Status is one of "starting", "healthy" or "unhealthy"
+	Status string `vic:"0.1" scope:"read-write" key:"status"`
+
+	// FailingStreak is the number of consecutive failed probes
+	FailingStreak int `vic:"0.1" scope:"read-write" key:"failingstreak"`
+
+	// Log holds the results of the most recent probes, oldest first
+	Log []HealthLog `vic:"0.1" scope:"read-write" key:"log"`
+}
+
+// HealthLog records the result of a single health probe
+type HealthLog struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
+}
+
+// Hardware records the virtual hardware of a container VM
+type Hardware struct {
+	// NumCPUs is the number of virtual CPUs
+	NumCPUs int32 `vic:"0.1" scope:"hidden" key:"cpus"`
+
+	// MemoryMB is the memory size in MB
+	MemoryMB int64 `vic:"0.1" scope:"hidden" key:"memory"`
+}
+
+// RestartPolicy describes whether an executor is restarted when its primary session exits,
+// using the names docker gives its policies
+type RestartPolicy struct {
+	// Name is one of "", "no", "always", "unless-stopped" or "on-failure"
+	Name string `vic:"0.1" scope:"hidden" key:"name"`
+
+	// MaximumRetryCount limits the restarts of an "on-failure" policy, zero is unlimited
+	MaximumRetryCount int `vic:"0.1" scope:"hidden" key:"maxretries"`
+}
+
 // MountSpec details a mount that must be executed within the executor
 // A mount is a URI -> path mapping with a credential of some kind
 // In the case of a labeled disk:
@@ -143,6 +211,20 @@ type ExecutorConfig struct {
 
 	// version
 	Version *version.Build `vic:"0.1" scope:"read-only" key:"version"`
+
+	// PendingHardware holds the hardware changes that could not be hot-added to the running
+	// container VM, which are made when it is next powered off
+	PendingHardware Hardware `vic:"0.1" scope:"hidden" key:"pendinghardware"`
+
+	// RestartPolicy of the executor
+	RestartPolicy RestartPolicy `vic:"0.1" scope:"hidden" key:"restartpolicy"`
+
+	// RestartCount is the number of times the executor has been restarted by its restart policy
+	RestartCount int `vic:"0.1" scope:"hidden" key:"restartcount"`
+
+	// StoppedByRequest records that the executor was last stopped on request rather than by the
+	// exit of its primary session, which keeps an "unless-stopped" policy from restarting it
+	StoppedByRequest bool `vic:"0.1" scope:"hidden" key:"stoppedbyrequest"`
 }
 
 // Cmd is here because the encoding packages seem to have issues with the full exec.Cmd struct
@@ -174,9 +256,16 @@ type SessionConfig struct {
 	// Allow attach
 	Attach bool `vic:"0.1" scope:"read-only" key:"attach"`
 
+	// AttachTimeout is how long the launch of an attachable process is held waiting for the
+	// attach to complete. The process is launched immediately if it is zero.
+	AttachTimeout time.Duration `vic:"0.1" scope:"read-only" key:"attachtimeout"`
+
 	// Allocate a tty or not
 	Tty bool `vic:"0.1" scope:"read-only" key:"tty"`
 
+	// Keep stdin open even if not attached
+	OpenStdin bool `vic:"0.1" scope:"read-only" key:"openstdin"`
+
 	ExitStatus int `vic:"0.1" scope:"read-write" key:"status"`
 
 	Started string `vic:"0.1" scope:"read-write" key:"started"`
@@ -189,6 +278,12 @@ type SessionConfig struct {
 	// Diagnostics holds basic diagnostics data
 	Diagnostics Diagnostics `vic:"0.1" scope:"read-only" key:"diagnostics"`
 
+	// Healthcheck is the probe the tether runs to check the health of the session process
+	Healthcheck HealthConfig `vic:"0.1" scope:"read-only" key:"healthcheck"`
+
+	// Health holds the results of the health probes
+	Health Health `vic:"0.1" scope:"read-write" key:"health"`
+
 	// Maps the intent to the signal for this specific app
 	// Signals map[int]int
 
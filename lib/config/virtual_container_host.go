@@ -41,6 +41,13 @@ const (
 	Name = "{name}"
 )
 
+const (
+	// PauseSignal pauses containers by stopping their processes with SIGSTOP
+	PauseSignal = "signal"
+	// PauseSuspend pauses containers by suspending their VMs
+	PauseSuspend = "suspend"
+)
+
 // Can we just treat the VCH appliance as a containerVM booting off a specific bootstrap image
 // It has many of the same requirements (around networks being attached, version recorded,
 // volumes mounted, et al). Each of the components can easily be captured as a Session given they
@@ -93,6 +100,8 @@ type Container struct {
 	ContainerNameConvention string
 	// Permitted datastore URLs for container storage for this virtual container host
 	ContainerStores []url.URL `vic:"0.1" scope:"read-only" recurse:"depth=0"`
+	// How containers are frozen when paused, either PauseSignal or PauseSuspend
+	PauseMode string `vic:"0.1" scope:"read-only" key:"pause_mode"`
 }
 
 // RegistryConfig defines the registries virtual container host can talk to
@@ -335,6 +335,8 @@ func (ic *ImageC) CreateImageConfig(images []*ImageWithMeta) (metadata.ImageConf
 	rootFS := docker.NewRootFS()
 	history := make([]docker.History, 0, len(images))
 	diffIDs := make(map[string]string)
+	blobSums := make(map[string]string)
+	layerSizes := make(map[string]int64)
 	var size int64
 
 	// step through layers to get command history and diffID from oldest to newest
@@ -352,6 +354,8 @@ func (ic *ImageC) CreateImageConfig(images []*ImageWithMeta) (metadata.ImageConf
 		history = append(history, h)
 		rootFS.DiffIDs = append(rootFS.DiffIDs, dockerLayer.DiffID(layer.diffID))
 		diffIDs[layer.diffID] = layer.ID
+		blobSums[layer.ID] = layer.layer.BlobSum
+		layerSizes[layer.ID] = layer.size
 		size += layer.size
 	}
 
@@ -390,12 +394,16 @@ func (ic *ImageC) CreateImageConfig(images []*ImageWithMeta) (metadata.ImageConf
 		ImageID: sum,
 		// TODO: this will change when issue 1186 is
 		// implemented -- only populate the digests when pulled by digest
-		Digests:   []string{manifest.Digest},
-		Tags:      []string{ic.Tag},
-		Name:      manifest.Name,
-		DiffIDs:   diffIDs,
-		History:   history,
-		Reference: ic.Reference,
+		Digests:    []string{manifest.Digest},
+		Tags:       []string{ic.Tag},
+		Name:       manifest.Name,
+		DiffIDs:    diffIDs,
+		History:    history,
+		Reference:  ic.Reference,
+		BlobSums:   blobSums,
+		LayerSizes: layerSizes,
+		// the image layer has the newest config
+		Healthcheck: metadata.HealthcheckFromConfig([]byte(imageLayer.meta)),
 	}
 
 	blob, err := json.Marshal(metaData)
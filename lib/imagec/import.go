@@ -0,0 +1,168 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	docker "github.com/docker/docker/image"
+	dockerLayer "github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/reference"
+	"github.com/docker/engine-api/types/container"
+
+	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/version"
+	"github.com/vmware/vic/pkg/vsphere/sys"
+)
+
+// stageLayer decompresses the layer to a temporary file, recording its diffID
+// and size, and returns the name of the file
+func (ic *ImageC) stageLayer(r io.Reader, layer *ImageWithMeta) (string, error) {
+	rc, err := archive.DecompressStream(r)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	f, err := ioutil.TempFile(ic.Destination, layer.ID)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	diffIDSum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, diffIDSum), rc)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	// the layer is written uncompressed so its sum is the diffID
+	layer.diffID = fmt.Sprintf("sha256:%x", diffIDSum.Sum(nil))
+	layer.layer.BlobSum = layer.diffID
+	layer.size = size
+
+	return f.Name(), nil
+}
+
+// importImageConfig returns the docker image config of an image imported with
+// the given container config and message
+func importImageConfig(diffID string, msg string, config *container.Config, created time.Time) ([]byte, *docker.Image, error) {
+	image := &docker.Image{
+		V1Image: docker.V1Image{
+			DockerVersion: version.Version,
+			Config:        config,
+			Architecture:  runtime.GOARCH,
+			OS:            "linux",
+			Created:       created,
+			Comment:       msg,
+		},
+		RootFS: docker.NewRootFS(),
+		History: []docker.History{
+			{Created: created, Comment: msg},
+		},
+	}
+	image.RootFS.Append(dockerLayer.DiffID(diffID))
+
+	blob, err := json.Marshal(image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return blob, image, nil
+}
+
+// ImportImage writes the filesystem tarball read from r to the image store as
+// a single layer image with the given config, tagged with ref if it is not nil,
+// and returns the ID of the image
+func (ic *ImageC) ImportImage(r io.Reader, ref reference.Named, msg string, config *container.Config) (string, error) {
+	defer trace.End(trace.Begin(""))
+
+	// Host is either the host's UUID (if run on vsphere) or the hostname of
+	// the system (if run standalone)
+	host, err := sys.UUID()
+	if err != nil {
+		log.Errorf("Failed to return host name: %s", err)
+		return "", err
+	}
+
+	ic.Storename = host
+
+	// Ping the server to ensure it's at least running
+	ok, err := PingPortLayer(ic.Host)
+	if err != nil || !ok {
+		log.Errorf("Failed to ping portlayer: %s", err)
+		return "", err
+	}
+
+	var tagged reference.NamedTagged
+	if ref != nil {
+		tagged, _ = reference.WithDefaultTag(ref).(reference.NamedTagged)
+	}
+
+	parent := "scratch"
+	layer := &ImageWithMeta{
+		Image: &models.Image{
+			ID:     stringid.GenerateRandomID(),
+			Parent: &parent,
+			Store:  ic.Storename,
+		},
+	}
+
+	name, err := ic.stageLayer(r, layer)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read image tarball: %s", err)
+	}
+	defer os.Remove(name)
+
+	blob, image, err := importImageConfig(layer.diffID, msg, config, time.Now().UTC())
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal image config: %s", err)
+	}
+
+	imageConfig, err := createLoadedImageConfig(blob, image, []*ImageWithMeta{layer}, tagged)
+	if err != nil {
+		return "", err
+	}
+
+	if err = ic.loadLayer(name, layer, nil); err != nil {
+		return "", err
+	}
+
+	cache.LayerCache().AddExisting(layer.ID)
+	cache.ImageCache().AddImage(&imageConfig)
+
+	if tagged != nil {
+		// the tag is taken from any image that has it, as docker does
+		if err = cache.RepositoryCache().AddReference(tagged, imageConfig.ImageID, true, layer.ID, true); err != nil {
+			return "", fmt.Errorf("Unable to Add Image Reference(%s): %s", tagged.String(), err)
+		}
+	}
+
+	return "sha256:" + imageConfig.ImageID, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/reference"
+	"github.com/docker/engine-api/types/container"
+
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+)
+
+func TestImportImageConfig(t *testing.T) {
+	content := layerTar(t, "etc/hostname", "imported")
+
+	// imports may be compressed
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write(content)
+	gz.Close()
+
+	ic := NewImageC(Options{Destination: os.TempDir(), Outstream: ioutil.Discard}, streamformatter.NewJSONStreamFormatter())
+	layer := &ImageWithMeta{Image: &models.Image{ID: "imported"}}
+
+	name, err := ic.stageLayer(buf, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+
+	diffID := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+	if layer.diffID != diffID || layer.layer.BlobSum != diffID || layer.size != int64(len(content)) {
+		t.Errorf("Unexpected staged layer %s with sum %s and size %d", layer.diffID, layer.layer.BlobSum, layer.size)
+	}
+
+	staged, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(staged, content) {
+		t.Errorf("Staged layer was not decompressed")
+	}
+
+	// the changes given to docker import arrive in the container config
+	config := &container.Config{Cmd: []string{"/bin/sh"}, Env: []string{"A=b"}}
+	blob, image, err := importImageConfig(layer.diffID, "Imported from -", config, time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	named, _ := reference.ParseNamed("imported:v1")
+	imageConfig, err := createLoadedImageConfig(blob, image, []*ImageWithMeta{layer}, named.(reference.NamedTagged))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imageConfig.ImageID != fmt.Sprintf("%x", sha256.Sum256(blob)) {
+		t.Errorf("Image ID %s is not the sum of the config", imageConfig.ImageID)
+	}
+	if imageConfig.ID != "imported" || imageConfig.Parent != "" {
+		t.Errorf("Unexpected image layer %s with parent %s", imageConfig.ID, imageConfig.Parent)
+	}
+	if imageConfig.Config.Cmd[0] != "/bin/sh" || imageConfig.Config.Env[0] != "A=b" {
+		t.Errorf("Config changes were not applied: %#v", imageConfig.Config)
+	}
+	if imageConfig.Comment != "Imported from -" || len(imageConfig.History) != 1 {
+		t.Errorf("Unexpected comment %q and history %v", imageConfig.Comment, imageConfig.History)
+	}
+	if imageConfig.Reference != "imported:v1" || imageConfig.DiffIDs[diffID] != "imported" {
+		t.Errorf("Unexpected reference %s and diffIDs %v", imageConfig.Reference, imageConfig.DiffIDs)
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/docker/distribution/digest"
+	docker "github.com/docker/docker/image"
+	"github.com/docker/docker/image/v1"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/reference"
+
+	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+	"github.com/vmware/vic/lib/metadata"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/vsphere/sys"
+)
+
+// safePath returns the path within the unpacked tarball, following symlinks
+// only as far as the root of the tarball
+func safePath(root, name string) (string, error) {
+	return symlink.FollowSymlinkInScope(filepath.Join(root, name), root)
+}
+
+// loadedImage is an image read from a docker save tarball
+type loadedImage struct {
+	Config   *metadata.ImageConfig
+	RepoTags []reference.NamedTagged
+}
+
+// layersToLoad creates a slice of ImageWithMeta for the layers of the manifest
+// item, from the image layer to the root as pulled layers are. Layers keep the
+// legacy ID they were saved with, falling back to their chain ID, so that layers
+// already in the image store are not written again.
+func (ic *ImageC) layersToLoad(dir string, item manifestItem, image *docker.Image) ([]*ImageWithMeta, error) {
+	if image.RootFS == nil || len(image.RootFS.DiffIDs) != len(item.Layers) {
+		return nil, fmt.Errorf("Invalid manifest, the number of layers does not match the image config")
+	}
+
+	layers := make([]*ImageWithMeta, len(item.Layers))
+	rootFS := docker.NewRootFS()
+	parent := "scratch"
+
+	for i, diffID := range image.RootFS.DiffIDs {
+		rootFS.Append(diffID)
+
+		id := filepath.Base(filepath.Dir(item.Layers[i]))
+		if err := v1.ValidateID(id); err != nil {
+			id = digest.Digest(rootFS.ChainID()).Hex()
+		}
+
+		// the history of the layer is taken from its legacy config if there is one
+		history := docker.V1Image{Created: image.Created}
+		if legacy, err := safePath(dir, filepath.Join(filepath.Dir(item.Layers[i]), layerConfigFileName)); err == nil {
+			if blob, err := ioutil.ReadFile(legacy); err == nil {
+				if err = json.Unmarshal(blob, &history); err != nil {
+					return nil, fmt.Errorf("Failed to unmarshal layer history: %s", err)
+				}
+			}
+		}
+
+		history.ID = id
+		history.Parent = ""
+		if parent != "scratch" {
+			history.Parent = parent
+		}
+
+		meta, err := json.Marshal(history)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to marshal layer history: %s", err)
+		}
+
+		// layers are ordered as they are in a pull, the image layer first
+		layerParent := parent
+		layers[len(layers)-1-i] = &ImageWithMeta{
+			Image: &models.Image{
+				ID:     id,
+				Parent: &layerParent,
+				Store:  ic.Storename,
+			},
+			diffID: string(diffID),
+			meta:   string(meta),
+		}
+
+		parent = id
+	}
+
+	return layers, nil
+}
+
+// checkLayer computes the sum of the layer file and verifies its diffID, which
+// is the sum of the uncompressed layer
+func checkLayer(name string, layer *ImageWithMeta) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	rc, err := archive.DecompressStream(io.TeeReader(f, sum))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	diffIDSum := sha256.New()
+	size, err := io.Copy(diffIDSum, rc)
+	if err != nil {
+		return err
+	}
+
+	if diffID := fmt.Sprintf("sha256:%x", diffIDSum.Sum(nil)); diffID != layer.diffID {
+		return fmt.Errorf("Invalid diffID for layer %s: expected %s, got %s", layer.ID, layer.diffID, diffID)
+	}
+
+	// the image store verifies the layer file as it is written
+	layer.layer.BlobSum = fmt.Sprintf("sha256:%x", sum.Sum(nil))
+	layer.size = size
+
+	return nil
+}
+
+// loadLayer writes the checked layer file to the image store
+func (ic *ImageC) loadLayer(name string, layer *ImageWithMeta, progressOutput progress.Output) error {
+	defer trace.End(trace.Begin(layer.ID))
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var in io.ReadCloser = f
+	if progressOutput != nil {
+		fi, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("Failed to stat file: %s", err)
+		}
+
+		in = progress.NewProgressReader(f, progressOutput, fi.Size(), layer.String(), "Loading layer")
+		defer in.Close()
+	}
+
+	if err = WriteImage(ic.Host, layer, in); err != nil {
+		return fmt.Errorf("Failed to write to image store: %s", err)
+	}
+
+	return nil
+}
+
+// createLoadedImageConfig constructs the image metadata from the docker image
+// config and the layers that compose the image, as CreateImageConfig does for
+// a pull, and stores it in the image layer
+func createLoadedImageConfig(config []byte, image *docker.Image, layers []*ImageWithMeta, named reference.NamedTagged) (metadata.ImageConfig, error) {
+	imageLayer := layers[0]
+
+	diffIDs := make(map[string]string)
+	layerSizes := make(map[string]int64)
+	var size int64
+	for _, layer := range layers {
+		diffIDs[layer.diffID] = layer.ID
+		layerSizes[layer.ID] = layer.size
+		size += layer.size
+	}
+
+	// the image ID is the sum of the docker image config
+	sum := fmt.Sprintf("%x", sha256.Sum256(config))
+	log.Infof("Image ID: sha256:%s", sum)
+
+	v1 := docker.V1Image{
+		ID:              imageLayer.ID,
+		Comment:         image.Comment,
+		Created:         image.Created,
+		Container:       image.Container,
+		ContainerConfig: image.ContainerConfig,
+		DockerVersion:   image.DockerVersion,
+		Author:          image.Author,
+		Config:          image.Config,
+		Architecture:    image.Architecture,
+		OS:              image.OS,
+		Size:            size,
+	}
+	if len(layers) > 1 {
+		v1.Parent = layers[1].ID
+	}
+
+	metaData := metadata.ImageConfig{
+		V1Image:     v1,
+		ImageID:     sum,
+		DiffIDs:     diffIDs,
+		History:     image.History,
+		LayerSizes:  layerSizes,
+		Healthcheck: metadata.HealthcheckFromConfig(config),
+	}
+
+	if named != nil {
+		metaData.Tags = []string{named.Tag()}
+		metaData.Name = named.RemoteName()
+		metaData.Reference = named.String()
+	}
+
+	blob, err := json.Marshal(metaData)
+	if err != nil {
+		return metadata.ImageConfig{}, fmt.Errorf("Failed to marshal image metadata: %s", err)
+	}
+
+	// store metadata
+	imageLayer.meta = string(blob)
+
+	return metaData, nil
+}
+
+// loadImage writes the layers of the manifest item that are not yet in the
+// image store and returns the image
+func (ic *ImageC) loadImage(dir string, item manifestItem, progressOutput progress.Output) (*loadedImage, error) {
+	name, err := safePath(dir, item.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := docker.NewFromJSON(config)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := &loadedImage{}
+	for _, repoTag := range item.RepoTags {
+		named, err := reference.ParseNamed(repoTag)
+		if err != nil {
+			return nil, err
+		}
+		tagged, ok := named.(reference.NamedTagged)
+		if !ok {
+			return nil, fmt.Errorf("Invalid tag %q", repoTag)
+		}
+		loaded.RepoTags = append(loaded.RepoTags, tagged)
+	}
+
+	layers, err := ic.layersToLoad(dir, item, image)
+	if err != nil {
+		return nil, err
+	}
+
+	// layers are verified before any are written, which also gives the sizes
+	// needed for the image config
+	names := make([]string, len(layers))
+	for i := range layers {
+		if names[i], err = safePath(dir, item.Layers[len(layers)-1-i]); err != nil {
+			return nil, err
+		}
+		if err = checkLayer(names[i], layers[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var named reference.NamedTagged
+	if len(loaded.RepoTags) > 0 {
+		named = loaded.RepoTags[0]
+	}
+
+	imageConfig, err := createLoadedImageConfig(config, image, layers, named)
+	if err != nil {
+		return nil, err
+	}
+	loaded.Config = &imageConfig
+
+	// parents are written before their children
+	for i := len(layers) - 1; i >= 0; i-- {
+		layer := layers[i]
+		if _, err := cache.LayerCache().IsDownloading(layer.ID); err == nil {
+			continue
+		}
+
+		if err = ic.loadLayer(names[i], layer, progressOutput); err != nil {
+			return nil, err
+		}
+
+		cache.LayerCache().AddExisting(layer.ID)
+	}
+
+	return loaded, nil
+}
+
+// LoadImages reads a docker save tarball and writes the images in it to the
+// image store, tagging them with the tags they were saved with
+func (ic *ImageC) LoadImages(r io.Reader, quiet bool) error {
+	defer trace.End(trace.Begin(""))
+
+	var progressOutput progress.Output
+	out := ic.Outstream
+	if !quiet {
+		progressOutput = ic.progressOutput
+		out = &streamformatter.StdoutFormatter{Writer: ic.Outstream, StreamFormatter: ic.sf}
+	}
+
+	// Host is either the host's UUID (if run on vsphere) or the hostname of
+	// the system (if run standalone)
+	host, err := sys.UUID()
+	if err != nil {
+		log.Errorf("Failed to return host name: %s", err)
+		return err
+	}
+
+	ic.Storename = host
+
+	// Ping the server to ensure it's at least running
+	ok, err := PingPortLayer(ic.Host)
+	if err != nil || !ok {
+		log.Errorf("Failed to ping portlayer: %s", err)
+		return err
+	}
+
+	dir, err := ioutil.TempDir(ic.Destination, "docker-import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err = archive.Untar(r, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+		return fmt.Errorf("Failed to unpack image tarball: %s", err)
+	}
+
+	name, err := safePath(dir, manifestFileName)
+	if err != nil {
+		return err
+	}
+
+	blob, err := ioutil.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Image tarballs without a %s are not supported", manifestFileName)
+		}
+		return err
+	}
+
+	var manifest []manifestItem
+	if err = json.Unmarshal(blob, &manifest); err != nil {
+		return fmt.Errorf("Failed to unmarshal %s: %s", manifestFileName, err)
+	}
+
+	repoCache := cache.RepositoryCache()
+
+	for _, item := range manifest {
+		loaded, err := ic.loadImage(dir, item, progressOutput)
+		if err != nil {
+			return err
+		}
+
+		image := loaded.Config
+		cache.ImageCache().AddImage(image)
+
+		if len(loaded.RepoTags) == 0 {
+			fmt.Fprintf(out, "Loaded image ID: sha256:%s\n", image.ImageID)
+			continue
+		}
+
+		// loading retags the image as docker does, taking the tags from older images
+		for _, tagged := range loaded.RepoTags {
+			if err = repoCache.AddReference(tagged, image.ImageID, true, image.ID, true); err != nil {
+				return fmt.Errorf("Unable to Add Image Reference(%s): %s", tagged.String(), err)
+			}
+			fmt.Fprintf(out, "Loaded image: %s\n", tagged.String())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/reference"
+
+	"github.com/vmware/vic/lib/apiservers/portlayer/models"
+	"github.com/vmware/vic/lib/metadata"
+)
+
+// layerTar returns a layer tar holding a single file
+func layerTar(t *testing.T, name, content string) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSaveAndLoadImageConfig(t *testing.T) {
+	layerIDs := []string{
+		"5f70bf18a086007016e948b04aed3b82103a36bea41755b6cddfaf10ace3c6ef",
+		"a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4",
+	}
+	contents := [][]byte{
+		layerTar(t, "etc/hostname", "root"),
+		layerTar(t, "etc/motd", "child"),
+	}
+
+	var diffIDs []string
+	for _, c := range contents {
+		diffIDs = append(diffIDs, fmt.Sprintf("sha256:%x", sha256.Sum256(c)))
+	}
+
+	image := &metadata.ImageConfig{
+		V1Image: docker.V1Image{
+			ID:           layerIDs[1],
+			Parent:       layerIDs[0],
+			Created:      time.Unix(0, 0).UTC(),
+			Architecture: "amd64",
+			OS:           "linux",
+		},
+		History: []docker.History{{CreatedBy: "ADD root"}, {CreatedBy: "ADD child"}},
+	}
+
+	config, err := saveImageConfig(image, diffIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// write a tarball as SaveImages does
+	buf := &bytes.Buffer{}
+	tw := &tarWriter{Writer: tar.NewWriter(buf), modTime: time.Now()}
+	item := manifestItem{
+		Config:   fmt.Sprintf("%x.json", sha256.Sum256(config)),
+		RepoTags: []string{"busybox:latest"},
+	}
+	for i, id := range layerIDs {
+		if err = tw.writeDir(id); err != nil {
+			t.Fatal(err)
+		}
+		if err = tw.writeBytes(path.Join(id, layerFileName), contents[i]); err != nil {
+			t.Fatal(err)
+		}
+		item.Layers = append(item.Layers, path.Join(id, layerFileName))
+	}
+	if err = tw.writeBytes(item.Config, config); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := json.Marshal([]manifestItem{item})
+	if err = tw.writeBytes(manifestFileName, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "imagec-load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = archive.Untar(buf, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedConfig, err := ioutil.ReadFile(filepath.Join(dir, item.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := docker.NewFromJSON(loadedConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ic := NewImageC(Options{Outstream: ioutil.Discard}, streamformatter.NewJSONStreamFormatter())
+	layers, err := ic.layersToLoad(dir, item, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// layers are ordered from the image layer, keeping their saved IDs
+	if len(layers) != 2 || layers[0].ID != layerIDs[1] || layers[1].ID != layerIDs[0] {
+		t.Fatalf("Unexpected layers: %#v", layers)
+	}
+	if *layers[0].Parent != layerIDs[0] || *layers[1].Parent != "scratch" {
+		t.Errorf("Unexpected parents %s and %s", *layers[0].Parent, *layers[1].Parent)
+	}
+
+	for i, layer := range layers {
+		if err = checkLayer(filepath.Join(dir, item.Layers[len(layers)-1-i]), layer); err != nil {
+			t.Fatal(err)
+		}
+		// the layers are uncompressed so the sum written is the diffID
+		if layer.layer.BlobSum != layer.diffID {
+			t.Errorf("Layer %s has sum %s rather than %s", layer.ID, layer.layer.BlobSum, layer.diffID)
+		}
+	}
+
+	named, _ := reference.ParseNamed(item.RepoTags[0])
+	loaded, err := createLoadedImageConfig(loadedConfig, img, layers, named.(reference.NamedTagged))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.ImageID != fmt.Sprintf("%x", sha256.Sum256(config)) {
+		t.Errorf("Image ID %s is not the sum of the config", loaded.ImageID)
+	}
+	if loaded.ID != layerIDs[1] || loaded.Parent != layerIDs[0] {
+		t.Errorf("Unexpected image layer %s with parent %s", loaded.ID, loaded.Parent)
+	}
+	if loaded.Reference != "busybox:latest" || loaded.Tags[0] != "latest" {
+		t.Errorf("Unexpected reference %s", loaded.Reference)
+	}
+	if loaded.DiffIDs[diffIDs[0]] != layerIDs[0] || loaded.DiffIDs[diffIDs[1]] != layerIDs[1] {
+		t.Errorf("Unexpected diffIDs %v", loaded.DiffIDs)
+	}
+	if len(loaded.History) != 2 || loaded.Architecture != "amd64" {
+		t.Errorf("Image config was not loaded: %#v", loaded)
+	}
+
+	// the image layer holds the image config
+	stored := metadata.ImageConfig{}
+	if err = json.Unmarshal([]byte(layers[0].meta), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.ImageID != loaded.ImageID {
+		t.Errorf("Image layer holds %s rather than %s", stored.ImageID, loaded.ImageID)
+	}
+}
+
+func TestCheckLayerDiffIDMismatch(t *testing.T) {
+	f, err := ioutil.TempFile("", "imagec-layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(layerTar(t, "etc/hostname", "root")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	layer := &ImageWithMeta{Image: &models.Image{ID: "layer"}, diffID: "sha256:0000"}
+	if err = checkLayer(f.Name(), layer); err == nil {
+		t.Errorf("Expected a diffID mismatch")
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/reference"
+	"github.com/docker/libtrust"
+
+	"github.com/vmware/vic/lib/apiservers/engine/backends/cache"
+	"github.com/vmware/vic/lib/metadata"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/vsphere/sys"
+)
+
+// LayersToPush creates a slice of ImageWithMeta for the layers of the image, from
+// the image layer to the root, as they are in the image store
+func (ic *ImageC) LayersToPush(image *metadata.ImageConfig) ([]*ImageWithMeta, error) {
+	var layers []*ImageWithMeta
+
+	for id := image.ID; id != "" && id != "scratch"; {
+		layer, err := GetImage(ic.Host, ic.Storename, id)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get layer %s from the image store: %s", id, err)
+		}
+
+		meta := layer.Metadata[metadata.MetaDataKey]
+		if len(layers) == 0 {
+			// the image layer holds the image config rather than its history
+			blob, err := json.Marshal(image.V1Image)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to marshal image history: %s", err)
+			}
+			meta = string(blob)
+		}
+
+		layers = append(layers, &ImageWithMeta{
+			Image: layer,
+			meta:  meta,
+			layer: FSLayer{BlobSum: image.BlobSums[id]},
+		})
+
+		id = ""
+		if layer.Parent != nil {
+			// the parent is the URL of the parent layer in the image store
+			id = path.Base(*layer.Parent)
+		}
+	}
+
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("Image %s has no layers", image.ImageID)
+	}
+
+	return layers, nil
+}
+
+// exportLayer writes the compressed layer from the image store to a temporary
+// file, recording its digests, and returns the file positioned at the start
+func (ic *ImageC) exportLayer(ctx context.Context, layer *ImageWithMeta) (*os.File, int64, error) {
+	defer trace.End(trace.Begin(layer.ID))
+
+	f, err := ioutil.TempFile(ic.Destination, layer.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cleanup := func(err error) (*os.File, int64, error) {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, err
+	}
+
+	// blobSum is the sha of the compressed layer
+	blobSum := sha256.New()
+
+	// diffIDSum is the sha of the uncompressed layer
+	diffIDSum := sha256.New()
+
+	gz := gzip.NewWriter(io.MultiWriter(f, blobSum))
+	if err = GetImageTar(ctx, ic.Host, ic.Storename, layer.ID, io.MultiWriter(gz, diffIDSum)); err != nil {
+		return cleanup(fmt.Errorf("Failed to export layer %s: %s", layer.ID, err))
+	}
+
+	if err = gz.Close(); err != nil {
+		return cleanup(err)
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return cleanup(err)
+	}
+
+	layer.layer.BlobSum = fmt.Sprintf("sha256:%x", blobSum.Sum(nil))
+	layer.diffID = fmt.Sprintf("sha256:%x", diffIDSum.Sum(nil))
+
+	return f, size, nil
+}
+
+// pushLayer makes the layer available in the repository, reusing the blob it was
+// pulled as if the registry has it and uploading it from the image store otherwise
+func (ic *ImageC) pushLayer(ctx context.Context, layer *ImageWithMeta, mountFrom string) error {
+	defer trace.End(trace.Begin(layer.ID))
+
+	id := stringid.TruncateID(layer.ID)
+
+	if blobSum := layer.layer.BlobSum; blobSum != "" {
+		exists, err := CheckImageBlob(ctx, ic.Options, blobSum)
+		if err != nil {
+			return err
+		}
+		if exists {
+			progress.Update(ic.progressOutput, id, "Layer already exists")
+			return nil
+		}
+
+		if mountFrom != "" {
+			mounted, _, err := MountImageBlob(ctx, ic.Options, blobSum, mountFrom)
+			if err != nil {
+				return err
+			}
+			if mounted {
+				progress.Update(ic.progressOutput, id, "Mounted from "+mountFrom)
+				return nil
+			}
+			// the upload started in place of the mount is abandoned for one of our own
+		}
+	}
+
+	f, size, err := ic.exportLayer(ctx, layer)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	location, err := StartImageBlobUpload(ctx, ic.Options)
+	if err != nil {
+		return err
+	}
+
+	if err = UploadImageBlob(ctx, ic.Options, location, f, size, layer.layer.BlobSum, ic.progressOutput, id); err != nil {
+		return err
+	}
+
+	progress.Update(ic.progressOutput, id, "Pushed")
+
+	return nil
+}
+
+// CreateImageManifest constructs the signed schema1 manifest of the pushed layers
+func (ic *ImageC) CreateImageManifest(image *metadata.ImageConfig, layers []*ImageWithMeta) (*schema1.SignedManifest, error) {
+	architecture := image.Architecture
+	if architecture == "" {
+		architecture = "amd64"
+	}
+
+	m := &schema1.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name:         ic.Image,
+		Tag:          ic.Tag,
+		Architecture: architecture,
+		FSLayers:     make([]schema1.FSLayer, len(layers)),
+		History:      make([]schema1.History, len(layers)),
+	}
+
+	// both are ordered from the image layer to the root
+	for i, layer := range layers {
+		m.FSLayers[i] = schema1.FSLayer{BlobSum: digest.Digest(layer.layer.BlobSum)}
+		m.History[i] = schema1.History{V1Compatibility: layer.meta}
+	}
+
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate manifest signing key: %s", err)
+	}
+
+	// this is schema1.Sign, which is tied to the libtrust vendored by distribution
+	canonical, err := json.MarshalIndent(m, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := libtrust.NewJSONSignature(canonical)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = js.Sign(key); err != nil {
+		return nil, fmt.Errorf("Failed to sign manifest: %s", err)
+	}
+
+	pretty, err := js.PrettySignature("signatures")
+	if err != nil {
+		return nil, err
+	}
+
+	signed := &schema1.SignedManifest{}
+	if err = json.Unmarshal(pretty, signed); err != nil {
+		return nil, err
+	}
+
+	return signed, nil
+}
+
+// PushImage pushes an image to its registry
+func (ic *ImageC) PushImage() error {
+
+	// ctx
+	ctx, cancel := context.WithTimeout(ctx, ic.Options.Timeout)
+	defer cancel()
+
+	// Parse the -reference parameter
+	if err := ic.ParseReference(); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	named, err := reference.ParseNamed(ic.Reference)
+	if err != nil {
+		return err
+	}
+	named = reference.WithDefaultTag(named)
+
+	image, err := cache.ImageCache().GetImage(named.String())
+	if err != nil {
+		return fmt.Errorf("An image does not exist locally with the tag: %s", named.Name())
+	}
+
+	// Host is either the host's UUID (if run on vsphere) or the hostname of
+	// the system (if run standalone)
+	host, err := sys.UUID()
+	if err != nil {
+		log.Errorf("Failed to return host name: %s", err)
+		return err
+	}
+
+	ic.Storename = host
+
+	// Ping the server to ensure it's at least running
+	ok, err := PingPortLayer(ic.Host)
+	if err != nil || !ok {
+		log.Errorf("Failed to ping portlayer: %s", err)
+		return err
+	}
+
+	// Calculate (and overwrite) the registry URL and make sure that it responds to requests
+	ic.Registry, err = LearnRegistryURL(ic.Options)
+	if err != nil {
+		log.Errorf("Error while pushing image: %s", err)
+		return err
+	}
+
+	// blobs can be mounted from the repository the image was pulled from if it
+	// is in the same registry
+	var mountFrom []string
+	if src, err := reference.ParseNamed(image.Reference); err == nil {
+		if src.Hostname() == named.Hostname() && src.RemoteName() != ic.Image {
+			mountFrom = append(mountFrom, src.RemoteName())
+		}
+	}
+
+	// Get the URL of the OAuth endpoint
+	url, err := LearnPushAuthURL(ic.Options, mountFrom...)
+	if err != nil {
+		return fmt.Errorf("Failed to obtain OAuth endpoint: %s", err)
+	}
+
+	// Get the OAuth token - if only we have a URL
+	if url != nil {
+		token, err := FetchToken(ctx, ic.Options, url, ic.progressOutput)
+		if err != nil {
+			log.Errorf("Failed to fetch OAuth token: %s", err)
+			return err
+		}
+		ic.Token = token
+	}
+
+	layers, err := ic.LayersToPush(image)
+	if err != nil {
+		return err
+	}
+	ic.ImageLayers = layers
+
+	progress.Message(ic.progressOutput, "", "The push refers to a repository ["+named.FullName()+"]")
+
+	for _, layer := range layers {
+		progress.Update(ic.progressOutput, stringid.TruncateID(layer.ID), "Preparing")
+	}
+
+	// parents are pushed before their children
+	for i := len(layers) - 1; i >= 0; i-- {
+		from := ""
+		if len(mountFrom) > 0 {
+			from = mountFrom[0]
+		}
+		if err := ic.pushLayer(ctx, layers[i], from); err != nil {
+			return err
+		}
+	}
+
+	signed, err := ic.CreateImageManifest(image, layers)
+	if err != nil {
+		return err
+	}
+
+	dgst, err := PushImageManifest(ctx, ic.Options, signed)
+	if err != nil {
+		return fmt.Errorf("Failed to push image manifest: %s", err)
+	}
+
+	_, payload, err := signed.Payload()
+	if err != nil {
+		return err
+	}
+	progress.Message(ic.progressOutput, "", fmt.Sprintf("%s: digest: %s size: %d", ic.Tag, dgst, len(payload)))
+
+	// record the digest of the image in the repository
+	if digested, err := reference.WithDigest(named, dgst); err == nil {
+		if err := cache.RepositoryCache().AddReference(digested, image.ImageID, false, image.ID, true); err != nil {
+			log.Warnf("Unable to add reference %s: %s", digested, err)
+		}
+	}
+
+	return nil
+}
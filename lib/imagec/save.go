@@ -0,0 +1,280 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	docker "github.com/docker/docker/image"
+	dockerLayer "github.com/docker/docker/layer"
+	"github.com/docker/docker/reference"
+
+	"github.com/vmware/vic/lib/metadata"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/vsphere/sys"
+)
+
+// The names of the files in a docker save tarball
+const (
+	manifestFileName     = "manifest.json"
+	repositoriesFileName = "repositories"
+	layerFileName        = "layer.tar"
+	layerConfigFileName  = "json"
+	layerVersionFileName = "VERSION"
+
+	// layerVersion is the version of the legacy layer format
+	layerVersion = "1.0"
+)
+
+// manifestItem is an image in the manifest.json of a docker save tarball
+type manifestItem struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// SavedImage is an image to include in a docker save tarball along with the
+// references it is tagged with there
+type SavedImage struct {
+	Image    *metadata.ImageConfig
+	RepoTags []reference.NamedTagged
+}
+
+// tarWriter writes the files of a docker save tarball
+type tarWriter struct {
+	*tar.Writer
+
+	modTime time.Time
+}
+
+// writeFile writes size bytes from r as the named file
+func (t *tarWriter) writeFile(name string, r io.Reader, size int64) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  t.modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := t.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := io.CopyN(t, r, size)
+	return err
+}
+
+// writeBytes writes the content as the named file
+func (t *tarWriter) writeBytes(name string, content []byte) error {
+	return t.writeFile(name, bytes.NewReader(content), int64(len(content)))
+}
+
+// writeDir writes the named directory
+func (t *tarWriter) writeDir(name string) error {
+	return t.WriteHeader(&tar.Header{
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  t.modTime,
+		Typeflag: tar.TypeDir,
+	})
+}
+
+// saveLayer writes the layer from the image store to the tarball and returns
+// its diffID
+func (ic *ImageC) saveLayer(ctx context.Context, t *tarWriter, layer *ImageWithMeta) (string, error) {
+	defer trace.End(trace.Begin(layer.ID))
+
+	// the tar header needs the size of the layer so it is staged first
+	f, err := ioutil.TempFile(ic.Destination, layer.ID)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	diffIDSum := sha256.New()
+	if err = GetImageTar(ctx, ic.Host, ic.Storename, layer.ID, io.MultiWriter(f, diffIDSum)); err != nil {
+		return "", fmt.Errorf("Failed to export layer %s: %s", layer.ID, err)
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if err = t.writeDir(layer.ID); err != nil {
+		return "", err
+	}
+	if err = t.writeBytes(path.Join(layer.ID, layerVersionFileName), []byte(layerVersion)); err != nil {
+		return "", err
+	}
+	if err = t.writeBytes(path.Join(layer.ID, layerConfigFileName), []byte(layer.meta)); err != nil {
+		return "", err
+	}
+	if err = t.writeFile(path.Join(layer.ID, layerFileName), f, size); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256:%x", diffIDSum.Sum(nil)), nil
+}
+
+// saveImageConfig returns the docker image config of the image with the diffIDs
+// of its layers as saved
+func saveImageConfig(image *metadata.ImageConfig, diffIDs []string) ([]byte, error) {
+	rootFS := docker.NewRootFS()
+	for _, diffID := range diffIDs {
+		rootFS.Append(dockerLayer.DiffID(diffID))
+	}
+
+	// the config is constructed without the fields of the image store
+	config := docker.Image{
+		V1Image: docker.V1Image{
+			Comment:         image.Comment,
+			Created:         image.Created,
+			Container:       image.Container,
+			ContainerConfig: image.ContainerConfig,
+			DockerVersion:   image.DockerVersion,
+			Author:          image.Author,
+			Config:          image.Config,
+			Architecture:    image.Architecture,
+			OS:              image.OS,
+		},
+		RootFS:  rootFS,
+		History: image.History,
+	}
+
+	return config.MarshalJSON()
+}
+
+// SaveImages writes the images and their layers to w in the docker save tarball
+// format. Layers shared between the images are written once.
+func (ic *ImageC) SaveImages(images []SavedImage, w io.Writer) error {
+	defer trace.End(trace.Begin(""))
+
+	// ctx
+	ctx, cancel := context.WithTimeout(ctx, ic.Options.Timeout)
+	defer cancel()
+
+	// Host is either the host's UUID (if run on vsphere) or the hostname of
+	// the system (if run standalone)
+	host, err := sys.UUID()
+	if err != nil {
+		log.Errorf("Failed to return host name: %s", err)
+		return err
+	}
+
+	ic.Storename = host
+
+	// Ping the server to ensure it's at least running
+	ok, err := PingPortLayer(ic.Host)
+	if err != nil || !ok {
+		log.Errorf("Failed to ping portlayer: %s", err)
+		return err
+	}
+
+	t := &tarWriter{
+		Writer:  tar.NewWriter(w),
+		modTime: time.Now(),
+	}
+
+	var manifest []manifestItem
+	repositories := make(map[string]map[string]string)
+	saved := make(map[string]string)
+
+	for _, image := range images {
+		layers, err := ic.LayersToPush(image.Image)
+		if err != nil {
+			return err
+		}
+
+		item := manifestItem{}
+		diffIDs := make([]string, 0, len(layers))
+
+		// layers are listed from the root to the image layer
+		for i := len(layers) - 1; i >= 0; i-- {
+			layer := layers[i]
+
+			diffID, ok := saved[layer.ID]
+			if !ok {
+				if diffID, err = ic.saveLayer(ctx, t, layer); err != nil {
+					return err
+				}
+				saved[layer.ID] = diffID
+			}
+
+			diffIDs = append(diffIDs, diffID)
+			item.Layers = append(item.Layers, path.Join(layer.ID, layerFileName))
+		}
+
+		config, err := saveImageConfig(image.Image, diffIDs)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal image config: %s", err)
+		}
+
+		item.Config = fmt.Sprintf("%x.json", sha256.Sum256(config))
+		if err = t.writeBytes(item.Config, config); err != nil {
+			return err
+		}
+
+		for _, tagged := range image.RepoTags {
+			name := tagged.Name()
+			if repositories[name] == nil {
+				repositories[name] = make(map[string]string)
+			}
+			repositories[name][tagged.Tag()] = image.Image.ID
+
+			item.RepoTags = append(item.RepoTags, tagged.String())
+		}
+
+		manifest = append(manifest, item)
+	}
+
+	blob, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err = t.writeBytes(manifestFileName, blob); err != nil {
+		return err
+	}
+
+	if len(repositories) > 0 {
+		if blob, err = json.Marshal(repositories); err != nil {
+			return err
+		}
+		if err = t.writeBytes(repositoriesFileName, blob); err != nil {
+			return err
+		}
+	}
+
+	return t.Close()
+}
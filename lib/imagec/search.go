@@ -0,0 +1,191 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/engine-api/types/registry"
+
+	"github.com/vmware/vic/pkg/trace"
+)
+
+const (
+	// DefaultSearchLimit is the number of search results returned if no limit is given
+	DefaultSearchLimit = 25
+
+	// MaxSearchLimit is the largest number of search results that may be asked for
+	MaxSearchLimit = 100
+
+	// catalogPageSize is the number of repositories asked for in each catalog request
+	catalogPageSize = 100
+)
+
+// acceptedSearchFilters are the filters docker search accepts
+var acceptedSearchFilters = map[string]bool{
+	"is-automated": true,
+	"is-official":  true,
+	"stars":        true,
+}
+
+// catalog is the response of the v2 catalog API
+type catalog struct {
+	Repositories []string `json:"repositories"`
+}
+
+// fetchCatalogPage fetches the repositories of the catalog following last,
+// fetching a token first if the registry asks for one
+func fetchCatalogPage(ctx context.Context, options *Options, last string) ([]string, error) {
+	url, err := url.Parse(options.Registry)
+	if err != nil {
+		return nil, err
+	}
+	url.Path = strings.TrimSuffix(url.Path, "/") + "/_catalog"
+
+	q := url.Query()
+	q.Set("n", strconv.Itoa(catalogPageSize))
+	if last != "" {
+		q.Set("last", last)
+	}
+	url.RawQuery = q.Encode()
+
+	log.Debugf("URL: %s", url)
+
+	fetcher := newUploadFetcher(*options)
+	data, err := fetcher.Fetch(ctx, url, false, nil)
+	if err != nil && options.Token == nil && fetcher.IsStatusUnauthorized() && fetcher.AuthURL() != nil {
+		// the token is kept for the pages that follow
+		if options.Token, err = FetchToken(ctx, *options, fetcher.AuthURL(), nil); err != nil {
+			return nil, err
+		}
+
+		fetcher = newUploadFetcher(*options)
+		data, err = fetcher.Fetch(ctx, url, false, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	page := catalog{}
+	if err = json.Unmarshal([]byte(data), &page); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal catalog: %s", err)
+	}
+
+	return page.Repositories, nil
+}
+
+// SearchCatalog searches the v2 catalog of the registry for the repositories whose
+// names contain the term, for registries that do not expose the v1 search API. The
+// names of the results are prefixed with index so that they can be pulled.
+func SearchCatalog(ctx context.Context, options Options, index, term string) ([]registry.SearchResult, error) {
+	defer trace.End(trace.Begin(index + "/" + term))
+
+	var results []registry.SearchResult
+
+	for last := ""; ; {
+		repositories, err := fetchCatalogPage(ctx, &options, last)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to search the catalog of %s: %s", index, err)
+		}
+
+		for _, repository := range repositories {
+			if strings.Contains(repository, term) {
+				results = append(results, registry.SearchResult{Name: index + "/" + repository})
+			}
+		}
+
+		if len(repositories) < catalogPageSize {
+			return results, nil
+		}
+		last = repositories[len(repositories)-1]
+	}
+}
+
+// searchFilterBool returns the value of the boolean search filter
+func searchFilterBool(searchFilters filters.Args, field string) (bool, error) {
+	values := searchFilters.Get(field)
+	if len(values) == 1 {
+		if b, err := strconv.ParseBool(values[0]); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("Invalid filter '%s=%s'", field, strings.Join(values, ","))
+}
+
+// FilterSearchResults applies the is-official, is-automated and stars filters of
+// docker search to the results, which registries do not do, and returns at most
+// limit of those that match
+func FilterSearchResults(results []registry.SearchResult, searchFilters filters.Args, limit int) ([]registry.SearchResult, error) {
+	if err := searchFilters.Validate(acceptedSearchFilters); err != nil {
+		return nil, err
+	}
+
+	if limit < 1 || limit > MaxSearchLimit {
+		return nil, fmt.Errorf("Limit %d is outside the range of [1, %d]", limit, MaxSearchLimit)
+	}
+
+	var isAutomated, isOfficial bool
+	var err error
+
+	if searchFilters.Include("is-automated") {
+		if isAutomated, err = searchFilterBool(searchFilters, "is-automated"); err != nil {
+			return nil, err
+		}
+	}
+	if searchFilters.Include("is-official") {
+		if isOfficial, err = searchFilterBool(searchFilters, "is-official"); err != nil {
+			return nil, err
+		}
+	}
+
+	stars := 0
+	for _, value := range searchFilters.Get("stars") {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid filter 'stars=%s'", value)
+		}
+		if n > stars {
+			stars = n
+		}
+	}
+
+	filtered := make([]registry.SearchResult, 0, len(results))
+	for _, result := range results {
+		if len(filtered) == limit {
+			break
+		}
+		if searchFilters.Include("is-automated") && result.IsAutomated != isAutomated {
+			continue
+		}
+		if searchFilters.Include("is-official") && result.IsOfficial != isOfficial {
+			continue
+		}
+		if result.StarCount < stars {
+			continue
+		}
+		filtered = append(filtered, result)
+	}
+
+	return filtered, nil
+}
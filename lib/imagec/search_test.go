@@ -0,0 +1,131 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/engine-api/types/registry"
+)
+
+func TestSearchCatalog(t *testing.T) {
+	// a full first page makes the search ask for the page that follows
+	var repositories []string
+	for i := 0; i < catalogPageSize; i++ {
+		repositories = append(repositories, fmt.Sprintf("library/repo%03d", i))
+	}
+	repositories = append(repositories, "library/busybox", "test/busybox")
+
+	var s *httptest.Server
+	s = httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/token":
+				json.NewEncoder(w).Encode(map[string]string{"token": "catalog"})
+			case "/v2/_catalog":
+				if r.Header.Get("Authorization") != "Bearer catalog" {
+					w.Header().Set("www-authenticate",
+						fmt.Sprintf("Bearer realm=\"%s/token\",service=\"registry\",scope=\"registry:catalog:*\"", s.URL))
+					http.Error(w, "You shall not pass", http.StatusUnauthorized)
+					return
+				}
+
+				page := repositories[catalogPageSize:]
+				if r.URL.Query().Get("last") == "" {
+					page = repositories[:catalogPageSize]
+				}
+				json.NewEncoder(w).Encode(catalog{Repositories: page})
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	defer s.Close()
+
+	options := Options{
+		Registry: s.URL + "/v2/",
+		Timeout:  DefaultHTTPTimeout,
+	}
+
+	results, err := SearchCatalog(context.Background(), options, "registry.local:5000", "busybox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Name != "registry.local:5000/library/busybox" || results[1].Name != "registry.local:5000/test/busybox" {
+		t.Errorf("Unexpected results: %#v", results)
+	}
+}
+
+func TestFilterSearchResults(t *testing.T) {
+	results := []registry.SearchResult{
+		{Name: "busybox", IsOfficial: true, StarCount: 1000},
+		{Name: "test/busybox", IsAutomated: true, StarCount: 10},
+		{Name: "other/busybox", StarCount: 1},
+	}
+
+	parse := func(args ...string) filters.Args {
+		f := filters.NewArgs()
+		for i := 0; i < len(args); i += 2 {
+			f.Add(args[i], args[i+1])
+		}
+		return f
+	}
+
+	tests := []struct {
+		filters filters.Args
+		limit   int
+		names   []string
+	}{
+		{parse(), DefaultSearchLimit, []string{"busybox", "test/busybox", "other/busybox"}},
+		{parse(), 1, []string{"busybox"}},
+		{parse("is-official", "true"), DefaultSearchLimit, []string{"busybox"}},
+		{parse("is-official", "false"), DefaultSearchLimit, []string{"test/busybox", "other/busybox"}},
+		{parse("is-automated", "true"), DefaultSearchLimit, []string{"test/busybox"}},
+		{parse("stars", "5", "stars", "100"), DefaultSearchLimit, []string{"busybox"}},
+		{parse("stars", "5", "is-official", "false"), DefaultSearchLimit, []string{"test/busybox"}},
+	}
+
+	for _, test := range tests {
+		filtered, err := FilterSearchResults(results, test.filters, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, result := range filtered {
+			names = append(names, result.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(test.names) {
+			t.Errorf("Expected %v for %v, got %v", test.names, test.filters, names)
+		}
+	}
+
+	for _, f := range []filters.Args{parse("is-official", "maybe"), parse("stars", "many"), parse("name", "busybox")} {
+		if _, err := FilterSearchResults(results, f, DefaultSearchLimit); err == nil {
+			t.Errorf("Expected an error for %v", f)
+		}
+	}
+
+	if _, err := FilterSearchResults(results, parse(), MaxSearchLimit+1); err == nil {
+		t.Errorf("Expected an error for limit %d", MaxSearchLimit+1)
+	}
+}
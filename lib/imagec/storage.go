@@ -15,7 +15,9 @@
 package imagec
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 
 	"golang.org/x/net/context"
 
@@ -115,3 +117,54 @@ func WriteImage(host string, image *ImageWithMeta, data io.ReadCloser) error {
 	return nil
 
 }
+
+// WriteEmptyLayer writes a layer that makes no changes to the filesystem of its
+// parent, carrying only the image metadata, to the given image store
+func WriteEmptyLayer(host, store, parent, id, meta string) error {
+	defer trace.End(trace.Begin(id))
+
+	image := &ImageWithMeta{
+		Image: &models.Image{
+			ID:     id,
+			Parent: &parent,
+			Store:  store,
+		},
+		layer: FSLayer{BlobSum: DigestSHA256EmptyTar},
+		meta:  meta,
+	}
+
+	// an empty tar file is 1024 NULL bytes
+	return WriteImage(host, image, ioutil.NopCloser(bytes.NewReader(make([]byte, 1024))))
+}
+
+// GetImage returns the image from given image store
+func GetImage(host, storename, id string) (*models.Image, error) {
+	defer trace.End(trace.Begin(id))
+
+	transport := httptransport.New(host, "/", []string{"http"})
+	client := apiclient.New(transport, nil)
+
+	image, err := client.Storage.GetImage(
+		storage.NewGetImageParamsWithContext(ctx).WithStoreName(storename).WithID(id),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return image.Payload, nil
+}
+
+// GetImageTar writes the layer tar of the image from given image store to w
+func GetImageTar(ctx context.Context, host, storename, id string, w io.Writer) error {
+	defer trace.End(trace.Begin(id))
+
+	transport := httptransport.New(host, "/", []string{"http"})
+	client := apiclient.New(transport, nil)
+
+	transport.Consumers["application/octet-stream"] = httpkit.ByteStreamConsumer()
+
+	_, err := client.Storage.GetImageTar(
+		storage.NewGetImageTarParamsWithContext(ctx).WithStoreName(storename).WithID(id),
+		w,
+	)
+	return err
+}
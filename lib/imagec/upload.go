@@ -0,0 +1,306 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/docker/pkg/progress"
+
+	urlfetcher "github.com/vmware/vic/pkg/fetcher"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// uploadChunkSize is the size of the chunks that blobs larger than it are uploaded in
+var uploadChunkSize int64 = 32 * 1024 * 1024
+
+// newUploadFetcher returns a fetcher carrying the credentials and token of the options
+func newUploadFetcher(options Options) urlfetcher.Fetcher {
+	return urlfetcher.NewURLFetcher(urlfetcher.Options{
+		Timeout:            options.Timeout,
+		Username:           options.Username,
+		Password:           options.Password,
+		Token:              options.Token,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	})
+}
+
+// registryURL returns the URL of the path elements below the repository
+func registryURL(options Options, elem ...string) (*url.URL, error) {
+	url, err := url.Parse(options.Registry)
+	if err != nil {
+		return nil, err
+	}
+	url.Path = path.Join(append([]string{url.Path, options.Image}, elem...)...)
+
+	return url, nil
+}
+
+// LearnPushAuthURL returns the URL of the OAuth endpoint with the scope needed to push
+// to the repository and to mount blobs from the repositories in mountFrom. It returns
+// nil if the registry does not use token authentication.
+func LearnPushAuthURL(options Options, mountFrom ...string) (*url.URL, error) {
+	defer trace.End(trace.Begin(options.Image + "/" + options.Tag))
+
+	url, err := url.Parse(options.Registry)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("URL: %s", url)
+
+	fetcher := urlfetcher.NewURLFetcher(urlfetcher.Options{
+		Timeout:            options.Timeout,
+		Username:           options.Username,
+		Password:           options.Password,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	})
+
+	// The base of the API challenges for authentication if the registry requires it
+	hdr, err := fetcher.Head(url)
+	if err != nil {
+		return nil, fmt.Errorf("%s returned an unexpected response: %s", url, err)
+	}
+
+	challenge := hdr.Get("www-authenticate")
+	if fetcher.IsStatusOK() || challenge == "" {
+		log.Debugf("%s does not support OAuth", url)
+		return nil, nil
+	}
+
+	auth, err := fetcher.ExtractOAuthURL(challenge, nil)
+	if err != nil {
+		// basic auth challenges are answered with the credentials on each request
+		log.Debugf("%s does not support OAuth: %s", url, err)
+		return nil, nil
+	}
+
+	q := auth.Query()
+	q.Del("scope")
+	q.Add("scope", fmt.Sprintf("repository:%s:pull,push", options.Image))
+	for _, from := range mountFrom {
+		q.Add("scope", fmt.Sprintf("repository:%s:pull", from))
+	}
+	auth.RawQuery = q.Encode()
+
+	return auth, nil
+}
+
+// CheckImageBlob returns whether the blob already exists in the repository
+func CheckImageBlob(ctx context.Context, options Options, blobSum string) (bool, error) {
+	defer trace.End(trace.Begin(options.Image + "/" + blobSum))
+
+	url, err := registryURL(options, "blobs", blobSum)
+	if err != nil {
+		return false, err
+	}
+
+	log.Debugf("URL: %s", url)
+
+	fetcher := newUploadFetcher(options)
+
+	_, err = fetcher.Head(url)
+	if err != nil {
+		// head treats anything but OK and Unauthorized as an error
+		if fetcher.IsStatusNotFound() {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if fetcher.IsStatusUnauthorized() {
+		return false, fmt.Errorf("Authentication required")
+	}
+
+	return true, nil
+}
+
+// MountImageBlob asks the registry to mount the blob from another repository. If the
+// blob could not be mounted the registry starts an upload instead, the location of
+// which is returned.
+func MountImageBlob(ctx context.Context, options Options, blobSum, from string) (bool, *url.URL, error) {
+	defer trace.End(trace.Begin(options.Image + "/" + blobSum + " from " + from))
+
+	url, err := registryURL(options, "blobs", "uploads")
+	if err != nil {
+		return false, nil, err
+	}
+	// the trailing slash is part of the endpoint
+	url.Path += "/"
+
+	q := url.Query()
+	q.Set("mount", blobSum)
+	q.Set("from", from)
+	url.RawQuery = q.Encode()
+
+	log.Debugf("URL: %s", url)
+
+	fetcher := newUploadFetcher(options)
+
+	hdr, err := fetcher.Post(ctx, url, nil, nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if fetcher.IsStatusCreated() {
+		return true, nil, nil
+	}
+
+	location, err := uploadLocation(url, hdr)
+	return false, location, err
+}
+
+// StartImageBlobUpload starts a blob upload and returns its location
+func StartImageBlobUpload(ctx context.Context, options Options) (*url.URL, error) {
+	defer trace.End(trace.Begin(options.Image))
+
+	url, err := registryURL(options, "blobs", "uploads")
+	if err != nil {
+		return nil, err
+	}
+	url.Path += "/"
+
+	log.Debugf("URL: %s", url)
+
+	fetcher := newUploadFetcher(options)
+
+	hdr, err := fetcher.Post(ctx, url, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return uploadLocation(url, hdr)
+}
+
+// uploadLocation returns the upload location from the response headers, which
+// may be relative to the request
+func uploadLocation(base *url.URL, hdr http.Header) (*url.URL, error) {
+	location := hdr.Get("Location")
+	if location == "" {
+		return nil, fmt.Errorf("Missing Location header in response from %s", base)
+	}
+
+	ref, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	return base.ResolveReference(ref), nil
+}
+
+// UploadImageBlob uploads size bytes of the blob from r to the upload location. Blobs
+// larger than the chunk size are uploaded in chunks and the upload is completed with
+// the digest of the blob.
+func UploadImageBlob(ctx context.Context, options Options, location *url.URL, r io.Reader, size int64, blobSum string, progressOutput progress.Output, id string) error {
+	defer trace.End(trace.Begin(options.Image + "/" + blobSum))
+
+	fetcher := newUploadFetcher(options)
+
+	in := progress.NewProgressReader(
+		ioutil.NopCloser(r),
+		progressOutput,
+		size,
+		id,
+		"Pushing",
+	)
+	defer in.Close()
+
+	hdrs := http.Header{}
+	hdrs.Set("Content-Type", "application/octet-stream")
+
+	// chunks are sent until only the last one remains, which completes the upload
+	var offset int64
+	for size-offset > uploadChunkSize {
+		end := offset + uploadChunkSize
+
+		hdrs.Set("Content-Range", fmt.Sprintf("%d-%d", offset, end-1))
+		hdrs.Set("Content-Length", strconv.FormatInt(end-offset, 10))
+
+		log.Debugf("URL: %s", location)
+
+		hdr, err := fetcher.Patch(ctx, location, io.LimitReader(in, end-offset), hdrs)
+		if err != nil {
+			return err
+		}
+
+		if location, err = uploadLocation(location, hdr); err != nil {
+			return err
+		}
+		offset = end
+	}
+
+	hdrs.Del("Content-Range")
+	hdrs.Set("Content-Length", strconv.FormatInt(size-offset, 10))
+
+	q := location.Query()
+	q.Set("digest", blobSum)
+	location.RawQuery = q.Encode()
+
+	log.Debugf("URL: %s", location)
+
+	if _, err := fetcher.Put(ctx, location, io.LimitReader(in, size-offset), hdrs); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PushImageManifest uploads the signed manifest as the tag of the repository and
+// returns its digest
+func PushImageManifest(ctx context.Context, options Options, manifest *schema1.SignedManifest) (digest.Digest, error) {
+	defer trace.End(trace.Begin(options.Image + "/" + options.Tag))
+
+	url, err := registryURL(options, "manifests", options.Tag)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("URL: %s", url)
+
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return "", err
+	}
+
+	hdrs := http.Header{}
+	hdrs.Set("Content-Type", schema1.MediaTypeSignedManifest)
+	hdrs.Set("Content-Length", strconv.Itoa(len(payload)))
+
+	fetcher := newUploadFetcher(options)
+
+	hdr, err := fetcher.Put(ctx, url, bytes.NewReader(payload), hdrs)
+	if err != nil {
+		return "", err
+	}
+
+	if d := hdr.Get("Docker-Content-Digest"); d != "" {
+		return digest.ParseDigest(d)
+	}
+
+	// the digest of a signed manifest is that of the manifest without signatures
+	return digest.FromBytes(manifest.Canonical), nil
+}
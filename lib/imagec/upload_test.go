@@ -0,0 +1,217 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagec
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/pkg/streamformatter"
+
+	"github.com/vmware/vic/lib/metadata"
+)
+
+func TestLearnPushAuthURL(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("www-authenticate",
+				"Bearer realm=\"https://auth.docker.io/token\",service=\"registry.docker.io\"")
+			http.Error(w, "You shall not pass", http.StatusUnauthorized)
+		}))
+	defer s.Close()
+
+	options := Options{
+		Registry: s.URL + "/v2/",
+		Image:    Image,
+		Tag:      Tag,
+		Timeout:  DefaultHTTPTimeout,
+	}
+
+	url, err := LearnPushAuthURL(options, "library/busybox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scopes := url.Query()["scope"]
+	if len(scopes) != 2 || scopes[0] != "repository:library/photon:pull,push" || scopes[1] != "repository:library/busybox:pull" {
+		t.Errorf("Returned url %s does not have the expected scopes", url)
+	}
+}
+
+func TestMountImageBlob(t *testing.T) {
+	s := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" || r.URL.Path != "/v2/"+Image+"/blobs/uploads/" {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+
+			if r.URL.Query().Get("from") == "library/busybox" {
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+
+			w.Header().Set("Location", "/v2/"+Image+"/blobs/uploads/1234")
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	defer s.Close()
+
+	options := Options{
+		Registry: s.URL + "/v2/",
+		Image:    Image,
+		Timeout:  DefaultHTTPTimeout,
+	}
+
+	ctx := context.TODO()
+	mounted, location, err := MountImageBlob(ctx, options, DigestSHA256LayerContent, "library/busybox")
+	if err != nil || !mounted || location != nil {
+		t.Errorf("Expected blob to be mounted: %t, %s, %v", mounted, location, err)
+	}
+
+	mounted, location, err = MountImageBlob(ctx, options, DigestSHA256LayerContent, "library/alpine")
+	if err != nil || mounted {
+		t.Fatalf("Expected an upload to be started: %t, %v", mounted, err)
+	}
+
+	if location.String() != s.URL+"/v2/"+Image+"/blobs/uploads/1234" {
+		t.Errorf("Returned location %s is different than expected", location)
+	}
+}
+
+func TestUploadImageBlob(t *testing.T) {
+	defer func(size int64) { uploadChunkSize = size }(uploadChunkSize)
+	uploadChunkSize = 8
+
+	var received bytes.Buffer
+	var completed string
+
+	s := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			switch r.Method {
+			case "PATCH":
+				if r.Header.Get("Content-Range") == "" || r.ContentLength != int64(len(data)) {
+					http.Error(w, "bad chunk", http.StatusRequestedRangeNotSatisfiable)
+					return
+				}
+				received.Write(data)
+				w.Header().Set("Location", r.URL.Path)
+				w.WriteHeader(http.StatusAccepted)
+			case "PUT":
+				received.Write(data)
+				completed = r.URL.Query().Get("digest")
+				w.WriteHeader(http.StatusCreated)
+			default:
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+			}
+		}))
+	defer s.Close()
+
+	options := Options{
+		Registry:  s.URL + "/v2/",
+		Image:     Image,
+		Timeout:   DefaultHTTPTimeout,
+		Outstream: os.Stdout,
+	}
+
+	ic := NewImageC(options, streamformatter.NewJSONStreamFormatter())
+
+	location, err := url.Parse(s.URL + "/v2/" + Image + "/blobs/uploads/1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.TODO()
+	err = UploadImageBlob(ctx, options, location, strings.NewReader(LayerContent), int64(len(LayerContent)), DigestSHA256LayerContent, ic.progressOutput, LayerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.String() != LayerContent {
+		t.Errorf("Registry received %q rather than the blob", received.String())
+	}
+
+	if completed != DigestSHA256LayerContent {
+		t.Errorf("Upload was completed with digest %q", completed)
+	}
+}
+
+func TestPushImageManifest(t *testing.T) {
+	var pushed []byte
+
+	s := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "PUT" || r.URL.Path != "/v2/"+Image+"/manifests/"+Tag {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+			pushed, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		}))
+	defer s.Close()
+
+	options := Options{
+		Registry: s.URL + "/v2/",
+		Image:    Image,
+		Tag:      Tag,
+		Timeout:  DefaultHTTPTimeout,
+	}
+
+	ic := NewImageC(options, streamformatter.NewJSONStreamFormatter())
+
+	image := &metadata.ImageConfig{}
+	image.ID = LayerID
+	layers := []*ImageWithMeta{
+		{
+			meta:  LayerHistory,
+			layer: FSLayer{BlobSum: DigestSHA256LayerContent},
+		},
+	}
+
+	signed, err := ic.CreateImageManifest(image, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signed.FSLayers[0].BlobSum != DigestSHA256LayerContent || signed.History[0].V1Compatibility != LayerHistory {
+		t.Errorf("Signed manifest %#v is different than expected", signed.Manifest)
+	}
+
+	ctx := context.TODO()
+	dgst, err := PushImageManifest(ctx, options, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dgst != digest.FromBytes(signed.Canonical) {
+		t.Errorf("Returned digest %s is different than expected", dgst)
+	}
+
+	if !bytes.Contains(pushed, []byte("signatures")) {
+		t.Errorf("Registry did not receive the signed manifest")
+	}
+}
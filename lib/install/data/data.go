@@ -64,6 +64,8 @@ type Data struct {
 
 	InsecureRegistries []url.URL
 
+	ContainerPauseMode string
+
 	NumCPUs  int
 	MemoryMB int
 
@@ -15,6 +15,9 @@
 package metadata
 
 import (
+	"encoding/json"
+	"time"
+
 	docker "github.com/docker/docker/image"
 )
 
@@ -35,4 +38,41 @@ type ImageConfig struct {
 	DiffIDs   map[string]string `json:"diff_ids,omitempty"`
 	History   []docker.History  `json:"history,omitempty"`
 	Reference string            `json:"registry"`
+	// BlobSums maps layer IDs to the digests of the compressed layers in the
+	// registry the image was pulled from
+	BlobSums map[string]string `json:"blob_sums,omitempty"`
+	// LayerSizes maps layer IDs to the uncompressed sizes of the layers
+	LayerSizes map[string]int64 `json:"layer_sizes,omitempty"`
+	// Healthcheck is the HEALTHCHECK of the image, which the config of the
+	// docker image does not hold in the docker version we vendor
+	Healthcheck *HealthConfig `json:"healthcheck,omitempty"`
+}
+
+// HealthConfig describes how to check that a container is healthy, in the
+// form docker gives it in image and container configs
+type HealthConfig struct {
+	// Test is the probe: {} inherits it, {"NONE"} disables it, {"CMD", args...}
+	// runs a command and {"CMD-SHELL", command} runs a command with the shell
+	Test []string `json:",omitempty"`
+
+	// Zero means to inherit the value
+	Interval time.Duration `json:",omitempty"`
+	Timeout  time.Duration `json:",omitempty"`
+	Retries  int           `json:",omitempty"`
+}
+
+// HealthcheckFromConfig returns the healthcheck of a docker image or
+// container config, if it has one
+func HealthcheckFromConfig(config []byte) *HealthConfig {
+	image := struct {
+		Config *struct {
+			Healthcheck *HealthConfig
+		}
+	}{}
+
+	if err := json.Unmarshal(config, &image); err != nil || image.Config == nil {
+		return nil
+	}
+
+	return image.Config.Healthcheck
 }
@@ -15,13 +15,18 @@
 package attach
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/vmware/vic/cmd/tether/msgs"
+	"github.com/vmware/vic/pkg/archive"
 	"github.com/vmware/vic/pkg/trace"
 )
 
@@ -44,6 +49,21 @@ type SessionInteraction interface {
 	Resize(cols, rows, widthpx, heightpx uint32) error
 
 	CloseStdin() error
+
+	// Release the process if its launch is held waiting for the attach to complete
+	Unblock() error
+
+	// Stat the path in the filesystem of the session's container
+	StatPath(path string) (*archive.PathStat, error)
+	// Export a tar archive of the path from the session's container
+	ArchiveExport(path string) (io.ReadCloser, error)
+	// Import a tar archive into the directory at path in the session's container
+	ArchiveImport(path string, noOverwriteDirNonDir bool, tar io.Reader) error
+	// Export a tar archive of the session's container filesystem
+	ExportFilesystem() (io.ReadCloser, error)
+
+	// Return the process table of the session's container formatted as for the ps arguments
+	ProcessList(args string) ([]string, [][]string, error)
 }
 
 type attachSSH struct {
@@ -128,6 +148,25 @@ func (t *attachSSH) CloseStdin() error {
 	return nil
 }
 
+// Unblock releases the held process of the session. Once the process is released a
+// repeated request is a no-op in the tether, but it is not guaranteed to succeed: it
+// fails once the process has exited and the tether has closed the session channel, so
+// callers should unblock once and not rely on repeating it.
+func (t *attachSSH) Unblock() error {
+	defer trace.End(trace.Begin(""))
+
+	ok, err := t.channel.SendRequest(msgs.RunUnblockReq, true, nil)
+	if err == nil && !ok {
+		return fmt.Errorf("unknown error releasing process")
+	}
+
+	if err != nil {
+		return fmt.Errorf("unblock error: %s", err)
+	}
+
+	return nil
+}
+
 func (t *attachSSH) Stdout() io.Reader {
 	defer trace.End(trace.Begin(""))
 
@@ -172,3 +211,151 @@ func (t *attachSSH) Resize(cols, rows, widthpx, heightpx uint32) error {
 	}
 	return nil
 }
+
+// StatPath returns the details of the path in the container filesystem
+func (t *attachSSH) StatPath(path string) (*archive.PathStat, error) {
+	defer trace.End(trace.Begin(path))
+
+	msg := msgs.StatPathMsg{Path: path}
+	ok, reply, err := t.client.SendRequest(msgs.StatPathReq, true, msg.Marshal())
+	if err != nil {
+		return nil, fmt.Errorf("stat path error: %s", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("stat path error: %s", string(reply))
+	}
+
+	stat := msgs.PathStatMsg{}
+	if err = stat.Unmarshal(reply); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stat from remote: %s", err)
+	}
+
+	if !stat.Found {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+
+	return &archive.PathStat{
+		Name:       stat.Name,
+		Size:       int64(stat.Size),
+		Mode:       os.FileMode(stat.Mode),
+		Mtime:      time.Unix(0, int64(stat.Mtime)),
+		LinkTarget: stat.LinkTarget,
+	}, nil
+}
+
+// ProcessList returns the process table of the container formatted as for the ps arguments
+func (t *attachSSH) ProcessList(args string) ([]string, [][]string, error) {
+	defer trace.End(trace.Begin(args))
+
+	msg := msgs.ProcessListMsg{Args: args}
+	ok, reply, err := t.client.SendRequest(msgs.ProcessListReq, true, msg.Marshal())
+	if err != nil {
+		return nil, nil, fmt.Errorf("process list error: %s", err)
+	}
+
+	if !ok {
+		return nil, nil, fmt.Errorf("process list error: %s", string(reply))
+	}
+
+	table := msgs.ProcessTableMsg{}
+	if err = table.Unmarshal(reply); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal process list from remote: %s", err)
+	}
+
+	return table.Titles, table.Processes, nil
+}
+
+// archiveChannel opens an archive channel for the given operation
+func (t *attachSSH) archiveChannel(msg *msgs.ArchiveMsg) (ssh.Channel, error) {
+	channel, requests, err := t.client.OpenChannel(msgs.ArchiveChannelType, msg.Marshal())
+	if err != nil {
+		return nil, err
+	}
+
+	go ssh.DiscardRequests(requests)
+
+	return channel, nil
+}
+
+// archiveError returns the failure reported by the tether on the channel stderr, if any
+func archiveError(channel ssh.Channel) error {
+	detail, err := ioutil.ReadAll(channel.Stderr())
+	if err != nil {
+		return err
+	}
+
+	if len(detail) > 0 {
+		return fmt.Errorf("%s", bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// archiveReader surfaces any failure reported by the tether once the tar stream ends
+type archiveReader struct {
+	ssh.Channel
+}
+
+func (a *archiveReader) Read(p []byte) (int, error) {
+	n, err := a.Channel.Read(p)
+	if err == io.EOF {
+		if aerr := archiveError(a.Channel); aerr != nil {
+			return n, aerr
+		}
+	}
+	return n, err
+}
+
+// ArchiveExport returns a tar stream of the path from the container filesystem
+func (t *attachSSH) ArchiveExport(path string) (io.ReadCloser, error) {
+	defer trace.End(trace.Begin(path))
+
+	channel, err := t.archiveChannel(&msgs.ArchiveMsg{Op: msgs.ArchiveExport, Path: path})
+	if err != nil {
+		return nil, fmt.Errorf("archive export error: %s", err)
+	}
+
+	return &archiveReader{Channel: channel}, nil
+}
+
+// ExportFilesystem returns a tar stream of the container filesystem
+func (t *attachSSH) ExportFilesystem() (io.ReadCloser, error) {
+	defer trace.End(trace.Begin(""))
+
+	channel, err := t.archiveChannel(&msgs.ArchiveMsg{Op: msgs.ArchiveExportFilesystem})
+	if err != nil {
+		return nil, fmt.Errorf("filesystem export error: %s", err)
+	}
+
+	return &archiveReader{Channel: channel}, nil
+}
+
+// ArchiveImport extracts the tar stream into the directory at path in the container filesystem
+func (t *attachSSH) ArchiveImport(path string, noOverwriteDirNonDir bool, tar io.Reader) error {
+	defer trace.End(trace.Begin(path))
+
+	msg := &msgs.ArchiveMsg{
+		Op:                   msgs.ArchiveImport,
+		Path:                 path,
+		NoOverwriteDirNonDir: noOverwriteDirNonDir,
+	}
+
+	channel, err := t.archiveChannel(msg)
+	if err != nil {
+		return fmt.Errorf("archive import error: %s", err)
+	}
+	defer channel.Close()
+
+	_, cerr := io.Copy(channel, tar)
+	channel.CloseWrite()
+
+	// the tether reports extraction failures in preference to our copy error
+	if err = archiveError(channel); err != nil {
+		return err
+	}
+
+	if cerr != nil {
+		return fmt.Errorf("archive import error: %s", cerr)
+	}
+	return nil
+}
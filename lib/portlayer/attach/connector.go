@@ -46,6 +46,10 @@ type Connector struct {
 	cond        *sync.Cond
 	connections map[string]*Connection
 
+	// clients holds the ssh clients to the tethers so that sessions created after the
+	// initial connection (e.g. exec) can be discovered
+	clients map[*ssh.Client]struct{}
+
 	listener net.Listener
 	// Quit channel for listener routine
 	listenerQuit chan bool
@@ -61,6 +65,7 @@ func NewConnector(listener net.Listener, debug bool) *Connector {
 
 	connector := &Connector{
 		connections:  make(map[string]*Connection),
+		clients:      make(map[*ssh.Client]struct{}),
 		listener:     listener,
 		listenerQuit: make(chan bool),
 		debug:        debug,
@@ -91,6 +96,9 @@ func (c *Connector) Get(ctx context.Context, id string, timeout time.Duration) (
 		return nil, fmt.Errorf("no such connection")
 	}
 
+	// the session may have been added to a tether we're already connected to
+	c.discover()
+
 	result := make(chan *Connection, 1)
 
 	go func() {
@@ -210,29 +218,88 @@ func (c *Connector) processIncoming(conn net.Conn) {
 		return
 	}
 
+	c.mutex.Lock()
+	c.clients[client] = struct{}{}
+	c.mutex.Unlock()
+
 	var si SessionInteraction
 	for _, id := range ids {
 		si, err = SSHAttach(client, id)
 		if err != nil {
 			log.Errorf("SSH connection could not be established (id=%s): %s", id, errors.ErrorStack(err))
+			c.removeClient(client)
 			return
 		}
 
 		log.Infof("Established connection with container VM: %s", id)
 
-		c.mutex.Lock()
-		connection := &Connection{
-			spty: si,
-			id:   id,
-		}
+		c.add(id, si)
+	}
 
-		c.connections[connection.id] = connection
+	return
+}
 
-		c.cond.Broadcast()
-		c.mutex.Unlock()
+// add records the session interaction and wakes up any waiters
+func (c *Connector) add(id string, si SessionInteraction) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	connection := &Connection{
+		spty: si,
+		id:   id,
 	}
 
-	return
+	c.connections[connection.id] = connection
+
+	c.cond.Broadcast()
+}
+
+func (c *Connector) removeClient(client *ssh.Client) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.clients, client)
+}
+
+// discover lists the sessions on each of the known tether connections and attaches
+// to any that we don't have a connection for yet
+func (c *Connector) discover() {
+	defer trace.End(trace.Begin(""))
+
+	c.mutex.RLock()
+	clients := make([]*ssh.Client, 0, len(c.clients))
+	for client := range c.clients {
+		clients = append(clients, client)
+	}
+	c.mutex.RUnlock()
+
+	for _, client := range clients {
+		ids, err := SSHls(client)
+		if err != nil {
+			log.Debugf("attach connector: dropping client after failed session list: %s", err)
+			c.removeClient(client)
+			continue
+		}
+
+		for _, id := range ids {
+			c.mutex.RLock()
+			_, ok := c.connections[id]
+			c.mutex.RUnlock()
+
+			if ok {
+				continue
+			}
+
+			si, err := SSHAttach(client, id)
+			if err != nil {
+				log.Errorf("attach connector: unable to attach to discovered session %s: %s", id, err)
+				continue
+			}
+
+			log.Infof("Established connection with new session: %s", id)
+			c.add(id, si)
+		}
+	}
 }
 
 // Starts the connector listening on the specified source
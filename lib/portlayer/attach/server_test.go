@@ -15,7 +15,9 @@
 package attach
 
 import (
+	"io/ioutil"
 	"net"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -152,3 +154,154 @@ func TestAttachSshSession(t *testing.T) {
 
 	wg.Wait()
 }
+
+// testTether is a minimal tether hosting a single session that records the stdin it is sent
+type testTether struct {
+	conn *ssh.ServerConn
+
+	// the stdin received, sent once the attach channel is closed
+	stdin chan []byte
+	// closed when the session is sent close-stdin
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// startTestTether connects a test tether hosting the session id to the attach server
+func startTestTether(t *testing.T, s *Server, id string) *testTether {
+	networkClientCon, err := net.Dial("tcp", s.l.Addr().String())
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	if !assert.NoError(t, serial.HandshakeServer(context.Background(), networkClientCon)) {
+		return nil
+	}
+
+	containerConfig := &ssh.ServerConfig{
+		NoClientAuth: true,
+	}
+
+	signer, err := ssh.ParsePrivateKey(testdata.PEMBytes["dsa"])
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	containerConfig.AddHostKey(signer)
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(networkClientCon, containerConfig)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	tt := &testTether{
+		conn:   sshConn,
+		stdin:  make(chan []byte, 1),
+		closed: make(chan struct{}),
+	}
+
+	go func() {
+		for req := range reqs {
+			if req.Type == msgs.ContainersReq {
+				msg := msgs.ContainersMsg{IDs: []string{id}}
+				req.Reply(true, msg.Marshal())
+				continue
+			}
+			req.Reply(false, nil)
+		}
+	}()
+
+	go func() {
+		for ch := range chans {
+			channel, requests, err := ch.Accept()
+			if err != nil {
+				continue
+			}
+
+			go func() {
+				for req := range requests {
+					if req.Type == msgs.CloseStdinReq {
+						tt.closeOnce.Do(func() { close(tt.closed) })
+						req.Reply(true, nil)
+						continue
+					}
+					req.Reply(false, nil)
+				}
+			}()
+
+			go func() {
+				data, _ := ioutil.ReadAll(channel)
+				tt.stdin <- data
+			}()
+		}
+	}()
+
+	return tt
+}
+
+func TestCopyStdin(t *testing.T) {
+	log.SetLevel(log.InfoLevel)
+
+	ctrlPQ := []byte{16, 17}
+
+	tests := []struct {
+		input string
+		keys  []byte
+		once  bool
+
+		err    error
+		stdin  string
+		closed bool
+	}{
+		// stdin is left open for other clients unless stdinOnce is set
+		{"hello world", nil, false, nil, "hello world", false},
+		{"hello world", nil, true, nil, "hello world", true},
+		// the default detach keys
+		{"hello\x10\x11world", ctrlPQ, false, ErrDetached, "hello", false},
+		{"hello\x10\x11world", ctrlPQ, true, ErrDetached, "hello", true},
+		// detach keys given for the attach, under which the default keys are passed on
+		{"hello\x10\x11\x18world", []byte{24}, true, ErrDetached, "hello\x10\x11", true},
+		// no detach keys means no detach
+		{"hello\x10\x11world", nil, false, nil, "hello\x10\x11world", false},
+	}
+
+	for _, test := range tests {
+		s := NewAttachServer("", -1)
+		if !assert.NoError(t, s.Start(true)) {
+			return
+		}
+
+		tt := startTestTether(t, s, "foo")
+		if tt == nil {
+			s.Stop()
+			return
+		}
+
+		session, err := s.Get(context.Background(), "foo", 5*time.Second)
+		if !assert.NoError(t, err) {
+			tt.conn.Close()
+			s.Stop()
+			return
+		}
+
+		err = CopyStdin(session, strings.NewReader(test.input), test.keys, test.once)
+		assert.Equal(t, test.err, err, "%q", test.input)
+
+		// close-stdin is sent before CopyStdin returns
+		select {
+		case <-tt.closed:
+			assert.True(t, test.closed, "%q: stdin closed", test.input)
+		default:
+			assert.False(t, test.closed, "%q: stdin not closed", test.input)
+		}
+
+		session.Close()
+		select {
+		case stdin := <-tt.stdin:
+			assert.Equal(t, test.stdin, string(stdin), "%q", test.input)
+		case <-time.After(5 * time.Second):
+			t.Errorf("%q: timed out waiting for stdin", test.input)
+		}
+
+		tt.conn.Close()
+		s.Stop()
+	}
+}
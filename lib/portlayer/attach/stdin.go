@@ -0,0 +1,135 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attach
+
+import (
+	"errors"
+	"io"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// ErrDetached is returned when the client detaches from stdin by sending the detach keys
+var ErrDetached = errors.New("detached from container")
+
+// detachReader passes on what is read from src until the detach keys are read, when it
+// returns ErrDetached in place of them. Bytes that may be the start of the keys are held
+// back until it is known whether they are, so the keys are detected however the reads
+// split them.
+type detachReader struct {
+	src  io.Reader
+	keys []byte
+
+	buf     []byte
+	pending []byte
+	// the number of bytes of the keys read so far
+	matched int
+	// fallback[i] is the length of the longest proper prefix of keys[:i+1] that is also a
+	// suffix of it, which is how much of a match survives a mismatch after i+1 keys
+	fallback []int
+	err      error
+}
+
+func newDetachReader(src io.Reader, keys []byte) *detachReader {
+	fallback := make([]int, len(keys))
+	for i, k := 1, 0; i < len(keys); i++ {
+		for k > 0 && keys[i] != keys[k] {
+			k = fallback[k-1]
+		}
+		if keys[i] == keys[k] {
+			k++
+		}
+		fallback[i] = k
+	}
+
+	return &detachReader{
+		src:      src,
+		keys:     keys,
+		buf:      make([]byte, 32*1024),
+		fallback: fallback,
+	}
+}
+
+func (d *detachReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 && d.err == nil {
+		n, err := d.src.Read(d.buf)
+		d.scan(d.buf[:n])
+
+		if err != nil && d.err == nil {
+			// anything held back was not the detach keys after all
+			d.pending = append(d.pending, d.keys[:d.matched]...)
+			d.matched = 0
+			d.err = err
+		}
+	}
+
+	if len(d.pending) > 0 {
+		n := copy(p, d.pending)
+		d.pending = d.pending[n:]
+		return n, nil
+	}
+
+	return 0, d.err
+}
+
+// scan moves the bytes read to pending, up to the detach keys if they are read
+func (d *detachReader) scan(b []byte) {
+	for _, c := range b {
+		// on a mismatch the match falls back to the longest part of it that may still start
+		// the keys, and the bytes before that part are passed on
+		for d.matched > 0 && c != d.keys[d.matched] {
+			next := d.fallback[d.matched-1]
+			d.pending = append(d.pending, d.keys[:d.matched-next]...)
+			d.matched = next
+		}
+
+		if c != d.keys[d.matched] {
+			d.pending = append(d.pending, c)
+			continue
+		}
+
+		d.matched++
+		if d.matched == len(d.keys) {
+			// whatever follows the keys is discarded along with them
+			d.err = ErrDetached
+			return
+		}
+	}
+}
+
+// CopyStdin copies src to the stdin of the session until src ends or, if keys is set, the
+// client detaches by sending them, in which case ErrDetached is returned. If once is set the
+// stdin of the session is closed when the copy ends, either way, so that the process sees the
+// end of its input once the first client is done with it.
+func CopyStdin(session SessionInteraction, src io.Reader, keys []byte, once bool) error {
+	defer trace.End(trace.Begin(""))
+
+	if len(keys) > 0 {
+		src = newDetachReader(src, keys)
+	}
+
+	_, err := io.Copy(session.Stdin(), src)
+
+	if once {
+		log.Debugf("Closing stdin after the copy as stdinOnce is set")
+		if cerr := session.CloseStdin(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
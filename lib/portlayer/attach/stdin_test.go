@@ -0,0 +1,61 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attach
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetachReader(t *testing.T) {
+	ctrlPQ := []byte{16, 17}
+
+	tests := []struct {
+		input  string
+		keys   []byte
+		output string
+		err    error
+	}{
+		{"hello world", ctrlPQ, "hello world", nil},
+		{"hello\x10\x11world", ctrlPQ, "hello", ErrDetached},
+		{"\x10\x11", ctrlPQ, "", ErrDetached},
+		// a partial sequence is passed on
+		{"a\x10b\x10", ctrlPQ, "a\x10b\x10", nil},
+		// a repeated first key restarts the sequence
+		{"a\x10\x10\x11b", ctrlPQ, "a\x10", ErrDetached},
+		// as does a repeated start of keys that repeat it
+		{"aaab", []byte("aab"), "a", ErrDetached},
+		{"abacabab", []byte("abab"), "abac", ErrDetached},
+		// keys given for the attach
+		{"abc\x01dx", []byte("\x01d"), "abc", ErrDetached},
+		{"q", []byte("q"), "", ErrDetached},
+	}
+
+	for _, test := range tests {
+		// the result is the same whether the keys arrive together or split across reads
+		for _, src := range []io.Reader{strings.NewReader(test.input), iotest.OneByteReader(strings.NewReader(test.input))} {
+			out := &bytes.Buffer{}
+			_, err := io.Copy(out, newDetachReader(src, test.keys))
+
+			assert.Equal(t, test.err, err, "%q", test.input)
+			assert.Equal(t, test.output, out.String(), "%q", test.input)
+		}
+	}
+}
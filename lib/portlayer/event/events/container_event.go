@@ -25,6 +25,9 @@ const (
 	ContainerReconfigured = "Reconfigured"
 	ContainerStarted      = "Started"
 	ContainerStopped      = "Stopped"
+	ContainerRenamed      = "Renamed"
+	ContainerHealthy      = "Healthy"
+	ContainerUnhealthy    = "Unhealthy"
 )
 
 type ContainerEvent struct {
@@ -0,0 +1,128 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/vic/lib/portlayer/event/events"
+)
+
+// subscriberBuffer is the number of entries a streaming subscriber may fall
+// behind before entries are dropped for it
+const subscriberBuffer = 256
+
+// Entry is an event recorded in the history along with its position in the sequence
+type Entry struct {
+	ID    int64
+	Event events.Event
+}
+
+// History keeps a bounded record of events, so that clients can catch up on
+// what they missed, and streams new events to its subscribers.
+type History struct {
+	m sync.Mutex
+
+	limit   int
+	last    int64
+	entries []Entry
+
+	subscribers map[chan Entry]struct{}
+}
+
+// NewHistory returns a History holding at most limit events
+func NewHistory(limit int) *History {
+	return &History{
+		limit:       limit,
+		subscribers: make(map[chan Entry]struct{}),
+	}
+}
+
+// Add records the event and sends it to the subscribers. It has the signature
+// of an event manager callback so can be subscribed to a topic directly.
+func (h *History) Add(e events.Event) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.last++
+	entry := Entry{ID: h.last, Event: e}
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.limit {
+		h.entries = h.entries[len(h.entries)-h.limit:]
+	}
+
+	for sub := range h.subscribers {
+		select {
+		case sub <- entry:
+		default:
+			log.Warnf("Dropping event %d for slow subscriber", entry.ID)
+		}
+	}
+}
+
+// Events returns the recorded events created within [since, until] along with
+// the ID of the last event added. A zero time leaves that end of the range open.
+func (h *History) Events(since, until time.Time) ([]Entry, int64) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var entries []Entry
+	for _, entry := range h.entries {
+		created := entry.Event.Created()
+		if !since.IsZero() && created.Before(since) {
+			continue
+		}
+		if !until.IsZero() && created.After(until) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, h.last
+}
+
+// Subscribe returns the recorded events after the given ID and a channel on which
+// subsequent events are delivered. The channel must be released with Unsubscribe.
+func (h *History) Subscribe(after int64) ([]Entry, chan Entry) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var backlog []Entry
+	for _, entry := range h.entries {
+		if entry.ID > after {
+			backlog = append(backlog, entry)
+		}
+	}
+
+	sub := make(chan Entry, subscriberBuffer)
+	h.subscribers[sub] = struct{}{}
+
+	return backlog, sub
+}
+
+// Unsubscribe stops delivery of events to the channel and closes it
+func (h *History) Unsubscribe(sub chan Entry) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/portlayer/event/events"
+)
+
+func newContainerEvent(id string, created time.Time) *events.ContainerEvent {
+	return &events.ContainerEvent{
+		BaseEvent: &events.BaseEvent{
+			Ref:         id,
+			Event:       events.ContainerStarted,
+			CreatedTime: created,
+		},
+	}
+}
+
+func TestHistoryEvents(t *testing.T) {
+	h := NewHistory(3)
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		h.Add(newContainerEvent("c", start.Add(time.Duration(i)*time.Second)))
+	}
+
+	// only the most recent are kept
+	entries, last := h.Events(time.Time{}, time.Time{})
+	assert.Equal(t, int64(5), last)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, int64(3), entries[0].ID)
+		assert.Equal(t, int64(5), entries[2].ID)
+	}
+
+	entries, _ = h.Events(start.Add(3*time.Second), time.Time{})
+	assert.Len(t, entries, 2)
+
+	entries, _ = h.Events(start.Add(2*time.Second), start.Add(3*time.Second))
+	assert.Len(t, entries, 2)
+
+	entries, _ = h.Events(start.Add(10*time.Second), time.Time{})
+	assert.Len(t, entries, 0)
+}
+
+func TestHistorySubscribe(t *testing.T) {
+	h := NewHistory(10)
+
+	h.Add(newContainerEvent("a", time.Now()))
+	h.Add(newContainerEvent("b", time.Now()))
+
+	backlog, sub := h.Subscribe(1)
+	if assert.Len(t, backlog, 1) {
+		assert.Equal(t, "b", backlog[0].Event.Reference())
+	}
+
+	h.Add(newContainerEvent("c", time.Now()))
+
+	select {
+	case entry := <-sub:
+		assert.Equal(t, int64(3), entry.ID)
+		assert.Equal(t, "c", entry.Event.Reference())
+	case <-time.After(time.Second):
+		t.Fatal("Expected event was not delivered")
+	}
+
+	h.Unsubscribe(sub)
+	_, ok := <-sub
+	assert.False(t, ok, "Expected channel to be closed")
+
+	// adding after unsubscribe must not block or panic
+	h.Add(newContainerEvent("d", time.Now()))
+	h.Unsubscribe(sub)
+}
@@ -18,7 +18,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/vmware/govmomi/guest"
@@ -27,9 +29,11 @@ import (
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/vic/lib/config"
 	"github.com/vmware/vic/lib/config/executor"
 	"github.com/vmware/vic/lib/portlayer/event/events"
 	"github.com/vmware/vic/pkg/errors"
+	"github.com/vmware/vic/pkg/iolog"
 	"github.com/vmware/vic/pkg/trace"
 	"github.com/vmware/vic/pkg/uid"
 	"github.com/vmware/vic/pkg/vsphere/extraconfig"
@@ -58,6 +62,7 @@ const (
 	StateCreating
 	StateRemoving
 	StateRemoved
+	StatePaused
 
 	propertyCollectorTimeout = 3 * time.Minute
 	containerLogName         = "output.log"
@@ -92,6 +97,15 @@ func (r ConcurrentAccessError) Error() string {
 	return r.err.Error()
 }
 
+// DiskInUseError is returned when the read-write layer of a container is attached to the appliance
+type DiskInUseError struct {
+	id string
+}
+
+func (r DiskInUseError) Error() string {
+	return fmt.Sprintf("the filesystem of container %s is in use by another operation", r.id)
+}
+
 type Container struct {
 	m sync.Mutex
 
@@ -106,6 +120,21 @@ type Container struct {
 
 	logFollowers []io.Closer
 
+	// restartTimer is the pending restart of the container by its restart policy, which waits
+	// restartDelay after the container exited, unless the exit followed a kill on request
+	restartTimer  *time.Timer
+	restartDelay  time.Duration
+	stopRequested bool
+
+	// healthMonitored is set while the health status the tether records for the container is
+	// watched, healthStatus is the last status seen
+	healthMonitored bool
+	healthStatus    string
+
+	// diskInUse is set while the read-write layer is attached to the appliance, which
+	// excludes powering on the container VM
+	diskInUse bool
+
 	// Current state
 	Config  *types.VirtualMachineConfigInfo
 	Runtime *types.VirtualMachineRuntimeInfo
@@ -149,6 +178,10 @@ func (s State) String() string {
 		return "Stopping"
 	case StateStopped:
 		return "Stopped"
+	case StateSuspending:
+		return "Suspending"
+	case StatePaused:
+		return "Paused"
 	case StateUnknown:
 		return "Unknown"
 	}
@@ -162,6 +195,33 @@ func (c *Container) CurrentState() State {
 	return c.state
 }
 
+// AcquireDisk reserves the read-write layer of the powered off container for attaching to the
+// appliance. It fails if the container VM is not powered off or the layer is already reserved,
+// and until the reservation is released with ReleaseDisk the container cannot be started.
+func (c *Container) AcquireDisk() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.state != StateStopped && c.state != StateCreated {
+		return fmt.Errorf("container %s is %s", c.ExecConfig.ID, c.state)
+	}
+
+	if c.diskInUse {
+		return DiskInUseError{id: c.ExecConfig.ID}
+	}
+
+	c.diskInUse = true
+	return nil
+}
+
+// ReleaseDisk releases the reservation of the read-write layer made by AcquireDisk
+func (c *Container) ReleaseDisk() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.diskInUse = false
+}
+
 // SetState changes container state.
 func (c *Container) SetState(s State) State {
 	c.m.Lock()
@@ -208,6 +268,19 @@ func (c *Container) WaitForState(s State) <-chan struct{} {
 	return eventChan
 }
 
+// VMReference returns the reference of the container VM, or nil if the VM has not been created
+func (c *Container) VMReference() *types.ManagedObjectReference {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.vm == nil {
+		return nil
+	}
+
+	ref := c.vm.Reference()
+	return &ref
+}
+
 func (c *Container) NewHandle(ctx context.Context) *Handle {
 	c.m.Lock()
 	defer c.m.Unlock()
@@ -255,6 +328,69 @@ func (c *Container) refresh(ctx context.Context) error {
 	return nil
 }
 
+// DiskSize returns the size on the datastore of the read-write layer of the container, and of
+// its whole root filesystem, which includes the image layers below it
+func (c *Container) DiskSize(ctx context.Context) (rw int64, rootfs int64, err error) {
+	defer trace.End(trace.Begin(c.ExecConfig.ID))
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.vm == nil {
+		return 0, 0, fmt.Errorf("vm not set")
+	}
+
+	var o mo.VirtualMachine
+	if err := c.vm.Properties(ctx, c.vm.Reference(), []string{"layoutEx"}, &o); err != nil {
+		return 0, 0, err
+	}
+
+	return diskSize(o.LayoutEx, c.ExecConfig.ID)
+}
+
+// diskSize finds the disk of the container in the file layout of its VM, by the name of the
+// delta disk at the top of its chain, and adds up the sizes of the files in the chain
+func diskSize(layout *types.VirtualMachineFileLayoutEx, id string) (rw int64, rootfs int64, err error) {
+	if layout == nil {
+		return 0, 0, fmt.Errorf("no file layout for %s", id)
+	}
+
+	files := make(map[int32]types.VirtualMachineFileLayoutExFileInfo, len(layout.File))
+	for _, f := range layout.File {
+		files[f.Key] = f
+	}
+
+	suffix := fmt.Sprintf("/%s.vmdk", id)
+	for _, disk := range layout.Disk {
+		if len(disk.Chain) == 0 {
+			continue
+		}
+
+		top := disk.Chain[len(disk.Chain)-1]
+
+		var found bool
+		rw = 0
+		for _, key := range top.FileKey {
+			rw += files[key].Size
+			found = found || strings.HasSuffix(files[key].Name, suffix)
+		}
+		if !found {
+			continue
+		}
+
+		rootfs = rw
+		for _, unit := range disk.Chain[:len(disk.Chain)-1] {
+			for _, key := range unit.FileKey {
+				rootfs += files[key].Size
+			}
+		}
+
+		return rw, rootfs, nil
+	}
+
+	return 0, 0, fmt.Errorf("no disk found for %s", id)
+}
+
 // Commit executes the requires steps on the handle
 func (c *Container) Commit(ctx context.Context, sess *session.Session, h *Handle, waitTime *int32) error {
 	defer trace.End(trace.Begin(h.ExecConfig.ID))
@@ -329,6 +465,25 @@ func (c *Container) Commit(ctx context.Context, sess *session.Session, h *Handle
 		}
 	}
 
+	// the container VM cannot be powered on while its disk is attached to the appliance
+	if c.diskInUse && h.CurrentState() == StateRunning && c.state != StateRunning && c.state != StatePaused {
+		return DiskInUseError{id: c.ExecConfig.ID}
+	}
+
+	// a paused container is resumed before any other change is made to it
+	if c.state == StatePaused && h.CurrentState() != StatePaused {
+		if err := c.unpause(ctx); err != nil {
+			return err
+		}
+
+		commitEvent = events.ContainerResumed
+
+		// refresh the struct with what property collector provides
+		if err := c.refresh(ctx); err != nil {
+			return err
+		}
+	}
+
 	// if we're stopping the VM, do so before the reconfigure to preserve the extraconfig
 	if h.CurrentState() == StateStopped &&
 		c.Runtime != nil && c.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn {
@@ -348,10 +503,19 @@ func (c *Container) Commit(ctx context.Context, sess *session.Session, h *Handle
 	if h.Spec != nil {
 		s := h.Spec.Spec()
 
-		// nilify ExtraConfig if vm is running
+		// sessions added to a running container have to be launched by the tether, which
+		// also applies any change to the networks when it reloads its configuration
+		var added []string
+		var reload bool
+
+		// the guest owns the read-write keys while the vm is running so only push
+		// the keys it cannot modify
 		if c.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn {
-			log.Errorf("Nilifying ExtraConfig as we are running")
-			s.ExtraConfig = nil
+			log.Debugf("Filtering guest writable ExtraConfig as we are running")
+			s.ExtraConfig = filterGuestWritable(s.ExtraConfig)
+
+			added = addedSessions(c.ExecConfig, &h.ExecConfig)
+			reload = changedNetworks(c.ExecConfig, &h.ExecConfig)
 		}
 
 		// set ChangeVersion. This property is useful because it guards against updates that have happened between when the VM’s config is read and when it is applied.
@@ -376,22 +540,58 @@ func (c *Container) Commit(ctx context.Context, sess *session.Session, h *Handle
 			return err
 		}
 
+		if h.ExecConfig.Name != c.ExecConfig.Name && commitEvent == "" {
+			commitEvent = events.ContainerRenamed
+		}
+
 		c.ExecConfig = &h.ExecConfig
 
 		// refresh the struct with what propery collector provides
 		if err = c.refresh(ctx); err != nil {
 			return err
 		}
+
+		if len(added) > 0 {
+			if err = c.launchSessions(ctx, added); err != nil {
+				return err
+			}
+
+			// pick up the launch status written by the tether
+			if err = c.refresh(ctx); err != nil {
+				return err
+			}
+		} else if reload {
+			if err = c.startGuestProgram(ctx, "reload", ""); err != nil {
+				return fmt.Errorf("unable to trigger tether reload: %s", err)
+			}
+		}
 	}
 
 	if h.CurrentState() == StateRunning &&
 		c.Runtime != nil && c.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOff {
+		c.stopRequested = false
+
 		// start the container
 		if err := h.Container.start(ctx); err != nil {
 			return err
 		}
 
 		commitEvent = events.ContainerStarted
+		c.startHealthMonitor()
+
+		// refresh the struct with what property collector provides
+		if err := c.refresh(ctx); err != nil {
+			return err
+		}
+	}
+
+	if h.CurrentState() == StatePaused && c.state == StateRunning {
+		// pause the container
+		if err := c.pause(ctx); err != nil {
+			return err
+		}
+
+		commitEvent = events.ContainerSuspended
 
 		// refresh the struct with what property collector provides
 		if err := c.refresh(ctx); err != nil {
@@ -445,6 +645,77 @@ func (c *Container) start(ctx context.Context) error {
 	return nil
 }
 
+// launchSessions asks the tether to reload its configuration and waits for the
+// specified sessions to report their launch status
+func (c *Container) launchSessions(ctx context.Context, ids []string) error {
+	defer trace.End(trace.Begin(c.ExecConfig.ID))
+
+	if err := c.startGuestProgram(ctx, "reload", ""); err != nil {
+		return fmt.Errorf("unable to trigger tether reload: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, propertyCollectorTimeout)
+	defer cancel()
+
+	for _, id := range ids {
+		key := fmt.Sprintf("guestinfo.vice..sessions|%s.started", id)
+
+		detail, err := c.vm.WaitForKeyInExtraConfig(ctx, key)
+		if err != nil {
+			return fmt.Errorf("unable to wait for process launch status of %s: %s", id, err)
+		}
+
+		if detail != "true" {
+			return errors.New(detail)
+		}
+	}
+
+	return nil
+}
+
+// addedSessions returns the IDs of the sessions present in the target config but not in the current one
+func addedSessions(current, target *executor.ExecutorConfig) []string {
+	var ids []string
+	for id := range target.Sessions {
+		if _, ok := current.Sessions[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// changedNetworks reports whether the target config connects the container to a different set of networks
+func changedNetworks(current, target *executor.ExecutorConfig) bool {
+	if len(current.Networks) != len(target.Networks) {
+		return true
+	}
+
+	for name := range target.Networks {
+		if _, ok := current.Networks[name]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterGuestWritable drops the read-write guestinfo keys, which are updated by the tether
+// and must not be overwritten with stale values when reconfiguring a running vm
+func filterGuestWritable(options []types.BaseOptionValue) []types.BaseOptionValue {
+	prefix := extraconfig.DefaultGuestInfoPrefix + "."
+
+	var filtered []types.BaseOptionValue
+	for _, o := range options {
+		if strings.HasPrefix(o.GetOptionValue().Key, prefix) {
+			continue
+		}
+		filtered = append(filtered, o)
+	}
+
+	return filtered
+}
+
 func (c *Container) waitForPowerState(ctx context.Context, max time.Duration, state types.VirtualMachinePowerState) (bool, error) {
 	defer trace.End(trace.Begin(c.ExecConfig.ID))
 	timeout, cancel := context.WithTimeout(ctx, max)
@@ -553,6 +824,101 @@ func (c *Container) stop(ctx context.Context, waitTime *int32) error {
 	return nil
 }
 
+// pause freezes the container, either by having the tether stop all of its processes or by
+// suspending the VM, as configured for the VCH
+func (c *Container) pause(ctx context.Context) error {
+	defer trace.End(trace.Begin(c.ExecConfig.ID))
+
+	if c.vm == nil {
+		return fmt.Errorf("vm not set")
+	}
+
+	// get existing state and set to suspending
+	// if there's a failure we'll revert to existing
+	existingState := c.updateState(StateSuspending)
+
+	var err error
+	if Config.PauseMode == config.PauseSuspend {
+		_, err = tasks.WaitForResult(ctx, func(ctx context.Context) (tasks.Task, error) {
+			return c.vm.Suspend(ctx)
+		})
+	} else {
+		err = c.startGuestProgram(ctx, "pause", "")
+
+		// a VM paused by signal is powered on as a running one is, so the pause is recorded
+		// to be recovered when the port layer restarts
+		if err == nil {
+			if err = c.recordPaused(ctx, true); err != nil {
+				if cerr := c.startGuestProgram(ctx, "unpause", ""); cerr != nil {
+					log.Errorf("unable to continue processes of %s: %s", c.ExecConfig.ID, cerr)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		c.updateState(existingState)
+		return fmt.Errorf("unable to pause %s: %s", c.ExecConfig.ID, err)
+	}
+
+	c.updateState(StatePaused)
+	return nil
+}
+
+// unpause resumes a paused container. The VM power state decides how, so that VMs suspended
+// outside of a pause can be resumed as well.
+func (c *Container) unpause(ctx context.Context) error {
+	defer trace.End(trace.Begin(c.ExecConfig.ID))
+
+	if c.vm == nil {
+		return fmt.Errorf("vm not set")
+	}
+
+	// resuming a suspended VM powers it on, so use the starting state to ignore that event
+	existingState := c.updateState(StateStarting)
+
+	var err error
+	if c.Runtime != nil && c.Runtime.PowerState == types.VirtualMachinePowerStateSuspended {
+		_, err = tasks.WaitForResult(ctx, func(ctx context.Context) (tasks.Task, error) {
+			return c.vm.PowerOn(ctx)
+		})
+	} else {
+		err = c.startGuestProgram(ctx, "unpause", "")
+
+		// the container is running regardless so a stale record is only reported
+		if err == nil {
+			if rerr := c.recordPaused(ctx, false); rerr != nil {
+				log.Errorf("unable to clear paused state of %s: %s", c.ExecConfig.ID, rerr)
+			}
+		}
+	}
+
+	if err != nil {
+		c.updateState(existingState)
+		return fmt.Errorf("unable to unpause %s: %s", c.ExecConfig.ID, err)
+	}
+
+	c.updateState(StateRunning)
+	return nil
+}
+
+// recordPaused sets or clears the paused key of the VM
+func (c *Container) recordPaused(ctx context.Context, paused bool) error {
+	value := ""
+	if paused {
+		value = "true"
+	}
+
+	s := types.VirtualMachineConfigSpec{
+		ExtraConfig: []types.BaseOptionValue{&types.OptionValue{Key: pausedKey, Value: value}},
+	}
+
+	_, err := tasks.WaitForResult(ctx, func(ctx context.Context) (tasks.Task, error) {
+		return c.vm.Reconfigure(ctx, s)
+	})
+	return err
+}
+
 func (c *Container) startGuestProgram(ctx context.Context, name string, args string) error {
 	defer trace.End(trace.Begin(c.ExecConfig.ID))
 	o := guest.NewOperationsManager(c.vm.Client.Client, c.vm.Reference())
@@ -582,6 +948,13 @@ func (c *Container) Signal(ctx context.Context, num int64) error {
 		return fmt.Errorf("vm not set")
 	}
 
+	// a container that is killed is not restarted by its restart policy
+	if num == int64(syscall.SIGKILL) {
+		c.m.Lock()
+		c.stopRequested = true
+		c.m.Unlock()
+	}
+
 	return c.startGuestProgram(ctx, "kill", fmt.Sprintf("%d", num))
 }
 
@@ -595,7 +968,11 @@ func (c *Container) onStop() {
 	}
 }
 
-func (c *Container) LogReader(ctx context.Context, tail int, follow bool) (io.ReadCloser, error) {
+// LogReader returns the entries of the container log that match the options, as a docker
+// multiplexed stream. If follow is set and the container is running, entries written after
+// the log was opened are passed on until the container stops. A log written by a tether
+// that predates the framed format is returned as standard output.
+func (c *Container) LogReader(ctx context.Context, follow bool, opts iolog.Options) (io.ReadCloser, error) {
 	defer trace.End(trace.Begin(c.ExecConfig.ID))
 	c.m.Lock()
 	defer c.m.Unlock()
@@ -618,22 +995,22 @@ func (c *Container) LogReader(ctx context.Context, tail int, follow bool) (io.Re
 		return nil, err
 	}
 
-	if tail >= 0 {
-		err = file.Tail(tail)
+	if follow && c.state == StateRunning {
+		// the tail is taken from the log as it is now, anything written later is followed
+		info, err := file.Stat()
 		if err != nil {
+			file.Close()
 			return nil, err
 		}
-	}
 
-	if follow && c.state == StateRunning {
 		follower := file.Follow(time.Second)
 
 		c.logFollowers = append(c.logFollowers, follower)
 
-		return follower, nil
+		return iolog.NewReader(follower, info.Size(), opts), nil
 	}
 
-	return file, nil
+	return iolog.NewReader(file, -1, opts), nil
 }
 
 // Remove removes a containerVM after detaching the disks
@@ -650,6 +1027,12 @@ func (c *Container) Remove(ctx context.Context, sess *session.Session) error {
 	if c.state == StateRunning {
 		return RemovePowerError{fmt.Errorf("Container is powered on")}
 	}
+	if c.state == StatePaused {
+		return RemovePowerError{fmt.Errorf("Container is paused")}
+	}
+
+	// a container that is removed is not restarted
+	c.cancelRestart()
 
 	// get existing state and set to removing
 	// if there's a failure we'll revert to existing
@@ -786,6 +1169,17 @@ func populateVMAttributes(ctx context.Context, sess *session.Session, refs []typ
 }
 
 // convert the infra containers to a container object
+// pausedBySignal returns whether the extraconfig records that the container was paused by
+// stopping its processes
+func pausedBySignal(config []types.BaseOptionValue) bool {
+	for _, o := range config {
+		if ov := o.GetOptionValue(); ov.Key == pausedKey {
+			return ov.Value == "true"
+		}
+	}
+	return false
+}
+
 func convertInfraContainers(ctx context.Context, sess *session.Session, vms []mo.VirtualMachine) []*Container {
 	defer trace.End(trace.Begin(fmt.Sprintf("converting %d containers", len(vms))))
 	var cons []*Container
@@ -807,6 +1201,11 @@ func convertInfraContainers(ctx context.Context, sess *session.Session, vms []mo
 		switch v.Runtime.PowerState {
 		case types.VirtualMachinePowerStatePoweredOn:
 			c.state = StateRunning
+
+			// containers paused by signal look like running ones unless the pause was recorded
+			if pausedBySignal(v.Config.ExtraConfig) {
+				c.state = StatePaused
+			}
 		case types.VirtualMachinePowerStatePoweredOff:
 			// check if any of the sessions was started
 			for _, s := range c.ExecConfig.Sessions {
@@ -816,8 +1215,8 @@ func convertInfraContainers(ctx context.Context, sess *session.Session, vms []mo
 				}
 			}
 		case types.VirtualMachinePowerStateSuspended:
-			log.Warnf("skipping converting container VM %s: invalid power state %s", v.Reference(), v.Runtime.PowerState)
-			continue
+			// suspended VMs are paused
+			c.state = StatePaused
 		}
 
 		if v.Summary.Storage != nil {
@@ -18,6 +18,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/pkg/vsphere/extraconfig"
+	"github.com/vmware/vic/pkg/vsphere/extraconfig/vmomi"
 )
 
 func TestStateStringer(t *testing.T) {
@@ -35,4 +40,198 @@ func TestStateStringer(t *testing.T) {
 	assert.Equal(t, "Starting", c.state.String())
 	c.state = StateCreated
 	assert.Equal(t, "Created", c.state.String())
+	c.state = StatePaused
+	assert.Equal(t, "Paused", c.state.String())
+}
+
+func TestAddedSessions(t *testing.T) {
+	current := &executor.ExecutorConfig{
+		Sessions: map[string]*executor.SessionConfig{
+			"primary": {},
+		},
+	}
+	target := &executor.ExecutorConfig{
+		Sessions: map[string]*executor.SessionConfig{
+			"primary": {},
+			"exec":    {},
+		},
+	}
+
+	assert.Equal(t, []string{"exec"}, addedSessions(current, target))
+	assert.Empty(t, addedSessions(target, target))
+}
+
+func TestChangedNetworks(t *testing.T) {
+	current := &executor.ExecutorConfig{
+		Networks: map[string]*executor.NetworkEndpoint{
+			"bridge": {},
+		},
+	}
+	target := &executor.ExecutorConfig{
+		Networks: map[string]*executor.NetworkEndpoint{
+			"other": {},
+		},
+	}
+
+	assert.True(t, changedNetworks(current, target))
+	assert.True(t, changedNetworks(current, &executor.ExecutorConfig{}))
+	assert.False(t, changedNetworks(current, current))
+}
+
+func TestNetworksKey(t *testing.T) {
+	cfg := &executor.ExecutorConfig{
+		Networks: map[string]*executor.NetworkEndpoint{
+			"bridge": {},
+		},
+	}
+
+	m := make(map[string]string)
+	extraconfig.Encode(extraconfig.MapSink(m), cfg)
+
+	// the key that is cleared when the container leaves its last network
+	assert.Equal(t, "bridge", m[networksKey])
+}
+
+func TestFilterGuestWritable(t *testing.T) {
+	cfg := &executor.ExecutorConfig{
+		Sessions: map[string]*executor.SessionConfig{
+			"exec": {
+				Cmd:     executor.Cmd{Path: "/bin/sh"},
+				Started: "true",
+			},
+		},
+	}
+	cfg.ID = "primary"
+
+	m := make(map[string]string)
+	extraconfig.Encode(extraconfig.MapSink(m), cfg)
+
+	filtered := filterGuestWritable(vmomi.OptionValueFromMap(m))
+	assert.NotEmpty(t, filtered)
+
+	keys := make(map[string]bool)
+	for _, o := range filtered {
+		keys[o.GetOptionValue().Key] = true
+	}
+
+	assert.False(t, keys["guestinfo.vice..sessions|exec.started"], "read-write key should be filtered")
+	assert.True(t, keys["guestinfo.vice./sessions|exec/cmd/Path"], "read-only key should be retained")
+
+	var empty []types.BaseOptionValue
+	assert.Empty(t, filterGuestWritable(empty))
+}
+
+func TestUpdateResources(t *testing.T) {
+	hotAdd := true
+	c := &Container{
+		ExecConfig: &executor.ExecutorConfig{},
+		Config: &types.VirtualMachineConfigInfo{
+			Hardware:         types.VirtualHardware{NumCPU: 2, MemoryMB: 2048},
+			CpuHotAddEnabled: &hotAdd,
+		},
+		Runtime: &types.VirtualMachineRuntimeInfo{PowerState: types.VirtualMachinePowerStatePoweredOn},
+	}
+
+	// a running container VM takes more CPUs when hot-add is enabled but its memory changes on restart
+	h := newHandle(c, StateRunning)
+	warnings := h.UpdateResources(Resources{CPUShares: 512, NumCPUs: 4, MemoryMB: 4096, MemoryReservation: 1024})
+	assert.Len(t, warnings, 1)
+
+	s := h.Spec.Spec()
+	assert.Equal(t, int32(4), s.NumCPUs)
+	assert.Equal(t, int64(0), s.MemoryMB)
+	assert.Equal(t, int32(512), s.CpuAllocation.GetResourceAllocationInfo().Shares.Shares)
+	assert.Equal(t, int64(1024), s.MemoryAllocation.GetResourceAllocationInfo().Reservation)
+	assert.Equal(t, executor.Hardware{MemoryMB: 4096}, h.ExecConfig.PendingHardware)
+
+	// CPUs cannot be removed from it either
+	h = newHandle(c, StateRunning)
+	assert.Len(t, h.UpdateResources(Resources{NumCPUs: 1}), 1)
+	assert.Equal(t, int32(1), h.ExecConfig.PendingHardware.NumCPUs)
+
+	h.applyPendingHardware()
+	assert.Equal(t, int32(1), h.Spec.Spec().NumCPUs)
+	assert.Equal(t, executor.Hardware{}, h.ExecConfig.PendingHardware)
+
+	// while all changes are made to a stopped one
+	c.Runtime.PowerState = types.VirtualMachinePowerStatePoweredOff
+	h = newHandle(c, StateStopped)
+	assert.Empty(t, h.UpdateResources(Resources{NumCPUs: 1, MemoryMB: 512}))
+	assert.Equal(t, int32(1), h.Spec.Spec().NumCPUs)
+	assert.Equal(t, int64(512), h.Spec.Spec().MemoryMB)
+}
+
+func TestDiskSize(t *testing.T) {
+	id := "0123456789abcdef"
+
+	layout := &types.VirtualMachineFileLayoutEx{
+		File: []types.VirtualMachineFileLayoutExFileInfo{
+			{Key: 1, Name: "[ds] vm/vm.vmx", Size: 1000},
+			{Key: 2, Name: "[ds] images/base/base.vmdk", Size: 1},
+			{Key: 3, Name: "[ds] images/base/base-flat.vmdk", Size: 300},
+			{Key: 4, Name: "[ds] images/app/app.vmdk", Size: 1},
+			{Key: 5, Name: "[ds] images/app/app-delta.vmdk", Size: 200},
+			{Key: 6, Name: "[ds] vm/" + id + ".vmdk", Size: 1},
+			{Key: 7, Name: "[ds] vm/" + id + "-delta.vmdk", Size: 50},
+			{Key: 8, Name: "[ds] volumes/data/data.vmdk", Size: 1},
+			{Key: 9, Name: "[ds] volumes/data/data-flat.vmdk", Size: 5000},
+		},
+		Disk: []types.VirtualMachineFileLayoutExDiskLayout{
+			{Key: 2000, Chain: []types.VirtualMachineFileLayoutExDiskUnit{{FileKey: []int32{8, 9}}}},
+			{Key: 2001, Chain: []types.VirtualMachineFileLayoutExDiskUnit{
+				{FileKey: []int32{2, 3}},
+				{FileKey: []int32{4, 5}},
+				{FileKey: []int32{6, 7}},
+			}},
+		},
+	}
+
+	rw, rootfs, err := diskSize(layout, id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(51), rw)
+	assert.Equal(t, int64(553), rootfs)
+
+	_, _, err = diskSize(layout, "fedcba9876543210")
+	assert.Error(t, err)
+
+	_, _, err = diskSize(nil, id)
+	assert.Error(t, err)
+}
+
+func TestAcquireDisk(t *testing.T) {
+	c := &Container{
+		ExecConfig: &executor.ExecutorConfig{},
+		state:      StateRunning,
+	}
+
+	// the disk of a powered on container is in use by its VM
+	assert.Error(t, c.AcquireDisk())
+	c.state = StatePaused
+	assert.Error(t, c.AcquireDisk())
+
+	c.state = StateStopped
+	assert.NoError(t, c.AcquireDisk())
+
+	// the disk is only attached to the appliance once at a time
+	err := c.AcquireDisk()
+	if assert.Error(t, err) {
+		assert.IsType(t, DiskInUseError{}, err)
+	}
+
+	c.ReleaseDisk()
+	assert.NoError(t, c.AcquireDisk())
+}
+
+func TestPausedBySignal(t *testing.T) {
+	config := []types.BaseOptionValue{
+		&types.OptionValue{Key: networksKey, Value: ""},
+	}
+	assert.False(t, pausedBySignal(config))
+
+	config = append(config, &types.OptionValue{Key: pausedKey, Value: "true"})
+	assert.True(t, pausedBySignal(config))
+
+	// a cleared record is not a pause
+	config[1] = &types.OptionValue{Key: pausedKey, Value: ""}
+	assert.False(t, pausedBySignal(config))
 }
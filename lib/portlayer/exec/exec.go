@@ -104,6 +104,19 @@ func Init(ctx context.Context, sess *session.Session, source extraconfig.DataSou
 		if err = Containers.sync(ctx, sess); err != nil {
 			return
 		}
+
+		// restart the containers that were stopped while the VCH was down, if their
+		// restart policy calls for it
+		stopped := StateStopped
+		for _, c := range Containers.Containers(&stopped) {
+			c.applyRestartPolicyOnBoot()
+		}
+
+		// pick up the health of the containers that were running while the VCH was down
+		running := StateRunning
+		for _, c := range Containers.Containers(&running) {
+			c.monitorHealth()
+		}
 	})
 
 	return err
@@ -122,7 +135,7 @@ func eventCallback(ie events.Event) {
 			case StateStopping,
 				StateRunning,
 				StateStopped,
-				StateSuspended:
+				StatePaused:
 
 				log.Debugf("Container(%s) state set to %s via event activity",
 					container.ExecConfig.ID, newState.String())
@@ -144,6 +157,15 @@ func eventCallback(ie events.Event) {
 					}
 					// regardless of update success failure publish the container event
 					publishContainerEvent(container.ExecConfig.ID, ie.Created(), ie.String())
+
+					// the container stopped without being asked to, so its restart policy applies
+					if newState == StateStopped {
+						container.applyRestartPolicy()
+					}
+
+					if newState == StateRunning {
+						container.monitorHealth()
+					}
 				}()
 			case StateRemoved:
 				log.Debugf("Container(%s) %s via event activity", container.ExecConfig.ID, newState.String())
@@ -172,9 +194,9 @@ func eventedState(e string, current State) State {
 			return StateStopped
 		}
 	case events.ContainerSuspended:
-		// are we in the process of suspending
+		// are we in the process of suspending - a suspended container is paused
 		if current != StateSuspending {
-			return StateSuspended
+			return StatePaused
 		}
 	case events.ContainerRemoved:
 		if current != StateRemoving {
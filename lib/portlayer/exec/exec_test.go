@@ -33,6 +33,7 @@ func TestEventedState(t *testing.T) {
 	assert.EqualValues(t, StateRunning, eventedState(event, StateRunning))
 	assert.EqualValues(t, StateRunning, eventedState(event, StateStopped))
 	assert.EqualValues(t, StateRunning, eventedState(event, StateSuspended))
+	assert.EqualValues(t, StateRunning, eventedState(event, StatePaused))
 
 	// powerOff event
 	event = events.ContainerPoweredOff
@@ -43,8 +44,8 @@ func TestEventedState(t *testing.T) {
 	// suspended event
 	event = events.ContainerSuspended
 	assert.EqualValues(t, StateSuspending, eventedState(event, StateSuspending))
-	assert.EqualValues(t, StateSuspended, eventedState(event, StateSuspended))
-	assert.EqualValues(t, StateSuspended, eventedState(event, StateRunning))
+	assert.EqualValues(t, StatePaused, eventedState(event, StatePaused))
+	assert.EqualValues(t, StatePaused, eventedState(event, StateRunning))
 
 	// removed event
 	event = events.ContainerRemoved
@@ -0,0 +1,141 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ContainerFilter selects containers from the cache. Each of the lists that is not empty has
+// to be matched by a container, which matches a list if it matches any of its values.
+type ContainerFilter struct {
+	// All includes the containers that are not running, otherwise only the running and
+	// paused containers are included
+	All bool
+
+	// IDs are prefixes of the container IDs
+	IDs []string
+	// Names are matched against the container names
+	Names []*regexp.Regexp
+	// States are the names of the container states, as given by State.String
+	States []string
+	// ExitCodes are the exit statuses of the primary sessions of stopped containers
+	ExitCodes []int
+	// LayerIDs are the IDs of the image layers backing the containers
+	LayerIDs []string
+	// Networks are the names or IDs of the networks the containers are connected to
+	Networks []string
+	// Volumes are the names of the volumes mounted in the containers, or the paths they
+	// are mounted at
+	Volumes []string
+
+	// Since and Before include only the containers created after and before them
+	Since  *Container
+	Before *Container
+
+	// Limit includes only that many of the most recently created containers, if positive
+	Limit int
+}
+
+// Filter returns the containers in the cache that match the filter, most recently created first
+func (conCache *containerCache) Filter(f *ContainerFilter) []*Container {
+	var containers []*Container
+	for _, c := range conCache.Containers(nil) {
+		if f.Match(c) {
+			containers = append(containers, c)
+		}
+	}
+
+	sort.Sort(sort.Reverse(containersByCreated(containers)))
+
+	if f.Limit > 0 && len(containers) > f.Limit {
+		containers = containers[:f.Limit]
+	}
+
+	return containers
+}
+
+// Match reports whether the container matches the filter, the limit aside
+func (f *ContainerFilter) Match(c *Container) bool {
+	state := c.CurrentState()
+	if !f.All && state != StateRunning && state != StatePaused {
+		return false
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	config := c.ExecConfig
+
+	if f.Since != nil && config.CreateTime <= f.Since.ExecConfig.CreateTime {
+		return false
+	}
+	if f.Before != nil && config.CreateTime >= f.Before.ExecConfig.CreateTime {
+		return false
+	}
+
+	return matchAny(len(f.IDs), func(i int) bool {
+		return strings.HasPrefix(config.ID, f.IDs[i])
+	}) && matchAny(len(f.Names), func(i int) bool {
+		return f.Names[i].MatchString(config.Name)
+	}) && matchAny(len(f.States), func(i int) bool {
+		return f.States[i] == state.String()
+	}) && matchAny(len(f.ExitCodes), func(i int) bool {
+		session, ok := config.Sessions[config.ID]
+		return ok && state == StateStopped && session.ExitStatus == f.ExitCodes[i]
+	}) && matchAny(len(f.LayerIDs), func(i int) bool {
+		return f.LayerIDs[i] == config.LayerID
+	}) && matchAny(len(f.Networks), func(i int) bool {
+		for name, endpoint := range config.Networks {
+			if f.Networks[i] == name || f.Networks[i] == endpoint.Network.ID {
+				return true
+			}
+		}
+		return false
+	}) && matchAny(len(f.Volumes), func(i int) bool {
+		for name, mount := range config.Mounts {
+			if f.Volumes[i] == name || f.Volumes[i] == mount.Path {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// matchAny reports whether any of the n values of a filter matches, which they trivially do
+// if there are none
+func matchAny(n int, match func(i int) bool) bool {
+	if n == 0 {
+		return true
+	}
+
+	for i := 0; i < n; i++ {
+		if match(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// containersByCreated sorts containers by their creation time
+type containersByCreated []*Container
+
+func (r containersByCreated) Len() int      { return len(r) }
+func (r containersByCreated) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r containersByCreated) Less(i, j int) bool {
+	return r[i].ExecConfig.CreateTime < r[j].ExecConfig.CreateTime
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/pkg/uid"
+)
+
+func newFilterContainer(name string, state State, created int64, exit int) *Container {
+	id := uid.New().String()
+
+	c := &Container{
+		state: state,
+		ExecConfig: &executor.ExecutorConfig{
+			Common:     executor.Common{ID: id, Name: name},
+			CreateTime: created,
+			LayerID:    "layer-" + name,
+			Sessions: map[string]*executor.SessionConfig{
+				id: {ExitStatus: exit},
+			},
+			Networks: map[string]*executor.NetworkEndpoint{
+				"bridge": {Network: executor.ContainerNetwork{Common: executor.Common{ID: "bridge-id"}}},
+			},
+			Mounts: map[string]executor.MountSpec{
+				"data-" + name: {Path: "/data"},
+			},
+		},
+	}
+
+	addTestVM(c)
+	Containers.Put(c)
+	return c
+}
+
+func TestContainerFilter(t *testing.T) {
+	NewContainerCache()
+
+	web := newFilterContainer("web", StateRunning, 1, 0)
+	db := newFilterContainer("db", StateStopped, 2, 137)
+	cache := newFilterContainer("cache", StatePaused, 3, 0)
+	newFilterContainer("job", StateStopped, 4, 0)
+
+	ids := func(f *ContainerFilter) []string {
+		var ids []string
+		for _, c := range Containers.Filter(f) {
+			ids = append(ids, c.ExecConfig.Name)
+		}
+		return ids
+	}
+
+	// running and paused containers only, unless all are asked for, the newest first
+	assert.Equal(t, []string{"cache", "web"}, ids(&ContainerFilter{}))
+	assert.Equal(t, []string{"job", "cache", "db", "web"}, ids(&ContainerFilter{All: true}))
+	assert.Equal(t, []string{"job", "cache"}, ids(&ContainerFilter{All: true, Limit: 2}))
+
+	assert.Equal(t, []string{"db"}, ids(&ContainerFilter{All: true, IDs: []string{db.ExecConfig.ID[:12]}}))
+	assert.Equal(t, []string{"db", "web"}, ids(&ContainerFilter{All: true, Names: []*regexp.Regexp{regexp.MustCompile("^(web|db)$")}}))
+	assert.Equal(t, []string{"job", "db"}, ids(&ContainerFilter{All: true, States: []string{"Stopped"}}))
+	assert.Equal(t, []string{"db"}, ids(&ContainerFilter{All: true, ExitCodes: []int{137}}))
+	assert.Equal(t, []string{"job"}, ids(&ContainerFilter{All: true, ExitCodes: []int{0}}), "only stopped containers have exited")
+	assert.Equal(t, []string{"web"}, ids(&ContainerFilter{All: true, LayerIDs: []string{"layer-web"}}))
+	assert.Equal(t, 4, len(ids(&ContainerFilter{All: true, Networks: []string{"bridge"}})))
+	assert.Equal(t, 4, len(ids(&ContainerFilter{All: true, Networks: []string{"bridge-id"}})))
+	assert.Empty(t, ids(&ContainerFilter{All: true, Networks: []string{"other"}}))
+	assert.Equal(t, []string{"cache"}, ids(&ContainerFilter{All: true, Volumes: []string{"data-cache"}}))
+	assert.Equal(t, 4, len(ids(&ContainerFilter{All: true, Volumes: []string{"/data"}})))
+
+	assert.Equal(t, []string{"job", "cache"}, ids(&ContainerFilter{All: true, Since: db}))
+	assert.Equal(t, []string{"db", "web"}, ids(&ContainerFilter{All: true, Before: cache}))
+	assert.Equal(t, []string{"db"}, ids(&ContainerFilter{All: true, Since: web, Before: cache}))
+
+	// every filter has to match
+	assert.Empty(t, ids(&ContainerFilter{All: true, States: []string{"Running"}, ExitCodes: []int{137}}))
+	assert.Equal(t, []string{"job"}, ids(&ContainerFilter{All: true, States: []string{"Stopped"}, Since: cache}))
+}
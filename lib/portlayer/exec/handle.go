@@ -53,6 +53,12 @@ var handlesLock sync.Mutex
 const (
 	handleLen = 16
 	lruSize   = 1000
+
+	// networksKey is the extraconfig key holding the list of networks of the container
+	networksKey = "guestinfo.vice./networks"
+
+	// pausedKey is the extraconfig key recording that the container is paused by signal
+	pausedKey = "guestinfo.vice./paused"
 )
 
 func init() {
@@ -70,6 +76,13 @@ type Handle struct {
 
 	key       string
 	committed bool
+
+	// launchHold is how long the launch of the primary process is held waiting for an
+	// attach when the handle starts the container
+	launchHold time.Duration
+
+	// onCommit are called once the handle has been committed
+	onCommit []func()
 }
 
 func newHandleKey() string {
@@ -172,6 +185,145 @@ func (h *Handle) SetSpec(s *spec.VirtualMachineConfigSpec) error {
 	return nil
 }
 
+// Rename sets the name of the container and of its primary session, and the name of the VM
+// which follows it, to be applied when the handle is committed
+func (h *Handle) Rename(name string) *Handle {
+	defer trace.End(trace.Begin(name))
+
+	h.SetSpec(nil)
+	h.Spec.Spec().Name = spec.VMName(name, h.ExecConfig.ID)
+
+	h.ExecConfig.Name = name
+	if primary := h.primarySession(); primary != nil {
+		primary.Name = name
+	}
+
+	return h
+}
+
+// HoldLaunch holds the launch of the primary process for up to timeout waiting for an attach
+// to complete when the handle starts the container
+func (h *Handle) HoldLaunch(timeout time.Duration) *Handle {
+	defer trace.End(trace.Begin(timeout.String()))
+
+	h.launchHold = timeout
+	return h
+}
+
+// primarySession returns a copy of the primary session that is private to the handle. The
+// handle shares the Sessions map with the container so the map is copied before the session,
+// otherwise a change would be visible before commit.
+func (h *Handle) primarySession() *executor.SessionConfig {
+	s, ok := h.ExecConfig.Sessions[h.ExecConfig.ID]
+	if !ok {
+		return nil
+	}
+
+	sessions := make(map[string]*executor.SessionConfig, len(h.ExecConfig.Sessions))
+	for id, s := range h.ExecConfig.Sessions {
+		sessions[id] = s
+	}
+
+	primary := *s
+	sessions[h.ExecConfig.ID] = &primary
+	h.ExecConfig.Sessions = sessions
+
+	return &primary
+}
+
+// OnCommit registers f to be called once the handle has been committed successfully, for
+// changes outside of the container that must not be made if the commit fails
+func (h *Handle) OnCommit(f func()) {
+	h.onCommit = append(h.onCommit, f)
+}
+
+// Resources are the CPU and memory settings of a container VM, zero values leave the
+// corresponding setting unchanged
+type Resources struct {
+	CPUShares int32
+	NumCPUs   int32
+
+	MemoryMB          int64
+	MemoryReservation int64
+}
+
+// UpdateResources sets the CPU and memory of the container VM, to be applied when the handle
+// is committed. Shares and reservations are changed on a running container VM as are increases
+// of hot-add enabled hardware, while other hardware changes are deferred until the container VM
+// is next powered off. A warning is returned for each deferred change.
+func (h *Handle) UpdateResources(r Resources) []string {
+	defer trace.End(trace.Begin(h.ExecConfig.ID))
+
+	h.SetSpec(nil)
+	s := h.Spec.Spec()
+
+	if r.CPUShares != 0 {
+		s.CpuAllocation = &types.ResourceAllocationInfo{
+			Shares: &types.SharesInfo{
+				Level:  types.SharesLevelCustom,
+				Shares: r.CPUShares,
+			},
+		}
+	}
+
+	if r.MemoryReservation != 0 {
+		s.MemoryAllocation = &types.ResourceAllocationInfo{
+			Reservation: r.MemoryReservation,
+		}
+	}
+
+	c := h.Container
+	running := c.Runtime != nil && c.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOff
+
+	var current types.VirtualHardware
+	var cpuHotAdd, memoryHotAdd bool
+	if c.Config != nil {
+		current = c.Config.Hardware
+		cpuHotAdd = c.Config.CpuHotAddEnabled != nil && *c.Config.CpuHotAddEnabled
+		memoryHotAdd = c.Config.MemoryHotAddEnabled != nil && *c.Config.MemoryHotAddEnabled
+	}
+
+	var warnings []string
+
+	if r.NumCPUs != 0 && r.NumCPUs != current.NumCPU {
+		if !running || (cpuHotAdd && r.NumCPUs > current.NumCPU) {
+			s.NumCPUs = r.NumCPUs
+			h.ExecConfig.PendingHardware.NumCPUs = 0
+		} else {
+			h.ExecConfig.PendingHardware.NumCPUs = r.NumCPUs
+			warnings = append(warnings, fmt.Sprintf("The CPU count will be changed to %d when the container is restarted", r.NumCPUs))
+		}
+	}
+
+	if r.MemoryMB != 0 && r.MemoryMB != int64(current.MemoryMB) {
+		if !running || (memoryHotAdd && r.MemoryMB > int64(current.MemoryMB)) {
+			s.MemoryMB = r.MemoryMB
+			h.ExecConfig.PendingHardware.MemoryMB = 0
+		} else {
+			h.ExecConfig.PendingHardware.MemoryMB = r.MemoryMB
+			warnings = append(warnings, fmt.Sprintf("The memory limit will be changed to %dMB when the container is restarted", r.MemoryMB))
+		}
+	}
+
+	return warnings
+}
+
+// applyPendingHardware adds the hardware changes deferred while the container VM was running
+// to the spec
+func (h *Handle) applyPendingHardware() {
+	pending := h.ExecConfig.PendingHardware
+	s := h.Spec.Spec()
+
+	if pending.NumCPUs != 0 {
+		s.NumCPUs = pending.NumCPUs
+	}
+	if pending.MemoryMB != 0 {
+		s.MemoryMB = pending.MemoryMB
+	}
+
+	h.ExecConfig.PendingHardware = executor.Hardware{}
+}
+
 func (h *Handle) String() string {
 	return h.key
 }
@@ -188,16 +340,57 @@ func (h *Handle) Commit(ctx context.Context, sess *session.Session, waitTime *in
 	// Set timestamps based on target state
 	switch h.CurrentState() {
 	case StateRunning:
+		// the start time only changes when the container is started, not when a running
+		// container is changed or a paused one resumed
+		if state := h.Container.CurrentState(); state == StateRunning || state == StatePaused {
+			break
+		}
+
+		// exec sessions do not survive a restart of the container
+		h.pruneSessions()
+
 		se := h.ExecConfig.Sessions[h.ExecConfig.ID]
 		se.StartTime = time.Now().UTC().Unix()
 		h.ExecConfig.Sessions[h.ExecConfig.ID] = se
+		h.ExecConfig.StoppedByRequest = false
+
+		// a container that was powered off while paused by signal is no longer paused
+		cfg[pausedKey] = ""
+
+		// the launch is only held for this start, a later start without an attach pending,
+		// such as by the restart policy, must not wait
+		if primary := h.primarySession(); primary != nil {
+			primary.AttachTimeout = h.launchHold
+		}
 	case StateStopped:
+		// nor does the stop time change when a stopped container is changed
+		if h.Container.CurrentState() == StateStopped {
+			break
+		}
+
 		se := h.ExecConfig.Sessions[h.ExecConfig.ID]
 		se.StopTime = time.Now().UTC().Unix()
 		h.ExecConfig.Sessions[h.ExecConfig.ID] = se
+
+		// a container stopped through a handle is stopped on request, which the restart
+		// policy has to respect
+		h.ExecConfig.StoppedByRequest = true
+	}
+
+	// the container VM is powered off when the spec is applied if it is stopped or
+	// being stopped, so deferred hardware changes can be made
+	if h.CurrentState() == StateStopped || h.Container.CurrentState() == StateStopped || h.Container.CurrentState() == StateCreated {
+		h.applyPendingHardware()
 	}
 
 	extraconfig.Encode(extraconfig.MapSink(cfg), h.ExecConfig)
+
+	// an empty map is not encoded so the list of networks has to be cleared explicitly
+	// once the container has been disconnected from the last of them
+	if len(h.ExecConfig.Networks) == 0 && h.Container.ExecConfig != nil && len(h.Container.ExecConfig.Networks) > 0 {
+		cfg[networksKey] = ""
+	}
+
 	s := h.Spec.Spec()
 	s.ExtraConfig = append(s.ExtraConfig, vmomi.OptionValueFromMap(cfg)...)
 
@@ -207,9 +400,25 @@ func (h *Handle) Commit(ctx context.Context, sess *session.Session, waitTime *in
 
 	h.committed = true
 	removeHandle(h.key)
+
+	for _, f := range h.onCommit {
+		f()
+	}
 	return nil
 }
 
+// pruneSessions removes all but the primary session from the handle
+func (h *Handle) pruneSessions() {
+	primary, ok := h.ExecConfig.Sessions[h.ExecConfig.ID]
+	if !ok || len(h.ExecConfig.Sessions) == 1 {
+		return
+	}
+
+	h.ExecConfig.Sessions = map[string]*executor.SessionConfig{
+		h.ExecConfig.ID: primary,
+	}
+}
+
 func (h *Handle) Close() {
 	removeHandle(h.key)
 }
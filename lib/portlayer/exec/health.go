@@ -0,0 +1,134 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/lib/portlayer/event/events"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// the interval at which the health of a container is read when its health check does not
+// specify one, which is the docker default probe interval
+const defaultHealthInterval = 30 * time.Second
+
+// healthEvent returns the container event published when the health status of a container
+// changes to status, if any
+func healthEvent(status string) string {
+	switch status {
+	case executor.Healthy:
+		return events.ContainerHealthy
+	case executor.Unhealthy:
+		return events.ContainerUnhealthy
+	}
+
+	return ""
+}
+
+// hasHealthcheck reports whether the primary session of the executor is probed for health
+func hasHealthcheck(config *executor.ExecutorConfig) bool {
+	session := config.Sessions[config.ID]
+	if session == nil {
+		return false
+	}
+
+	test := session.Healthcheck.Test
+	return len(test) > 0 && test[0] != "NONE"
+}
+
+// monitorHealth starts watching the health status the tether records for a running container,
+// publishing an event when it changes, if the container has a health check
+func (c *Container) monitorHealth() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.startHealthMonitor()
+}
+
+// startHealthMonitor starts watching the health status of the container unless it is already
+// watched. The caller must hold the container lock.
+func (c *Container) startHealthMonitor() {
+	if c.healthMonitored || !hasHealthcheck(c.ExecConfig) {
+		return
+	}
+
+	interval := c.ExecConfig.Sessions[c.ExecConfig.ID].Healthcheck.Interval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+
+	c.healthMonitored = true
+	go c.watchHealth(interval)
+}
+
+// watchHealth reads the health status of the container on the probe interval until the
+// container stops
+func (c *Container) watchHealth(interval time.Duration) {
+	defer trace.End(trace.Begin(c.ExecConfig.ID))
+
+	for {
+		time.Sleep(interval)
+
+		event, ok := c.updateHealth()
+		if !ok {
+			return
+		}
+
+		if event != "" {
+			publishContainerEvent(c.ExecConfig.ID, time.Now().UTC(), event)
+		}
+	}
+}
+
+// updateHealth refreshes the container and returns the event for a change of its health
+// status, if any. It returns false once the container is no longer running.
+func (c *Container) updateHealth() (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), propertyCollectorTimeout)
+	defer cancel()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	switch c.state {
+	case StateRunning:
+	case StatePaused, StateSuspending:
+		// the probes resume along with the container
+		return "", true
+	default:
+		c.healthMonitored = false
+		c.healthStatus = ""
+		return "", false
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		log.Errorf("Container(%s) health update failed: %s", c.ExecConfig.ID, err)
+		return "", true
+	}
+
+	session := c.ExecConfig.Sessions[c.ExecConfig.ID]
+	if session == nil || session.Health.Status == c.healthStatus {
+		return "", true
+	}
+
+	log.Infof("Container(%s) health is now %s", c.ExecConfig.ID, session.Health.Status)
+	c.healthStatus = session.Health.Status
+
+	return healthEvent(c.healthStatus), true
+}
@@ -0,0 +1,49 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/lib/portlayer/event/events"
+)
+
+func TestHealthEvent(t *testing.T) {
+	assert.Equal(t, events.ContainerHealthy, healthEvent(executor.Healthy))
+	assert.Equal(t, events.ContainerUnhealthy, healthEvent(executor.Unhealthy))
+	assert.Equal(t, "", healthEvent(executor.HealthStarting))
+}
+
+func TestHasHealthcheck(t *testing.T) {
+	config := &executor.ExecutorConfig{
+		Common: executor.Common{ID: "abc"},
+		Sessions: map[string]*executor.SessionConfig{
+			"abc": {},
+		},
+	}
+	assert.False(t, hasHealthcheck(config))
+
+	config.Sessions["abc"].Healthcheck.Test = []string{"NONE"}
+	assert.False(t, hasHealthcheck(config))
+
+	config.Sessions["abc"].Healthcheck.Test = []string{"CMD", "true"}
+	assert.True(t, hasHealthcheck(config))
+
+	delete(config.Sessions, "abc")
+	assert.False(t, hasHealthcheck(config))
+}
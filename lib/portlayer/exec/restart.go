@@ -0,0 +1,175 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+const (
+	// the delay before a container is restarted doubles with each restart, as it does in docker,
+	// until the container has run long enough to be considered healthy
+	minRestartDelay = 100 * time.Millisecond
+	maxRestartDelay = time.Minute
+	restartResetRun = 10 * time.Second
+)
+
+// shouldRestart reports whether the restart policy calls for a container to be restarted after
+// its primary session exited with the status, given the number of restarts it has already had
+// and whether it was stopped on request
+func shouldRestart(p executor.RestartPolicy, status int, restarts int, stopped bool) bool {
+	if stopped {
+		return false
+	}
+
+	switch p.Name {
+	case "always", "unless-stopped":
+		return true
+	case "on-failure":
+		return status != 0 && (p.MaximumRetryCount == 0 || restarts < p.MaximumRetryCount)
+	}
+
+	return false
+}
+
+// nextRestartDelay returns the delay before the next restart of a container that ran for the
+// given time, given the delay before its previous restart
+func nextRestartDelay(previous time.Duration, ran time.Duration) time.Duration {
+	if previous == 0 || ran >= restartResetRun {
+		return minRestartDelay
+	}
+
+	if next := 2 * previous; next < maxRestartDelay {
+		return next
+	}
+	return maxRestartDelay
+}
+
+// applyRestartPolicy schedules the restart of a container whose VM powered off without being
+// asked to, if its restart policy calls for it
+func (c *Container) applyRestartPolicy() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.state != StateStopped {
+		return
+	}
+
+	session := c.ExecConfig.Sessions[c.ExecConfig.ID]
+	if session == nil {
+		return
+	}
+
+	if !shouldRestart(c.ExecConfig.RestartPolicy, session.ExitStatus, c.ExecConfig.RestartCount, c.stopRequested) {
+		return
+	}
+
+	ran := time.Since(time.Unix(session.StartTime, 0))
+	c.restartDelay = nextRestartDelay(c.restartDelay, ran)
+
+	log.Infof("Container(%s) exited with status %d, restarting in %s as its restart policy is %q",
+		c.ExecConfig.ID, session.ExitStatus, c.restartDelay, c.ExecConfig.RestartPolicy.Name)
+
+	c.scheduleRestart(c.restartDelay)
+}
+
+// applyRestartPolicyOnBoot restarts a stopped container whose restart policy calls for it when
+// the VCH starts. Unlike a restart after an exit, an "always" policy restarts the container even
+// if it was stopped on request.
+func (c *Container) applyRestartPolicyOnBoot() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.state != StateStopped {
+		return
+	}
+
+	session := c.ExecConfig.Sessions[c.ExecConfig.ID]
+	if session == nil {
+		return
+	}
+
+	p := c.ExecConfig.RestartPolicy
+	stopped := c.ExecConfig.StoppedByRequest && p.Name != "always"
+	if !shouldRestart(p, session.ExitStatus, c.ExecConfig.RestartCount, stopped) {
+		return
+	}
+
+	log.Infof("Container(%s) is restarted as its restart policy is %q", c.ExecConfig.ID, p.Name)
+
+	c.scheduleRestart(0)
+}
+
+// scheduleRestart restarts the container after the delay, replacing any restart that is
+// already pending. The caller must hold the container lock.
+func (c *Container) scheduleRestart(delay time.Duration) {
+	c.cancelRestart()
+	c.restartTimer = time.AfterFunc(delay, c.restart)
+}
+
+// cancelRestart cancels any pending restart of the container. The caller must hold the
+// container lock.
+func (c *Container) cancelRestart() {
+	if c.restartTimer != nil {
+		c.restartTimer.Stop()
+		c.restartTimer = nil
+	}
+}
+
+// restart starts the container again on behalf of its restart policy
+func (c *Container) restart() {
+	defer trace.End(trace.Begin(c.ExecConfig.ID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), propertyCollectorTimeout)
+	defer cancel()
+
+	// the container may have been started or removed while the restart was pending
+	if c.CurrentState() != StateStopped || Containers.Container(c.ExecConfig.ID) == nil {
+		return
+	}
+
+	h := c.NewHandle(ctx)
+	if h == nil {
+		log.Errorf("Container(%s) could not be restarted: unable to get a handle", c.ExecConfig.ID)
+		return
+	}
+
+	h.ExecConfig.RestartCount++
+	h.SetState(StateRunning)
+
+	if err := h.Commit(ctx, nil, nil); err != nil {
+		h.Close()
+		log.Errorf("Container(%s) could not be restarted: %s", c.ExecConfig.ID, err)
+
+		// keep trying, backing off as if the container had exited straight away
+		if Containers.Container(c.ExecConfig.ID) == nil {
+			return
+		}
+
+		c.m.Lock()
+		defer c.m.Unlock()
+
+		if c.state == StateStopped {
+			c.restartDelay = nextRestartDelay(c.restartDelay, 0)
+			c.scheduleRestart(c.restartDelay)
+		}
+	}
+}
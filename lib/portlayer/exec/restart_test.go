@@ -0,0 +1,64 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/config/executor"
+)
+
+func TestShouldRestart(t *testing.T) {
+	no := executor.RestartPolicy{Name: "no"}
+	always := executor.RestartPolicy{Name: "always"}
+	unlessStopped := executor.RestartPolicy{Name: "unless-stopped"}
+	onFailure := executor.RestartPolicy{Name: "on-failure"}
+	onFailureMax := executor.RestartPolicy{Name: "on-failure", MaximumRetryCount: 2}
+
+	assert.False(t, shouldRestart(executor.RestartPolicy{}, 1, 0, false))
+	assert.False(t, shouldRestart(no, 1, 0, false))
+
+	assert.True(t, shouldRestart(always, 0, 10, false))
+	assert.True(t, shouldRestart(unlessStopped, 0, 10, false))
+	assert.False(t, shouldRestart(always, 0, 0, true))
+	assert.False(t, shouldRestart(unlessStopped, 0, 0, true))
+
+	assert.False(t, shouldRestart(onFailure, 0, 0, false))
+	assert.True(t, shouldRestart(onFailure, 1, 100, false))
+	assert.True(t, shouldRestart(onFailureMax, 1, 1, false))
+	assert.False(t, shouldRestart(onFailureMax, 1, 2, false))
+	assert.False(t, shouldRestart(onFailure, 1, 0, true))
+}
+
+func TestNextRestartDelay(t *testing.T) {
+	delay := nextRestartDelay(0, 0)
+	assert.Equal(t, minRestartDelay, delay)
+
+	delay = nextRestartDelay(delay, time.Second)
+	assert.Equal(t, 2*minRestartDelay, delay)
+
+	delay = nextRestartDelay(delay, time.Second)
+	assert.Equal(t, 4*minRestartDelay, delay)
+
+	// the delay is capped
+	assert.Equal(t, maxRestartDelay, nextRestartDelay(45*time.Second, 0))
+	assert.Equal(t, maxRestartDelay, nextRestartDelay(maxRestartDelay, 0))
+
+	// and starts over once the container runs for long enough
+	assert.Equal(t, minRestartDelay, nextRestartDelay(maxRestartDelay, restartResetRun))
+}
@@ -0,0 +1,236 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics samples the vSphere performance counters of container VMs
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/vsphere/session"
+)
+
+// The counters sampled for each container VM, named group.counter.rollup
+const (
+	// hundredths of a percent of the CPU capacity of the VM
+	cpuUsage = "cpu.usage.average"
+	// KB
+	memActive = "mem.active.average"
+	// KBps
+	netReceived    = "net.received.average"
+	netTransmitted = "net.transmitted.average"
+	diskRead       = "virtualDisk.read.average"
+	diskWrite      = "virtualDisk.write.average"
+)
+
+var counterNames = []string{
+	cpuUsage,
+	memActive,
+	netReceived,
+	netTransmitted,
+	diskRead,
+	diskWrite,
+}
+
+// RealtimeInterval is the interval, in seconds, of the realtime statistics
+// that ESX keeps for powered on VMs
+const RealtimeInterval = 20
+
+// Sample is the most recent set of counter values for a container VM. The rates
+// are averages over the interval that ends at Timestamp.
+type Sample struct {
+	Timestamp time.Time
+	// length of the sample in seconds
+	Interval int32
+
+	// hundredths of a percent of the CPU capacity of the VM
+	CPUUsage int64
+	// KB
+	MemoryActive int64
+	// KBps
+	NetworkRx int64
+	NetworkTx int64
+	DiskRead  int64
+	DiskWrite int64
+}
+
+// Collector queries the PerformanceManager for container VM samples
+type Collector struct {
+	client *vim25.Client
+	perf   types.ManagedObjectReference
+
+	// counter name to counter key, populated on first use
+	m        sync.Mutex
+	counters map[string]int32
+}
+
+func NewCollector(sess *session.Session) (*Collector, error) {
+	if sess.ServiceContent.PerfManager == nil {
+		return nil, fmt.Errorf("performance manager is not available")
+	}
+
+	return &Collector{
+		client: sess.Vim25(),
+		perf:   *sess.ServiceContent.PerfManager,
+	}, nil
+}
+
+// counterName returns the dotted name of the counter, e.g. cpu.usage.average
+func counterName(info *types.PerfCounterInfo) string {
+	return fmt.Sprintf("%s.%s.%s", info.GroupInfo.GetElementDescription().Key, info.NameInfo.GetElementDescription().Key, info.RollupType)
+}
+
+// counterKeys returns the keys of the sampled counters. The counters are
+// defined by the server so are looked up once and cached.
+func (c *Collector) counterKeys(ctx context.Context) (map[string]int32, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.counters != nil {
+		return c.counters, nil
+	}
+
+	var pm mo.PerformanceManager
+	if err := object.NewCommon(c.client, c.perf).Properties(ctx, c.perf, []string{"perfCounter"}, &pm); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range counterNames {
+		wanted[name] = true
+	}
+
+	counters := make(map[string]int32)
+	for i := range pm.PerfCounter {
+		name := counterName(&pm.PerfCounter[i])
+		if wanted[name] {
+			counters[name] = pm.PerfCounter[i].Key
+		}
+	}
+
+	if len(counters) != len(counterNames) {
+		return nil, fmt.Errorf("performance counters unavailable: found %d of %d", len(counters), len(counterNames))
+	}
+
+	c.counters = counters
+	return c.counters, nil
+}
+
+// Sample returns the latest realtime sample for the VM
+func (c *Collector) Sample(ctx context.Context, vm types.ManagedObjectReference) (*Sample, error) {
+	defer trace.End(trace.Begin(vm.String()))
+
+	counters, err := c.counterKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int32]string)
+	spec := types.PerfQuerySpec{
+		Entity:     vm,
+		MaxSample:  1,
+		IntervalId: RealtimeInterval,
+	}
+
+	for name, key := range counters {
+		names[key] = name
+		// all instances so that per device values are returned for counters without an aggregate
+		spec.MetricId = append(spec.MetricId, types.PerfMetricId{CounterId: key, Instance: "*"})
+	}
+
+	req := types.QueryPerf{
+		This:      c.perf,
+		QuerySpec: []types.PerfQuerySpec{spec},
+	}
+
+	res, err := methods.QueryPerf(ctx, c.client, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, base := range res.Returnval {
+		if metric, ok := base.(*types.PerfEntityMetric); ok {
+			return newSample(names, metric)
+		}
+	}
+
+	return nil, fmt.Errorf("no performance data available for %s", vm)
+}
+
+// newSample converts the metric series into a Sample. The aggregate instance of
+// a counter is used if present, otherwise the per device instances are summed.
+func newSample(names map[int32]string, metric *types.PerfEntityMetric) (*Sample, error) {
+	if len(metric.SampleInfo) == 0 {
+		return nil, fmt.Errorf("no performance samples available for %s", metric.Entity)
+	}
+
+	// use the most recent sample if more than one was returned
+	last := len(metric.SampleInfo) - 1
+	info := metric.SampleInfo[last]
+
+	aggregate := make(map[string]int64)
+	devices := make(map[string]int64)
+
+	for _, base := range metric.Value {
+		series, ok := base.(*types.PerfMetricIntSeries)
+		if !ok || len(series.Value) <= last {
+			continue
+		}
+
+		name, ok := names[series.Id.CounterId]
+		if !ok {
+			continue
+		}
+
+		value := series.Value[last]
+		// -1 denotes an unavailable value
+		if value < 0 {
+			continue
+		}
+
+		if series.Id.Instance == "" {
+			aggregate[name] = value
+		} else {
+			devices[name] += value
+		}
+	}
+
+	value := func(name string) int64 {
+		if v, ok := aggregate[name]; ok {
+			return v
+		}
+		return devices[name]
+	}
+
+	return &Sample{
+		Timestamp:    info.Timestamp,
+		Interval:     info.Interval,
+		CPUUsage:     value(cpuUsage),
+		MemoryActive: value(memActive),
+		NetworkRx:    value(netReceived),
+		NetworkTx:    value(netTransmitted),
+		DiskRead:     value(diskRead),
+		DiskWrite:    value(diskWrite),
+	}, nil
+}
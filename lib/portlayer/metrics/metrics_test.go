@@ -0,0 +1,90 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func series(key int32, instance string, values ...int64) types.BasePerfMetricSeries {
+	return &types.PerfMetricIntSeries{
+		PerfMetricSeries: types.PerfMetricSeries{
+			Id: types.PerfMetricId{CounterId: key, Instance: instance},
+		},
+		Value: values,
+	}
+}
+
+func TestCounterName(t *testing.T) {
+	info := &types.PerfCounterInfo{
+		GroupInfo:  &types.ElementDescription{Key: "cpu"},
+		NameInfo:   &types.ElementDescription{Key: "usage"},
+		RollupType: types.PerfSummaryTypeAverage,
+	}
+
+	assert.Equal(t, cpuUsage, counterName(info))
+}
+
+func TestNewSample(t *testing.T) {
+	names := map[int32]string{
+		1: cpuUsage,
+		2: memActive,
+		3: netReceived,
+		4: diskRead,
+	}
+
+	now := time.Now()
+	metric := &types.PerfEntityMetric{
+		SampleInfo: []types.PerfSampleInfo{
+			{Timestamp: now.Add(-RealtimeInterval * time.Second), Interval: RealtimeInterval},
+			{Timestamp: now, Interval: RealtimeInterval},
+		},
+		Value: []types.BasePerfMetricSeries{
+			series(1, "", 1000, 2500),
+			series(1, "0", 500, 1250),
+			series(2, "", 1024, 2048),
+			// the aggregate is preferred over the devices
+			series(3, "4000", 1, 2),
+			series(3, "", 3, 4),
+			// no aggregate so the devices are summed
+			series(4, "scsi0:0", 10, 20),
+			series(4, "scsi0:1", 5, -1),
+			series(4, "scsi0:2", 0, 5),
+			// unknown counter
+			series(5, "", 100, 100),
+		},
+	}
+
+	sample, err := newSample(names, metric)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, now, sample.Timestamp)
+	assert.Equal(t, int32(RealtimeInterval), sample.Interval)
+	assert.Equal(t, int64(2500), sample.CPUUsage)
+	assert.Equal(t, int64(2048), sample.MemoryActive)
+	assert.Equal(t, int64(4), sample.NetworkRx)
+	assert.Equal(t, int64(0), sample.NetworkTx)
+	assert.Equal(t, int64(25), sample.DiskRead)
+
+	_, err = newSample(names, &types.PerfEntityMetric{})
+	assert.Error(t, err)
+}
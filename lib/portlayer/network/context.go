@@ -34,6 +34,7 @@ import (
 	"github.com/vmware/vic/pkg/ip"
 	"github.com/vmware/vic/pkg/trace"
 	"github.com/vmware/vic/pkg/uid"
+	"github.com/vmware/vic/pkg/vsphere/session"
 	"golang.org/x/net/context"
 )
 
@@ -751,25 +752,7 @@ func (c *Context) UnbindContainer(h *exec.Handle) ([]*Endpoint, error) {
 		ne.Assigned.IP = net.IPv4zero
 
 		// aliases to remove
-		// name for dns lookup
-		aliases = append(aliases, fmt.Sprintf("%s:%s", s.Name(), con.name))
-		aliases = append(aliases, fmt.Sprintf("%s:%s", s.Name(), con.id.Truncate()))
-		for _, as := range e.aliases {
-			for _, a := range as {
-				aliases = append(aliases, a.scopedName())
-			}
-		}
-
-		// aliases from other containers
-		for _, e := range s.Endpoints() {
-			if e.Container() == con {
-				continue
-			}
-
-			for _, a := range e.getAliases(con.name) {
-				aliases = append(aliases, a.scopedName())
-			}
-		}
+		aliases = append(aliases, scopedAliases(s, con, e)...)
 
 		endpoints = append(endpoints, e)
 	}
@@ -779,14 +762,149 @@ func (c *Context) UnbindContainer(h *exec.Handle) ([]*Endpoint, error) {
 		delete(c.containers, a)
 	}
 
+	c.removeNames(con)
+
+	return endpoints, nil
+}
+
+// scopedAliases returns the names the container can be looked up by in the scope, including
+// the aliases other containers in the scope have for it
+func scopedAliases(s *Scope, con *Container, e *Endpoint) []string {
+	// name for dns lookup
+	aliases := []string{
+		fmt.Sprintf("%s:%s", s.Name(), con.name),
+		fmt.Sprintf("%s:%s", s.Name(), con.id.Truncate()),
+	}
+	for _, as := range e.aliases {
+		for _, a := range as {
+			aliases = append(aliases, a.scopedName())
+		}
+	}
+
+	// aliases from other containers
+	for _, e := range s.Endpoints() {
+		if e.Container() == con {
+			continue
+		}
+
+		for _, a := range e.getAliases(con.name) {
+			aliases = append(aliases, a.scopedName())
+		}
+	}
+
+	return aliases
+}
+
+// removeNames removes the names the container is known by outside of any scope
+func (c *Context) removeNames(con *Container) {
 	// long id
 	delete(c.containers, con.ID().String())
 	// short id
 	delete(c.containers, con.ID().Truncate().String())
 	// name
 	delete(c.containers, con.Name())
+}
 
-	return endpoints, nil
+// RenameContainer renames the container of the handle by committing the handle and, if the
+// container is bound, moving its DNS names and aliases to the new name. The context stays
+// locked from the check for conflicting names until the names are moved, and nothing is moved
+// if the commit fails, so lookups never see a half applied rename.
+func (c *Context) RenameContainer(ctx context.Context, sess *session.Session, h *exec.Handle, name string) error {
+	defer trace.End(trace.Begin(name))
+	c.Lock()
+	defer c.Unlock()
+
+	con, err := c.container(h)
+	if err != nil {
+		if _, ok := err.(ResourceNotFoundError); !ok {
+			return err
+		}
+	}
+
+	if _, ok := c.containers[name]; ok {
+		return DuplicateResourceError{resID: name}
+	}
+
+	var renamed map[string]string
+	if con != nil {
+		if renamed, err = c.renamedKeys(con, name); err != nil {
+			return err
+		}
+	}
+
+	if err = h.Rename(name).Commit(ctx, sess, nil); err != nil {
+		return err
+	}
+
+	if con != nil {
+		c.moveNames(con, name, renamed)
+	}
+
+	return nil
+}
+
+// renamedKeys returns the keys of the container, and of the aliases it declares for the
+// containers it links to, that contain its name, mapped to the keys with the new name
+func (c *Context) renamedKeys(con *Container, name string) (map[string]string, error) {
+	renamed := map[string]string{con.name: name}
+	for _, e := range con.Endpoints() {
+		scope := e.Scope().Name()
+		renamed[fmt.Sprintf("%s:%s", scope, con.name)] = fmt.Sprintf("%s:%s", scope, name)
+
+		for who, as := range e.aliases {
+			// aliases of the container itself are only scoped by the network
+			if who == con.name {
+				continue
+			}
+
+			for _, a := range as {
+				renamed[a.scopedName()] = fmt.Sprintf("%s:%s:%s", scope, name, a.Name)
+			}
+		}
+	}
+
+	for _, key := range renamed {
+		if _, ok := c.containers[key]; ok {
+			return nil, DuplicateResourceError{resID: key}
+		}
+	}
+
+	return renamed, nil
+}
+
+// moveNames moves the renamed keys, and the aliases held for the container by the
+// endpoints in its scopes, to the new name of the container
+func (c *Context) moveNames(con *Container, name string, renamed map[string]string) {
+	moved := make(map[string]*Container)
+	for old, key := range renamed {
+		if v, ok := c.containers[old]; ok {
+			moved[key] = v
+			delete(c.containers, old)
+		}
+	}
+	for key, v := range moved {
+		c.containers[key] = v
+	}
+
+	// aliases are held by the endpoints under the name of the container they refer to
+	for _, e := range con.Endpoints() {
+		for _, other := range e.Scope().Endpoints() {
+			as, ok := other.aliases[con.name]
+			if !ok {
+				continue
+			}
+
+			for i := range as {
+				as[i].Container = name
+			}
+			delete(other.aliases, con.name)
+			other.aliases[name] = as
+		}
+	}
+
+	con.Lock()
+	con.name = name
+	con.Unlock()
 }
 
 var addEthernetCard = func(h *exec.Handle, s *Scope) (types.BaseVirtualDevice, error) {
@@ -933,9 +1051,12 @@ func (c *Context) AddContainer(h *exec.Handle, options *AddContainerOptions) err
 		pciSlot = spec.VirtualDeviceSlotNumber(d)
 	}
 
-	if h.ExecConfig.Networks == nil {
-		h.ExecConfig.Networks = make(map[string]*executor.NetworkEndpoint)
+	// the map is shared with the committed config of the container until the handle is committed
+	networks := make(map[string]*executor.NetworkEndpoint, len(h.ExecConfig.Networks)+1)
+	for name, e := range h.ExecConfig.Networks {
+		networks[name] = e
 	}
+	h.ExecConfig.Networks = networks
 
 	ne := &executor.NetworkEndpoint{
 		Common: executor.Common{
@@ -966,6 +1087,10 @@ func (c *Context) AddContainer(h *exec.Handle, options *AddContainerOptions) err
 	return nil
 }
 
+// RemoveContainer removes the container from the scope. The NIC is removed with the handle
+// unless it is shared with another bridge scope, and a bound container has its address
+// released and its names in the scope removed once the handle has been committed, so they
+// stay in place if the commit fails.
 func (c *Context) RemoveContainer(h *exec.Handle, scope string) error {
 	defer trace.End(trace.Begin(""))
 	c.Lock()
@@ -975,20 +1100,33 @@ func (c *Context) RemoveContainer(h *exec.Handle, scope string) error {
 		return fmt.Errorf("handle is required")
 	}
 
-	if con, _ := c.container(h); con != nil {
-		return fmt.Errorf("container is bound")
-	}
-
 	var err error
 	s, err := c.resolveScope(scope)
 	if err != nil {
 		return err
 	}
 
+	if s == nil {
+		return ResourceNotFoundError{error: fmt.Errorf("scope %s not found", scope)}
+	}
+
 	var ne *executor.NetworkEndpoint
 	ne, ok := h.ExecConfig.Networks[s.Name()]
 	if !ok {
-		return fmt.Errorf("container %s not part of network %s", h.ExecConfig.ID, s.Name())
+		return ResourceNotFoundError{error: fmt.Errorf("container %s not part of network %s", h.ExecConfig.ID, s.Name())}
+	}
+
+	con, err := c.container(h)
+	if err != nil {
+		if _, ok := err.(ResourceNotFoundError); !ok {
+			return err
+		}
+	}
+
+	if con != nil {
+		if e := con.Endpoint(s); e != nil {
+			h.OnCommit(func() { c.releaseEndpoint(con, s, e) })
+		}
 	}
 
 	// figure out if any other networks are using the NIC
@@ -1007,28 +1145,118 @@ func (c *Context) RemoveContainer(h *exec.Handle, scope string) error {
 		// ensure spec is not nil
 		h.SetSpec(nil)
 
-		var devices object.VirtualDeviceList
-		backing, err := s.network.EthernetCardBackingInfo(context.Background())
-		if err != nil {
+		if err = removeEthernetCard(h, atoiOrZero(ne.ID)); err != nil {
 			return err
 		}
+	}
 
-		d, err := devices.CreateEthernetCard("vmxnet3", backing)
-		if err != nil {
-			return err
+	// the map is shared with the committed config of the container until the handle is committed
+	networks := make(map[string]*executor.NetworkEndpoint, len(h.ExecConfig.Networks))
+	for name, e := range h.ExecConfig.Networks {
+		if name != s.Name() {
+			networks[name] = e
 		}
+	}
+	h.ExecConfig.Networks = networks
 
-		devices = append(devices, d)
-		spec, err := devices.ConfigSpec(types.VirtualDeviceConfigSpecOperationRemove)
+	return nil
+}
+
+// ReleaseContainer removes a container that no longer exists from the scope, releasing
+// its address and its names in the scope
+func (c *Context) ReleaseContainer(id string, scope string) error {
+	defer trace.End(trace.Begin(id))
+	c.Lock()
+	defer c.Unlock()
+
+	s, err := c.resolveScope(scope)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		return ResourceNotFoundError{error: fmt.Errorf("scope %s not found", scope)}
+	}
+
+	con, ok := c.containers[id]
+	if !ok || con.Endpoint(s) == nil {
+		return ResourceNotFoundError{error: fmt.Errorf("container %s not part of network %s", id, s.Name())}
+	}
+
+	return c.removeEndpoint(con, s)
+}
+
+// releaseEndpoint removes the endpoint of the container from the scope once the container has
+// been disconnected from it, unless the endpoint has been removed or replaced since
+func (c *Context) releaseEndpoint(con *Container, s *Scope, e *Endpoint) {
+	c.Lock()
+	defer c.Unlock()
+
+	if con.Endpoint(s) != e {
+		return
+	}
+
+	if err := c.removeEndpoint(con, s); err != nil {
+		log.Errorf("unable to release container %s from scope %s: %s", con.ID(), s.Name(), err)
+	}
+}
+
+// removeEndpoint removes the bound container from the scope, releasing its address and
+// the names it can be looked up by in the scope
+func (c *Context) removeEndpoint(con *Container, s *Scope) error {
+	// save the endpoint info
+	e := con.Endpoint(s).copy()
+
+	if err := s.RemoveContainer(con); err != nil {
+		return err
+	}
+
+	for _, a := range scopedAliases(s, con, e) {
+		delete(c.containers, a)
+	}
+
+	if len(con.Endpoints()) == 0 {
+		c.removeNames(con)
+	}
+
+	return nil
+}
+
+// removeEthernetCard removes the NIC in the PCI slot from the container, dropping the change
+// that adds it if it has not been created yet
+func removeEthernetCard(h *exec.Handle, pciSlot int32) error {
+	for i, dc := range h.Spec.DeviceChange {
+		ds := dc.GetVirtualDeviceConfigSpec()
+		if ds.Operation != types.VirtualDeviceConfigSpecOperationAdd || spec.VirtualDeviceSlotNumber(ds.Device) != pciSlot {
+			continue
+		}
+
+		if _, ok := ds.Device.(types.BaseVirtualEthernetCard); ok {
+			h.Spec.DeviceChange = append(h.Spec.DeviceChange[:i], h.Spec.DeviceChange[i+1:]...)
+			return nil
+		}
+	}
+
+	if h.Container == nil || h.Container.Config == nil {
+		return fmt.Errorf("no NIC found in pci slot %d", pciSlot)
+	}
+
+	devices := object.VirtualDeviceList(h.Container.Config.Hardware.Device).SelectByType((*types.VirtualEthernetCard)(nil))
+	for _, d := range devices {
+		if spec.VirtualDeviceSlotNumber(d) != pciSlot {
+			continue
+		}
+
+		specs, err := object.VirtualDeviceList{d}.ConfigSpec(types.VirtualDeviceConfigSpecOperationRemove)
 		if err != nil {
 			return err
 		}
-		h.Spec.DeviceChange = append(h.Spec.DeviceChange, spec...)
-	}
 
-	delete(h.ExecConfig.Networks, s.Name())
+		h.Spec.DeviceChange = append(h.Spec.DeviceChange, specs...)
+		return nil
+	}
 
-	return nil
+	return fmt.Errorf("no NIC found in pci slot %d", pciSlot)
 }
 
 func (c *Context) Container(key string) *Container {
@@ -892,6 +892,15 @@ func TestContextRemoveContainer(t *testing.T) {
 	options.Scope = scope.Name()
 	ctx.AddContainer(hBar, options)
 
+	// container whose NIC has already been created
+	hQux := newContainer("qux")
+	ctx.AddContainer(hQux, options)
+	hQux.Container.Config = &types.VirtualMachineConfigInfo{}
+	for _, dc := range hQux.Spec.DeviceChange {
+		hQux.Container.Config.Hardware.Device = append(hQux.Container.Config.Hardware.Device, dc.GetVirtualDeviceConfigSpec().Device)
+	}
+	hQux.Spec = nil
+
 	var tests = []struct {
 		h     *exec.Handle
 		scope string
@@ -899,11 +908,18 @@ func TestContextRemoveContainer(t *testing.T) {
 	}{
 		{nil, "", fmt.Errorf("")},                        // nil handle
 		{hBar, "bar", fmt.Errorf("")},                    // scope not found
-		{hFoo, scope.Name(), fmt.Errorf("")},             // bound container
 		{newContainer("baz"), "default", fmt.Errorf("")}, // container not part of scope
+		{hFoo, scope.Name(), nil},                        // bound container
 		{hBar, "default", nil},
 		{hBar, scope.Name(), nil},
+		{hQux, scope.Name(), nil},
+	}
+
+	conFoo := ctx.Container(hFoo.ExecConfig.ID)
+	if conFoo == nil {
+		t.Fatalf("container %s is not bound", hFoo.ExecConfig.Name)
 	}
+	eFoo := conFoo.Endpoint(scope)
 
 	for i, te := range tests {
 		var ne *executor.NetworkEndpoint
@@ -926,6 +942,15 @@ func TestContextRemoveContainer(t *testing.T) {
 			t.Fatalf(err.Error())
 		}
 
+		// a bound container keeps its address until the handle is committed
+		if te.h == hFoo {
+			if s.Container(uid.Parse(te.h.Container.ExecConfig.ID)) == nil {
+				t.Fatalf("container %s was removed from scope %s before commit", te.h, s.Name())
+			}
+
+			ctx.releaseEndpoint(conFoo, s, eFoo)
+		}
+
 		if s.Container(uid.Parse(te.h.Container.ExecConfig.ID)) != nil {
 			t.Fatalf("container %s is part of scope %s", te.h, s.Name())
 		}
@@ -970,6 +995,16 @@ func TestContextRemoveContainer(t *testing.T) {
 			t.Fatalf("%d: endpoint metadata for container still present in handle %#v", i, te.h.ExecConfig)
 		}
 	}
+
+	// the names of the bound container are released along with its address
+	if ctx.Container(hFoo.ExecConfig.Name) != nil || ctx.Container(fmt.Sprintf("%s:%s", scope.Name(), hFoo.ExecConfig.Name)) != nil {
+		t.Fatalf("container %s can still be looked up", hFoo.ExecConfig.Name)
+	}
+
+	// the NIC that was created is removed with a reconfigure
+	if len(hQux.Spec.DeviceChange) != 1 || hQux.Spec.DeviceChange[0].GetVirtualDeviceConfigSpec().Operation != types.VirtualDeviceConfigSpecOperationRemove {
+		t.Fatalf("expected a remove spec for the NIC of %s, got %#v", hQux.ExecConfig.Name, hQux.Spec.DeviceChange)
+	}
 }
 
 func TestDeleteScope(t *testing.T) {
@@ -1150,3 +1185,48 @@ func TestAliases(t *testing.T) {
 	assert.Nil(t, ctx.Container(fmt.Sprintf("%s:c1:other", scope.Name())))
 	assert.Nil(t, ctx.Container(fmt.Sprintf("%s:c3:c2", scope.Name())))
 }
+
+func TestRenameContainerNames(t *testing.T) {
+	ctx, err := NewContext(testConfig())
+	assert.NoError(t, err)
+
+	scope := ctx.DefaultScope()
+
+	bind := func(name string, aliases ...string) *Container {
+		h := newContainer(name)
+		err := ctx.AddContainer(h, &AddContainerOptions{Scope: scope.Name(), Aliases: aliases})
+		assert.NoError(t, err)
+
+		_, err = ctx.BindContainer(h)
+		assert.NoError(t, err)
+
+		return ctx.Container(h.ExecConfig.ID)
+	}
+
+	c1 := bind("c1", "c2:other", ":web")
+	c2 := bind("c2", "c1:other")
+
+	// the new name may not collide with the names of other containers
+	_, err = ctx.renamedKeys(c1, "c2")
+	assert.Error(t, err)
+
+	renamed, err := ctx.renamedKeys(c1, "c4")
+	assert.NoError(t, err)
+	ctx.moveNames(c1, "c4", renamed)
+
+	assert.Equal(t, "c4", c1.Name())
+	assert.Equal(t, c1, ctx.Container("c4"))
+	assert.Equal(t, c1, ctx.Container(fmt.Sprintf("%s:c4", scope.Name())))
+	assert.Nil(t, ctx.Container("c1"))
+	assert.Nil(t, ctx.Container(fmt.Sprintf("%s:c1", scope.Name())))
+
+	// aliases declared by the renamed container
+	assert.Equal(t, c1, ctx.Container(fmt.Sprintf("%s:web", scope.Name())))
+	assert.Equal(t, c2, ctx.Container(fmt.Sprintf("%s:c4:other", scope.Name())))
+	assert.Nil(t, ctx.Container(fmt.Sprintf("%s:c1:other", scope.Name())))
+
+	// aliases declared for the renamed container by others
+	assert.Equal(t, c1, ctx.Container(fmt.Sprintf("%s:c2:other", scope.Name())))
+	assert.Len(t, c2.Endpoint(scope).getAliases("c4"), 1)
+	assert.Empty(t, c2.Endpoint(scope).getAliases("c1"))
+}
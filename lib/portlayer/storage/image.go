@@ -60,6 +60,13 @@ type ImageStorer interface {
 	// ID - textual ID for the image to be retrieved
 	GetImage(op trace.Operation, store *url.URL, ID string) (*Image, error)
 
+	// GetImageTar returns a tar stream of the changes the image makes to its
+	// parent, i.e. the layer as it was given to WriteImage.  The stream must
+	// be closed to release the image.
+	//
+	// image - The image to be read
+	GetImageTar(op trace.Operation, image *Image) (io.ReadCloser, error)
+
 	// ListImages returns a list of Images given a list of image IDs, or all
 	// images in the image store if no param is passed.
 	ListImages(op trace.Operation, store *url.URL, IDs []string) ([]*Image, error)
@@ -277,6 +277,17 @@ func (c *NameLookupCache) GetImage(op trace.Operation, store *url.URL, ID string
 	return img, nil
 }
 
+// GetImageTar returns a tar stream of the image layer from the image store
+func (c *NameLookupCache) GetImageTar(op trace.Operation, image *Image) (io.ReadCloser, error) {
+	// Check the image exists and complete its details from the cache
+	i, err := c.GetImage(op, image.Store, image.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.DataStore.GetImageTar(op, i)
+}
+
 // ListImages returns a list of Images for a list of IDs, or all if no IDs are passed
 func (c *NameLookupCache) ListImages(op trace.Operation, store *url.URL, IDs []string) ([]*Image, error) {
 	// Filter the results
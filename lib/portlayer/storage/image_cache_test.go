@@ -15,8 +15,10 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
 	"strconv"
 	"testing"
@@ -104,6 +106,14 @@ func (c *MockDataStore) GetImage(op trace.Operation, store *url.URL, ID string)
 	return i, nil
 }
 
+// GetImageTar returns an empty tar stream for the image
+func (c *MockDataStore) GetImageTar(op trace.Operation, image *Image) (io.ReadCloser, error) {
+	if _, ok := c.db[*image.Store][image.ID]; !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	return ioutil.NopCloser(bytes.NewReader(make([]byte, 1024))), nil
+}
+
 // ListImages resturns a list of Images for a list of IDs, or all if no IDs are passed
 func (c *MockDataStore) ListImages(op trace.Operation, store *url.URL, IDs []string) ([]*Image, error) {
 	var imageList []*Image
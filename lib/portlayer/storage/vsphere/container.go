@@ -0,0 +1,227 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vsphere
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	darchive "github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/ioutils"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/vic/lib/portlayer/exec"
+	"github.com/vmware/vic/pkg/archive"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/vsphere/disk"
+	"github.com/vmware/vic/pkg/vsphere/session"
+)
+
+// ContainerStore gives the appliance access to the read-write layer of
+// containers that are not running by attaching their disk to the appliance.
+type ContainerStore struct {
+	// wraps our vmdks and filesystem primitives.
+	dm *disk.Manager
+}
+
+func NewContainerStore(op trace.Operation, s *session.Session) (*ContainerStore, error) {
+	dm, err := disk.NewDiskManager(op, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContainerStore{dm: dm}, nil
+}
+
+// containerBacking returns the backing of the read-write layer of the container
+func containerBacking(handle *exec.Handle) (*types.VirtualDiskFlatVer2BackingInfo, error) {
+	if handle.Container.Config == nil {
+		return nil, fmt.Errorf("no configuration available for container %s", handle.ExecConfig.ID)
+	}
+
+	// the read-write layer is named after the container, volumes are not
+	suffix := fmt.Sprintf("/%s.vmdk", handle.ExecConfig.ID)
+
+	devices := object.VirtualDeviceList(handle.Container.Config.Hardware.Device)
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		backing, ok := device.GetVirtualDevice().Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		if !ok {
+			continue
+		}
+
+		if strings.HasSuffix(backing.FileName, suffix) {
+			return backing, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find read-write layer for container %s", handle.ExecConfig.ID)
+}
+
+// ContainerDisk returns the datastore path of the read-write layer of the container
+func ContainerDisk(handle *exec.Handle) (string, error) {
+	backing, err := containerBacking(handle)
+	if err != nil {
+		return "", err
+	}
+
+	return backing.FileName, nil
+}
+
+// Mount attaches the read-write layer of the container to the appliance and
+// mounts it on a temporary directory. The returned function unmounts and
+// detaches the disk and must be called once the caller is done with it.
+// The container cannot be started, nor its layer mounted again, until then.
+func (c *ContainerStore) Mount(op trace.Operation, handle *exec.Handle, readonly bool) (string, func(), error) {
+	defer trace.End(trace.Begin(handle.ExecConfig.ID))
+
+	diskDsURI, err := ContainerDisk(handle)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// fails fast if the container is running or its disk is already attached
+	if err = handle.Container.AcquireDisk(); err != nil {
+		return "", nil, err
+	}
+
+	dir, release, err := c.mount(op, diskDsURI, "mnt-"+handle.ExecConfig.ID, os.O_RDWR, readonly)
+	if err != nil {
+		handle.Container.ReleaseDisk()
+		return "", nil, err
+	}
+
+	return dir, func() {
+		release()
+		handle.Container.ReleaseDisk()
+	}, nil
+}
+
+// mountWithParent mounts the read-write layer of the container and the image
+// layer it was created from, which holds the filesystem of the whole image chain.
+// The returned function unmounts and detaches both disks.
+func (c *ContainerStore) mountWithParent(op trace.Operation, handle *exec.Handle) (string, string, func(), error) {
+	backing, err := containerBacking(handle)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if backing.Parent == nil {
+		return "", "", nil, fmt.Errorf("read-write layer of container %s has no parent", handle.ExecConfig.ID)
+	}
+
+	dir, release, err := c.Mount(op, handle, true)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	parentDir, releaseParent, err := c.mount(op, backing.Parent.FileName, "mnt-parent-"+handle.ExecConfig.ID, os.O_RDONLY, true)
+	if err != nil {
+		release()
+		return "", "", nil, err
+	}
+
+	return dir, parentDir, func() {
+		releaseParent()
+		release()
+	}, nil
+}
+
+// Diff returns a tar stream of the changes the read-write layer of the container
+// makes to the image it was created from. Both disks are attached to the appliance
+// until the stream is closed.
+func (c *ContainerStore) Diff(op trace.Operation, handle *exec.Handle) (io.ReadCloser, error) {
+	defer trace.End(trace.Begin(handle.ExecConfig.ID))
+
+	dir, parentDir, release, err := c.mountWithParent(op, handle)
+	if err != nil {
+		return nil, err
+	}
+
+	tar, err := archive.Diff(dir, parentDir)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return ioutils.NewReadCloserWrapper(tar, func() error {
+		err := tar.Close()
+		release()
+		return err
+	}), nil
+}
+
+// Changes returns the paths the read-write layer of the container adds, modifies
+// or deletes in the image it was created from
+func (c *ContainerStore) Changes(op trace.Operation, handle *exec.Handle) ([]darchive.Change, error) {
+	defer trace.End(trace.Begin(handle.ExecConfig.ID))
+
+	dir, parentDir, release, err := c.mountWithParent(op, handle)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return archive.Changes(dir, parentDir)
+}
+
+// mount attaches the existing disk to the appliance and mounts it on a temporary
+// directory. The returned function unmounts and detaches the disk.
+func (c *ContainerStore) mount(op trace.Operation, diskDsURI, prefix string, flag int, readonly bool) (string, func(), error) {
+	// attaches the existing disk as no parent or capacity is specified
+	vmdisk, err := c.dm.CreateAndAttach(op, diskDsURI, "", 0, flag)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		if vmdisk.Mounted() {
+			if err := vmdisk.Unmount(); err != nil {
+				log.Errorf("Failed to unmount %s: %s", diskDsURI, err)
+			}
+		}
+
+		if err := c.dm.Detach(op, vmdisk); err != nil {
+			log.Errorf("Failed to detach %s: %s", diskDsURI, err)
+		}
+	}
+
+	// tmp dir to mount the disk
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	var opts []string
+	if readonly {
+		opts = []string{"ro"}
+	}
+
+	if err = vmdisk.Mount(dir, opts); err != nil {
+		cleanup()
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	return dir, func() {
+		cleanup()
+		os.RemoveAll(dir)
+	}, nil
+}
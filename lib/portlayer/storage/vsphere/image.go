@@ -25,11 +25,13 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/ioutils"
 
 	"github.com/vmware/govmomi/vim25/types"
 	"github.com/vmware/vic/lib/portlayer/exec"
 	portlayer "github.com/vmware/vic/lib/portlayer/storage"
 	"github.com/vmware/vic/lib/portlayer/util"
+	vicarchive "github.com/vmware/vic/pkg/archive"
 	"github.com/vmware/vic/pkg/trace"
 	"github.com/vmware/vic/pkg/vsphere/datastore"
 	"github.com/vmware/vic/pkg/vsphere/disk"
@@ -411,6 +413,98 @@ func (v *ImageStore) scratch(op trace.Operation, storeName string) error {
 	return nil
 }
 
+// GetImageTar returns a tar stream of the changes the image layer makes to its
+// parent. The image and parent disks are attached to the appliance until the
+// stream is closed.
+func (v *ImageStore) GetImageTar(op trace.Operation, image *portlayer.Image) (io.ReadCloser, error) {
+	defer trace.End(trace.Begin(image.ID))
+
+	storeName, err := util.ImageStoreName(image.Store)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, release, err := v.mount(op, storeName, image.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentDir string
+	var releaseParent func()
+
+	if image.ID == portlayer.Scratch.ID || image.ParentLink == nil {
+		// the root of the store is diffed against an empty filesystem
+		if parentDir, err = ioutil.TempDir("", "empty-"+image.ID); err != nil {
+			release()
+			return nil, err
+		}
+		releaseParent = func() { os.RemoveAll(parentDir) }
+	} else {
+		parent, err := portlayer.Parse(image.ParentLink)
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		if parentDir, releaseParent, err = v.mount(op, storeName, parent.ID); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	tar, err := vicarchive.Diff(dir, parentDir)
+	if err != nil {
+		releaseParent()
+		release()
+		return nil, err
+	}
+
+	return ioutils.NewReadCloserWrapper(tar, func() error {
+		err := tar.Close()
+		releaseParent()
+		release()
+		return err
+	}), nil
+}
+
+// mount attaches the disk of the image to the appliance without persisting
+// changes and mounts it read-only on a temporary directory. The returned
+// function unmounts and detaches the disk.
+func (v *ImageStore) mount(op trace.Operation, storeName, ID string) (string, func(), error) {
+	diskDsURI := v.imageDiskDSPath(storeName, ID)
+
+	vmdisk, err := v.dm.CreateAndAttach(op, diskDsURI, "", 0, os.O_RDONLY)
+	if err != nil {
+		return "", nil, err
+	}
+
+	detach := func() {
+		if err := v.dm.Detach(op, vmdisk); err != nil {
+			log.Errorf("Failed to detach %s: %s", diskDsURI, err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "mnt-"+ID)
+	if err != nil {
+		detach()
+		return "", nil, err
+	}
+
+	if err = vmdisk.Mount(dir, []string{"ro"}); err != nil {
+		detach()
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	return dir, func() {
+		if err := vmdisk.Unmount(); err != nil {
+			log.Errorf("Failed to unmount %s: %s", diskDsURI, err)
+		}
+		detach()
+		os.RemoveAll(dir)
+	}, nil
+}
+
 func (v *ImageStore) GetImage(op trace.Operation, store *url.URL, ID string) (*portlayer.Image, error) {
 
 	defer trace.End(trace.Begin(store.String()))
@@ -0,0 +1,89 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/lib/portlayer/exec"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// Join adds the given session to the handle. The session is launched by the tether
+// once the handle is committed against a running container.
+func Join(h interface{}, session *executor.SessionConfig) (interface{}, error) {
+	defer trace.End(trace.Begin(""))
+
+	handle, ok := h.(*exec.Handle)
+	if !ok {
+		return nil, fmt.Errorf("Type assertion failed for %#+v", handle)
+	}
+
+	if session == nil || session.ID == "" {
+		return nil, fmt.Errorf("task ID must be specified")
+	}
+
+	if handle.Container.CurrentState() == exec.StatePaused {
+		return nil, fmt.Errorf("container %s is paused, unpause the container first", handle.ExecConfig.ID)
+	}
+
+	if _, ok := handle.ExecConfig.Sessions[session.ID]; ok {
+		return nil, fmt.Errorf("task %s already exists", session.ID)
+	}
+
+	// the handle shares the Sessions map with the container so copy it before
+	// adding the new entry, otherwise the task would appear before commit
+	sessions := make(map[string]*executor.SessionConfig, len(handle.ExecConfig.Sessions)+1)
+	for id, s := range handle.ExecConfig.Sessions {
+		sessions[id] = s
+	}
+
+	session.CreateTime = time.Now().UTC().Unix()
+	sessions[session.ID] = session
+	handle.ExecConfig.Sessions = sessions
+
+	// make sure a spec exists so that the change is applied on commit
+	handle.SetSpec(nil)
+
+	return handle, nil
+}
+
+// Inspect returns the session with the given ID from the handle
+func Inspect(h interface{}, id string) (*executor.SessionConfig, error) {
+	defer trace.End(trace.Begin(id))
+
+	handle, ok := h.(*exec.Handle)
+	if !ok {
+		return nil, fmt.Errorf("Type assertion failed for %#+v", handle)
+	}
+
+	session, ok := handle.ExecConfig.Sessions[id]
+	if !ok {
+		return nil, TaskNotFoundError{ID: id}
+	}
+
+	return session, nil
+}
+
+// TaskNotFoundError is returned when the requested task is not present in the handle
+type TaskNotFoundError struct {
+	ID string
+}
+
+func (e TaskNotFoundError) Error() string {
+	return fmt.Sprintf("task %s not found", e.ID)
+}
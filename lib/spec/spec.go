@@ -89,6 +89,18 @@ type VirtualMachineConfigSpec struct {
 	key int32
 }
 
+// VMName returns the name of the VM for the container with the given name and ID
+func VMName(name, id string) string {
+	// set VM name to prettyname-ID, to make it readable a little bit
+	// if prettyname-ID is longer than max vm name length, truncate pretty name, instead of UUID, to make it unique
+	nameMaxLen := maxVMNameLength - len(id)
+	prettyName := name
+	if len(prettyName) > nameMaxLen-1 {
+		prettyName = prettyName[:nameMaxLen-1]
+	}
+	return fmt.Sprintf("%s-%s", prettyName, id)
+}
+
 // NewVirtualMachineConfigSpec returns a VirtualMachineConfigSpec
 func NewVirtualMachineConfigSpec(ctx context.Context, session *session.Session, config *VirtualMachineConfigSpecConfig) (*VirtualMachineConfigSpec, error) {
 	defer trace.End(trace.Begin(config.ID))
@@ -96,14 +108,7 @@ func NewVirtualMachineConfigSpec(ctx context.Context, session *session.Session,
 	log.Debugf("Adding metadata to the configspec: %+v", config.Metadata)
 	// TEMPORARY
 
-	// set VM name to prettyname-ID, to make it readable a little bit
-	// if prettyname-ID is longer than max vm name length, truncate pretty name, instead of UUID, to make it unique
-	nameMaxLen := maxVMNameLength - len(config.ID)
-	prettyName := config.Name
-	if len(prettyName) > nameMaxLen-1 {
-		prettyName = prettyName[:nameMaxLen-1]
-	}
-	fullName := fmt.Sprintf("%s-%s", prettyName, config.ID)
+	fullName := VMName(config.Name, config.ID)
 	config.VMFullName = fullName
 
 	s := &types.VirtualMachineConfigSpec{
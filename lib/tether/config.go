@@ -19,6 +19,7 @@ import (
 	"os"
 	"os/exec"
 	"sync"
+	"time"
 
 	"github.com/vmware/vic/lib/config/executor"
 	"github.com/vmware/vic/pkg/dio"
@@ -41,6 +42,9 @@ type ExecutorConfig struct {
 	// Set of child PIDs created by us.
 	pids map[int]*SessionConfig
 
+	// Set of health probe PIDs created by us, with the channel their exit status is sent on
+	probes map[int]chan int
+
 	// Sessions is the set of sessions currently hosted by this executor
 	// These are keyed by session ID
 	Sessions map[string]*SessionConfig `vic:"0.1" scope:"read-only" key:"sessions"`
@@ -82,6 +86,12 @@ type SessionConfig struct {
 	// Allow attach
 	Attach bool `vic:"0.1" scope:"read-only" key:"attach"`
 
+	// AttachTimeout is how long the launch of the process is held waiting for an attach
+	AttachTimeout time.Duration `vic:"0.1" scope:"read-only" key:"attachtimeout"`
+
+	// closed to release the process while its launch is held waiting for an attach
+	unblock chan struct{}
+
 	// Allocate a tty or not
 	Tty bool `vic:"0.1" scope:"read-only" key:"tty"`
 
@@ -92,8 +102,17 @@ type SessionConfig struct {
 	StopSignal string `vic:"0.1" scope:"read-only" key:"stopSignal"`
 
 	// User and group for setuid programs
-	User  string `vic:"0.1" scope:"read-only" key:"user"`
-	Group string `vic:"0.1" scope:"read-only" key:"group"`
+	User  string `vic:"0.1" scope:"read-only" key:"User"`
+	Group string `vic:"0.1" scope:"read-only" key:"Group"`
+
+	// Healthcheck is the probe run to check the health of the process
+	Healthcheck executor.HealthConfig `vic:"0.1" scope:"read-only" key:"healthcheck"`
+
+	// Health holds the results of the health probes
+	Health executor.Health `vic:"0.1" scope:"read-write" key:"health"`
+
+	// closed to stop the health probes when the process exits
+	healthStop chan struct{}
 
 	// if there's a pty then we need additional management data
 	Pty       *os.File
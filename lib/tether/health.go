@@ -0,0 +1,244 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tether
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/vic/lib/config/executor"
+	"github.com/vmware/vic/pkg/trace"
+	"github.com/vmware/vic/pkg/vsphere/extraconfig"
+)
+
+const (
+	// MaxHealthLogEntries is the number of probe results kept in the health log of a session
+	MaxHealthLogEntries = 5
+
+	// the number of bytes of probe output kept for each result
+	maxHealthOutput = 4096
+
+	// the docker defaults for a health check that does not specify them
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 30 * time.Second
+	defaultHealthRetries  = 3
+)
+
+// probeArgs returns the command line of the health probe given its test, or nil if the test
+// does not call for a probe
+func probeArgs(test []string) ([]string, error) {
+	if len(test) == 0 {
+		return nil, nil
+	}
+
+	switch test[0] {
+	case "NONE":
+		return nil, nil
+	case "CMD":
+		if len(test) < 2 {
+			return nil, fmt.Errorf("health check command is empty")
+		}
+		return test[1:], nil
+	case "CMD-SHELL":
+		if len(test) < 2 {
+			return nil, fmt.Errorf("health check command is empty")
+		}
+		return []string{"/bin/sh", "-c", strings.Join(test[1:], " ")}, nil
+	}
+
+	return nil, fmt.Errorf("unknown health check type %q", test[0])
+}
+
+// recordProbe adds the result of a probe to the health of a session, updating its status
+func recordProbe(health *executor.Health, result executor.HealthLog, retries int) {
+	health.Log = append(health.Log, result)
+	if len(health.Log) > MaxHealthLogEntries {
+		health.Log = health.Log[len(health.Log)-MaxHealthLogEntries:]
+	}
+
+	if result.ExitCode == 0 {
+		health.FailingStreak = 0
+		health.Status = executor.Healthy
+		return
+	}
+
+	health.FailingStreak++
+	if health.FailingStreak >= retries {
+		health.Status = executor.Unhealthy
+	}
+}
+
+// startHealthcheck starts probing the health of the session process if the session has a
+// health check. The caller must hold the session lock.
+func (t *tether) startHealthcheck(session *SessionConfig) {
+	t.stopHealthcheck(session)
+
+	args, err := probeArgs(session.Healthcheck.Test)
+	if err != nil {
+		log.Errorf("Not probing health of session %s: %s", session.ID, err)
+		return
+	}
+	if args == nil {
+		return
+	}
+
+	hc := session.Healthcheck
+	if hc.Interval <= 0 {
+		hc.Interval = defaultHealthInterval
+	}
+	if hc.Timeout <= 0 {
+		hc.Timeout = defaultHealthTimeout
+	}
+	if hc.Retries <= 0 {
+		hc.Retries = defaultHealthRetries
+	}
+
+	session.Health = executor.Health{Status: executor.HealthStarting}
+	session.healthStop = make(chan struct{})
+
+	go t.healthcheck(session, args, hc, session.healthStop)
+}
+
+// stopHealthcheck stops probing the health of the session process. The caller must hold
+// the session lock.
+func (t *tether) stopHealthcheck(session *SessionConfig) {
+	if session.healthStop != nil {
+		close(session.healthStop)
+		session.healthStop = nil
+	}
+}
+
+// healthcheck runs the health probe of the session on its interval until stopped, recording
+// each result in the session config
+func (t *tether) healthcheck(session *SessionConfig, args []string, hc executor.HealthConfig, stop chan struct{}) {
+	defer trace.End(trace.Begin("health probes for session " + session.ID))
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(hc.Interval):
+		}
+
+		result := t.probe(session, args, hc.Timeout)
+
+		session.m.Lock()
+		select {
+		case <-stop:
+			// the process exited while it was being probed
+			session.m.Unlock()
+			return
+		default:
+		}
+
+		previous := session.Health.Status
+		recordProbe(&session.Health, result, hc.Retries)
+		if session.Health.Status != previous {
+			log.Infof("Health of session %s is now %s", session.ID, session.Health.Status)
+		}
+
+		// FIXME: we cannot have this embedded knowledge of the extraconfig encoding pattern, but not
+		// currently sure how to expose it neatly via a utility function
+		extraconfig.EncodeWithPrefix(t.sink, session, fmt.Sprintf("guestinfo.vice..sessions|%s", session.ID))
+		session.m.Unlock()
+	}
+}
+
+// probe runs the health probe once in the environment of the session process. A probe that
+// has not exited within the timeout is killed and considered to have failed.
+func (t *tether) probe(session *SessionConfig, args []string, timeout time.Duration) (result executor.HealthLog) {
+	result.Start = time.Now().UTC()
+	result.ExitCode = -1
+	defer func() {
+		result.End = time.Now().UTC()
+	}()
+
+	session.m.Lock()
+	cmd := &exec.Cmd{
+		Path:        args[0],
+		Args:        args,
+		Env:         session.Cmd.Env,
+		Dir:         session.Cmd.Dir,
+		SysProcAttr: session.Cmd.SysProcAttr,
+	}
+	session.m.Unlock()
+
+	resolved, err := lookPath(cmd.Path, cmd.Env, cmd.Dir)
+	if err != nil {
+		result.Output = fmt.Sprintf("failed to find health check command %s: %s", cmd.Path, err)
+		return result
+	}
+	cmd.Path = resolved
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		result.Output = fmt.Sprintf("failed to create health check output pipe: %s", err)
+		return result
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	// the probe is reaped by the child reaper, which sends its exit status on this channel, so
+	// the pid is registered as the probe starts, in the same way as session processes
+	exit := make(chan int, 1)
+	err = func() error {
+		t.config.pidMutex.Lock()
+		defer t.config.pidMutex.Unlock()
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		t.config.probes[cmd.Process.Pid] = exit
+		return nil
+	}()
+	w.Close()
+
+	if err != nil {
+		r.Close()
+		result.Output = fmt.Sprintf("failed to start health check: %s", err)
+		return result
+	}
+
+	output := make(chan string, 1)
+	go func() {
+		defer r.Close()
+
+		buf, _ := ioutil.ReadAll(io.LimitReader(r, maxHealthOutput))
+		// drain anything past the limit so the probe does not block on a full pipe
+		io.Copy(ioutil.Discard, r)
+		output <- string(buf)
+	}()
+
+	select {
+	case status := <-exit:
+		result.ExitCode = status
+		result.Output = <-output
+	case <-time.After(timeout):
+		if err := cmd.Process.Kill(); err != nil {
+			log.Warnf("Failed to kill health check for session %s: %s", session.ID, err)
+		}
+		result.Output = fmt.Sprintf("Health check exceeded timeout (%s)", timeout)
+	}
+
+	return result
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tether
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vic/lib/config/executor"
+)
+
+func TestProbeArgs(t *testing.T) {
+	args, err := probeArgs(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, args)
+
+	args, err = probeArgs([]string{"NONE"})
+	assert.NoError(t, err)
+	assert.Nil(t, args)
+
+	args, err = probeArgs([]string{"CMD", "curl", "-f", "http://localhost/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"curl", "-f", "http://localhost/"}, args)
+
+	args, err = probeArgs([]string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/bin/sh", "-c", "curl -f http://localhost/ || exit 1"}, args)
+
+	for _, test := range [][]string{{"CMD"}, {"CMD-SHELL"}, {"BOGUS", "true"}} {
+		_, err = probeArgs(test)
+		assert.Error(t, err, "%v", test)
+	}
+}
+
+func TestRecordProbe(t *testing.T) {
+	health := executor.Health{Status: executor.HealthStarting}
+	pass := executor.HealthLog{ExitCode: 0}
+	fail := executor.HealthLog{ExitCode: 1}
+
+	// failures below the retry count leave the status alone
+	recordProbe(&health, fail, 2)
+	assert.Equal(t, executor.HealthStarting, health.Status)
+	assert.Equal(t, 1, health.FailingStreak)
+
+	recordProbe(&health, fail, 2)
+	assert.Equal(t, executor.Unhealthy, health.Status)
+	assert.Equal(t, 2, health.FailingStreak)
+
+	recordProbe(&health, pass, 2)
+	assert.Equal(t, executor.Healthy, health.Status)
+	assert.Equal(t, 0, health.FailingStreak)
+
+	recordProbe(&health, fail, 2)
+	assert.Equal(t, executor.Healthy, health.Status)
+
+	// only the most recent results are kept
+	for i := 0; i < 2*MaxHealthLogEntries; i++ {
+		recordProbe(&health, executor.HealthLog{ExitCode: i}, 100)
+	}
+	if assert.Len(t, health.Log, MaxHealthLogEntries) {
+		assert.Equal(t, 2*MaxHealthLogEntries-1, health.Log[MaxHealthLogEntries-1].ExitCode)
+	}
+}
@@ -33,10 +33,12 @@ type Operations interface {
 
 	SetHostname(hostname string, aliases ...string) error
 	Apply(endpoint *NetworkEndpoint) error
+	Remove(endpoint *NetworkEndpoint) error
 	MountLabel(ctx context.Context, label, target string) error
 	Fork() error
 
-	SessionLog(session *SessionConfig) (dio.DynamicMultiWriter, error)
+	// SessionLog returns the writers for the standard output and standard error of the session
+	SessionLog(session *SessionConfig) (dio.DynamicMultiWriter, dio.DynamicMultiWriter, error)
 	// Returns a function to invoke after the session state has been persisted
 	HandleSessionExit(config *ExecutorConfig, session *SessionConfig) func()
 	ProcessEnv(env []string) []string
@@ -155,3 +155,67 @@ func TestSetIpAddress(t *testing.T) {
 
 	assert.Equal(t, 1, len(eIface.Addrs), "Expected one address on external interface")
 }
+
+func TestRemoveEndpoint(t *testing.T) {
+	_, mocker := testSetup(t)
+
+	hFile, err := ioutil.TempFile("", "vic_remove_endpoint_test_hosts")
+	if err != nil {
+		t.Errorf("Failed to create tmp hosts file: %s", err)
+	}
+	rFile, err := ioutil.TempFile("", "vic_remove_endpoint_test_resolv")
+	if err != nil {
+		t.Errorf("Failed to create tmp resolv file: %s", err)
+	}
+
+	defer func(hosts etcconf.Hosts, resolv etcconf.ResolvConf) {
+		Sys.Hosts = hosts
+		Sys.ResolvConf = resolv
+	}(Sys.Hosts, Sys.ResolvConf)
+
+	Sys.Hosts = etcconf.NewHosts(hFile.Name())
+	Sys.ResolvConf = etcconf.NewResolvConf(rFile.Name())
+
+	mocker.Base.dynEndpoints = make(map[string][]*NetworkEndpoint)
+	mocker.Base.dhcpLoops = make(map[string]chan bool)
+
+	bridge := AddInterface("eth1", mocker)
+
+	firstIP, _ := netlink.ParseIPNet("172.16.0.10/24")
+	secondIP, _ := netlink.ParseIPNet("172.17.0.10/24")
+	endpoint := func(name string, ip *net.IPNet) *NetworkEndpoint {
+		return &NetworkEndpoint{
+			Common: executor.Common{
+				ID: bridge,
+			},
+			Network: executor.ContainerNetwork{
+				Common: executor.Common{
+					Name: name,
+				},
+			},
+			Static: true,
+			IP:     ip,
+		}
+	}
+
+	// both networks share the NIC
+	first := endpoint("first", firstIP)
+	second := endpoint("second", secondIP)
+	assert.NoError(t, mocker.Apply(first))
+	assert.NoError(t, mocker.Apply(second))
+
+	iface, _ := mocker.Interfaces["eth1"].(*Interface)
+	assert.Equal(t, 2, len(iface.Addrs), "Expected two addresses on shared interface")
+
+	assert.NoError(t, mocker.Remove(second))
+
+	assert.Equal(t, 1, len(iface.Addrs), "Expected one address after removing an endpoint")
+	assert.Equal(t, firstIP.IP.String(), iface.Addrs[0].IP.String())
+	assert.Nil(t, Sys.Hosts.HostIP("second.localhost"), "Expected hosts entry to be removed")
+	assert.NotNil(t, Sys.Hosts.HostIP("first.localhost"), "Expected hosts entry to be retained")
+
+	// the link is gone when the NIC has been removed
+	delete(mocker.Interfaces, "eth1")
+	assert.NoError(t, mocker.Remove(first))
+	assert.Nil(t, Sys.Hosts.HostIP("first.localhost"), "Expected hosts entry to be removed")
+}
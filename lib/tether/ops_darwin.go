@@ -19,13 +19,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/user"
-	"strconv"
 	"strings"
 	"syscall"
 
-	log "github.com/Sirupsen/logrus"
-
 	"github.com/vmware/vic/pkg/trace"
 )
 
@@ -45,6 +41,13 @@ func (t *BaseOperations) Apply(endpoint *NetworkEndpoint) error {
 	return errors.New("not implemented on OSX")
 }
 
+// Remove tears down the configuration applied for the network endpoint
+func (t *BaseOperations) Remove(endpoint *NetworkEndpoint) error {
+	defer trace.End(trace.Begin("removing endpoint configuration for " + endpoint.Network.Name))
+
+	return errors.New("not implemented on OSX")
+}
+
 // MountLabel performs a mount with the source treated as a disk label
 // This assumes that /dev/disk/by-label is being populated, probably by udev
 func (t *BaseOperations) MountLabel(ctx context.Context, label, target string) error {
@@ -87,24 +90,6 @@ func (t *BaseOperations) Cleanup() error {
 	return nil
 }
 
-// Need to put this here because Windows does not
-// support SysProcAttr.Credential
-func getUserSysProcAttr(uname string) *syscall.SysProcAttr {
-	uinfo, err := user.Lookup(uname)
-	if err != nil {
-		detail := fmt.Sprintf("Unable to find user %s: %s", uname, err)
-		log.Error(detail)
-		return nil
-	} else {
-		u, _ := strconv.Atoi(uinfo.Uid)
-		g, _ := strconv.Atoi(uinfo.Gid)
-		// Unfortunately lookup GID by name is currently unsupported in Go.
-		return &syscall.SysProcAttr{
-			Credential: &syscall.Credential{
-				Uid: uint32(u),
-				Gid: uint32(g),
-			},
-			Setsid: true,
-		}
-	}
+func getUserSysProcAttr(uname, gname string) (*syscall.SysProcAttr, string, error) {
+	return nil, "", errors.New("not implemented on OSX")
 }
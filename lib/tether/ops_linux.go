@@ -21,7 +21,6 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
-	"os/user"
 	"path"
 	"path/filepath"
 	"strconv"
@@ -30,6 +29,7 @@ import (
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/opencontainers/runc/libcontainer/user"
 	"github.com/vishvananda/netlink"
 
 	"github.com/vmware/vic/lib/dhcp"
@@ -42,6 +42,8 @@ import (
 var (
 	hostnameFile = "/etc/hostname"
 	byLabelDir   = "/dev/disk/by-label"
+	passwdPath   = "/etc/passwd"
+	groupPath    = "/etc/group"
 )
 
 const (
@@ -529,6 +531,70 @@ func apply(nl Netlink, t *BaseOperations, endpoint *NetworkEndpoint) error {
 	return nil
 }
 
+// Remove tears down the configuration applied for the network endpoint, used when the
+// container has been disconnected from its network
+func (t *BaseOperations) Remove(endpoint *NetworkEndpoint) error {
+	defer trace.End(trace.Begin("removing endpoint configuration for " + endpoint.Network.Name))
+
+	return remove(t, t, endpoint)
+}
+
+func remove(nl Netlink, t *BaseOperations, endpoint *NetworkEndpoint) error {
+	// a dynamic address is shared by the endpoints on the same NIC so it is only
+	// released along with the last of them
+	var shared []*NetworkEndpoint
+	for _, e := range t.dynEndpoints[endpoint.ID] {
+		if e != endpoint {
+			shared = append(shared, e)
+		}
+	}
+
+	if len(shared) > 0 {
+		t.dynEndpoints[endpoint.ID] = shared
+	} else {
+		delete(t.dynEndpoints, endpoint.ID)
+
+		if stop, ok := t.dhcpLoops[endpoint.ID]; ok {
+			stop <- true
+			delete(t.dhcpLoops, endpoint.ID)
+		}
+	}
+
+	// the link is gone if the NIC was removed along with the network, in which
+	// case the kernel has already dropped its addresses and routes
+	slot, err := strconv.Atoi(endpoint.ID)
+	if err != nil {
+		detail := fmt.Sprintf("endpoint ID must be a base10 numeric pci slot identifier: %s", err)
+		return errors.New(detail)
+	}
+
+	if len(shared) == 0 && !ip.IsUnspecifiedIP(endpoint.Assigned.IP) {
+		if link, err := nl.LinkBySlot(int32(slot)); err == nil {
+			log.Infof("removing ip address %s from link %s", &endpoint.Assigned, link.Attrs().Name)
+			if err = nl.AddrDel(link, &netlink.Addr{IPNet: &endpoint.Assigned}); err != nil {
+				if errno, ok := err.(syscall.Errno); !ok || errno != syscall.EADDRNOTAVAIL {
+					return fmt.Errorf("failed to remove address %s from link %s: %s", &endpoint.Assigned, link.Attrs().Name, err)
+				}
+			}
+		}
+	}
+
+	if endpoint.Network.Name != "" {
+		Sys.Hosts.RemoveHost(fmt.Sprintf("%s.localhost", endpoint.Network.Name))
+		if err = Sys.Hosts.Save(); err != nil {
+			return err
+		}
+	}
+
+	// the nameservers of the remaining networks are added back when they are applied
+	Sys.ResolvConf.RemoveNameservers(endpoint.Network.Nameservers...)
+	if len(endpoint.Network.Nameservers) == 0 && !ip.IsUnspecifiedIP(endpoint.Network.Gateway.IP) {
+		Sys.ResolvConf.RemoveNameservers(endpoint.Network.Gateway.IP)
+	}
+
+	return Sys.ResolvConf.Save()
+}
+
 func (t *BaseOperations) dhcpLoop(stop chan bool, e *NetworkEndpoint, ack *dhcp.Packet, id client.ID) {
 	exp := time.After(ack.LeaseTime() / 2)
 	for {
@@ -713,24 +779,41 @@ func (t *BaseOperations) Cleanup() error {
 	return nil
 }
 
-// Need to put this here because Windows does not
-// support SysProcAttr.Credential
-func getUserSysProcAttr(uname string) *syscall.SysProcAttr {
-	uinfo, err := user.Lookup(uname)
+// getUserSysProcAttr resolves the user and group, each a name or an id, against the passwd
+// and group files of the container. It returns the attributes to run a process as that user,
+// with the supplementary groups of the user, along with the home directory of the user.
+// Need to put this here because Windows does not support SysProcAttr.Credential
+func getUserSysProcAttr(uname, gname string) (*syscall.SysProcAttr, string, error) {
+	spec := uname
+	if gname != "" && !strings.Contains(uname, ":") {
+		spec = fmt.Sprintf("%s:%s", uname, gname)
+	}
+
+	// anything that is not specified is as for root
+	defaults := &user.ExecUser{
+		Uid:  0,
+		Gid:  0,
+		Home: "/root",
+	}
+
+	u, err := user.GetExecUserPath(spec, defaults, passwdPath, groupPath)
 	if err != nil {
-		detail := fmt.Sprintf("Unable to find user %s: %s", uname, err)
-		log.Error(detail)
-		return nil
-	} else {
-		u, _ := strconv.Atoi(uinfo.Uid)
-		g, _ := strconv.Atoi(uinfo.Gid)
-		// Unfortunately lookup GID by name is currently unsupported in Go.
-		return &syscall.SysProcAttr{
-			Credential: &syscall.Credential{
-				Uid: uint32(u),
-				Gid: uint32(g),
-			},
-			Setsid: true,
-		}
+		return nil, "", fmt.Errorf("unable to find user %s: %s", spec, err)
 	}
+
+	groups := make([]uint32, len(u.Sgids))
+	for i, g := range u.Sgids {
+		groups[i] = uint32(g)
+	}
+
+	attr := &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid:    uint32(u.Uid),
+			Gid:    uint32(u.Gid),
+			Groups: groups,
+		},
+		Setsid: true,
+	}
+
+	return attr, u.Home, nil
 }
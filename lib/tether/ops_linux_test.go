@@ -19,7 +19,10 @@ package tether
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path"
+	"reflect"
 	"syscall"
 	"testing"
 
@@ -180,3 +183,68 @@ func TestSlotToPciPath(t *testing.T) {
 		}
 	}
 }
+
+func TestGetUserSysProcAttr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tether-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	passwd := "root:x:0:0:root:/root:/bin/sh\nnginx:x:101:102:nginx:/var/cache/nginx:/sbin/nologin\n"
+	group := "root:x:0:\nnginx:x:102:\nwww:x:33:nginx\nadm:x:4:root,nginx\n"
+
+	oldPasswd, oldGroup := passwdPath, groupPath
+	defer func() {
+		passwdPath, groupPath = oldPasswd, oldGroup
+	}()
+
+	passwdPath = path.Join(dir, "passwd")
+	groupPath = path.Join(dir, "group")
+	if err = ioutil.WriteFile(passwdPath, []byte(passwd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(groupPath, []byte(group), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		user   string
+		group  string
+		uid    uint32
+		gid    uint32
+		groups []uint32
+		home   string
+		err    bool
+	}{
+		{"nginx", "", 101, 102, []uint32{33, 4}, "/var/cache/nginx", false},
+		// a group that is given replaces the supplementary groups of the user
+		{"nginx", "www", 101, 33, []uint32{}, "/var/cache/nginx", false},
+		{"101:4", "", 101, 4, []uint32{}, "/var/cache/nginx", false},
+		// ids that are not in the files are used as they are
+		{"1000:1000", "", 1000, 1000, []uint32{}, "/root", false},
+		{"", "www", 0, 33, []uint32{}, "/root", false},
+		{"apache", "", 0, 0, nil, "", true},
+		{"nginx", "apache", 0, 0, nil, "", true},
+	}
+
+	for _, te := range tests {
+		attr, home, err := getUserSysProcAttr(te.user, te.group)
+		if te.err {
+			if err == nil {
+				t.Fatalf("getUserSysProcAttr(%q, %q) => (%#v, %q, nil), want error", te.user, te.group, attr, home)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("getUserSysProcAttr(%q, %q) => error %s", te.user, te.group, err)
+		}
+
+		c := attr.Credential
+		if c.Uid != te.uid || c.Gid != te.gid || home != te.home || !reflect.DeepEqual(c.Groups, te.groups) {
+			t.Fatalf("getUserSysProcAttr(%q, %q) => (%d:%d %v, %q), want (%d:%d %v, %q)", te.user, te.group, c.Uid, c.Gid, c.Groups, home, te.uid, te.gid, te.groups, te.home)
+		}
+	}
+}
@@ -42,6 +42,13 @@ func (t *BaseOperations) Apply(endpoint *NetworkEndpoint) error {
 	return errors.New("not implemented on windows")
 }
 
+// Remove tears down the configuration applied for the network endpoint
+func (t *BaseOperations) Remove(endpoint *NetworkEndpoint) error {
+	defer trace.End(trace.Begin("removing endpoint configuration for " + endpoint.Network.Name))
+
+	return errors.New("not implemented on windows")
+}
+
 // MountLabel performs a mount with the source treated as a disk label
 // This assumes that /dev/disk/by-label is being populated, probably by udev
 func (t *BaseOperations) MountLabel(ctx context.Context, label, target string) error {
@@ -73,6 +80,6 @@ func (t *BaseOperations) Cleanup() error {
 }
 
 // Uid/Gid is not supported in Windows
-func getUserSysProcAttr(uname string) *syscall.SysProcAttr {
-	return nil
+func getUserSysProcAttr(uname, gname string) (*syscall.SysProcAttr, string, error) {
+	return nil, "", nil
 }
@@ -0,0 +1,339 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tether
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmware/vic/cmd/tether/msgs"
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// process is an entry in the process table of the container
+type process struct {
+	pid  int
+	ppid int
+	uid  int
+	user string
+
+	// single character state, e.g. R or S
+	state string
+	// BSD style state with modifiers, e.g. Ss
+	stat string
+	tty  string
+
+	comm string
+	args string
+
+	start time.Time
+	// cumulative user and system time
+	cpu time.Duration
+
+	// KB
+	vsz uint64
+	rss uint64
+
+	pcpu float64
+	pmem float64
+}
+
+// column is a column of the formatted process table
+type column struct {
+	title string
+	value func(p *process, now time.Time) string
+}
+
+func pidColumn(p *process, _ time.Time) string  { return strconv.Itoa(p.pid) }
+func ppidColumn(p *process, _ time.Time) string { return strconv.Itoa(p.ppid) }
+func uidColumn(p *process, _ time.Time) string  { return strconv.Itoa(p.uid) }
+func userColumn(p *process, _ time.Time) string { return p.user }
+func statColumn(p *process, _ time.Time) string { return p.stat }
+func ttyColumn(p *process, _ time.Time) string  { return p.tty }
+func commColumn(p *process, _ time.Time) string { return p.comm }
+func argsColumn(p *process, _ time.Time) string { return p.args }
+func timeColumn(p *process, _ time.Time) string { return formatDuration(p.cpu) }
+func vszColumn(p *process, _ time.Time) string  { return strconv.FormatUint(p.vsz, 10) }
+func rssColumn(p *process, _ time.Time) string  { return strconv.FormatUint(p.rss, 10) }
+
+func stateColumn(p *process, _ time.Time) string { return p.state }
+
+func pcpuColumn(p *process, _ time.Time) string {
+	return strconv.FormatFloat(p.pcpu, 'f', 1, 64)
+}
+
+func pmemColumn(p *process, _ time.Time) string {
+	return strconv.FormatFloat(p.pmem, 'f', 1, 64)
+}
+
+func cColumn(p *process, _ time.Time) string {
+	return strconv.Itoa(int(p.pcpu))
+}
+
+func startColumn(p *process, now time.Time) string {
+	return formatStart(p.start, now)
+}
+
+func etimeColumn(p *process, now time.Time) string {
+	return formatDuration(now.Sub(p.start))
+}
+
+// columns are the ps -o format specifiers that are supported
+var columns = map[string]column{
+	"pid":     {"PID", pidColumn},
+	"ppid":    {"PPID", ppidColumn},
+	"uid":     {"UID", uidColumn},
+	"user":    {"USER", userColumn},
+	"s":       {"S", stateColumn},
+	"state":   {"S", stateColumn},
+	"stat":    {"STAT", statColumn},
+	"tty":     {"TT", ttyColumn},
+	"comm":    {"COMMAND", commColumn},
+	"args":    {"COMMAND", argsColumn},
+	"cmd":     {"CMD", argsColumn},
+	"command": {"COMMAND", argsColumn},
+	"time":    {"TIME", timeColumn},
+	"etime":   {"ELAPSED", etimeColumn},
+	"c":       {"C", cColumn},
+	"pcpu":    {"%CPU", pcpuColumn},
+	"%cpu":    {"%CPU", pcpuColumn},
+	"pmem":    {"%MEM", pmemColumn},
+	"%mem":    {"%MEM", pmemColumn},
+	"vsz":     {"VSZ", vszColumn},
+	"rss":     {"RSS", rssColumn},
+	"stime":   {"STIME", startColumn},
+	"start":   {"START", startColumn},
+}
+
+var (
+	// ps -e
+	defaultFormat = []column{
+		{"PID", pidColumn},
+		{"TTY", ttyColumn},
+		{"TIME", timeColumn},
+		{"CMD", commColumn},
+	}
+
+	// ps -ef, the docker top default
+	fullFormat = []column{
+		{"UID", userColumn},
+		{"PID", pidColumn},
+		{"PPID", ppidColumn},
+		{"C", cColumn},
+		{"STIME", startColumn},
+		{"TTY", ttyColumn},
+		{"TIME", timeColumn},
+		{"CMD", argsColumn},
+	}
+
+	// ps ax
+	bsdFormat = []column{
+		{"PID", pidColumn},
+		{"TTY", ttyColumn},
+		{"STAT", statColumn},
+		{"TIME", timeColumn},
+		{"COMMAND", argsColumn},
+	}
+
+	// ps aux
+	userFormat = []column{
+		{"USER", userColumn},
+		{"PID", pidColumn},
+		{"%CPU", pcpuColumn},
+		{"%MEM", pmemColumn},
+		{"VSZ", vszColumn},
+		{"RSS", rssColumn},
+		{"TTY", ttyColumn},
+		{"STAT", statColumn},
+		{"START", startColumn},
+		{"TIME", timeColumn},
+		{"COMMAND", argsColumn},
+	}
+)
+
+// psFormat returns the columns selected by the ps arguments. Every process in
+// the container is always listed so the process selection options are accepted
+// but have no effect.
+func psFormat(args string) ([]column, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return fullFormat, nil
+	}
+
+	var full, bsd, user bool
+	var custom []column
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		// BSD options have no dash, though procps also accepts them with one
+		if !strings.HasPrefix(field, "-") || strings.ContainsAny(field, "ux") && !strings.ContainsAny(field, "efo") {
+			for _, c := range strings.TrimPrefix(field, "-") {
+				switch c {
+				case 'a', 'x', 'w':
+					bsd = true
+				case 'u':
+					bsd = true
+					user = true
+				default:
+					return nil, fmt.Errorf("unsupported ps option: %c", c)
+				}
+			}
+			continue
+		}
+
+		flags := field[1:]
+		for j, c := range flags {
+			switch c {
+			case 'e', 'A', 'a', 'd', 'w':
+			case 'f':
+				full = true
+			case 'o':
+				// the format list is the rest of the field or the next field
+				list := flags[j+1:]
+				if list == "" {
+					i++
+					if i == len(fields) {
+						return nil, fmt.Errorf("ps option -o requires a format list")
+					}
+					list = fields[i]
+				}
+
+				for _, spec := range strings.Split(list, ",") {
+					col, ok := columns[strings.ToLower(spec)]
+					if !ok {
+						return nil, fmt.Errorf("unsupported ps format specifier: %s", spec)
+					}
+					custom = append(custom, col)
+				}
+			default:
+				return nil, fmt.Errorf("unsupported ps option: -%c", c)
+			}
+
+			if c == 'o' {
+				break
+			}
+		}
+	}
+
+	switch {
+	case custom != nil:
+		return custom, nil
+	case user:
+		return userFormat, nil
+	case bsd:
+		return bsdFormat, nil
+	case full:
+		return fullFormat, nil
+	default:
+		return defaultFormat, nil
+	}
+}
+
+// formatDuration returns the duration as [DD-]HH:MM:SS
+func formatDuration(d time.Duration) string {
+	secs := int64(d / time.Second)
+	days := secs / 86400
+	hms := fmt.Sprintf("%02d:%02d:%02d", secs%86400/3600, secs%3600/60, secs%60)
+	if days > 0 {
+		return fmt.Sprintf("%d-%s", days, hms)
+	}
+	return hms
+}
+
+// formatStart returns the start time as the time of day if it is today, the
+// date if it is this year and the year otherwise
+func formatStart(start, now time.Time) string {
+	switch {
+	case now.Sub(start) < 24*time.Hour && start.Day() == now.Day():
+		return start.Format("15:04")
+	case start.Year() == now.Year():
+		return start.Format("Jan02")
+	default:
+		return start.Format("2006")
+	}
+}
+
+// ProcessList is a tether extension that lists the processes in the container
+type ProcessList struct{}
+
+// NewProcessList returns a tether.Extension that lists the process table
+func NewProcessList() *ProcessList {
+	return &ProcessList{}
+}
+
+// Start implementation of the tether.Extension interface
+func (p *ProcessList) Start() error {
+	return nil
+}
+
+// Stop implementation of the tether.Extension interface
+func (p *ProcessList) Stop() error {
+	return nil
+}
+
+// Reload implementation of the tether.Extension interface
+func (p *ProcessList) Reload(config *ExecutorConfig) error {
+	return nil
+}
+
+// List returns the process table of the container formatted as for the ps arguments
+func (p *ProcessList) List(args string) ([]string, [][]string, error) {
+	defer trace.End(trace.Begin(args))
+
+	format, err := psFormat(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	titles := make([]string, len(format))
+	for i := range format {
+		titles[i] = format[i].title
+	}
+
+	now := time.Now()
+	rows := make([][]string, len(procs))
+	for i, proc := range procs {
+		rows[i] = make([]string, len(format))
+		for j := range format {
+			rows[i][j] = format[j].value(proc, now)
+		}
+	}
+
+	return titles, rows, nil
+}
+
+// Handle answers a msgs.ProcessListReq
+func (p *ProcessList) Handle(payload []byte) ([]byte, error) {
+	msg := msgs.ProcessListMsg{}
+	if err := msg.Unmarshal(payload); err != nil {
+		return nil, err
+	}
+
+	titles, rows, err := p.List(msg.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	table := msgs.ProcessTableMsg{Titles: titles, Processes: rows}
+	return table.Marshal(), nil
+}
@@ -0,0 +1,319 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tether
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	procRoot = "/proc"
+
+	// USER_HZ, the unit of the times in /proc/[pid]/stat
+	clockTicks = 100
+
+	// PF_KTHREAD from linux/sched.h
+	kernelThreadFlag = 0x00200000
+)
+
+// procStat holds the fields of /proc/[pid]/stat that are used
+type procStat struct {
+	comm    string
+	state   string
+	ppid    int
+	pgrp    int
+	session int
+	ttyNr   int
+	tpgid   int
+	flags   uint64
+	utime   uint64
+	stime   uint64
+	nice    int
+	threads int
+	start   uint64
+	vsize   uint64
+	rss     uint64
+}
+
+// parseStat parses the content of /proc/[pid]/stat
+func parseStat(data string) (*procStat, error) {
+	// comm may contain spaces and parentheses so is delimited by the first ( and last )
+	open := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("malformed process stat: %q", data)
+	}
+
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed process stat: %q", data)
+	}
+
+	s := &procStat{
+		comm:  data[open+1 : end],
+		state: fields[0],
+	}
+
+	var err error
+	ints := []*int{&s.ppid, &s.pgrp, &s.session, &s.ttyNr, &s.tpgid}
+	for i, p := range ints {
+		if *p, err = strconv.Atoi(fields[1+i]); err != nil {
+			return nil, err
+		}
+	}
+
+	uints := map[int]*uint64{6: &s.flags, 11: &s.utime, 12: &s.stime, 19: &s.start, 20: &s.vsize, 21: &s.rss}
+	for i, p := range uints {
+		if *p, err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.nice, err = strconv.Atoi(fields[16]); err != nil {
+		return nil, err
+	}
+
+	if s.threads, err = strconv.Atoi(fields[17]); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ttyName returns the name of the controlling terminal from its device number
+func ttyName(nr int) string {
+	major := (nr >> 8) & 0xfff
+	minor := (nr & 0xff) | ((nr >> 12) & 0xfff00)
+
+	switch {
+	case nr == 0:
+		return "?"
+	case major >= 136 && major <= 143:
+		return fmt.Sprintf("pts/%d", minor+(major-136)*256)
+	case major == 4 && minor < 64:
+		return fmt.Sprintf("tty%d", minor)
+	case major == 4:
+		return fmt.Sprintf("ttyS%d", minor-64)
+	default:
+		return "?"
+	}
+}
+
+// bsdStat returns the BSD style state with modifiers
+func bsdStat(pid int, s *procStat) string {
+	stat := s.state
+	if s.nice < 0 {
+		stat += "<"
+	} else if s.nice > 0 {
+		stat += "N"
+	}
+	if pid == s.session {
+		stat += "s"
+	}
+	if s.threads > 1 {
+		stat += "l"
+	}
+	if s.pgrp == s.tpgid {
+		stat += "+"
+	}
+	return stat
+}
+
+// readKey returns the first field of the line in the file starting with key
+func readKey(path, key string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, key) {
+			fields := strings.Fields(line[len(key):])
+			if len(fields) > 0 {
+				return fields[0], nil
+			}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("%s not found in %s", key, path)
+}
+
+// listProcesses returns the processes in the container. Kernel threads and the
+// tether itself are not included.
+func listProcesses() ([]*process, error) {
+	btime, err := readKey(filepath.Join(procRoot, "stat"), "btime")
+	if err != nil {
+		return nil, err
+	}
+
+	boot, err := strconv.ParseInt(btime, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	memTotal := uint64(0)
+	if total, err := readKey(filepath.Join(procRoot, "meminfo"), "MemTotal:"); err == nil {
+		memTotal, _ = strconv.ParseUint(total, 10, 64)
+	}
+
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	self := os.Getpid()
+	pageKB := uint64(os.Getpagesize() / 1024)
+	users := make(map[int]string)
+
+	var procs []*process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		proc, err := readProcess(pid)
+		if err != nil {
+			// the process may have exited since the directory was read
+			if !os.IsNotExist(err) {
+				log.Warnf("Unable to read process %d: %s", pid, err)
+			}
+			continue
+		}
+
+		if proc == nil {
+			continue
+		}
+
+		proc.start = time.Unix(boot, 0).Add(time.Duration(proc.stat.start) * time.Second / clockTicks)
+		proc.cpu = time.Duration(proc.stat.utime+proc.stat.stime) * time.Second / clockTicks
+		proc.vsz = proc.stat.vsize / 1024
+		proc.rss = proc.stat.rss * pageKB
+
+		if elapsed := now.Sub(proc.start); elapsed > 0 {
+			proc.pcpu = float64(proc.cpu) / float64(elapsed) * 100
+		}
+		if memTotal > 0 {
+			proc.pmem = float64(proc.rss) / float64(memTotal) * 100
+		}
+
+		name, ok := users[proc.uid]
+		if !ok {
+			name = strconv.Itoa(proc.uid)
+			if u, err := user.LookupId(name); err == nil {
+				name = u.Username
+			}
+			users[proc.uid] = name
+		}
+		proc.user = name
+
+		procs = append(procs, &proc.process)
+	}
+
+	sort.Sort(byPid(procs))
+	return procs, nil
+}
+
+type procEntry struct {
+	process
+	stat *procStat
+}
+
+// readProcess reads the details of the process from procfs, returning nil for kernel threads
+func readProcess(pid int) (*procEntry, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := parseStat(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if stat.flags&kernelThreadFlag != 0 {
+		return nil, nil
+	}
+
+	// the effective uid is the second field
+	uids, err := ioutil.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return nil, err
+	}
+
+	uid := 0
+	for _, line := range strings.Split(string(uids), "\n") {
+		if fields := strings.Fields(line); len(fields) > 2 && fields[0] == "Uid:" {
+			uid, _ = strconv.Atoi(fields[2])
+			break
+		}
+	}
+
+	cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+
+	args := string(bytes.Join(bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0}), []byte{' '}))
+	if args == "" {
+		// zombies have no command line
+		args = fmt.Sprintf("[%s]", stat.comm)
+		if stat.state == "Z" {
+			args += " <defunct>"
+		}
+	}
+
+	return &procEntry{
+		process: process{
+			pid:   pid,
+			ppid:  stat.ppid,
+			uid:   uid,
+			state: stat.state,
+			stat:  bsdStat(pid, stat),
+			tty:   ttyName(stat.ttyNr),
+			comm:  stat.comm,
+			args:  args,
+		},
+		stat: stat,
+	}, nil
+}
+
+type byPid []*process
+
+func (p byPid) Len() int           { return len(p) }
+func (p byPid) Less(i, j int) bool { return p[i].pid < p[j].pid }
+func (p byPid) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
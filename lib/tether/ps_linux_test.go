@@ -0,0 +1,83 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package tether
+
+import (
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStat(t *testing.T) {
+	data := "1234 (my (odd) cmd) S 1 1234 1234 34816 1234 4194560 1066 0 0 0 150 50 0 0 20 -5 3 0 4200 12648448 400 18446744073709551615 1 1 0 0 0 0 0 0 65536 0 0 0 17 0 0 0 0 0 0"
+
+	stat, err := parseStat(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "my (odd) cmd", stat.comm)
+	assert.Equal(t, "S", stat.state)
+	assert.Equal(t, 1, stat.ppid)
+	assert.Equal(t, 34816, stat.ttyNr)
+	assert.Equal(t, uint64(150), stat.utime)
+	assert.Equal(t, uint64(50), stat.stime)
+	assert.Equal(t, uint64(4200), stat.start)
+	assert.Equal(t, uint64(400), stat.rss)
+
+	assert.Equal(t, "pts/0", ttyName(stat.ttyNr))
+	assert.Equal(t, "S<sl+", bsdStat(1234, stat))
+
+	_, err = parseStat("1234 S 1")
+	assert.Error(t, err)
+}
+
+func TestTTYName(t *testing.T) {
+	assert.Equal(t, "?", ttyName(0))
+	assert.Equal(t, "tty1", ttyName(4<<8|1))
+	assert.Equal(t, "ttyS0", ttyName(4<<8|64))
+	assert.Equal(t, "pts/3", ttyName(136<<8|3))
+}
+
+func TestProcessList(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start process: %s", err)
+	}
+	defer cmd.Process.Kill()
+
+	titles, rows, err := NewProcessList().List("-o pid,ppid,args")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"PID", "PPID", "COMMAND"}, titles)
+
+	found := false
+	for _, row := range rows {
+		if row[0] == strconv.Itoa(cmd.Process.Pid) {
+			found = true
+			assert.Equal(t, "sleep 30", row[2])
+		}
+	}
+	assert.True(t, found, "Expected to find process %d", cmd.Process.Pid)
+
+	_, _, err = NewProcessList().List("-z")
+	assert.Error(t, err)
+}
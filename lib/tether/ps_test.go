@@ -0,0 +1,69 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tether
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func titles(t *testing.T, args string) []string {
+	format, err := psFormat(args)
+	if !assert.NoError(t, err, "ps %s", args) {
+		return nil
+	}
+
+	var titles []string
+	for _, c := range format {
+		titles = append(titles, c.title)
+	}
+	return titles
+}
+
+func TestPsFormat(t *testing.T) {
+	full := []string{"UID", "PID", "PPID", "C", "STIME", "TTY", "TIME", "CMD"}
+	user := []string{"USER", "PID", "%CPU", "%MEM", "VSZ", "RSS", "TTY", "STAT", "START", "TIME", "COMMAND"}
+
+	assert.Equal(t, full, titles(t, ""))
+	assert.Equal(t, full, titles(t, "-ef"))
+	assert.Equal(t, full, titles(t, "-e -f"))
+	assert.Equal(t, []string{"PID", "TTY", "TIME", "CMD"}, titles(t, "-e"))
+	assert.Equal(t, user, titles(t, "aux"))
+	assert.Equal(t, user, titles(t, "-aux"))
+	assert.Equal(t, []string{"PID", "TTY", "STAT", "TIME", "COMMAND"}, titles(t, "ax"))
+	assert.Equal(t, []string{"PID", "USER", "COMMAND"}, titles(t, "-o pid,user,args"))
+	assert.Equal(t, []string{"PID", "%CPU"}, titles(t, "-eopid,pcpu"))
+
+	for _, args := range []string{"-ez", "-o", "-o pid,bogus", "auxq"} {
+		_, err := psFormat(args)
+		assert.Error(t, err, "ps %s", args)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "00:00:00", formatDuration(0))
+	assert.Equal(t, "01:02:03", formatDuration(time.Hour+2*time.Minute+3*time.Second))
+	assert.Equal(t, "2-00:00:01", formatDuration(48*time.Hour+time.Second))
+}
+
+func TestFormatStart(t *testing.T) {
+	now := time.Date(2016, time.September, 6, 15, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "14:05", formatStart(now.Add(-55*time.Minute), now))
+	assert.Equal(t, "Sep05", formatStart(now.Add(-20*time.Hour), now))
+	assert.Equal(t, "2015", formatStart(now.AddDate(-1, 0, 0), now))
+}
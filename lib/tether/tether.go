@@ -26,6 +26,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"path"
+	"strings"
 	"syscall"
 	"time"
 
@@ -73,7 +74,8 @@ func New(src extraconfig.DataSource, sink extraconfig.DataSink, ops Operations)
 		ops:    ops,
 		reload: make(chan bool, 1),
 		config: &ExecutorConfig{
-			pids: make(map[int]*SessionConfig),
+			pids:   make(map[int]*SessionConfig),
+			probes: make(map[int]chan int),
 		},
 		extensions: make(map[string]Extension),
 		src:        src,
@@ -93,6 +95,17 @@ func (t *tether) removeChildPid(pid int) (*SessionConfig, bool) {
 	return session, ok
 }
 
+// removeProbePid is a synchronized accessor for the health probe pid map that deletes the entry
+// and returns the value
+func (t *tether) removeProbePid(pid int) (chan int, bool) {
+	t.config.pidMutex.Lock()
+	defer t.config.pidMutex.Unlock()
+
+	exit, ok := t.config.probes[pid]
+	delete(t.config.probes, pid)
+	return exit, ok
+}
+
 // lenChildPid returns the number of entries
 func (t *tether) lenChildPid() int {
 	t.config.pidMutex.Lock()
@@ -116,7 +129,8 @@ func (t *tether) setup() error {
 
 	t.reload = make(chan bool, 1)
 	t.config = &ExecutorConfig{
-		pids: make(map[int]*SessionConfig),
+		pids:   make(map[int]*SessionConfig),
+		probes: make(map[int]chan int),
 	}
 
 	if err := t.childReaper(); err != nil {
@@ -220,7 +234,14 @@ func (t *tether) Start() error {
 			return errors.New(detail)
 		}
 
-		// process the networks then publish any dynamic data
+		// tear down the networks the container has been disconnected from, then process
+		// the remaining networks and publish any dynamic data
+		if err := t.removeNetworks(); err != nil {
+			detail := fmt.Sprintf("failed to remove network endpoint config: %s", err)
+			log.Error(detail)
+			return errors.New(detail)
+		}
+
 		for _, v := range t.config.Networks {
 			if err := t.ops.Apply(v); err != nil {
 				detail := fmt.Sprintf("failed to apply network endpoint config: %s", err)
@@ -245,6 +266,11 @@ func (t *tether) Start() error {
 		// process the sessions and launch if needed
 		for id, session := range t.config.Sessions {
 			log.Debugf("Processing config for session %s", session.ID)
+			if session.Held() {
+				log.Debugf("Process for session %s is held waiting for attach", session.ID)
+				continue
+			}
+
 			var proc = session.Cmd.Process
 
 			// check if session is alive and well
@@ -337,6 +363,9 @@ func (t *tether) handleSessionExit(session *SessionConfig) {
 	session.Outwriter.Close()
 	session.Errwriter.Close()
 
+	// there is nothing left to probe
+	t.stopHealthcheck(session)
+
 	// Remove associated PID file
 	cmdname := path.Base(session.Cmd.Path)
 	_ = os.Remove(fmt.Sprintf("%s.pid", path.Join(PIDFileDir(), cmdname)))
@@ -356,6 +385,26 @@ func (t *tether) handleSessionExit(session *SessionConfig) {
 	}
 }
 
+// Held reports whether the launch of the session process is held waiting for an attach
+func (session *SessionConfig) Held() bool {
+	session.m.Lock()
+	defer session.m.Unlock()
+
+	return session.unblock != nil
+}
+
+// Unblock releases the session process if its launch is held waiting for an attach. It
+// is a no-op otherwise.
+func (session *SessionConfig) Unblock() {
+	session.m.Lock()
+	defer session.m.Unlock()
+
+	if session.unblock != nil {
+		close(session.unblock)
+		session.unblock = nil
+	}
+}
+
 // launch will launch the command defined in the session.
 // This will return an error if the session fails to launch
 func (t *tether) launch(session *SessionConfig) error {
@@ -369,7 +418,7 @@ func (t *tether) launch(session *SessionConfig) error {
 		extraconfig.EncodeWithPrefix(t.sink, session, fmt.Sprintf("guestinfo.vice..sessions|%s", session.ID))
 	}()
 
-	logwriter, err := t.ops.SessionLog(session)
+	stdout, stderr, err := t.ops.SessionLog(session)
 	if err != nil {
 		detail := fmt.Sprintf("failed to get log writer for session: %s", err)
 		log.Error(detail)
@@ -380,14 +429,26 @@ func (t *tether) launch(session *SessionConfig) error {
 
 	// we store these outside of the session.Cmd struct so that there's consistent
 	// handling between tty & non-tty paths
-	session.Outwriter = logwriter
-	session.Errwriter = logwriter
+	session.Outwriter = stdout
+	session.Errwriter = stderr
 	session.Reader = dio.MultiReader()
 
 	// Special case here because UID/GID lookup need to be done
 	// on the appliance...
-	if len(session.User) > 0 {
-		session.Cmd.SysProcAttr = getUserSysProcAttr(session.User)
+	if session.User != "" || session.Group != "" {
+		attr, home, err := getUserSysProcAttr(session.User, session.Group)
+		if err != nil {
+			detail := fmt.Sprintf("failed to resolve user for session: %s", err)
+			log.Error(detail)
+			session.Started = detail
+
+			return errors.New(detail)
+		}
+
+		session.Cmd.SysProcAttr = attr
+		if home != "" && !hasEnv(session.Cmd.Env, "HOME") {
+			session.Cmd.Env = append(session.Cmd.Env, "HOME="+home)
+		}
 	}
 
 	session.Cmd.Env = t.ops.ProcessEnv(session.Cmd.Env)
@@ -404,6 +465,49 @@ func (t *tether) launch(session *SessionConfig) error {
 	log.Debugf("Resolved %s to %s", session.Cmd.Path, resolved)
 	session.Cmd.Path = resolved
 
+	// hold the process until the attach is complete so that none of its output is lost, but
+	// only on first launch as nothing waits to attach to a restarted process
+	if session.Attach && session.AttachTimeout > 0 && session.Diagnostics.ResurrectionCount == 0 {
+		log.Infof("Holding launch of session %s for attach (timeout: %s)", session.ID, session.AttachTimeout)
+
+		session.unblock = make(chan struct{})
+		go t.holdLaunch(session, session.unblock, session.AttachTimeout)
+		return nil
+	}
+
+	return t.startProcess(session)
+}
+
+// holdLaunch starts the session process once an attach releases it or the timeout expires,
+// whichever occurs first
+func (t *tether) holdLaunch(session *SessionConfig, unblock chan struct{}, timeout time.Duration) {
+	defer trace.End(trace.Begin("holding launch of session " + session.ID))
+
+	select {
+	case <-unblock:
+		log.Infof("Releasing process for session %s after attach", session.ID)
+	case <-time.After(timeout):
+		log.Warnf("Timed out waiting for attach to session %s, releasing process", session.ID)
+	}
+
+	session.m.Lock()
+	defer session.m.Unlock()
+
+	session.unblock = nil
+
+	// a failure is reported to the port layer via the Started key
+	t.startProcess(session)
+
+	// FIXME: we cannot have this embedded knowledge of the extraconfig encoding pattern, but not
+	// currently sure how to expose it neatly via a utility function
+	extraconfig.EncodeWithPrefix(t.sink, session, fmt.Sprintf("guestinfo.vice..sessions|%s", session.ID))
+}
+
+// startProcess starts the session process that launch has prepared. The caller must hold the
+// session lock.
+func (t *tether) startProcess(session *SessionConfig) error {
+	var err error
+
 	pid := 0
 	// Use the mutex to make creating a child and adding the child pid into the
 	// childPidTable appear atomic to the reaper function. Use a anonymous function
@@ -443,6 +547,9 @@ func (t *tether) launch(session *SessionConfig) error {
 	// Set the Started key to "true" - this indicates a successful launch
 	session.Started = "true"
 
+	// start probing the health of the process, if required
+	t.startHealthcheck(session)
+
 	// Write the PID to the associated PID file
 	cmdname := path.Base(session.Cmd.Path)
 	if err = os.MkdirAll(PIDFileDir(), 0755); err != nil {
@@ -522,6 +629,41 @@ func restartableCmd(cmd *exec.Cmd) *exec.Cmd {
 	}
 }
 
+// hasEnv returns true if the environment sets the variable
+func hasEnv(env []string, name string) bool {
+	for _, tuple := range env {
+		if strings.HasPrefix(tuple, name+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeNetworks removes the endpoints of the networks that are no longer in the configuration,
+// as decoding the configuration in place leaves them behind
+func (t *tether) removeNetworks() error {
+	current := struct {
+		Networks map[string]*NetworkEndpoint `vic:"0.1" scope:"read-only" key:"networks"`
+	}{}
+	extraconfig.Decode(t.src, &current)
+
+	for name, endpoint := range t.config.Networks {
+		if _, ok := current.Networks[name]; ok {
+			continue
+		}
+
+		log.Infof("Removing endpoint for network %s", name)
+		if err := t.ops.Remove(endpoint); err != nil {
+			return err
+		}
+
+		delete(t.config.Networks, name)
+	}
+
+	return nil
+}
+
 // Config interface
 func (t *tether) UpdateNetworkEndpoint(e *NetworkEndpoint) error {
 	defer trace.End(trace.Begin("tether.UpdateNetworkEndpoint"))
@@ -119,6 +119,8 @@ func (t *tether) childReaper() error {
 						session.m.Unlock()
 
 						t.handleSessionExit(session)
+					} else if exit, ok := t.removeProbePid(pid); ok {
+						exit <- status.ExitStatus()
 					} else {
 						// This is an adopted zombie. The Wait4 call already clean it up from the kernel
 						log.Warnf("Reaped zombie process PID %d", pid)
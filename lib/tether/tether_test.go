@@ -115,8 +115,8 @@ func (t *Mocker) Log() (io.Writer, error) {
 	return &t.LogBuffer, nil
 }
 
-func (t *Mocker) SessionLog(session *SessionConfig) (dio.DynamicMultiWriter, error) {
-	return dio.MultiWriter(&t.SessionLogBuffer), nil
+func (t *Mocker) SessionLog(session *SessionConfig) (dio.DynamicMultiWriter, dio.DynamicMultiWriter, error) {
+	return dio.MultiWriter(&t.SessionLogBuffer), dio.MultiWriter(&t.SessionLogBuffer), nil
 }
 
 func (t *Mocker) HandleSessionExit(config *ExecutorConfig, session *SessionConfig) func() {
@@ -148,6 +148,11 @@ func (t *Mocker) Apply(endpoint *NetworkEndpoint) error {
 	return apply(t, &t.Base, endpoint)
 }
 
+// Remove tears down the network endpoint configuration
+func (t *Mocker) Remove(endpoint *NetworkEndpoint) error {
+	return remove(t, &t.Base, endpoint)
+}
+
 // MountLabel performs a mount with the source treated as a disk label
 // This assumes that /dev/disk/by-label is being populated, probably by udev
 func (t *Mocker) MountLabel(ctx context.Context, label, target string) error {
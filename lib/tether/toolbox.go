@@ -30,6 +30,9 @@ import (
 	"github.com/vmware/vic/pkg/vsphere/toolbox"
 )
 
+// maxPausePasses bounds the number of times the process table is walked when pausing
+const maxPausePasses = 10
+
 // Toolbox is a tether extension that wraps toolbox.Service
 type Toolbox struct {
 	*toolbox.Service
@@ -40,6 +43,10 @@ type Toolbox struct {
 	}
 
 	stop chan struct{}
+
+	// ReloadHandler is invoked when a configuration reload is requested, e.g. after
+	// a session has been added to a running container
+	ReloadHandler func()
 }
 
 // NewToolbox returns a tether.Extension that wraps the vsphere/toolbox service
@@ -132,6 +139,69 @@ func (t *Toolbox) kill(name string) error {
 	return t.killHelper(session, name)
 }
 
+func (t *Toolbox) reload() error {
+	if t.ReloadHandler == nil {
+		return errors.New("reload is not supported")
+	}
+
+	log.Info("toolbox: reloading configuration")
+	// the handler blocks until the tether picks up the request so don't hold the vix reply
+	go t.ReloadHandler()
+
+	return nil
+}
+
+// pause stops all of the processes in the container with SIGSTOP. Processes forked while
+// the signals are being sent are caught by the passes that follow.
+func (t *Toolbox) pause() error {
+	log.Info("toolbox: pausing container")
+
+	stopped := make(map[int]bool)
+	for pass := 0; pass < maxPausePasses; pass++ {
+		procs, err := listProcesses()
+		if err != nil {
+			return fmt.Errorf("failed to list processes: %s", err)
+		}
+
+		signalled := 0
+		for _, p := range procs {
+			if stopped[p.pid] {
+				continue
+			}
+
+			if err = syscall.Kill(p.pid, syscall.SIGSTOP); err != nil && err != syscall.ESRCH {
+				return fmt.Errorf("failed to stop process %d: %s", p.pid, err)
+			}
+			stopped[p.pid] = true
+			signalled++
+		}
+
+		if signalled == 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("processes were still being created after %d passes", maxPausePasses)
+}
+
+// unpause continues all of the processes in the container with SIGCONT
+func (t *Toolbox) unpause() error {
+	log.Info("toolbox: unpausing container")
+
+	procs, err := listProcesses()
+	if err != nil {
+		return fmt.Errorf("failed to list processes: %s", err)
+	}
+
+	for _, p := range procs {
+		if err = syscall.Kill(p.pid, syscall.SIGCONT); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to continue process %d: %s", p.pid, err)
+		}
+	}
+
+	return nil
+}
+
 func (t *Toolbox) killHelper(session *SessionConfig, name string) error {
 	if name == "" {
 		name = string(ssh.SIGTERM)
@@ -180,6 +250,12 @@ func (t *Toolbox) containerStartCommand(r *toolbox.VixMsgStartProgramRequest) (i
 	switch r.ProgramPath {
 	case "kill":
 		return -1, t.kill(r.Arguments)
+	case "reload":
+		return -1, t.reload()
+	case "pause":
+		return -1, t.pause()
+	case "unpause":
+		return -1, t.unpause()
 	default:
 		return -1, fmt.Errorf("unknown command %q", r.ProgramPath)
 	}
@@ -0,0 +1,233 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive copies tar streams in to and out of a filesystem tree rooted at
+// a given directory. It is used by the tether, where the root is the container
+// filesystem, and by the port layer against mounted container and image disks.
+package archive
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	darchive "github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/mount"
+	"github.com/docker/docker/pkg/symlink"
+
+	"github.com/vmware/vic/pkg/trace"
+)
+
+// ErrExtractPointNotDirectory is returned when the target of an import is not a directory
+var ErrExtractPointNotDirectory = errors.New("extraction point is not a directory")
+
+// PathStat describes a filesystem resource in the container
+type PathStat struct {
+	Name       string
+	Size       int64
+	Mode       os.FileMode
+	Mtime      time.Time
+	LinkTarget string
+}
+
+// absPath returns path as an absolute path in the container. Trailing separators
+// and dots are preserved as they change the semantics of the copy.
+func absPath(path string) string {
+	return darchive.PreserveTrailingDotOrSeparator(filepath.Join(string(filepath.Separator), path), path)
+}
+
+// resolve returns the location of path within root, following symlinks in all
+// but the last element without leaving root.
+func resolve(root, path string) (string, error) {
+	abs := absPath(path)
+
+	dir, base := filepath.Split(abs)
+	resolved, err := symlink.FollowSymlinkInScope(filepath.Join(root, dir), root)
+	if err != nil {
+		return "", err
+	}
+
+	return darchive.PreserveTrailingDotOrSeparator(filepath.Join(resolved, base), abs), nil
+}
+
+// Stat returns the details of the resource at path within root
+func Stat(root, path string) (*PathStat, error) {
+	defer trace.End(trace.Begin(path))
+
+	resolved, err := resolve(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Lstat(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := &PathStat{
+		Name:  filepath.Base(absPath(path)),
+		Size:  fi.Size(),
+		Mode:  fi.Mode(),
+		Mtime: fi.ModTime(),
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if stat.LinkTarget, err = os.Readlink(resolved); err != nil {
+			return nil, err
+		}
+	}
+
+	return stat, nil
+}
+
+// Export returns a tar stream of the resource at path within root. The entries
+// in the archive are rebased onto the base name of the requested path.
+func Export(root, path string) (io.ReadCloser, error) {
+	defer trace.End(trace.Begin(path))
+
+	resolved, err := resolve(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return darchive.TarResourceRebase(resolved, filepath.Base(absPath(path)))
+}
+
+// lostAndFound is created in the root of the container disks by mkfs and is not
+// part of the container filesystem
+const lostAndFound = "lost+found"
+
+// excludePattern returns the pattern that excludes exactly the path, escaping
+// the punctuation that has meaning in patterns
+func excludePattern(path string) string {
+	var pattern []rune
+	for _, r := range path {
+		if r < unicode.MaxASCII && (unicode.IsPunct(r) || unicode.IsSymbol(r)) && r != filepath.Separator {
+			pattern = append(pattern, '\\')
+		}
+		pattern = append(pattern, r)
+	}
+	return string(pattern)
+}
+
+// filesystemExcludes returns the exclude patterns, relative to root, for the
+// mountpoints below root and the lost+found directory of the disk
+func filesystemExcludes(root string, mountpoints []string) []string {
+	root = filepath.Clean(root)
+	excludes := []string{excludePattern(lostAndFound)}
+
+	for _, mountpoint := range mountpoints {
+		rel, err := filepath.Rel(root, filepath.Clean(mountpoint))
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		excludes = append(excludes, excludePattern(rel))
+	}
+
+	return excludes
+}
+
+// ExportFilesystem returns a tar stream of the filesystem mounted at root, with
+// entries relative to root. Filesystems mounted below root, such as volumes and
+// pseudo filesystems, are not included.
+func ExportFilesystem(root string) (io.ReadCloser, error) {
+	defer trace.End(trace.Begin(root))
+
+	mounts, err := mount.GetMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	mountpoints := make([]string, len(mounts))
+	for i := range mounts {
+		mountpoints[i] = mounts[i].Mountpoint
+	}
+
+	return darchive.TarWithOptions(root, &darchive.TarOptions{
+		Compression:     darchive.Uncompressed,
+		ExcludePatterns: filesystemExcludes(root, mountpoints),
+	})
+}
+
+// Import extracts the tar stream into the directory at path within root. If
+// noOverwriteDirNonDir is true it is an error for the archive to replace an
+// existing directory with a non-directory or vice versa.
+func Import(root, path string, noOverwriteDirNonDir bool, r io.Reader) error {
+	defer trace.End(trace.Begin(path))
+
+	resolved, err := resolve(root, path)
+	if err != nil {
+		return err
+	}
+
+	// the extraction point itself may be a symlink to a directory
+	resolved, err = symlink.FollowSymlinkInScope(resolved, root)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return ErrExtractPointNotDirectory
+	}
+
+	opts := &darchive.TarOptions{
+		NoOverwriteDirNonDir: noOverwriteDirNonDir,
+	}
+
+	return darchive.Untar(r, resolved, opts)
+}
+
+// Changes returns the paths that were added, modified or deleted to turn the
+// filesystem tree at oldRoot into the one at newRoot. The lost+found directory
+// of the disks is not part of either filesystem.
+func Changes(newRoot, oldRoot string) ([]darchive.Change, error) {
+	defer trace.End(trace.Begin(newRoot))
+
+	changes, err := darchive.ChangesDirs(newRoot, oldRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := "/" + lostAndFound
+	filtered := changes[:0]
+	for _, change := range changes {
+		if change.Path == excluded || strings.HasPrefix(change.Path, excluded+"/") {
+			continue
+		}
+		filtered = append(filtered, change)
+	}
+
+	return filtered, nil
+}
+
+// Diff returns a tar stream of the changes that turn the filesystem tree at
+// oldRoot into the one at newRoot. Removed files are recorded as whiteouts so
+// the stream can be applied as a layer on top of oldRoot.
+func Diff(newRoot, oldRoot string) (io.ReadCloser, error) {
+	changes, err := Changes(newRoot, oldRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return darchive.ExportChanges(newRoot, changes, nil, nil)
+}
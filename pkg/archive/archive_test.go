@@ -0,0 +1,231 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	darchive "github.com/docker/docker/pkg/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+func setup(t *testing.T) string {
+	root, err := ioutil.TempDir("", "archive-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.MkdirAll(filepath.Join(root, "etc", "conf.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(root, "etc", "conf.d", "app.conf"), []byte("key=value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Symlink("/etc/conf.d", filepath.Join(root, "conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func names(t *testing.T, r io.Reader) []string {
+	var entries []string
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, hdr.Name)
+	}
+
+	return entries
+}
+
+func TestStat(t *testing.T) {
+	root := setup(t)
+	defer os.RemoveAll(root)
+
+	stat, err := Stat(root, "/etc/conf.d/app.conf")
+	assert.NoError(t, err)
+	assert.Equal(t, "app.conf", stat.Name)
+	assert.Equal(t, int64(len("key=value\n")), stat.Size)
+	assert.False(t, stat.Mode.IsDir())
+
+	stat, err = Stat(root, "etc/conf.d")
+	assert.NoError(t, err)
+	assert.Equal(t, "conf.d", stat.Name)
+	assert.True(t, stat.Mode.IsDir())
+
+	// the last element is not followed
+	stat, err = Stat(root, "/conf")
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/conf.d", stat.LinkTarget)
+
+	// symlinks in the parent are resolved within root
+	stat, err = Stat(root, "/conf/app.conf")
+	assert.NoError(t, err)
+	assert.Equal(t, "app.conf", stat.Name)
+
+	_, err = Stat(root, "/missing")
+	assert.True(t, os.IsNotExist(err), "Expected not exist error, got %s", err)
+}
+
+func TestExport(t *testing.T) {
+	root := setup(t)
+	defer os.RemoveAll(root)
+
+	rc, err := Export(root, "/etc/conf.d")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	assert.Equal(t, []string{"conf.d/", "conf.d/app.conf"}, names(t, rc))
+
+	_, err = Export(root, "/missing")
+	assert.Error(t, err)
+}
+
+func TestExportFilesystem(t *testing.T) {
+	root := setup(t)
+	defer os.RemoveAll(root)
+
+	if err := os.Mkdir(filepath.Join(root, "lost+found"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := ExportFilesystem(root)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	assert.Equal(t, []string{"conf", "etc/", "etc/conf.d/", "etc/conf.d/app.conf"}, names(t, rc))
+}
+
+func TestFilesystemExcludes(t *testing.T) {
+	mountpoints := []string{"/", "/proc", "/.tether", "/data/volume", "/mnt", "/mnt/root/sub"}
+
+	assert.Equal(t, []string{`lost\+found`, "proc", `\.tether`, "data/volume", "mnt", "mnt/root/sub"}, filesystemExcludes("/", mountpoints))
+	assert.Equal(t, []string{`lost\+found`, "sub"}, filesystemExcludes("/mnt/root/", mountpoints))
+}
+
+func TestImport(t *testing.T) {
+	root := setup(t)
+	defer os.RemoveAll(root)
+
+	rc, err := Export(root, "/etc/conf.d/app.conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	if err = os.Mkdir(filepath.Join(root, "tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, Import(root, "/tmp", false, rc))
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "tmp", "app.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key=value\n", string(data))
+
+	// extraction through a symlinked directory
+	rc, err = Export(root, "/tmp/app.conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	assert.NoError(t, os.Remove(filepath.Join(root, "etc", "conf.d", "app.conf")))
+	assert.NoError(t, Import(root, "/conf", false, rc))
+
+	_, err = os.Stat(filepath.Join(root, "etc", "conf.d", "app.conf"))
+	assert.NoError(t, err)
+
+	// cannot extract onto a file
+	err = Import(root, "/tmp/app.conf", false, rc)
+	assert.Equal(t, ErrExtractPointNotDirectory, err)
+}
+
+func TestDiff(t *testing.T) {
+	oldRoot := setup(t)
+	defer os.RemoveAll(oldRoot)
+
+	newRoot := setup(t)
+	defer os.RemoveAll(newRoot)
+
+	if err := ioutil.WriteFile(filepath.Join(newRoot, "etc", "hostname"), []byte("vic\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(newRoot, "conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := Diff(newRoot, oldRoot)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rc.Close()
+
+	entries := names(t, rc)
+	assert.Contains(t, entries, "etc/hostname")
+	assert.Contains(t, entries, ".wh.conf")
+}
+
+func TestChanges(t *testing.T) {
+	oldRoot := setup(t)
+	defer os.RemoveAll(oldRoot)
+
+	newRoot := setup(t)
+	defer os.RemoveAll(newRoot)
+
+	if err := os.Remove(filepath.Join(newRoot, "conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"tmp", lostAndFound} {
+		if err := os.Mkdir(filepath.Join(newRoot, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := Changes(newRoot, oldRoot)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	kinds := make(map[string]int)
+	for _, change := range changes {
+		kinds[change.Path] = int(change.Kind)
+	}
+
+	assert.Equal(t, darchive.ChangeDelete, kinds["/conf"])
+	assert.Equal(t, darchive.ChangeAdd, kinds["/tmp"])
+	assert.NotContains(t, kinds, "/"+lostAndFound)
+}
@@ -53,10 +53,16 @@ type Fetcher interface {
 
 	Head(url *url.URL) (http.Header, error)
 
+	Post(ctx context.Context, url *url.URL, body io.Reader, reqHdrs http.Header) (http.Header, error)
+	Put(ctx context.Context, url *url.URL, body io.Reader, reqHdrs http.Header) (http.Header, error)
+	Patch(ctx context.Context, url *url.URL, body io.Reader, reqHdrs http.Header) (http.Header, error)
+
 	ExtractOAuthURL(hdr string, repository *url.URL) (*url.URL, error)
 
 	IsStatusUnauthorized() bool
 	IsStatusOK() bool
+	IsStatusCreated() bool
+	IsStatusAccepted() bool
 	IsStatusNotFound() bool
 
 	AuthURL() *url.URL
@@ -348,7 +354,16 @@ func (u *URLFetcher) Head(url *url.URL) (http.Header, error) {
 }
 
 func (u *URLFetcher) head(ctx context.Context, url *url.URL) (http.Header, error) {
-	res, err := ctxhttp.Head(ctx, u.client, url.String())
+	req, err := http.NewRequest("HEAD", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	u.setBasicAuth(req)
+
+	u.setAuthToken(req)
+
+	res, err := ctxhttp.Do(ctx, u.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -362,6 +377,76 @@ func (u *URLFetcher) head(ctx context.Context, url *url.URL) (http.Header, error
 	return nil, fmt.Errorf("Unexpected http code: %d, URL: %s", u.StatusCode, url)
 }
 
+// Post sends a POST request with the body to url and returns the response headers
+func (u *URLFetcher) Post(ctx context.Context, url *url.URL, body io.Reader, reqHdrs http.Header) (http.Header, error) {
+	defer trace.End(trace.Begin(url.String()))
+
+	return u.upload(ctx, "POST", url, body, reqHdrs)
+}
+
+// Put sends a PUT request with the body to url and returns the response headers
+func (u *URLFetcher) Put(ctx context.Context, url *url.URL, body io.Reader, reqHdrs http.Header) (http.Header, error) {
+	defer trace.End(trace.Begin(url.String()))
+
+	return u.upload(ctx, "PUT", url, body, reqHdrs)
+}
+
+// Patch sends a PATCH request with the body to url and returns the response headers
+func (u *URLFetcher) Patch(ctx context.Context, url *url.URL, body io.Reader, reqHdrs http.Header) (http.Header, error) {
+	defer trace.End(trace.Begin(url.String()))
+
+	return u.upload(ctx, "PATCH", url, body, reqHdrs)
+}
+
+// upload sends the body to url using the given method. Uploads are not retried
+// as the body cannot be replayed.
+func (u *URLFetcher) upload(ctx context.Context, method string, url *url.URL, body io.Reader, reqHdrs http.Header) (http.Header, error) {
+	req, err := http.NewRequest(method, url.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range reqHdrs {
+		// the transport only sends the length it is told about in the request
+		if http.CanonicalHeaderKey(k) == "Content-Length" && len(v) > 0 {
+			if req.ContentLength, err = strconv.ParseInt(v[0], 10, 64); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		req.Header[k] = v
+	}
+
+	u.setBasicAuth(req)
+
+	u.setAuthToken(req)
+
+	res, err := ctxhttp.Do(ctx, u.client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	u.StatusCode = res.StatusCode
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent:
+		return res.Header, nil
+	}
+
+	if u.IsStatusUnauthorized() {
+		hdr := res.Header.Get("www-authenticate")
+		if strings.Contains(hdr, "error=\"insufficient_scope\"") || strings.Contains(hdr, "error=\"invalid_token\"") {
+			return nil, DoNotRetry{Err: fmt.Errorf("not authorized")}
+		}
+		return nil, DoNotRetry{Err: fmt.Errorf("Authentication required")}
+	}
+
+	// the registry describes the failure in the body
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+	return nil, fmt.Errorf("Unexpected http code: %d, URL: %s, %s", u.StatusCode, url, strings.TrimSpace(string(msg)))
+}
+
 // AuthURL returns the Oauth endpoint URL
 func (u *URLFetcher) AuthURL() *url.URL {
 	return u.OAuthEndpoint
@@ -377,6 +462,16 @@ func (u *URLFetcher) IsStatusOK() bool {
 	return u.StatusCode == http.StatusOK
 }
 
+// IsStatusCreated returns true if status code is StatusCreated
+func (u *URLFetcher) IsStatusCreated() bool {
+	return u.StatusCode == http.StatusCreated
+}
+
+// IsStatusAccepted returns true if status code is StatusAccepted
+func (u *URLFetcher) IsStatusAccepted() bool {
+	return u.StatusCode == http.StatusAccepted
+}
+
 // IsStatusNotFound returns true if status code is StatusNotFound
 func (u *URLFetcher) IsStatusNotFound() bool {
 	return u.StatusCode == http.StatusNotFound
@@ -0,0 +1,234 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iolog
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+)
+
+var epoch = time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// writeLog writes the lines to a log, each a second after the previous one
+func writeLog(t *testing.T, lines ...string) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	stdout, stderr := NewWriters(buf)
+
+	clock := epoch
+	stdout.(*streamWriter).f.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	for i, line := range lines {
+		w := stdout
+		if i%2 == 1 {
+			w = stderr
+		}
+
+		n, err := w.Write([]byte(line))
+		assert.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+
+	return buf
+}
+
+// readLog returns what is read from the log with the options, split by stream
+func readLog(t *testing.T, log *bytes.Buffer, opts Options) (string, string) {
+	var stdout, stderr bytes.Buffer
+
+	r := NewReader(ioutil.NopCloser(bytes.NewReader(log.Bytes())), -1, opts)
+	defer r.Close()
+
+	_, err := stdcopy.StdCopy(&stdout, &stderr, r)
+	assert.NoError(t, err)
+
+	return stdout.String(), stderr.String()
+}
+
+func TestDecode(t *testing.T) {
+	log := writeLog(t, "one\ntwo\nthree", "four\n")
+	size := int64(log.Len())
+	d := NewDecoder(log)
+
+	var entries []*Entry
+	for {
+		e, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	// a frame for each line, with the partial line kept as it was written
+	if assert.Equal(t, 4, len(entries)) {
+		assert.Equal(t, "two\n", string(entries[1].Data))
+		assert.Equal(t, "three", string(entries[2].Data))
+		assert.Equal(t, Stdout, entries[2].Stream)
+		assert.Equal(t, Stderr, entries[3].Stream)
+		assert.Equal(t, epoch.Add(2*time.Second), entries[3].Time)
+	}
+	assert.Equal(t, size, d.Offset())
+
+	// a frame that is cut short ends the log
+	cut := writeLog(t, "one\n", "two\n")
+	cut.Truncate(cut.Len() - 1)
+	d = NewDecoder(cut)
+
+	_, err := d.Decode()
+	assert.NoError(t, err)
+	_, err = d.Decode()
+	assert.Equal(t, io.EOF, err)
+
+	// as does anything that is not a frame, when there is no marker to resume from
+	_, err = NewDecoder(bytes.NewBufferString("raw output that is not framed")).Decode()
+	assert.Equal(t, io.EOF, err)
+
+	// a marker of a later version of the format is refused
+	newer := append(append([]byte{}, magic...), Version+1)
+	_, err = NewDecoder(bytes.NewReader(newer)).Decode()
+	assert.Error(t, err)
+}
+
+func TestDecodeRestart(t *testing.T) {
+	for cut := 1; cut < headerLen+len("two\n"); cut++ {
+		// the writers restart after the last frame was cut short, at any point
+		log := writeLog(t, "one\n", "two\n")
+		log.Truncate(log.Len() - cut)
+		log.Write(writeLog(t, "three\n", "four\n").Bytes())
+		size := int64(log.Len())
+
+		d := NewDecoder(log)
+
+		var data []string
+		for {
+			e, err := d.Decode()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			data = append(data, string(e.Data))
+		}
+
+		// the data written before the cut is kept if the header of its frame was not cut
+		expected := []string{"one\n", "three\n", "four\n"}
+		if cut <= len("two\n") {
+			expected = []string{"one\n", "two\n"[:len("two\n")-cut], "three\n", "four\n"}
+		}
+		assert.Equal(t, expected, data, "cut %d", cut)
+		assert.Equal(t, size, d.Offset(), "cut %d", cut)
+	}
+}
+
+func TestReader(t *testing.T) {
+	log := writeLog(t, "out 1\n", "err 2\n", "out 3\n", "err 4\n", "out 5\n")
+
+	stdout, stderr := readLog(t, log, Options{Tail: -1})
+	assert.Equal(t, "out 1\nout 3\nout 5\n", stdout)
+	assert.Equal(t, "err 2\nerr 4\n", stderr)
+
+	stdout, stderr = readLog(t, log, Options{Tail: 2})
+	assert.Equal(t, "out 5\n", stdout)
+	assert.Equal(t, "err 4\n", stderr)
+
+	stdout, stderr = readLog(t, log, Options{Tail: 0})
+	assert.Empty(t, stdout)
+	assert.Empty(t, stderr)
+
+	// the lines were written a second apart, starting a second after the epoch
+	stdout, stderr = readLog(t, log, Options{Tail: -1, Since: epoch.Add(2 * time.Second), Until: epoch.Add(4 * time.Second)})
+	assert.Equal(t, "out 3\n", stdout)
+	assert.Equal(t, "err 2\nerr 4\n", stderr)
+
+	stdout, _ = readLog(t, log, Options{Tail: 1, Until: epoch.Add(3 * time.Second)})
+	assert.Equal(t, "out 3\n", stdout)
+
+	stdout, _ = readLog(t, log, Options{Tail: -1, Since: epoch.Add(5 * time.Second), Timestamps: true})
+	assert.Equal(t, "2016-10-01T12:00:05.000000000Z out 5\n", stdout)
+}
+
+func TestReaderRaw(t *testing.T) {
+	// a log written before the framed format is read as standard output
+	log := bytes.NewBufferString("out 1\nout 2\nout 3")
+
+	stdout, stderr := readLog(t, log, Options{Tail: -1, Since: epoch, Timestamps: true})
+	assert.Equal(t, "out 1\nout 2\nout 3", stdout)
+	assert.Empty(t, stderr)
+
+	stdout, _ = readLog(t, log, Options{Tail: 2})
+	assert.Equal(t, "out 2\nout 3", stdout)
+
+	stdout, _ = readLog(t, &bytes.Buffer{}, Options{Tail: -1})
+	assert.Empty(t, stdout)
+}
+
+func TestReaderFollow(t *testing.T) {
+	log := writeLog(t, "out 1\n", "err 2\n", "out 3\n")
+	size := int64(log.Len())
+
+	// the lines written after the log was opened are not part of the tail
+	more := writeLog(t, "out 1\n", "err 2\n", "out 3\n", "err 4\n")
+	r := NewReader(ioutil.NopCloser(bytes.NewReader(more.Bytes())), size, Options{Tail: 1})
+
+	var stdout, stderr bytes.Buffer
+	_, err := stdcopy.StdCopy(&stdout, &stderr, r)
+	assert.NoError(t, err)
+	assert.Equal(t, "out 3\n", stdout.String())
+	assert.Equal(t, "err 4\n", stderr.String())
+}
+
+func TestWritersMarker(t *testing.T) {
+	// the marker is written once, ahead of the first frame
+	log := writeLog(t, "out 1\n", "err 2\n")
+	assert.True(t, bytes.HasPrefix(log.Bytes(), marker()))
+	assert.Equal(t, 1, bytes.Count(log.Bytes(), magic))
+
+	assert.Empty(t, writeLog(t).Bytes())
+}
+
+func TestWritersClose(t *testing.T) {
+	pr, pw := io.Pipe()
+	stdout, stderr := NewWriters(pw)
+
+	assert.NoError(t, stdout.Close())
+	assert.NoError(t, stdout.Close())
+
+	// the log stays open until both writers are closed
+	go stderr.Write([]byte("still open\n"))
+	buf := make([]byte, headerLen)
+	_, err := io.ReadFull(pr, buf)
+	assert.NoError(t, err)
+	pr.Close()
+
+	pr, pw = io.Pipe()
+	stdout, stderr = NewWriters(pw)
+	stdout.Close()
+	stderr.Close()
+
+	_, err = pr.Read(buf)
+	assert.Equal(t, io.EOF, err)
+}
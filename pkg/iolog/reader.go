@@ -0,0 +1,353 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iolog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonlog"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Entry is a frame of the log
+type Entry struct {
+	Stream byte
+	Time   time.Time
+	Data   []byte
+}
+
+// Decoder reads the entries of a framed log
+type Decoder struct {
+	r *bufio.Reader
+	// unread holds bytes read ahead that are read again before the rest of the log
+	unread []byte
+	offset int64
+}
+
+// NewDecoder returns a decoder of the log read from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// read fills p from the log, returning io.EOF and keeping what it read if the log ends first
+func (d *Decoder) read(p []byte) error {
+	n := copy(p, d.unread)
+	d.unread = d.unread[n:]
+
+	m, err := io.ReadFull(d.r, p[n:])
+	if err != nil {
+		d.unreadBytes(p[:n+m])
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return err
+	}
+	return nil
+}
+
+// unreadBytes returns p to the log, to be read again
+func (d *Decoder) unreadBytes(p []byte) {
+	d.unread = append(append([]byte{}, p...), d.unread...)
+}
+
+// skip drops the first byte of what was read as p and moves on to the next marker of the
+// format, as what starts there is not a frame
+func (d *Decoder) skip(p []byte) error {
+	d.unreadBytes(p[1:])
+	d.offset++
+
+	var b [1]byte
+	for {
+		if err := d.read(b[:]); err != nil {
+			return err
+		}
+
+		if b[0] == magic[0] {
+			d.unreadBytes(b[:])
+			return nil
+		}
+		d.offset++
+	}
+}
+
+// magicPrefix returns the length of the longest start of the magic that ends data
+func magicPrefix(data []byte) int {
+	for k := len(magic) - 1; k > 0; k-- {
+		if bytes.HasSuffix(data, magic[:k]) {
+			return k
+		}
+	}
+	return 0
+}
+
+// Decode returns the next entry of the log, or io.EOF at its end. A frame that is cut short
+// is the one being written as the log is read, so it also ends the log. Anything else that
+// is not a frame, such as the rest of a frame cut short by a restart of the writers, is
+// skipped up to the marker they wrote when they started again, and ends the log if there
+// is no such marker.
+func (d *Decoder) Decode() (*Entry, error) {
+	for {
+		var first [1]byte
+		if err := d.read(first[:]); err != nil {
+			return nil, err
+		}
+
+		if first[0] == magic[0] {
+			m := make([]byte, len(magic)+1)
+			m[0] = first[0]
+			if err := d.read(m[1:]); err != nil {
+				return nil, err
+			}
+
+			if !bytes.Equal(m[:len(magic)], magic) {
+				if err := d.skip(m); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			if v := m[len(magic)]; v > Version {
+				return nil, fmt.Errorf("unsupported log format version %d at offset %d", v, d.offset)
+			}
+
+			d.offset += int64(len(m))
+			continue
+		}
+
+		frame := make([]byte, headerLen)
+		frame[0] = first[0]
+		if err := d.read(frame[1:]); err != nil {
+			return nil, err
+		}
+
+		n := binary.BigEndian.Uint32(frame[9:headerLen])
+		if (frame[0] != Stdout && frame[0] != Stderr) || n > maxFrameLen {
+			if err := d.skip(frame); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		frame = append(frame, make([]byte, n)...)
+		if err := d.read(frame[headerLen:]); err != nil {
+			// the log ends within the frame, unless the writers restarted before its end
+			if err == io.EOF && bytes.Contains(append(frame[1:headerLen:headerLen], d.unread...), magic) {
+				if err = d.skip(frame[:headerLen]); err == nil {
+					continue
+				}
+			}
+			return nil, err
+		}
+
+		// a frame cut short by a restart of the writers ends where their marker starts, and
+		// the marker may run on past the length given in the header
+		var ahead int
+		if k := magicPrefix(frame[1:]); k > 0 {
+			more := make([]byte, len(magic)-k)
+			if err := d.read(more); err == nil {
+				frame = append(frame, more...)
+				ahead = len(more)
+			}
+		}
+
+		if i := bytes.Index(frame[1:], magic); i >= 0 {
+			i++
+			if i < headerLen {
+				if err := d.skip(frame); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			d.unreadBytes(frame[i:])
+			frame = frame[:i]
+		} else if ahead > 0 {
+			d.unreadBytes(frame[len(frame)-ahead:])
+			frame = frame[:len(frame)-ahead]
+		}
+
+		d.offset += int64(len(frame))
+		return &Entry{
+			Stream: frame[0],
+			Time:   time.Unix(0, int64(binary.BigEndian.Uint64(frame[1:9]))).UTC(),
+			Data:   frame[headerLen:],
+		}, nil
+	}
+}
+
+// Offset returns the number of bytes of the log that have been decoded
+func (d *Decoder) Offset() int64 {
+	return d.offset
+}
+
+// rawDecoder reads a log written before the framed format, as lines of standard output
+// at an unknown time
+type rawDecoder struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (d *rawDecoder) Decode() (*Entry, error) {
+	line, err := d.r.ReadBytes('\n')
+	if len(line) == 0 {
+		return nil, err
+	}
+
+	d.offset += int64(len(line))
+	return &Entry{Stream: Stdout, Data: line}, nil
+}
+
+func (d *rawDecoder) Offset() int64 {
+	return d.offset
+}
+
+type decoder interface {
+	Decode() (*Entry, error)
+	Offset() int64
+}
+
+// newDecoder returns the decoder of the log read from r, which is read as plain output
+// if it does not start with the marker of the framed format
+func newDecoder(r io.Reader) decoder {
+	br := bufio.NewReader(r)
+
+	if head, _ := br.Peek(len(magic)); !bytes.Equal(head, magic) {
+		return &rawDecoder{r: br}
+	}
+	return NewDecoder(br)
+}
+
+// Options selects the entries of the log that are read and how they are presented
+type Options struct {
+	// Tail is the number of entries to read from the end of the log, or all of them if negative
+	Tail int
+	// Since and Until bound the time of the entries that are read, if they are not zero.
+	// The entries of a log written as plain output have no time and are not bounded.
+	Since time.Time
+	Until time.Time
+	// Timestamps prefixes the data of each entry with its time
+	Timestamps bool
+}
+
+type reader struct {
+	*io.PipeReader
+
+	log io.Closer
+}
+
+// NewReader returns a reader of the entries of the framed log read from r that match the
+// options, as a docker multiplexed stream that keeps standard output and standard error
+// apart. A log that does not start with the marker of the framed format is read as the
+// standard output of the process. The tail is taken from the first size bytes of the log, or all of it if size is
+// negative, and any entries read after those are passed on as they arrive, which allows
+// a log that is being followed to be tailed.
+func NewReader(r io.ReadCloser, size int64, opts Options) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(copyEntries(pw, r, size, opts))
+	}()
+
+	return &reader{PipeReader: pr, log: r}
+}
+
+func (r *reader) Close() error {
+	r.PipeReader.Close()
+	return r.log.Close()
+}
+
+// early returns whether the entry is from before since, if the times of both are known
+func early(e *Entry, since time.Time) bool {
+	return !since.IsZero() && !e.Time.IsZero() && e.Time.Before(since)
+}
+
+// late returns whether the entry is from after until, if the times of both are known
+func late(e *Entry, until time.Time) bool {
+	return !until.IsZero() && !e.Time.IsZero() && e.Time.After(until)
+}
+
+func copyEntries(w io.Writer, r io.Reader, size int64, opts Options) error {
+	d := newDecoder(r)
+
+	writers := map[byte]io.Writer{
+		Stdout: stdcopy.NewStdWriter(w, stdcopy.Stdout),
+		Stderr: stdcopy.NewStdWriter(w, stdcopy.Stderr),
+	}
+
+	write := func(e *Entry) error {
+		data := e.Data
+		if opts.Timestamps && !e.Time.IsZero() {
+			data = append([]byte(e.Time.Format(jsonlog.RFC3339NanoFixed)+" "), data...)
+		}
+
+		_, err := writers[e.Stream].Write(data)
+		return err
+	}
+
+	// the tail is held back until the end of the log as it was when it was opened
+	var tail []*Entry
+	for opts.Tail >= 0 && (size < 0 || d.Offset() < size) {
+		e, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if early(e, opts.Since) || late(e, opts.Until) {
+			continue
+		}
+
+		tail = append(tail, e)
+		if len(tail) > opts.Tail {
+			tail = tail[1:]
+		}
+	}
+
+	for _, e := range tail {
+		if err := write(e); err != nil {
+			return err
+		}
+	}
+
+	for {
+		e, err := d.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if early(e, opts.Since) {
+			continue
+		}
+
+		// entries are in time order, so a followed log ends at the first one that is too late
+		if late(e, opts.Until) {
+			return nil
+		}
+
+		if err = write(e); err != nil {
+			return err
+		}
+	}
+}
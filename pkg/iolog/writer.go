@@ -0,0 +1,158 @@
+// Copyright 2016 VMware, Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iolog implements the framed format of container logs. Each frame records the
+// stream and the time of a line of output, so that a log written as raw bytes through a
+// serial port can still be filtered by stream and by time when it is read back.
+//
+// The frames written by each set of writers are preceded by a marker naming the format
+// and its version, which tells a framed log apart from one written as plain output, and
+// which lets a reader find its place again after a frame cut short by a restart.
+package iolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// Stdout is the stream of standard output, as numbered by docker stdcopy
+	Stdout byte = 1
+	// Stderr is the stream of standard error, as numbered by docker stdcopy
+	Stderr byte = 2
+
+	// headerLen is the length of the frame header - the stream, the time in unix
+	// nanoseconds and the length of the data, in network byte order
+	headerLen = 1 + 8 + 4
+
+	// maxFrameLen bounds the data of a frame so that a corrupt header is detected
+	maxFrameLen = 1 << 20
+
+	// Version is the version of the framed format written by this package
+	Version byte = 1
+)
+
+// magic starts the marker of the format, which is followed by the version. Its first
+// byte is not a stream so the marker cannot be mistaken for the header of a frame.
+var magic = []byte("\x00vic-iolog")
+
+// marker returns the marker written ahead of the frames
+func marker() []byte {
+	return append(append([]byte{}, magic...), Version)
+}
+
+// framer writes the frames of both streams to the log
+type framer struct {
+	mutex sync.Mutex
+
+	w      io.Writer
+	open   int
+	now    func() time.Time
+	marked bool
+}
+
+type streamWriter struct {
+	f      *framer
+	stream byte
+	closed bool
+}
+
+// NewWriters returns the writers for standard output and standard error of a process,
+// which write the data given to them to w as frames, one for each line, after the marker
+// of the format. The writers are safe for concurrent use and w is closed, if it is a Closer, once both are closed.
+func NewWriters(w io.Writer) (stdout io.WriteCloser, stderr io.WriteCloser) {
+	f := &framer{
+		w:    w,
+		open: 2,
+		now:  time.Now,
+	}
+
+	return &streamWriter{f: f, stream: Stdout}, &streamWriter{f: f, stream: Stderr}
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	return s.f.write(s.stream, p)
+}
+
+func (s *streamWriter) Close() error {
+	return s.f.close(s)
+}
+
+// appendFrame appends the frame holding the data to buf
+func appendFrame(buf *bytes.Buffer, stream byte, t int64, data []byte) {
+	var header [headerLen]byte
+
+	header[0] = stream
+	binary.BigEndian.PutUint64(header[1:9], uint64(t))
+	binary.BigEndian.PutUint32(header[9:], uint32(len(data)))
+
+	buf.Write(header[:])
+	buf.Write(data)
+}
+
+func (f *framer) write(stream byte, p []byte) (int, error) {
+	t := f.now().UTC().UnixNano()
+
+	// the frames are written in one go so those of the other stream cannot interleave
+	buf := &bytes.Buffer{}
+	for data := p; len(data) > 0; {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		if n > maxFrameLen {
+			n = maxFrameLen
+		}
+
+		appendFrame(buf, stream, t, data[:n])
+		data = data[n:]
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// the marker goes out with the first frames so a log that is never written stays empty
+	out := buf.Bytes()
+	if !f.marked {
+		out = append(marker(), out...)
+	}
+
+	if _, err := f.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	f.marked = true
+	return len(p), nil
+}
+
+func (f *framer) close(s *streamWriter) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	f.open--
+
+	if c, ok := f.w.(io.Closer); ok && f.open == 0 {
+		return c.Close()
+	}
+
+	return nil
+}